@@ -1,4 +1,5 @@
 import { getContract } from "../fabric/fabricConnect.js";
+import { sendChaincodeError } from "../fabric/chaincodeError.js";
 
 /**
  * Module allows you to invoke audit-teail chaincode to do CRUD on AUDITS on the ledger
@@ -22,11 +23,7 @@ export async function initLedger(req, res) {
     });
   } catch (error) {
     console.error("[AuditController]: ERROR initializing ledger:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to initialize ledger",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to initialize ledger");
   }
 }
 
@@ -93,11 +90,7 @@ export async function logAudit(req, res) {
     });
   } catch (error) {
     console.error("[AuditController]: ERROR logging audit:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to log audit",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to log audit");
   }
 }
 
@@ -124,11 +117,7 @@ export async function auditExists(req, res) {
     });
   } catch (error) {
     console.error("[AuditController]: Error checking audit existence:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to check audit existence",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to check audit existence");
   }
 }
 
@@ -154,11 +143,7 @@ export async function getAudit(req, res) {
     });
   } catch (error) {
     console.error("[AuditController]: Error getting audit:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to get audit",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to get audit");
   }
 }
 
@@ -183,10 +168,6 @@ export async function getAllAudits(req, res) {
     });
   } catch (error) {
     console.error("[AuditController]: Error getting all audits:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to get audits",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to get audits");
   }
 }
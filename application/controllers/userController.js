@@ -1,4 +1,5 @@
 import { getContract } from "../fabric/fabricConnect.js";
+import { sendChaincodeError } from "../fabric/chaincodeError.js";
 /**
  * Tis module allows you to invoke audit-trail chaincode to do CRUD on USERS on the ledger
  * - Check chaincode-go/audit-chaincode/chaincode/users.go to understand the chaincode
@@ -42,11 +43,7 @@ export async function registerUser(req, res) {
     });
   } catch (error) {
     console.error("[UserControler]: Error registering user:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to register user",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to register user");
   }
 }
 
@@ -73,11 +70,7 @@ export async function getUser(req, res) {
     });
   } catch (error) {
     console.error("[UserControler]: Error getting user:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to get user",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to get user");
   }
 }
 
@@ -110,27 +103,31 @@ export async function updateUserRole(req, res) {
     });
   } catch (error) {
     console.error("[UserControler]: Error updating user role:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to update user role",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to update user role");
   }
 }
 
 /**
  * Deactivate user (soft delete)
- * DELETE /users/:id
+ * DELETE /users/:id   body: { reason }
  *
- * Calls chaincode function: DeactivateUser(id string)
+ * Calls chaincode function: DeactivateUser(id string, reason string)
  * - This does a soft deelte btw
  */
 export async function deactivateUser(req, res) {
   try {
     const { id } = req.params;
+    const reason = (req.body && req.body.reason) || req.query.reason;
+
+    if (!reason) {
+      return res.status(400).json({
+        success: false,
+        error: "Missing required field: reason",
+      });
+    }
 
     const contract = await getContract();
-    await contract.submitTransaction("DeactivateUser", id);
+    await contract.submitTransaction("DeactivateUser", id, reason);
 
     res.json({
       success: true,
@@ -139,11 +136,7 @@ export async function deactivateUser(req, res) {
     });
   } catch (error) {
     console.error("[UserControler]: Error deactivating user:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to deactivate user",
-      details: error.message,
-    });
+    sendChaincodeError(res, error, "Failed to deactivate user");
   }
 }
 
@@ -170,10 +163,46 @@ export async function userExists(req, res) {
     });
   } catch (error) {
     console.error("[UserControler]: Error checking user existence:", error);
-    res.status(500).json({
-      success: false,
-      error: "Failed to check user existence",
-      details: error.message,
+    sendChaincodeError(res, error, "Failed to check user existence");
+  }
+}
+
+/**
+ * List users (paginated)
+ * GET /users?role=ADMIN&organization=Org1MSP&active=true&createdBy=user-alice&pageSize=20&bookmark=...
+ *
+ * Calls chaincode function: ListUsers(filterJSON string, pageSize int32, bookmark string) returns *UserQueryResult
+ */
+export async function listUsers(req, res) {
+  try {
+    const { role, organization, active, createdBy, bookmark } = req.query;
+    const pageSize = req.query.pageSize || "20";
+
+    // Only send the filters that were actually set
+    const filter = {};
+    if (role) filter.role = role;
+    if (organization) filter.organization = organization;
+    if (active !== undefined) filter.active = active === "true";
+    if (createdBy) filter.createdBy = createdBy;
+
+    const contract = await getContract();
+    const result = await contract.evaluateTransaction(
+      "ListUsers",
+      JSON.stringify(filter),
+      String(pageSize),
+      bookmark || ""
+    );
+
+    const page = JSON.parse(result.toString());
+
+    res.json({
+      success: true,
+      data: page.records,
+      count: page.fetchedRecordsCount,
+      bookmark: page.bookmark,
     });
+  } catch (error) {
+    console.error("[UserControler]: Error listing users:", error);
+    sendChaincodeError(res, error, "Failed to list users");
   }
 }
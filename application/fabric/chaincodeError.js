@@ -0,0 +1,95 @@
+/**
+ * Module turns chaincode errors into HTTP responses
+ *  - The chaincode returns every error as a JSON envelope (chaincode-go/audit-chaincode/chaincode/errors.go):
+ *      {"code":"NOT_FOUND","message":"...","details":[{"field":"id","description":"..."}],"function":"...","txId":"..."}
+ *  - fabric-network wraps it in error.message (evaluate) or in error.responses (submit)
+ *  - Branch on code, never on the message text
+ */
+
+// Chaincode error code -> HTTP status
+const HTTP_STATUS = {
+  NOT_FOUND: 404,
+  ALREADY_EXISTS: 409,
+  INVALID_ARGUMENT: 400,
+  PERMISSION_DENIED: 403,
+  FAILED_PRECONDITION: 409,
+  INTERNAL: 500,
+};
+
+/**
+ * Finds the chaincode error envelope inside a fabric-network error
+ * Returns the parsed envelope, or null when the error did not come from the chaincode
+ */
+export function parseChaincodeError(error) {
+  const texts = [error?.message];
+  for (const response of error?.responses || []) {
+    texts.push(response?.response?.message);
+  }
+  for (const inner of error?.errors || []) {
+    texts.push(inner?.message);
+  }
+
+  for (const text of texts) {
+    const envelope = extractEnvelope(text);
+    if (envelope) {
+      return envelope;
+    }
+  }
+  return null;
+}
+
+// Cuts the first {"code":...} object out of text (peers prefix it with their own words)
+function extractEnvelope(text) {
+  if (typeof text !== "string") {
+    return null;
+  }
+  const start = text.indexOf('{"code":"');
+  if (start === -1) {
+    return null;
+  }
+
+  let depth = 0;
+  let inString = false;
+  for (let i = start; i < text.length; i++) {
+    const char = text[i];
+    if (inString) {
+      if (char === "\\") {
+        i++;
+      } else if (char === '"') {
+        inString = false;
+      }
+    } else if (char === '"') {
+      inString = true;
+    } else if (char === "{") {
+      depth++;
+    } else if (char === "}") {
+      depth--;
+      if (depth === 0) {
+        try {
+          return JSON.parse(text.slice(start, i + 1));
+        } catch {
+          return null;
+        }
+      }
+    }
+  }
+  return null;
+}
+
+/**
+ * Sends a failed chaincode call as JSON with the status matching its code
+ * Body: { success, error, code, details, fieldErrors, txId }
+ */
+export function sendChaincodeError(res, error, summary) {
+  const chaincodeError = parseChaincodeError(error);
+  const code = chaincodeError?.code || "INTERNAL";
+
+  res.status(HTTP_STATUS[code] || 500).json({
+    success: false,
+    error: summary,
+    code,
+    details: chaincodeError?.message || error.message,
+    fieldErrors: chaincodeError?.details || [],
+    txId: chaincodeError?.txId,
+  });
+}
@@ -6,10 +6,14 @@ import {
   updateUserRole,
   deactivateUser,
   userExists,
+  listUsers,
 } from "../controllers/userController.js";
 
 const router = express.Router();
 
+// List users (filters + pagination via query params)
+router.get("/", listUsers);
+
 // Registr new user
 router.post("/register", registerUser);
 
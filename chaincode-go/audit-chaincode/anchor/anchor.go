@@ -0,0 +1,150 @@
+// Package anchor is the off-chain half of trusted timestamp anchoring: it asks an RFC 3161
+// Time Stamping Authority to timestamp checkpoints and high-risk audit entries, then stores
+// the tokens on the ledger, where chaincode/anchor.go verifies them.
+package anchor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ AnchorCheckpoints walks back from the latest checkpoint until it meets an anchored one,
+ then anchors the unanchored ones oldest first. A run that fails half way is simply resumed
+ by the next run, and a checkpoint another service instance anchored first is skipped.
+
+ The skip (ALREADY_EXISTS) and "no checkpoints yet" (NOT_FOUND) cases are told apart by the
+ code of the chaincode's error envelope (chaincode.HasErrorCode), never by its message.
+
+ AnchorAudit anchors one entry, for entries that should not wait for the next checkpoint.
+
+ The digests are computed with the chaincode's own helpers (CheckpointDigest, AuditEntryDigest),
+ so the token always covers exactly what the chaincode re-verifies.
+*/
+
+// Ledger is the part of gateway.Client the service uses
+type Ledger interface {
+	Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error)
+	Submit(ctx context.Context, fn string, args ...string) ([]byte, error)
+}
+
+// Timestamper is the part of tsa.Client the service uses
+type Timestamper interface {
+	Timestamp(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// Service anchors ledger data with one TSA
+type Service struct {
+	Ledger Ledger
+	TSA    Timestamper
+}
+
+// AnchorCheckpoints anchors every checkpoint newer than the last anchored one and returns their IDs
+func (s *Service) AnchorCheckpoints(ctx context.Context) ([]string, error) {
+	latest, err := s.latestCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back to the newest anchored checkpoint (or the first one)
+	var pending []*chaincode.Checkpoint
+	for checkpoint := latest; checkpoint != nil && checkpoint.AnchoredAt == 0; {
+		pending = append(pending, checkpoint)
+		if checkpoint.PrevID == "" {
+			break
+		}
+		checkpoint, err = s.checkpoint(ctx, checkpoint.PrevID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	anchored := []string{}
+	for i := len(pending) - 1; i >= 0; i-- {
+		checkpoint := pending[i]
+		token, err := s.TSA.Timestamp(ctx, chaincode.CheckpointDigest(checkpoint))
+		if err != nil {
+			return anchored, fmt.Errorf("failed to timestamp checkpoint %s: %v", checkpoint.ID, err)
+		}
+		_, err = s.Ledger.Submit(ctx, "CheckpointContract:AnchorCheckpoint", checkpoint.ID, base64.StdEncoding.EncodeToString(token))
+		if chaincode.HasErrorCode(err, chaincode.CodeAlreadyExists) {
+			log.Printf("[AnchorCheckpoints] SKIP checkpointId=%s already anchored", checkpoint.ID)
+			continue
+		}
+		if err != nil {
+			return anchored, fmt.Errorf("failed to anchor checkpoint %s: %v", checkpoint.ID, err)
+		}
+		log.Printf("[AnchorCheckpoints] SUCCESS checkpointId=%s", checkpoint.ID)
+		anchored = append(anchored, checkpoint.ID)
+	}
+	return anchored, nil
+}
+
+// AnchorAudit anchors a single audit entry and returns the stored anchor
+func (s *Service) AnchorAudit(ctx context.Context, auditID string) (*chaincode.Anchor, error) {
+	result, err := s.Ledger.Evaluate(ctx, "GetAudit", auditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit entry %s: %v", auditID, err)
+	}
+	var entry chaincode.AuditEntry
+	err = json.Unmarshal(result, &entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit entry %s: %v", auditID, err)
+	}
+	digest, err := chaincode.AuditEntryDigest(&entry)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.TSA.Timestamp(ctx, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to timestamp audit entry %s: %v", auditID, err)
+	}
+	result, err = s.Ledger.Submit(ctx, "CheckpointContract:AnchorAuditEntry", auditID, base64.StdEncoding.EncodeToString(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to anchor audit entry %s: %v", auditID, err)
+	}
+
+	var anchor chaincode.Anchor
+	err = json.Unmarshal(result, &anchor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal anchor of %s: %v", auditID, err)
+	}
+	log.Printf("[AnchorAudit] SUCCESS auditId=%s genTime=%d driftMs=%d", auditID, anchor.GenTime, anchor.DriftMs)
+	return &anchor, nil
+}
+
+// latestCheckpoint returns the head of the checkpoint chain, nil if there are no checkpoints yet
+func (s *Service) latestCheckpoint(ctx context.Context) (*chaincode.Checkpoint, error) {
+	result, err := s.Ledger.Evaluate(ctx, "CheckpointContract:GetLatestCheckpoint")
+	if chaincode.HasErrorCode(err, chaincode.CodeNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latest checkpoint: %v", err)
+	}
+	return decodeCheckpoint(result)
+}
+
+func (s *Service) checkpoint(ctx context.Context, id string) (*chaincode.Checkpoint, error) {
+	result, err := s.Ledger.Evaluate(ctx, "CheckpointContract:GetCheckpoint", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %v", id, err)
+	}
+	return decodeCheckpoint(result)
+}
+
+func decodeCheckpoint(result []byte) (*chaincode.Checkpoint, error) {
+	var checkpoint chaincode.Checkpoint
+	err := json.Unmarshal(result, &checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %v", err)
+	}
+	return &checkpoint, nil
+}
@@ -0,0 +1,228 @@
+package anchor_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/anchor"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa/tsatest"
+)
+
+// fakeLedger answers the handful of functions the service calls, verifying tokens like the chaincode
+type fakeLedger struct {
+	roots       *x509.CertPool
+	checkpoints map[string]*chaincode.Checkpoint
+	latest      string
+	audits      map[string]*chaincode.AuditEntry
+	anchored    []string
+	elsewhere   map[string]bool // checkpoints another service instance anchors first
+}
+
+// failed is the error gateway.Client returns for a failed transaction, the envelope wrapped in gRPC text
+func failed(fn string, code chaincode.ErrorCode, message string) error {
+	envelope, err := json.Marshal(chaincode.TransactionError{Code: code, Message: message, Function: fn, TxID: "tx1"})
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("evaluate %s: rpc error: code = Unknown desc = chaincode response 500, %s", fn, envelope)
+}
+
+func (l *fakeLedger) Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	switch fn {
+	case "CheckpointContract:GetLatestCheckpoint":
+		if l.latest == "" {
+			return nil, failed(fn, chaincode.CodeNotFound, "no checkpoints have been created")
+		}
+		return json.Marshal(l.checkpoints[l.latest])
+	case "CheckpointContract:GetCheckpoint":
+		return json.Marshal(l.checkpoints[args[0]])
+	case "GetAudit":
+		return json.Marshal(l.audits[args[0]])
+	}
+	return nil, fmt.Errorf("unexpected evaluate %s", fn)
+}
+
+func (l *fakeLedger) Submit(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	token, err := base64.StdEncoding.DecodeString(args[1])
+	if err != nil {
+		return nil, err
+	}
+	switch fn {
+	case "CheckpointContract:AnchorCheckpoint":
+		checkpoint := l.checkpoints[args[0]]
+		if l.elsewhere[checkpoint.ID] {
+			return nil, failed(fn, chaincode.CodeAlreadyExists, "checkpoint "+checkpoint.ID+" is already anchored")
+		}
+		verified, err := tsa.Verify(token, chaincode.CheckpointDigest(checkpoint), l.roots)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint.AnchoredAt = verified.GenTime.UnixMilli()
+	case "CheckpointContract:AnchorAuditEntry":
+		digest, err := chaincode.AuditEntryDigest(l.audits[args[0]])
+		if err != nil {
+			return nil, err
+		}
+		_, err = tsa.Verify(token, digest, l.roots)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected submit %s", fn)
+	}
+	l.anchored = append(l.anchored, args[0])
+	return json.Marshal(chaincode.Anchor{TargetID: args[0], Verified: true})
+}
+
+func newLedger(roots *x509.CertPool, count int) *fakeLedger {
+	ledger := &fakeLedger{roots: roots, checkpoints: map[string]*chaincode.Checkpoint{}}
+	prevID := ""
+	for i := 1; i <= count; i++ {
+		checkpoint := &chaincode.Checkpoint{ID: fmt.Sprintf("ckpt-%08d", i), Sequence: i, PrevID: prevID}
+		checkpoint.Statement = chaincode.CheckpointStatement(checkpoint)
+		ledger.checkpoints[checkpoint.ID] = checkpoint
+		ledger.latest = checkpoint.ID
+		prevID = checkpoint.ID
+	}
+	return ledger
+}
+
+func TestAnchorCheckpointsOldestFirst(t *testing.T) {
+	server, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start TSA stand-in: %v", err)
+	}
+	defer server.Close()
+
+	ledger := newLedger(server.Pool(), 3)
+	service := &anchor.Service{Ledger: ledger, TSA: &tsa.Client{URL: server.URL}}
+
+	anchored, err := service.AnchorCheckpoints(context.Background())
+	if err != nil {
+		t.Fatalf("AnchorCheckpoints() error = %v", err)
+	}
+	want := []string{"ckpt-00000001", "ckpt-00000002", "ckpt-00000003"}
+	if !reflect.DeepEqual(anchored, want) {
+		t.Fatalf("anchored = %v, want %v", anchored, want)
+	}
+
+	// Only the new checkpoint is anchored on the next run
+	next := &chaincode.Checkpoint{ID: "ckpt-00000004", Sequence: 4, PrevID: "ckpt-00000003"}
+	next.Statement = chaincode.CheckpointStatement(next)
+	ledger.checkpoints[next.ID] = next
+	ledger.latest = next.ID
+
+	anchored, err = service.AnchorCheckpoints(context.Background())
+	if err != nil {
+		t.Fatalf("AnchorCheckpoints() error = %v", err)
+	}
+	if !reflect.DeepEqual(anchored, []string{"ckpt-00000004"}) {
+		t.Fatalf("anchored = %v, want [ckpt-00000004]", anchored)
+	}
+	if server.Requests() != 4 {
+		t.Errorf("TSA requests = %d, want 4", server.Requests())
+	}
+}
+
+func TestAnchorCheckpointsNoCheckpoints(t *testing.T) {
+	service := &anchor.Service{Ledger: newLedger(nil, 0)}
+	anchored, err := service.AnchorCheckpoints(context.Background())
+	if err != nil || len(anchored) != 0 {
+		t.Fatalf("AnchorCheckpoints() = %v, %v, want nothing anchored", anchored, err)
+	}
+}
+
+func TestAnchorCheckpointsSkipsAnchoredElsewhere(t *testing.T) {
+	server, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start TSA stand-in: %v", err)
+	}
+	defer server.Close()
+
+	ledger := newLedger(server.Pool(), 3)
+	ledger.elsewhere = map[string]bool{"ckpt-00000002": true}
+	service := &anchor.Service{Ledger: ledger, TSA: &tsa.Client{URL: server.URL}}
+
+	anchored, err := service.AnchorCheckpoints(context.Background())
+	if err != nil {
+		t.Fatalf("AnchorCheckpoints() error = %v", err)
+	}
+	if !reflect.DeepEqual(anchored, []string{"ckpt-00000001", "ckpt-00000003"}) {
+		t.Fatalf("anchored = %v, want ckpt-00000001 and ckpt-00000003", anchored)
+	}
+}
+
+func TestAnchorCheckpointsStopsOnOtherErrors(t *testing.T) {
+	// Only the code decides, a message that reads like a skip does not
+	service := &anchor.Service{Ledger: &wrongLatest{newLedger(nil, 0)}}
+	_, err := service.AnchorCheckpoints(context.Background())
+	if err == nil {
+		t.Fatal("AnchorCheckpoints() ignored an INTERNAL error")
+	}
+}
+
+// wrongLatest fails GetLatestCheckpoint with INTERNAL and the NOT_FOUND wording
+type wrongLatest struct {
+	*fakeLedger
+}
+
+func (l *wrongLatest) Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	if fn == "CheckpointContract:GetLatestCheckpoint" {
+		return nil, failed(fn, chaincode.CodeInternal, "no checkpoints have been created")
+	}
+	return l.fakeLedger.Evaluate(ctx, fn, args...)
+}
+
+func TestAnchorAudit(t *testing.T) {
+	server, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start TSA stand-in: %v", err)
+	}
+	defer server.Close()
+
+	ledger := newLedger(server.Pool(), 0)
+	ledger.audits = map[string]*chaincode.AuditEntry{
+		"audit-001": {ID: "audit-001", UserID: "user-alice", Action: "DELETE", TimeStamp: 1700000000000},
+	}
+	service := &anchor.Service{Ledger: ledger, TSA: &tsa.Client{URL: server.URL}}
+
+	got, err := service.AnchorAudit(context.Background(), "audit-001")
+	if err != nil {
+		t.Fatalf("AnchorAudit() error = %v", err)
+	}
+	if got.TargetID != "audit-001" || !got.Verified {
+		t.Errorf("anchor = %+v, want verified anchor of audit-001", got)
+	}
+}
+
+func TestAnchorAuditUntrustedTSA(t *testing.T) {
+	server, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start TSA stand-in: %v", err)
+	}
+	defer server.Close()
+	other, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start TSA stand-in: %v", err)
+	}
+	defer other.Close()
+
+	ledger := newLedger(other.Pool(), 0)
+	ledger.audits = map[string]*chaincode.AuditEntry{"audit-001": {ID: "audit-001"}}
+	service := &anchor.Service{Ledger: ledger, TSA: &tsa.Client{URL: server.URL}}
+
+	_, err = service.AnchorAudit(context.Background(), "audit-001")
+	if err == nil {
+		t.Fatal("AnchorAudit() stored a token from an untrusted TSA")
+	}
+	if len(ledger.anchored) != 0 {
+		t.Errorf("anchored = %v, want none", ledger.anchored)
+	}
+}
@@ -0,0 +1,379 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ anchor.go stores RFC 3161 trusted timestamps (tsa package) for checkpoints and single entries.
+ TimeStamp on an AuditEntry is the client-proposed transaction time; a TSA token is independent
+ evidence that the data existed no later than the TSA's genTime.
+	- SetTrustedTSA / ListTrustedTSAs - Admin managed TSA root certificates
+	- AnchorCheckpoint - Store a token over SHA-256(checkpoint statement)
+	- AnchorAuditEntry - Store a token over SHA-256(CanonicalAuditJSON(entry)), for high-risk entries
+	- GetAnchor - Read an anchor, re-verified against the current trusted TSAs on every read
+
+Tokens are requested off-chain by cmd/audit-anchor and verified here before they are stored:
+message imprint = target digest, signer chains to a trusted TSA root at the token's genTime.
+driftMs = genTime - the target's own claimed time, large positive values deserve a look.
+
+Storage:
+	"TSA_ROOT~freetsa~"            -> TrustedTSA
+	"ANCHOR~CHECKPOINT~ckpt-...~"  -> Anchor
+	"ANCHOR~AUDIT~audit-001~"      -> Anchor
+*/
+
+// Anchor target types
+const (
+	AnchorTargetCheckpoint = "CHECKPOINT"
+	AnchorTargetAudit      = "AUDIT"
+)
+
+// docTypes of anchor records
+const (
+	docTypeAnchor     = "anchor"
+	docTypeTrustedTSA = "trustedTsa"
+)
+
+/*
+--- SET TRUSTED TSA ---
+Admin only. Adds or replaces a trusted TSA root certificate (PEM)
+*/
+func (c *CheckpointContract) SetTrustedTSA(ctx contractapi.TransactionContextInterface, name string, certificatePEM string) error {
+	lg := txLog(ctx, "SetTrustedTSA")
+	lg.Enter("name", name)
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		lg.Reject("DENIED", err)
+		return err
+	}
+
+	// Input validation
+	if name == "" {
+		return invalidArgument("name", "name is required")
+	}
+	if len(name) > 64 {
+		return invalidArgument("name", "name exceeds maximum length of 64 characters")
+	}
+	certs, err := tsa.ParseCertificates([]byte(certificatePEM))
+	if err != nil {
+		return invalidArgument("certificatePEM", "invalid certificate: %v", err)
+	}
+	if len(certs) != 1 {
+		return invalidArgument("certificatePEM", "certificate must hold exactly one PEM certificate, got %d", len(certs))
+	}
+
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+
+	trusted := TrustedTSA{
+		DocType:     docTypeTrustedTSA,
+		Name:        name,
+		Certificate: certificatePEM,
+		Subject:     certs[0].Subject.String(),
+		NotAfter:    certs[0].NotAfter.UnixMilli(),
+		AddedBy:     callerID,
+		AddedAt:     nowMillis,
+	}
+	err = putAnchorRecord(ctx, "TSA_ROOT", []string{name}, trusted)
+	if err != nil {
+		lg.Fail("ERROR", err, "name", name)
+		return err
+	}
+
+	lg.Done("name", name, "subject", trusted.Subject)
+	return nil
+}
+
+// ListTrustedTSAs returns every trusted TSA root
+func (c *CheckpointContract) ListTrustedTSAs(ctx contractapi.TransactionContextInterface) ([]*TrustedTSA, error) {
+	lg := txLog(ctx, "ListTrustedTSAs")
+	lg.Enter()
+	return listTrustedTSAs(ctx)
+}
+
+/*
+--- ANCHOR CHECKPOINT ---
+Stores a base64 DER RFC 3161 token over SHA-256(checkpoint statement)
+*/
+func (c *CheckpointContract) AnchorCheckpoint(ctx contractapi.TransactionContextInterface, checkpointId string, token string) (*Anchor, error) {
+	lg := txLog(ctx, "AnchorCheckpoint")
+	lg.Enter("checkpointId", checkpointId)
+
+	checkpoint, err := c.GetCheckpoint(ctx, checkpointId)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor, err := storeAnchor(ctx, AnchorTargetCheckpoint, checkpointId, CheckpointDigest(checkpoint), checkpoint.CreatedAt, token)
+	if err != nil {
+		lg.Fail("ERROR", err, "checkpointId", checkpointId)
+		return nil, err
+	}
+
+	checkpoint.AnchoredAt = anchor.GenTime
+	err = putCheckpoint(ctx, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	lg.Done("checkpointId", checkpointId, "genTime", anchor.GenTime)
+	return anchor, nil
+}
+
+/*
+--- ANCHOR AUDIT ENTRY ---
+Stores a base64 DER RFC 3161 token over SHA-256(CanonicalAuditJSON(entry))
+*/
+func (c *CheckpointContract) AnchorAuditEntry(ctx contractapi.TransactionContextInterface, auditId string, token string) (*Anchor, error) {
+	lg := txLog(ctx, "AnchorAuditEntry")
+	lg.Enter("auditId", auditId)
+
+	entry, err := (&AuditContract{}).GetAudit(ctx, auditId)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := AuditEntryDigest(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor, err := storeAnchor(ctx, AnchorTargetAudit, auditId, digest, entry.TimeStamp, token)
+	if err != nil {
+		lg.Fail("ERROR", err, "auditId", auditId)
+		return nil, err
+	}
+
+	lg.Done("auditId", auditId, "genTime", anchor.GenTime, "driftMs", anchor.DriftMs)
+	return anchor, nil
+}
+
+/*
+--- GET ANCHOR ---
+Returns the anchor of a CHECKPOINT or AUDIT target, re-verified now:
+Verified / VerifyError reflect the target's current digest and the current trusted TSAs
+*/
+func (c *CheckpointContract) GetAnchor(ctx contractapi.TransactionContextInterface, targetType string, targetId string) (*Anchor, error) {
+	lg := txLog(ctx, "GetAnchor")
+	lg.Enter("targetType", targetType, "targetId", targetId)
+
+	var anchor Anchor
+	found, err := getAnchorRecord(ctx, "ANCHOR", []string{targetType, targetId}, &anchor)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		lg.Reject("NOT_FOUND", nil, "targetType", targetType, "targetId", targetId)
+		return nil, notFound("anchor for %s %s does not exist", targetType, targetId)
+	}
+
+	digest, err := anchorTargetDigest(ctx, c, targetType, targetId)
+	if err == nil {
+		_, err = verifyAnchorToken(ctx, anchor.Token, digest)
+	}
+	anchor.Verified = err == nil
+	if err != nil {
+		anchor.VerifyError = asContractError(err).Message
+	}
+
+	lg.Done("targetType", targetType, "targetId", targetId, "verified", anchor.Verified)
+	return &anchor, nil
+}
+
+// CheckpointDigest is the SHA-256 a checkpoint's TSA token covers
+func CheckpointDigest(checkpoint *Checkpoint) []byte {
+	sum := sha256.Sum256([]byte(checkpoint.Statement))
+	return sum[:]
+}
+
+// AuditEntryDigest is the SHA-256 an audit entry's TSA token covers
+func AuditEntryDigest(entry *AuditEntry) ([]byte, error) {
+	canonical, err := CanonicalAuditJSON(entry)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	return sum[:], nil
+}
+
+// storeAnchor verifies a token over digest and writes the anchor, one anchor per target
+func storeAnchor(ctx contractapi.TransactionContextInterface, targetType string, targetID string,
+	digest []byte, claimedAt int64, token string) (*Anchor, error) {
+
+	if token == "" {
+		return nil, invalidArgument("token", "token is required")
+	}
+	var existing Anchor
+	found, err := getAnchorRecord(ctx, "ANCHOR", []string{targetType, targetID}, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, alreadyExists("%s %s is already anchored (genTime %d)", targetType, targetID, existing.GenTime)
+	}
+
+	verified, err := verifyAnchorToken(ctx, token, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	genTime := verified.GenTime.UnixMilli()
+	anchor := Anchor{
+		DocType:       docTypeAnchor,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		Digest:        hex.EncodeToString(digest),
+		HashAlgorithm: "SHA-256",
+		Token:         token,
+		TSASubject:    verified.Signer.Subject.String(),
+		SerialNumber:  verified.SerialNumber,
+		GenTime:       genTime,
+		ClaimedAt:     claimedAt,
+		DriftMs:       genTime - claimedAt,
+		AnchoredBy:    callerID,
+		AnchoredAt:    nowMillis,
+		TxID:          ctx.GetStub().GetTxID(),
+	}
+	err = putAnchorRecord(ctx, "ANCHOR", []string{targetType, targetID}, anchor)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor.Verified = true
+	return &anchor, nil
+}
+
+// verifyAnchorToken checks a base64 token against digest and the trusted TSA roots on the ledger
+func verifyAnchorToken(ctx contractapi.TransactionContextInterface, token string, digest []byte) (*tsa.Verified, error) {
+	der, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalidArgument("token", "token must be base64 DER: %v", err)
+	}
+
+	trusted, err := listTrustedTSAs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(trusted) == 0 {
+		return nil, failedPrecondition("no trusted TSAs configured, see SetTrustedTSA")
+	}
+	roots := x509.NewCertPool()
+	for _, root := range trusted {
+		certs, err := tsa.ParseCertificates([]byte(root.Certificate))
+		if err != nil {
+			return nil, internalError("trusted TSA %s holds an invalid certificate: %v", root.Name, err)
+		}
+		roots.AddCert(certs[0])
+	}
+
+	verified, err := tsa.Verify(der, digest, roots)
+	if err != nil {
+		return nil, invalidArgument("token", "token does not verify: %v", err)
+	}
+	return verified, nil
+}
+
+// anchorTargetDigest recomputes the digest of an anchored target from the ledger
+func anchorTargetDigest(ctx contractapi.TransactionContextInterface, c *CheckpointContract, targetType string, targetID string) ([]byte, error) {
+	switch targetType {
+	case AnchorTargetCheckpoint:
+		checkpoint, err := c.GetCheckpoint(ctx, targetID)
+		if err != nil {
+			return nil, err
+		}
+		return CheckpointDigest(checkpoint), nil
+	case AnchorTargetAudit:
+		entry, err := (&AuditContract{}).GetAudit(ctx, targetID)
+		if err != nil {
+			return nil, err
+		}
+		return AuditEntryDigest(entry)
+	}
+	return nil, invalidArgument("targetType", "invalid targetType: %s. Valid types: %s, %s", targetType, AnchorTargetCheckpoint, AnchorTargetAudit)
+}
+
+// listTrustedTSAs scans every TSA_ROOT record
+func listTrustedTSAs(ctx contractapi.TransactionContextInterface) ([]*TrustedTSA, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("TSA_ROOT", []string{})
+	if err != nil {
+		return nil, internalError("failed to get trusted TSAs: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	trusted := []*TrustedTSA{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError("failed to iterate trusted TSAs: %v", err)
+		}
+		var root TrustedTSA
+		err = json.Unmarshal(queryResponse.Value, &root)
+		if err != nil {
+			return nil, internalError("failed to unmarshal trusted TSA: %v", err)
+		}
+		trusted = append(trusted, &root)
+	}
+	return trusted, nil
+}
+
+// getAnchorRecord reads a JSON record under a composite key, false if it does not exist
+func getAnchorRecord(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, record interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return false, internalError("failed to create composite key for %s %s: %v", objectType, strings.Join(attributes, "/"), err)
+	}
+	recordJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, internalError("failed to read %s %s from ledger: %v", objectType, strings.Join(attributes, "/"), err)
+	}
+	if recordJSON == nil {
+		return false, nil
+	}
+	err = json.Unmarshal(recordJSON, record)
+	if err != nil {
+		return false, internalError("failed to unmarshal %s %s: %v", objectType, strings.Join(attributes, "/"), err)
+	}
+	return true, nil
+}
+
+// putAnchorRecord writes a JSON record under a composite key
+func putAnchorRecord(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, record interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return internalError("failed to create composite key for %s %s: %v", objectType, strings.Join(attributes, "/"), err)
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return internalError("failed to marshal %s %s: %v", objectType, strings.Join(attributes, "/"), err)
+	}
+	err = ctx.GetStub().PutState(key, recordJSON)
+	if err != nil {
+		return internalError("failed to write %s %s to ledger: %v", objectType, strings.Join(attributes, "/"), err)
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 
 	// import hyperledger fabric SDK for writing go chaincode, provides interfaces:
 	//  - contractapi.Contract : base struct for contracts
@@ -17,6 +16,14 @@ import (
 	- AuditContract struct (the main contract)
 	- Basic CRUD functions: InitLedger, LogAudit, GetAudit, AuditExists, GetAllAudits
 	- Rich Query functions: QueryAuditsByUser, QueryAuditsByDateRange, QueryAuditsByAction
+	- Actions and resource types are validated against the registry (registry.go)
+	- LogAudit stores an OldValue -> NewValue patch (diff.go), queried by QueryAuditsByFieldChange
+	- Per resource history: GetResourceTimeline, ReconstructResourceAt (resources.go)
+	- Correlation / causality links between entries: LogAuditWithTrace, GetTrace (trace.go)
+	- History integrity: CheckResourceContinuity, CheckContinuityByDateRange (continuity.go)
+	- LogAudit only accepts sessionIds of OPEN sessions owned by the user (sessions.go)
+	- Paginated bulk reads for exports: QueryAuditsPage (pagination.go)
+	- Every appended entry is also emitted as an AuditLogged chaincode event (one per transaction)
 
 Uses hyperledger fabric SDK for writing go chaincode
 	- contractapi.Contract : base struct for contracts
@@ -37,21 +44,32 @@ type AuditContract struct {
 	contractapi.Contract
 }
 
-// InitLedger initializes the ledger with sample audit entries for testing (Optional: only for dev/testing)
+// AuditEventName is the chaincode event set by every LogAudit / LogAuditWithTrace, payload = the stored AuditEntry JSON
+const AuditEventName = "AuditLogged"
+
+/*
+--- INIT LEDGER ---
+Initializes the ledger with sample sessions and audit entries for testing (Optional: only for dev/testing)
+ - ADMIN only, and only once: the seed IDs are never overwritten, a sealed or closed seed stays as it is
+ - the entries go through appendAudit like any other, Fabric keeps one chaincode event per transaction
+   so only the last seed entry is emitted, mirror Reconcile with Repair backfills the other
+*/
 func (c *AuditContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	log.Printf("[InitLedger] ENTER")
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Get deterministic timestamp from transaction (same across all peers)
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+		return internalError("failed to get transaction timestamp: %v", err)
 	}
 
-	// Sample audit entries for testing
+	// Sample audit entries for testing, TimeStamp / TxID / Changes are filled in by appendAudit
 	audits := []AuditEntry{
 		{
 			ID:            "audit-001",
-			TimeStamp:     txTimestamp.AsTime().UnixMilli(),
 			UserID:        "user-alice",
 			UserRole:      "ADMIN",
 			Action:        "CREATE",
@@ -64,11 +82,9 @@ func (c *AuditContract) InitLedger(ctx contractapi.TransactionContextInterface)
 			SessionID:     "sess-001",
 			Metadata:      `{"source":"web-portal"}`,
 			ComplianceTag: "SOC2",
-			TxID:          ctx.GetStub().GetTxID(),
 		},
 		{
 			ID:            "audit-002",
-			TimeStamp:     txTimestamp.AsTime().UnixMilli(),
 			UserID:        "user-bob",
 			UserRole:      "AUDITOR",
 			Action:        "QUERY",
@@ -81,28 +97,70 @@ func (c *AuditContract) InitLedger(ctx contractapi.TransactionContextInterface)
 			SessionID:     "sess-002",
 			Metadata:      `{"source":"api"}`,
 			ComplianceTag: "GDPR",
-			TxID:          ctx.GetStub().GetTxID(),
 		},
 	}
 
-	// Check audits for syntax errors and add to ledger
+	// Sample sessions referenced by the audit entries above
+	sessions := []Session{
+		{
+			DocType:    docTypeSession,
+			ID:         "sess-001",
+			UserID:     "user-alice",
+			IPAddress:  "192.168.1.100",
+			AuthMethod: "SSO",
+			Status:     SessionStatusOpen,
+			StartedAt:  txTimestamp.AsTime().UnixMilli(),
+			StartTxID:  ctx.GetStub().GetTxID(),
+		},
+		{
+			DocType:    docTypeSession,
+			ID:         "sess-002",
+			UserID:     "user-bob",
+			IPAddress:  "192.168.1.101",
+			AuthMethod: "API_KEY",
+			Status:     SessionStatusOpen,
+			StartedAt:  txTimestamp.AsTime().UnixMilli(),
+			StartTxID:  ctx.GetStub().GetTxID(),
+		},
+	}
+	// Refuse to run twice, the seed may already be sealed by a checkpoint or closed
 	for _, audit := range audits {
-		auditJSON, err := json.Marshal(audit)
+		exists, err := c.AuditExists(ctx, audit.ID)
 		if err != nil {
-			log.Printf("[InitLedger] ERROR marshaling audit ID=%s err=%v", audit.ID, err)
-			return fmt.Errorf("failed to marshal audit entry ID=%s: %v", audit.ID, err)
+			return err
 		}
-		//  Putstate writes JSON to ledger using audit.ID as key
-		err = ctx.GetStub().PutState(audit.ID, auditJSON) 
+		if exists {
+			return alreadyExists("ledger is already initialized, audit entry %s exists", audit.ID)
+		}
+	}
+	for i := range sessions {
+		existing, err := getSession(ctx, sessions[i].ID)
 		if err != nil {
-			log.Printf("[InitLedger] ERROR writing audit ID=%s err=%v", audit.ID, err)
-			return fmt.Errorf("failed to write audit entry ID=%s to ledger: %v", audit.ID, err)
+			return err
 		}
+		if existing != nil {
+			return alreadyExists("ledger is already initialized, session %s exists", pii(sessions[i].ID))
+		}
+	}
 
-		log.Printf("[InitLedger] Created audit entry ID=%s", audit.ID)
+	pending := map[string]*Session{}
+	for i := range sessions {
+		err = putSession(ctx, &sessions[i])
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR writing session", err, "sessionId", sessions[i].ID)
+			return err
+		}
+		pending[sessions[i].ID] = &sessions[i]
+	}
+
+	// Same validation, sealed window check and event as LogAudit
+	for i := range audits {
+		err = c.appendAudit(ctx, &audits[i], pending)
+		if err != nil {
+			return err
+		}
 	}
 
-	log.Printf("[InitLedger] SUCCESS - initialized %d audit entries", len(audits))
 	return nil
 }
 
@@ -110,6 +168,7 @@ func (c *AuditContract) InitLedger(ctx contractapi.TransactionContextInterface)
 /* 
 --- CREATE AUDIT ENTRY --- 
  Create an audti log entry on the ledger, used to record all credential access events
+ - entries that belong to a larger request use LogAuditWithTrace (trace.go)
 */
 func (c *AuditContract) LogAudit(ctx contractapi.TransactionContextInterface,
 	id string, userId string, userRole string, action string,
@@ -117,42 +176,88 @@ func (c *AuditContract) LogAudit(ctx contractapi.TransactionContextInterface,
 	status string, ipAddress string, sessionId string,
 	metadata string, complianceTag string) error {
 
-	// Entry log
-	log.Printf("[LogAudit] ENTER id=%s userId=%s action=%s resourceId=%s",
-		id, userId, action, resourceId)
+
+	entry := AuditEntry{
+		ID:            id,
+		UserID:        userId,
+		UserRole:      userRole,
+		Action:        action,
+		ResourceType:  resourceType,
+		ResourceID:    resourceId,
+		OldValue:      oldValue,
+		NewValue:      newValue,
+		Status:        status,
+		IPAddress:     ipAddress,
+		SessionID:     sessionId,
+		Metadata:      metadata,
+		ComplianceTag: complianceTag,
+	}
+	return c.appendAudit(ctx, &entry, nil)
+}
+
+/*
+--- HELPER appendAudit ---
+Validates a caller supplied entry and appends it to the ledger, shared by LogAudit, LogAuditWithTrace and InitLedger
+- fills in TimeStamp, TxID and Changes, everything else comes from the caller
+- pendingSessions: sessions the same transaction wrote (InitLedger), nil otherwise
+*/
+func (c *AuditContract) appendAudit(ctx contractapi.TransactionContextInterface, entry *AuditEntry, pendingSessions map[string]*Session) error {
+	id := entry.ID
 
 	// Input validation - Required fields
 	if id == "" {
-		return fmt.Errorf("id is required")
+		return invalidArgument("id", "id is required")
 	}
-	if userId == "" {
-		return fmt.Errorf("userId is required")
+	if entry.UserID == "" {
+		return invalidArgument("userId", "userId is required")
 	}
-	if action == "" {
-		return fmt.Errorf("action is required")
+	if entry.Action == "" {
+		return invalidArgument("action", "action is required")
 	}
 
-	// Check for Valid actions
-	validActions := map[string]bool{
-		"CREATE": true, "UPDATE": true, "DELETE": true,
-		"QUERY": true, "VERIFY": true, "REVOKE": true, "ISSUE": true,
+	// Check action + resource type against the registry (registry.go)
+	_, resType, err := validateAuditAction(ctx, entry.ResourceType, entry.Action)
+	if err != nil {
+		return err
 	}
-	if !validActions[action] {
-		return fmt.Errorf("invalid action: %s. Valid actions: CREATE, UPDATE, DELETE, QUERY, VERIFY, REVOKE, ISSUE", action)
+
+	// OldValue, NewValue and Metadata must be well-formed JSON and match the resource type's schemas (schema.go)
+	err = validateAuditPayloads(resType, entry.OldValue, entry.NewValue, entry.Metadata)
+	if err != nil {
+		return err
 	}
 
 	// Length validation (prevent DoS, reject overized/sus inputs)
 	if len(id) > 64 {
-		return fmt.Errorf("id exceeds maximum length of 64 characters")
+		return invalidArgument("id", "id exceeds maximum length of 64 characters")
+	}
+
+	// A referenced session must be OPEN and belong to userId (sessions.go)
+	err = validateAuditSession(ctx, entry.SessionID, entry.UserID, pendingSessions)
+	if err != nil {
+		return err
 	}
 
 	// Check if audit entry already exists on ledger
 	exists, err := c.AuditExists(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to check if audit entry exists: %v", err)
+		return wrapError(err, "failed to check if audit entry exists")
 	}
 	if exists {
-		return fmt.Errorf("audit entry %s already exists (audit log is append-only)", id)
+		return alreadyExists("audit entry %s already exists (audit log is append-only)", id)
+	}
+
+	// Referenced parent / cause entries must exist, correlation IDs must agree (trace.go)
+	err = c.validateTraceLinks(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	// Structured OldValue -> NewValue patch, so investigators can query what changed (diff.go)
+	changes, err := computeChanges(entry.OldValue, entry.NewValue)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR computing changes", err, "auditId", id)
+		return wrapError(err, "failed to compute changes for audit entry ID=%s", id)
 	}
 
 	// Get Fabric transaction ID for tracing
@@ -161,67 +266,65 @@ func (c *AuditContract) LogAudit(ctx contractapi.TransactionContextInterface,
 	// Get deterministic timestamp from transaction (same across all peers)
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+		return internalError("failed to get transaction timestamp: %v", err)
 	}
 
-	// Create audit entry with auto-populated fields
-	entry := AuditEntry{
-		ID:            id,
-		TimeStamp:     txTimestamp.AsTime().UnixMilli(),
-		UserID:        userId,
-		UserRole:      userRole,
-		Action:        action,
-		ResourceType:  resourceType,
-		ResourceID:    resourceId,
-		OldValue:      oldValue,
-		NewValue:      newValue,
-		Status:        status,
-		IPAddress:     ipAddress,
-		SessionID:     sessionId,
-		Metadata:      metadata,
-		ComplianceTag: complianceTag,
-		TxID:          txID,
+	// Entries cannot join a window a checkpoint has already sealed (checkpoint.go)
+	timestamp := txTimestamp.AsTime().UnixMilli()
+	sealedUntil, err := getCheckpointSealedUntil(ctx)
+	if err != nil {
+		return err
 	}
+	if timestamp <= sealedUntil {
+		return failedPrecondition("timestamp %d falls in a checkpointed window (sealed until %d), resubmit the entry", timestamp, sealedUntil)
+	}
+
+	// Auto-populated fields
+	entry.TimeStamp = timestamp
+	entry.TxID = txID
+	entry.Changes = changes
 
 	// Json encode entry 
 	entryJSON, err := json.Marshal(entry)
 	if err != nil {
-		log.Printf("[LogAudit] ERROR id=%s err=%v", id, err)
-		return fmt.Errorf("failed to marshal audit entry ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR", err, "auditId", id)
+		return internalError("failed to marshal audit entry ID=%s: %v", id, err)
 	}
 
 	// Write to ledger
 	err = ctx.GetStub().PutState(id, entryJSON)
 	if err != nil {
-		log.Printf("[LogAudit] ERROR id=%s err=%v", id, err)
-		return fmt.Errorf("failed to write audit entry ID=%s to ledger: %v", id, err)
+		ctxLog(ctx).Fail("ERROR", err, "auditId", id)
+		return internalError("failed to write audit entry ID=%s to ledger: %v", id, err)
+	}
+
+	// Emit the stored entry as a chaincode event, followed off-chain by the syslog forwarder (forward package)
+	err = ctx.GetStub().SetEvent(AuditEventName, entryJSON)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR setting event", err, "auditId", id)
+		return internalError("failed to set %s event for audit entry ID=%s: %v", AuditEventName, id, err)
 	}
 
-	// Success log
-	log.Printf("[LogAudit] SUCCESS id=%s txId=%s userId=%s action=%s",
-		id, txID, userId, action)
 	return nil
 }
 
 
 // HELPER AuditExists : checks if an audit entry exists in the ledger
 func (c *AuditContract) AuditExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	log.Printf("[AuditExists] ENTER id=%s", id)
-
 	// Input validation
 	if id == "" {
-		return false, fmt.Errorf("id is required")
+		return false, invalidArgument("id", "id is required")
 	}
 
 	// Get state of audit from ledger
 	auditJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
-		log.Printf("[AuditExists] ERROR id=%s err=%v", id, err)
-		return false, fmt.Errorf("failed to check if audit entry exists: %v", err)
+		ctxLog(ctx).Fail("ERROR", err, "auditId", id)
+		return false, internalError("failed to check if audit entry exists: %v", err)
 	}
 
 	exists := auditJSON != nil
-	log.Printf("[AuditExists] id=%s exists=%v", id, exists)
+	ctxLog(ctx).Debug("EXISTS", "auditId", id, "exists", exists)
 	return exists, nil
 }
 
@@ -232,35 +335,31 @@ returns: *AuditEntry, a pointr to AuditEntry struct,
          -  pro for pointer: Doesn't copy entire struct, just returns memory address, can return Nil
 */
 func (c *AuditContract) GetAudit(ctx contractapi.TransactionContextInterface, id string) (*AuditEntry, error) {
-	log.Printf("[GetAudit] ENTER id=%s", id)
-
 	// Input validation
 	if id == "" {
-		return nil, fmt.Errorf("id is required")
+		return nil, invalidArgument("id", "id is required")
 	}
 
 	// Get state from ledger
 	auditJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
-		log.Printf("[GetAudit] ERROR id=%s err=%v", id, err)
-		return nil, fmt.Errorf("failed to read audit entry ID=%s from ledger: %v", id, err)
+		ctxLog(ctx).Fail("ERROR", err, "auditId", id)
+		return nil, internalError("failed to read audit entry ID=%s from ledger: %v", id, err)
 	}
 
 	// Check if exists
 	if auditJSON == nil {
-		log.Printf("[GetAudit] NOT_FOUND id=%s", id)
-		return nil, fmt.Errorf("audit entry %s does not exist in ledger", id)
+		return nil, notFound("audit entry %s does not exist in ledger", id)
 	}
 
 	// Unmarshal JSON
 	var audit AuditEntry
 	err = json.Unmarshal(auditJSON, &audit)
 	if err != nil {
-		log.Printf("[GetAudit] ERROR id=%s err=%v", id, err)
-		return nil, fmt.Errorf("failed to unmarshal audit entry ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR", err, "auditId", id)
+		return nil, internalError("failed to unmarshal audit entry ID=%s: %v", id, err)
 	}
 
-	log.Printf("[GetAudit] SUCCESS id=%s userId=%s action=%s", id, audit.UserID, audit.Action)
 	return &audit, nil
 }
 
@@ -270,14 +369,12 @@ Returns all audit entries from the ledger
 - In PROD, use pagination (GetStateByRangeWithPagination) to handle large datasets
 */
 func (c *AuditContract) GetAllAudits(ctx contractapi.TransactionContextInterface) ([]*AuditEntry, error) {
-	log.Printf("[GetAllAudits] ENTER")
-
 	// Get all entries from ledger
 	// Using "" empty strings for startKey and endKey returns all entries
 	resultsIterator, err := ctx.GetStub().GetStateByRange("", "") // Opens connection
 	if err != nil {
-		log.Printf("[GetAllAudits] ERROR err=%v", err)
-		return nil, fmt.Errorf("failed to get all audit entries: %v", err)
+		ctxLog(ctx).Fail("ERROR", err)
+		return nil, internalError("failed to get all audit entries: %v", err)
 	}
 
 	// defer runs when function exits like a finally, .Close() closes connection, prevents memory leaks 
@@ -289,21 +386,20 @@ func (c *AuditContract) GetAllAudits(ctx contractapi.TransactionContextInterface
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			log.Printf("[GetAllAudits] ERROR iterating err=%v", err)
-			return nil, fmt.Errorf("failed to iterate results: %v", err)
+			ctxLog(ctx).Fail("ERROR iterating", err)
+			return nil, internalError("failed to iterate results: %v", err)
 		}
 
 		var audit AuditEntry
 		err = json.Unmarshal(queryResponse.Value, &audit)
 		if err != nil {
-			log.Printf("[GetAllAudits] ERROR unmarshaling key=%s err=%v", queryResponse.Key, err)
-			return nil, fmt.Errorf("failed to unmarshal audit entry: %v", err)
+			ctxLog(ctx).Fail("ERROR unmarshaling", err, "key", queryResponse.Key)
+			return nil, internalError("failed to unmarshal audit entry: %v", err)
 		}
 
 		audits = append(audits, &audit)
 	}
 
-	log.Printf("[GetAllAudits] SUCCESS count=%d", len(audits))
 	return audits, nil
 }
 
@@ -315,11 +411,9 @@ Get all audit entries for a given user
 - uses couchDB rich queries 
 */
 func (c *AuditContract) QueryAuditsByUser(ctx contractapi.TransactionContextInterface, userId string) ([]*AuditEntry, error) {
-	log.Printf("[QueryAuditsByUser] ENTER userId=%s", userId)
-
 	// Input validation
 	if userId == "" {
-		return nil, fmt.Errorf("userId is required")
+		return nil, invalidArgument("userId", "userId is required")
 	}
 
 	// Build CouchDB query selector
@@ -339,14 +433,12 @@ Returns a list of audits within a time range
 Params: startDate and endDate should be Unix timestamps in milliseconds
 */
 func (c *AuditContract) QueryAuditsByDateRange(ctx contractapi.TransactionContextInterface, startDate int64, endDate int64) ([]*AuditEntry, error) {
-	log.Printf("[QueryAuditsByDateRange] ENTER startDate=%d endDate=%d", startDate, endDate)
-
 	// Input validation
 	if startDate < 0 || endDate < 0 {
-		return nil, fmt.Errorf("startDate and endDate must be positive timestamps")
+		return nil, invalidArgument("startDate", "startDate and endDate must be positive timestamps")
 	}
 	if startDate > endDate {
-		return nil, fmt.Errorf("startDate must be before endDate")
+		return nil, invalidArgument("startDate", "startDate must be before endDate")
 	}
 
 	// Build CouchDB query selector
@@ -369,20 +461,18 @@ func (c *AuditContract) QueryAuditsByDateRange(ctx contractapi.TransactionContex
 Returns audits filtered by a specific action type 
 */
 func (c *AuditContract) QueryAuditsByAction(ctx contractapi.TransactionContextInterface, action string) ([]*AuditEntry, error) {
-	log.Printf("[QueryAuditsByAction] ENTER action=%s", action)
-
 	// Input validation
 	if action == "" {
-		return nil, fmt.Errorf("action is required")
+		return nil, invalidArgument("action", "action is required")
 	}
 
-	// Validate action type
-	validActions := map[string]bool{
-		"CREATE": true, "UPDATE": true, "DELETE": true,
-		"QUERY": true, "VERIFY": true, "REVOKE": true, "ISSUE": true,
+	// Validate action type against the registry (registry.go)
+	actionType, err := getActionType(ctx, action)
+	if err != nil {
+		return nil, err
 	}
-	if !validActions[action] {
-		return nil, fmt.Errorf("invalid action: %s", action)
+	if actionType == nil {
+		return nil, invalidArgument("action", "invalid action: %s", action)
 	}
 
 	// Build CouchDB query selector
@@ -397,19 +487,91 @@ func (c *AuditContract) QueryAuditsByAction(ctx contractapi.TransactionContextIn
 }
 
 
+/*
+--- GET AUDITS by FIELD CHANGE ---
+Returns audits whose OldValue -> NewValue patch touched a field
+Params:
+	- resourceType: optional, "" = any resource type
+	- path: JSON Pointer ("/status") or shorthand ("status", "holder.name")
+	- value: optional new value, "" = any change at path. JSON literals are matched as-is,
+	  anything else is treated as a string: REVOKED == "REVOKED"
+Example - every time a credential's status flipped to REVOKED:
+	QueryAuditsByFieldChange("CREDENTIAL", "status", "REVOKED")
+Cost:
+	CouchDB JSON indexes cannot reach into the changes array, so the $elemMatch is checked per document.
+	With a resourceType only that type is scanned (indexResourceTypeTimestamp),
+	without one it is a full scan of every audit entry in timestamp order (indexTimestamp).
+*/
+func (c *AuditContract) QueryAuditsByFieldChange(ctx contractapi.TransactionContextInterface,
+	resourceType string, path string, value string) ([]*AuditEntry, error) {
+
+
+	// Input validation
+	if path == "" {
+		return nil, invalidArgument("path", "path is required")
+	}
+	pointer := normalizePointer(path)
+
+	change := map[string]interface{}{"path": pointer}
+	if value != "" {
+		if json.Valid([]byte(value)) {
+			doc, err := decodeJSONNumbers(value)
+			if err != nil {
+				return nil, invalidArgument("value", "invalid value: %v", err)
+			}
+			value, err = compactJSON(doc)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			encoded, err := compactJSON(value)
+			if err != nil {
+				return nil, err
+			}
+			value = encoded
+		}
+		change["value"] = value
+	}
+
+	selector := map[string]interface{}{
+		"changes": map[string]interface{}{"$elemMatch": change},
+	}
+	if resourceType != "" {
+		selector["resourceType"] = resourceType
+	}
+
+	// Build CouchDB query with json.Marshal, values are user supplied
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+		"sort":     []map[string]string{{"timestamp": "desc"}},
+	})
+	if err != nil {
+		return nil, internalError("failed to build query: %v", err)
+	}
+
+	return c.queryAudits(ctx, string(queryJSON))
+}
+
 /*
 --- HELPER queryAudits ----
 executes CouchDB queries to get audits 
-
+- other record types (sessions, ...) share the state database and carry a docType,
+  audit entries never do, so every selector gets "docType": {"$exists": false}
 */
 func (c *AuditContract) queryAudits(ctx contractapi.TransactionContextInterface, queryString string) ([]*AuditEntry, error) {
-	log.Printf("[queryAudits] ENTER queryString=%s", queryString)
+	ctxLog(ctx).Debug("QUERY", "query", queryString)
+
+	queryString, err := excludeDocTypes(queryString)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err)
+		return nil, err
+	}
 
 	// Execute rich query
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
-		log.Printf("[queryAudits] ERROR err=%v", err)
-		return nil, fmt.Errorf("failed to execute query: %v", err)
+		ctxLog(ctx).Fail("ERROR", err)
+		return nil, internalError("failed to execute query: %v", err)
 	}
 	defer resultsIterator.Close()
 
@@ -419,26 +581,41 @@ func (c *AuditContract) queryAudits(ctx contractapi.TransactionContextInterface,
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			log.Printf("[queryAudits] ERROR iterating err=%v", err)
-			return nil, fmt.Errorf("failed to iterate query results: %v", err)
+			ctxLog(ctx).Fail("ERROR iterating", err)
+			return nil, internalError("failed to iterate query results: %v", err)
 		}
 
 		var audit AuditEntry
 		err = json.Unmarshal(queryResponse.Value, &audit)
 		if err != nil {
-			log.Printf("[queryAudits] ERROR unmarshaling key=%s err=%v", queryResponse.Key, err)
-			return nil, fmt.Errorf("failed to unmarshal audit entry: %v", err)
+			ctxLog(ctx).Fail("ERROR unmarshaling", err, "key", queryResponse.Key)
+			return nil, internalError("failed to unmarshal audit entry: %v", err)
 		}
 
 		audits = append(audits, &audit)
 	}
 
-	log.Printf("[queryAudits] SUCCESS count=%d", len(audits))
+	ctxLog(ctx).Debug("QUERY_RESULT", "count", len(audits))
 	return audits, nil
 }
 
+// excludeDocTypes adds "docType": {"$exists": false} to a query's selector so only audit entries match
+func excludeDocTypes(queryString string) (string, error) {
+	var query map[string]interface{}
+	err := json.Unmarshal([]byte(queryString), &query)
+	if err != nil {
+		return "", internalError("invalid query: %v", err)
+	}
 
+	selector, ok := query["selector"].(map[string]interface{})
+	if !ok {
+		return "", internalError("invalid query: selector must be an object")
+	}
+	selector["docType"] = map[string]interface{}{"$exists": false}
 
-
-
-
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return "", internalError("failed to build query: %v", err)
+	}
+	return string(queryJSON), nil
+}
@@ -0,0 +1,522 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/merkle"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ checkpoint.go seals windows of audit entries under Merkle roots (merkle package), so a single
+ entry can be verified against a published root without trusting any peer's GetAudit response.
+	- CreateCheckpoint - Admin: Merkle tree over every entry in [startTs, endTs], root stored on the ledger
+	- CountersignCheckpoint - Each org signs the checkpoint statement with its own certificate
+	- GetCheckpoint / GetLatestCheckpoint - Read checkpoints
+	- GetInclusionProof - Sibling path from one entry up to its checkpoint's root
+	- RFC 3161 anchors of checkpoints and entries live in anchor.go
+
+Leaves:
+	leaf data  = CanonicalAuditJSON(entry) - compact JSON, object keys sorted, no HTML escaping
+	leaf hash  = merkle.LeafHash(leaf data)
+	leaf order = timestamp ascending, ties broken by audit ID
+
+Windows are contiguous: checkpoint N+1 must start at checkpoint N's endTs + 1, so every entry
+lands in exactly one checkpoint. Two rules keep late entries out of sealed windows:
+	- endTs must be at least checkpointFinalityMillis older than the CreateCheckpoint transaction,
+	  proposals endorsed before that may still be on their way to the orderer
+	- LogAudit rejects timestamps <= the latest checkpoint's endTs (CHECKPOINT_SEALED~). It reads
+	  that key, so a LogAudit endorsed before a checkpoint commits fails with an MVCC conflict
+Checkpoints form a chain: prevHash = SHA-256(statement of the previous checkpoint).
+
+Statement (what orgs countersign, ECDSA over its SHA-256, ASN.1 DER signature):
+	audit-trail-checkpoint:v1
+	<id>
+	<sequence>
+	<startTs>
+	<endTs>
+	<treeSize>
+	<root hex>
+	<prevHash hex>
+
+Storage:
+	"CHECKPOINT~ckpt-00000001~" -> Checkpoint
+	"CHECKPOINT_HEAD~"          -> ID of the latest checkpoint
+	"CHECKPOINT_SEALED~"        -> endTs of the latest checkpoint, decimal
+	"CKPT_ENTRY~audit-001~"     -> ID of the checkpoint holding audit-001
+*/
+
+// CheckpointContract seals audit entries under Merkle roots
+type CheckpointContract struct {
+	contractapi.Contract
+}
+
+// docTypeCheckpoint marks checkpoint documents in CouchDB
+const docTypeCheckpoint = "checkpoint"
+
+// checkpointStatementVersion prefixes every statement, bump it if the format changes
+const checkpointStatementVersion = "audit-trail-checkpoint:v1"
+
+// maxCheckpointEntries bounds one checkpoint, use smaller windows beyond this
+const maxCheckpointEntries = 10000
+
+// checkpointFinalityMillis is how far endTs must lie behind the CreateCheckpoint transaction time
+const checkpointFinalityMillis = 5 * 60 * 1000
+
+/*
+--- CREATE CHECKPOINT ---
+Admin only. Builds a Merkle tree over every audit entry with startTs <= timestamp <= endTs
+Params: startTs and endTs should be Unix timestamps in milliseconds
+*/
+func (c *CheckpointContract) CreateCheckpoint(ctx contractapi.TransactionContextInterface,
+	startTs int64, endTs int64) (*Checkpoint, error) {
+
+	lg := txLog(ctx, "CreateCheckpoint")
+	lg.Enter("startTs", startTs, "endTs", endTs)
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		lg.Reject("DENIED", err)
+		return nil, err
+	}
+
+	// Input validation
+	if startTs < 0 || endTs < 0 {
+		return nil, invalidArgument("startTs", "startTs and endTs must be positive timestamps")
+	}
+	if startTs > endTs {
+		return nil, invalidArgument("startTs", "startTs must be before endTs")
+	}
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if endTs > nowMillis-checkpointFinalityMillis {
+		return nil, invalidArgument("endTs", "endTs must be at least %d ms in the past, entries may still be in flight for an open window",
+			checkpointFinalityMillis)
+	}
+
+	previous, err := getLatestCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sequence := 1
+	prevID, prevHash := "", ""
+	if previous != nil {
+		if startTs != previous.EndTs+1 {
+			return nil, failedPrecondition("startTs must be %d: windows are contiguous, checkpoint %s ends at %d",
+				previous.EndTs+1, previous.ID, previous.EndTs)
+		}
+		sequence = previous.Sequence + 1
+		prevID = previous.ID
+		prevHash = statementHash(previous.Statement)
+	}
+
+	entries, err := (&AuditContract{}).QueryAuditsByDateRange(ctx, startTs, endTs)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > maxCheckpointEntries {
+		return nil, invalidArgument("endTs", "window holds %d entries, a checkpoint is limited to %d: use a smaller window",
+			len(entries), maxCheckpointEntries)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].TimeStamp != entries[j].TimeStamp {
+			return entries[i].TimeStamp < entries[j].TimeStamp
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	leaves := make([]CheckpointLeaf, 0, len(entries))
+	hashes := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		leafHash, err := AuditLeafHash(entry)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, CheckpointLeaf{AuditID: entry.ID, Hash: hex.EncodeToString(leafHash)})
+		hashes = append(hashes, leafHash)
+	}
+
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := Checkpoint{
+		DocType:    docTypeCheckpoint,
+		ID:         fmt.Sprintf("ckpt-%08d", sequence),
+		Sequence:   sequence,
+		StartTs:    startTs,
+		EndTs:      endTs,
+		TreeSize:   len(leaves),
+		Root:       hex.EncodeToString(merkle.Root(hashes)),
+		PrevID:     prevID,
+		PrevHash:   prevHash,
+		Leaves:     leaves,
+		Signatures: []CheckpointSignature{},
+		CreatedBy:  callerID,
+		CreatedAt:  nowMillis,
+		TxID:       ctx.GetStub().GetTxID(),
+	}
+	checkpoint.Statement = CheckpointStatement(&checkpoint)
+
+	err = putCheckpoint(ctx, &checkpoint)
+	if err != nil {
+		lg.Fail("ERROR", err, "checkpointId", checkpoint.ID)
+		return nil, err
+	}
+	err = putCheckpointHead(ctx, checkpoint.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = putCheckpointSealedUntil(ctx, checkpoint.EndTs)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range leaves {
+		key, err := ctx.GetStub().CreateCompositeKey("CKPT_ENTRY", []string{leaf.AuditID})
+		if err != nil {
+			return nil, internalError("failed to create composite key for checkpoint entry %s: %v", leaf.AuditID, err)
+		}
+		err = ctx.GetStub().PutState(key, []byte(checkpoint.ID))
+		if err != nil {
+			return nil, internalError("failed to index checkpoint entry %s: %v", leaf.AuditID, err)
+		}
+	}
+
+	lg.Done("checkpointId", checkpoint.ID, "treeSize", checkpoint.TreeSize, "root", checkpoint.Root)
+	return &checkpoint, nil
+}
+
+/*
+--- COUNTERSIGN CHECKPOINT ---
+Adds the calling org's signature over the checkpoint statement
+  - signature: base64 ASN.1 ECDSA signature over SHA-256(statement), made with the
+    same key as the certificate submitting this transaction
+  - one signature per MSP
+*/
+func (c *CheckpointContract) CountersignCheckpoint(ctx contractapi.TransactionContextInterface,
+	id string, signature string) error {
+
+	lg := txLog(ctx, "CountersignCheckpoint")
+	lg.Enter("checkpointId", id)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) == 0 {
+		return invalidArgument("signature", "signature must be non-empty base64")
+	}
+
+	checkpoint, err := c.GetCheckpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return internalError("failed to read caller MSP ID: %v", err)
+	}
+	for _, existing := range checkpoint.Signatures {
+		if existing.MSPID == mspID {
+			return alreadyExists("checkpoint %s is already countersigned by %s", id, mspID)
+		}
+	}
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil || cert == nil {
+		return internalError("failed to read caller certificate: %v", err)
+	}
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return failedPrecondition("caller certificate must hold an ECDSA key")
+	}
+	digest := sha256.Sum256([]byte(checkpoint.Statement))
+	if !ecdsa.VerifyASN1(publicKey, digest[:], sig) {
+		lg.Reject("INVALID_SIGNATURE", nil, "checkpointId", id)
+		return invalidArgument("signature", "signature does not verify against the checkpoint statement and caller certificate")
+	}
+
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+
+	checkpoint.Signatures = append(checkpoint.Signatures, CheckpointSignature{
+		MSPID:       mspID,
+		SignerID:    callerID,
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		Signature:   signature,
+		SignedAt:    nowMillis,
+	})
+	err = putCheckpoint(ctx, checkpoint)
+	if err != nil {
+		lg.Fail("ERROR", err, "checkpointId", id)
+		return err
+	}
+
+	lg.Done("checkpointId", id, "signatures", len(checkpoint.Signatures))
+	return nil
+}
+
+// GetCheckpoint returns a checkpoint by ID
+func (c *CheckpointContract) GetCheckpoint(ctx contractapi.TransactionContextInterface, id string) (*Checkpoint, error) {
+	lg := txLog(ctx, "GetCheckpoint")
+	lg.Enter("checkpointId", id)
+
+	// Input validation
+	if id == "" {
+		return nil, invalidArgument("id", "id is required")
+	}
+
+	checkpoint, err := getCheckpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		lg.Reject("NOT_FOUND", nil, "checkpointId", id)
+		return nil, notFound("checkpoint %s does not exist", id)
+	}
+	return checkpoint, nil
+}
+
+// GetLatestCheckpoint returns the most recent checkpoint
+func (c *CheckpointContract) GetLatestCheckpoint(ctx contractapi.TransactionContextInterface) (*Checkpoint, error) {
+	lg := txLog(ctx, "GetLatestCheckpoint")
+	lg.Enter()
+
+	checkpoint, err := getLatestCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		return nil, notFound("no checkpoints have been created")
+	}
+	return checkpoint, nil
+}
+
+/*
+--- GET INCLUSION PROOF ---
+Returns everything an external party needs to check one entry against a published root:
+
+	leafHash = merkle.LeafHash(CanonicalAuditJSON(entry))
+	merkle.VerifyInclusion(leafHash, leafIndex, treeSize, path, root)
+*/
+func (c *CheckpointContract) GetInclusionProof(ctx contractapi.TransactionContextInterface, auditId string) (*InclusionProof, error) {
+	lg := txLog(ctx, "GetInclusionProof")
+	lg.Enter("auditId", auditId)
+
+	// Input validation
+	if auditId == "" {
+		return nil, invalidArgument("auditId", "auditId is required")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("CKPT_ENTRY", []string{auditId})
+	if err != nil {
+		return nil, internalError("failed to create composite key for checkpoint entry %s: %v", auditId, err)
+	}
+	checkpointID, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, internalError("failed to read checkpoint entry %s: %v", auditId, err)
+	}
+	if checkpointID == nil {
+		lg.Reject("NOT_FOUND", nil, "auditId", auditId)
+		return nil, failedPrecondition("audit entry %s is not in any checkpoint", auditId)
+	}
+
+	checkpoint, err := c.GetCheckpoint(ctx, string(checkpointID))
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	hashes := make([][]byte, len(checkpoint.Leaves))
+	for i, leaf := range checkpoint.Leaves {
+		hashes[i], err = hex.DecodeString(leaf.Hash)
+		if err != nil {
+			return nil, internalError("checkpoint %s holds an invalid leaf hash: %v", checkpoint.ID, err)
+		}
+		if leaf.AuditID == auditId {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, internalError("checkpoint %s does not list audit entry %s", checkpoint.ID, auditId)
+	}
+
+	path, err := merkle.Proof(hashes, index)
+	if err != nil {
+		return nil, err
+	}
+	proof := &InclusionProof{
+		AuditID:      auditId,
+		CheckpointID: checkpoint.ID,
+		LeafIndex:    index,
+		TreeSize:     checkpoint.TreeSize,
+		LeafHash:     checkpoint.Leaves[index].Hash,
+		Path:         make([]string, len(path)),
+		Root:         checkpoint.Root,
+	}
+	for i, sibling := range path {
+		proof.Path[i] = hex.EncodeToString(sibling)
+	}
+
+	lg.Done("auditId", auditId, "checkpoint", checkpoint.ID, "index", index)
+	return proof, nil
+}
+
+// CanonicalAuditJSON is the exact byte form of an entry that checkpoints hash
+func CanonicalAuditJSON(entry *AuditEntry) ([]byte, error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return nil, internalError("failed to marshal audit entry ID=%s: %v", entry.ID, err)
+	}
+	doc, err := decodeJSONNumbers(string(entryJSON))
+	if err != nil {
+		return nil, internalError("failed to decode audit entry ID=%s: %v", entry.ID, err)
+	}
+	canonical, err := compactJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(canonical), nil
+}
+
+// AuditLeafHash is the Merkle leaf hash of an entry
+func AuditLeafHash(entry *AuditEntry) ([]byte, error) {
+	canonical, err := CanonicalAuditJSON(entry)
+	if err != nil {
+		return nil, err
+	}
+	return merkle.LeafHash(canonical), nil
+}
+
+// CheckpointStatement builds the text every org countersigns
+func CheckpointStatement(checkpoint *Checkpoint) string {
+	return fmt.Sprintf("%s\n%s\n%d\n%d\n%d\n%d\n%s\n%s",
+		checkpointStatementVersion, checkpoint.ID, checkpoint.Sequence,
+		checkpoint.StartTs, checkpoint.EndTs, checkpoint.TreeSize, checkpoint.Root, checkpoint.PrevHash)
+}
+
+// statementHash links a checkpoint to its predecessor
+func statementHash(statement string) string {
+	sum := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(sum[:])
+}
+
+// getCheckpoint reads a checkpoint, nil if it does not exist
+func getCheckpoint(ctx contractapi.TransactionContextInterface, id string) (*Checkpoint, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("CHECKPOINT", []string{id})
+	if err != nil {
+		return nil, internalError("failed to create composite key for checkpoint ID=%s: %v", id, err)
+	}
+	checkpointJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, internalError("failed to read checkpoint ID=%s from ledger: %v", id, err)
+	}
+	if checkpointJSON == nil {
+		return nil, nil
+	}
+
+	var checkpoint Checkpoint
+	err = json.Unmarshal(checkpointJSON, &checkpoint)
+	if err != nil {
+		return nil, internalError("failed to unmarshal checkpoint ID=%s: %v", id, err)
+	}
+	return &checkpoint, nil
+}
+
+// getLatestCheckpoint follows CHECKPOINT_HEAD, nil before the first checkpoint
+func getLatestCheckpoint(ctx contractapi.TransactionContextInterface) (*Checkpoint, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("CHECKPOINT_HEAD", []string{})
+	if err != nil {
+		return nil, internalError("failed to create composite key for checkpoint head: %v", err)
+	}
+	headID, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, internalError("failed to read checkpoint head: %v", err)
+	}
+	if headID == nil {
+		return nil, nil
+	}
+	return getCheckpoint(ctx, string(headID))
+}
+
+// putCheckpoint writes a checkpoint under its "CHECKPOINT" composite key
+func putCheckpoint(ctx contractapi.TransactionContextInterface, checkpoint *Checkpoint) error {
+	key, err := ctx.GetStub().CreateCompositeKey("CHECKPOINT", []string{checkpoint.ID})
+	if err != nil {
+		return internalError("failed to create composite key for checkpoint ID=%s: %v", checkpoint.ID, err)
+	}
+	checkpointJSON, err := json.Marshal(checkpoint)
+	if err != nil {
+		return internalError("failed to marshal checkpoint ID=%s: %v", checkpoint.ID, err)
+	}
+	err = ctx.GetStub().PutState(key, checkpointJSON)
+	if err != nil {
+		return internalError("failed to write checkpoint ID=%s to ledger: %v", checkpoint.ID, err)
+	}
+	return nil
+}
+
+// putCheckpointHead moves CHECKPOINT_HEAD to id
+func putCheckpointHead(ctx contractapi.TransactionContextInterface, id string) error {
+	key, err := ctx.GetStub().CreateCompositeKey("CHECKPOINT_HEAD", []string{})
+	if err != nil {
+		return internalError("failed to create composite key for checkpoint head: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, []byte(id))
+	if err != nil {
+		return internalError("failed to write checkpoint head: %v", err)
+	}
+	return nil
+}
+
+// getCheckpointSealedUntil returns the endTs of the latest checkpoint, -1 if there is none
+func getCheckpointSealedUntil(ctx contractapi.TransactionContextInterface) (int64, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("CHECKPOINT_SEALED", []string{})
+	if err != nil {
+		return 0, internalError("failed to create composite key for checkpoint seal: %v", err)
+	}
+	sealedJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, internalError("failed to read checkpoint seal: %v", err)
+	}
+	if sealedJSON == nil {
+		return -1, nil
+	}
+	sealedUntil, err := strconv.ParseInt(string(sealedJSON), 10, 64)
+	if err != nil {
+		return 0, internalError("failed to parse checkpoint seal %q: %v", sealedJSON, err)
+	}
+	return sealedUntil, nil
+}
+
+// putCheckpointSealedUntil records the endTs of the latest checkpoint
+func putCheckpointSealedUntil(ctx contractapi.TransactionContextInterface, endTs int64) error {
+	key, err := ctx.GetStub().CreateCompositeKey("CHECKPOINT_SEALED", []string{})
+	if err != nil {
+		return internalError("failed to create composite key for checkpoint seal: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, []byte(strconv.FormatInt(endTs, 10)))
+	if err != nil {
+		return internalError("failed to write checkpoint seal: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/ledgersim"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/merkle"
+)
+
+// countersignArgs are the CountersignCheckpoint arguments with a signature by signer's key
+func (n *network) countersignArgs(signer *ledgersim.Identity, checkpoint *Checkpoint) []string {
+	n.t.Helper()
+	signature, err := signer.Sign([]byte(checkpoint.Statement))
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	return []string{"CheckpointContract:CountersignCheckpoint", checkpoint.ID, base64.StdEncoding.EncodeToString(signature)}
+}
+
+func TestCheckpoints(t *testing.T) {
+	n := newNetwork(t)
+	start := n.millis()
+	for i := 1; i <= 3; i++ {
+		n.logAudit(auditArgs(fmt.Sprintf("audit-%d", i), "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""))
+	}
+	end := n.millis() - 1
+
+	// Admin only, and the window must have closed at least the finality margin ago
+	n.reject(CodePermissionDenied, n.client, "CheckpointContract:CreateCheckpoint", fmt.Sprint(start), fmt.Sprint(end))
+	n.reject(CodeInvalidArgument, n.admin, "CheckpointContract:CreateCheckpoint", fmt.Sprint(start), fmt.Sprint(end))
+	n.wait(checkpointFinalityMillis * time.Millisecond)
+
+	var first Checkpoint
+	n.submit(n.admin, &first, "CheckpointContract:CreateCheckpoint", fmt.Sprint(start), fmt.Sprint(end))
+	if first.ID != "ckpt-00000001" || first.Sequence != 1 || first.TreeSize != 3 || first.PrevHash != "" {
+		t.Errorf("first = %+v", first)
+	}
+
+	// Any entry checks out against the root with nothing but the merkle package
+	var entry AuditEntry
+	n.evaluate(n.client, &entry, "GetAudit", "audit-2")
+	var proof InclusionProof
+	n.evaluate(n.client, &proof, "CheckpointContract:GetInclusionProof", "audit-2")
+	leafData, err := CanonicalAuditJSON(&entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafHash := merkle.LeafHash(leafData)
+	root, _ := hex.DecodeString(first.Root)
+	path := [][]byte{}
+	for _, sibling := range proof.Path {
+		hash, _ := hex.DecodeString(sibling)
+		path = append(path, hash)
+	}
+	if hex.EncodeToString(leafHash) != proof.LeafHash || proof.LeafIndex != 1 || proof.Root != first.Root {
+		t.Errorf("proof = %+v", proof)
+	}
+	if err := merkle.VerifyInclusion(leafHash, int64(proof.LeafIndex), int64(proof.TreeSize), path, root); err != nil {
+		t.Errorf("VerifyInclusion: %v", err)
+	}
+
+	// A late transaction cannot add entries to the sealed window
+	resume := n.ledger.Now()
+	n.ledger.SetClock(time.UnixMilli(end), time.Second)
+	n.reject(CodeFailedPrecondition, n.client, auditArgs("audit-9", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", "")...)
+	n.ledger.SetClock(resume, time.Second)
+
+	// Windows are contiguous and chained
+	n.logAudit(auditArgs("audit-4", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""))
+	n.reject(CodeFailedPrecondition, n.client, "CheckpointContract:GetInclusionProof", "audit-4")
+	next := n.millis() - 1
+	n.wait(checkpointFinalityMillis * time.Millisecond)
+	n.reject(CodeFailedPrecondition, n.admin, "CheckpointContract:CreateCheckpoint", fmt.Sprint(end+2), fmt.Sprint(next))
+	var second Checkpoint
+	n.submit(n.admin, &second, "CheckpointContract:CreateCheckpoint", fmt.Sprint(end+1), fmt.Sprint(next))
+	statementHash := sha256.Sum256([]byte(first.Statement))
+	if second.Sequence != 2 || second.PrevID != first.ID || second.PrevHash != hex.EncodeToString(statementHash[:]) || second.TreeSize != 1 {
+		t.Errorf("second = %+v", second)
+	}
+	var latest Checkpoint
+	n.evaluate(n.client, &latest, "CheckpointContract:GetLatestCheckpoint")
+	if latest.ID != second.ID {
+		t.Errorf("latest = %s", latest.ID)
+	}
+}
+
+func TestCountersignCheckpoint(t *testing.T) {
+	n := newNetwork(t)
+	n.logAudit(auditArgs("audit-1", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""))
+	end := n.millis() - 1
+	n.wait(checkpointFinalityMillis * time.Millisecond)
+	var checkpoint Checkpoint
+	n.submit(n.admin, &checkpoint, "CheckpointContract:CreateCheckpoint", fmt.Sprint(networkStart.UnixMilli()), fmt.Sprint(end))
+
+	// One signature per MSP, made with the submitting certificate's key
+	org2 := n.identity("Org2MSP", "Admin@org2.example.com", "admin", "")
+	n.submit(n.admin, nil, n.countersignArgs(n.admin, &checkpoint)...)
+	n.reject(CodeAlreadyExists, n.client, n.countersignArgs(n.client, &checkpoint)...)
+	n.reject(CodeInvalidArgument, org2, n.countersignArgs(n.admin, &checkpoint)...)
+	n.reject(CodeInvalidArgument, org2, "CheckpointContract:CountersignCheckpoint", checkpoint.ID, "not base64")
+	n.submit(org2, nil, n.countersignArgs(org2, &checkpoint)...)
+
+	n.evaluate(n.client, &checkpoint, "CheckpointContract:GetCheckpoint", checkpoint.ID)
+	if len(checkpoint.Signatures) != 2 || checkpoint.Signatures[0].MSPID != "Org1MSP" || checkpoint.Signatures[1].MSPID != "Org2MSP" {
+		t.Errorf("signatures = %+v", checkpoint.Signatures)
+	}
+	n.reject(CodeNotFound, org2, "CheckpointContract:CountersignCheckpoint", "ckpt-00000009", "c2ln")
+}
@@ -0,0 +1,236 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ continuity.go checks that a resource's audit history is a connected chain of states.
+ Producers supply OldValue themselves, so nothing at LogAudit time proves it is the
+ state the previous entry left behind.
+
+	- CheckResourceContinuity - Walk one resource's entries in timestamp order, ties by audit ID
+	- CheckContinuityByDateRange - Same check for every resource changed in a date range
+
+Walk rules (same entries as ReconstructResourceAt, resources.go):
+	- Only SUCCESS entries of mutating action types take part
+	- The "current state" is the last NewValue seen, DELETE clears it
+	- JSON is compared canonically (key order and whitespace do not matter)
+
+Break kinds:
+	OLD_VALUE_MISMATCH  - OldValue differs from the previous entry's NewValue
+	MISSING_PREDECESSOR - OldValue claims a prior state but no earlier entry recorded one
+	                      (first entry of the resource, or first entry after a DELETE)
+	UNEXPLAINED_JUMP    - The resource had a recorded state but the entry replaces it (NewValue or DELETE)
+	                      without stating OldValue, so the transition cannot be checked.
+	                      An entry with neither value leaves the state alone, as in ReconstructResourceAt
+*/
+
+// Continuity break kinds
+const (
+	ContinuityOldValueMismatch   = "OLD_VALUE_MISMATCH"
+	ContinuityMissingPredecessor = "MISSING_PREDECESSOR"
+	ContinuityUnexplainedJump    = "UNEXPLAINED_JUMP"
+)
+
+// maxContinuitySweepResources bounds CheckContinuityByDateRange, narrow the range beyond this
+const maxContinuitySweepResources = 500
+
+/*
+--- CHECK RESOURCE CONTINUITY ---
+Reports every break in one resource's chain of OldValue -> NewValue transitions
+*/
+func (c *AuditContract) CheckResourceContinuity(ctx contractapi.TransactionContextInterface,
+	resourceType string, resourceId string) (*ContinuityReport, error) {
+
+	lg := txLog(ctx, "CheckResourceContinuity")
+	lg.Enter("resourceType", resourceType, "resourceId", resourceId)
+
+	// Input validation
+	if resourceType == "" {
+		return nil, invalidArgument("resourceType", "resourceType is required")
+	}
+	if resourceId == "" {
+		return nil, invalidArgument("resourceId", "resourceId is required")
+	}
+
+	report, err := c.checkContinuity(ctx, lg, resourceType, resourceId, mutatingActions{})
+	if err != nil {
+		lg.Fail("ERROR", err, "resourceId", resourceId)
+		return nil, err
+	}
+
+	lg.Done("resourceId", resourceId, "checked", report.EntriesChecked, "breaks", len(report.Breaks))
+	return report, nil
+}
+
+/*
+--- CHECK CONTINUITY BY DATE RANGE ---
+Batch sweep: checks the full history of every resource with a mutating entry in the range
+Params: startDate and endDate should be Unix timestamps in milliseconds
+Returns: the sweep summary, Reports only lists resources with breaks
+*/
+func (c *AuditContract) CheckContinuityByDateRange(ctx contractapi.TransactionContextInterface,
+	startDate int64, endDate int64) (*ContinuitySweep, error) {
+
+	lg := txLog(ctx, "CheckContinuityByDateRange")
+	lg.Enter("startDate", startDate, "endDate", endDate)
+
+	entries, err := c.QueryAuditsByDateRange(ctx, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Distinct resources changed in the range, sorted so every peer walks them in the same order
+	mutating := mutatingActions{}
+	seen := map[[2]string]bool{}
+	var resources [][2]string
+	for _, entry := range entries {
+		if entry.ResourceType == "" || entry.ResourceID == "" {
+			continue
+		}
+		changesState, err := mutating.changesState(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		resource := [2]string{entry.ResourceType, entry.ResourceID}
+		if !changesState || seen[resource] {
+			continue
+		}
+		seen[resource] = true
+		resources = append(resources, resource)
+	}
+	if len(resources) > maxContinuitySweepResources {
+		return nil, invalidArgument("endDate", "%d resources changed in range, sweep is limited to %d: narrow the date range",
+			len(resources), maxContinuitySweepResources)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i][0] != resources[j][0] {
+			return resources[i][0] < resources[j][0]
+		}
+		return resources[i][1] < resources[j][1]
+	})
+
+	sweep := &ContinuitySweep{
+		StartDate: startDate,
+		EndDate:   endDate,
+		Reports:   []*ContinuityReport{},
+	}
+	for _, resource := range resources {
+		report, err := c.checkContinuity(ctx, lg, resource[0], resource[1], mutating)
+		if err != nil {
+			lg.Fail("ERROR", err, "resourceId", resource[1])
+			return nil, err
+		}
+		sweep.ResourcesChecked++
+		if !report.Continuous {
+			sweep.ResourcesWithBreaks++
+			sweep.Reports = append(sweep.Reports, report)
+		}
+	}
+
+	lg.Done("checked", sweep.ResourcesChecked, "withBreaks", sweep.ResourcesWithBreaks)
+	return sweep, nil
+}
+
+// checkContinuity walks one resource's entries and collects its breaks
+func (c *AuditContract) checkContinuity(ctx contractapi.TransactionContextInterface, lg *txLogger,
+	resourceType string, resourceId string, mutating mutatingActions) (*ContinuityReport, error) {
+
+	entries, err := c.queryResourceEntries(ctx, lg, resourceType, resourceId, -1)
+	if err != nil {
+		return nil, err
+	}
+	// The verdict must not depend on the query plan, so the walk fixes its own order
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].TimeStamp != entries[j].TimeStamp {
+			return entries[i].TimeStamp < entries[j].TimeStamp
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	report := &ContinuityReport{
+		ResourceType: resourceType,
+		ResourceID:   resourceId,
+		Breaks:       []ContinuityBreak{},
+	}
+
+	// previous = last entry that left a recorded state, nil if there is none
+	var previous *AuditEntry
+	for _, entry := range entries {
+		changesState, err := mutating.changesState(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !changesState {
+			continue
+		}
+		report.EntriesChecked++
+
+		if brk := continuityBreak(previous, entry); brk != nil {
+			report.Breaks = append(report.Breaks, *brk)
+		}
+
+		switch {
+		case entry.Action == "DELETE":
+			previous = nil
+		case entry.NewValue != "":
+			previous = entry
+		}
+	}
+
+	report.Continuous = len(report.Breaks) == 0
+	return report, nil
+}
+
+// continuityBreak compares an entry's OldValue with the state the previous entry left, nil if they agree
+func continuityBreak(previous *AuditEntry, entry *AuditEntry) *ContinuityBreak {
+	brk := &ContinuityBreak{
+		AuditID:   entry.ID,
+		Timestamp: entry.TimeStamp,
+		Actual:    entry.OldValue,
+	}
+	if previous != nil {
+		brk.PreviousAuditID = previous.ID
+		brk.Expected = previous.NewValue
+	}
+
+	// Without a snapshot (and not a DELETE) the entry leaves the state unchanged, as in replayResourceEntries
+	replaces := entry.NewValue != "" || entry.Action == "DELETE"
+
+	switch {
+	case previous == nil && entry.OldValue != "":
+		brk.Kind = ContinuityMissingPredecessor
+		brk.Message = "oldValue is set but no earlier entry recorded a state for this resource"
+	case previous != nil && entry.OldValue == "" && !replaces:
+		return nil
+	case previous != nil && entry.OldValue == "":
+		brk.Kind = ContinuityUnexplainedJump
+		brk.Message = fmt.Sprintf("state recorded by %s is replaced without an oldValue", previous.ID)
+	case previous != nil && !sameJSON(entry.OldValue, previous.NewValue):
+		brk.Kind = ContinuityOldValueMismatch
+		brk.Message = fmt.Sprintf("oldValue does not match the newValue of %s", previous.ID)
+	default:
+		return nil
+	}
+	return brk
+}
+
+// sameJSON compares two JSON documents canonically, falling back to exact text for non-JSON
+func sameJSON(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	docA, errA := decodeJSONNumbers(a)
+	docB, errB := decodeJSONNumbers(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	canonicalA, errA := compactJSON(docA)
+	canonicalB, errB := compactJSON(docB)
+	return errA == nil && errB == nil && canonicalA == canonicalB
+}
@@ -0,0 +1,199 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ diff.go computes the structured change list LogAudit stores on AuditEntry.Changes
+
+When both OldValue and NewValue are JSON, the entry gets an RFC 6902 style patch:
+	OldValue: {"status":"ACTIVE","holder":"bob"}
+	NewValue: {"status":"REVOKED","holder":"bob","reason":"fraud"}
+	Changes:  [{"op":"add","path":"/reason","value":"\"fraud\""},
+	           {"op":"replace","path":"/status","value":"\"REVOKED\"","oldValue":"\"ACTIVE\""}]
+
+	- path is a JSON Pointer (RFC 6901, "~" -> "~0", "/" -> "~1")
+	- value / oldValue hold compact JSON text, so strings keep their quotes
+	- object keys are walked in sorted order, so every peer computes the exact same patch
+	- arrays are compared index by index, extra old elements are removed from the end first
+	  (RFC 6902 applies operations in order, removing from the end keeps indexes valid)
+
+QueryAuditsByFieldChange (audit.go) matches entries on changes.path / changes.value
+*/
+
+// Patch operations
+const (
+	patchOpAdd     = "add"
+	patchOpRemove  = "remove"
+	patchOpReplace = "replace"
+)
+
+// computeChanges diffs two JSON documents, nil if either side is empty
+func computeChanges(oldValue string, newValue string) ([]FieldChange, error) {
+	if oldValue == "" || newValue == "" {
+		return nil, nil
+	}
+
+	oldDoc, err := decodeJSONNumbers(oldValue)
+	if err != nil {
+		return nil, internalError("failed to decode oldValue: %v", err)
+	}
+	newDoc, err := decodeJSONNumbers(newValue)
+	if err != nil {
+		return nil, internalError("failed to decode newValue: %v", err)
+	}
+
+	changes := []FieldChange{}
+	err = diffValues("", oldDoc, newDoc, &changes)
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// diffValues appends the operations turning oldDoc into newDoc at path
+func diffValues(path string, oldDoc interface{}, newDoc interface{}, changes *[]FieldChange) error {
+	oldObject, oldIsObject := oldDoc.(map[string]interface{})
+	newObject, newIsObject := newDoc.(map[string]interface{})
+	if oldIsObject && newIsObject {
+		return diffObjects(path, oldObject, newObject, changes)
+	}
+
+	oldArray, oldIsArray := oldDoc.([]interface{})
+	newArray, newIsArray := newDoc.([]interface{})
+	if oldIsArray && newIsArray {
+		return diffArrays(path, oldArray, newArray, changes)
+	}
+
+	oldJSON, err := compactJSON(oldDoc)
+	if err != nil {
+		return err
+	}
+	newJSON, err := compactJSON(newDoc)
+	if err != nil {
+		return err
+	}
+	if oldJSON != newJSON {
+		*changes = append(*changes, FieldChange{Op: patchOpReplace, Path: path, Value: newJSON, OldValue: oldJSON})
+	}
+	return nil
+}
+
+func diffObjects(path string, oldObject map[string]interface{}, newObject map[string]interface{}, changes *[]FieldChange) error {
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "/" + escapePointerToken(key)
+		oldChild, inOld := oldObject[key]
+		newChild, inNew := newObject[key]
+
+		switch {
+		case inOld && !inNew:
+			oldJSON, err := compactJSON(oldChild)
+			if err != nil {
+				return err
+			}
+			*changes = append(*changes, FieldChange{Op: patchOpRemove, Path: childPath, OldValue: oldJSON})
+		case !inOld && inNew:
+			newJSON, err := compactJSON(newChild)
+			if err != nil {
+				return err
+			}
+			*changes = append(*changes, FieldChange{Op: patchOpAdd, Path: childPath, Value: newJSON})
+		default:
+			err := diffValues(childPath, oldChild, newChild, changes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func diffArrays(path string, oldArray []interface{}, newArray []interface{}, changes *[]FieldChange) error {
+	common := len(oldArray)
+	if len(newArray) < common {
+		common = len(newArray)
+	}
+
+	for i := 0; i < common; i++ {
+		err := diffValues(fmt.Sprintf("%s/%d", path, i), oldArray[i], newArray[i], changes)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove surplus old elements from the end so earlier indexes stay valid
+	for i := len(oldArray) - 1; i >= common; i-- {
+		oldJSON, err := compactJSON(oldArray[i])
+		if err != nil {
+			return err
+		}
+		*changes = append(*changes, FieldChange{Op: patchOpRemove, Path: fmt.Sprintf("%s/%d", path, i), OldValue: oldJSON})
+	}
+
+	for i := common; i < len(newArray); i++ {
+		newJSON, err := compactJSON(newArray[i])
+		if err != nil {
+			return err
+		}
+		*changes = append(*changes, FieldChange{Op: patchOpAdd, Path: fmt.Sprintf("%s/%d", path, i), Value: newJSON})
+	}
+	return nil
+}
+
+// normalizePointer accepts "/status" or the shorthand "status" / "credential.status"
+func normalizePointer(path string) string {
+	if path == "" || strings.HasPrefix(path, "/") {
+		return path
+	}
+	tokens := strings.Split(path, ".")
+	for i, token := range tokens {
+		tokens[i] = escapePointerToken(token)
+	}
+	return "/" + strings.Join(tokens, "/")
+}
+
+// escapePointerToken escapes one JSON Pointer reference token (RFC 6901)
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// decodeJSONNumbers decodes JSON keeping numbers exactly as written
+func decodeJSONNumbers(value string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var doc interface{}
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// compactJSON encodes a decoded value as compact JSON with sorted object keys
+func compactJSON(value interface{}) (string, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return "", internalError("failed to encode JSON value: %v", err)
+	}
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
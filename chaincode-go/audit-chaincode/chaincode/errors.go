@@ -0,0 +1,208 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ errors.go is the typed error model every contract function returns:
+	- ContractError - Code + message + optional reason and field details, JSON on the wire
+	- notFound, alreadyExists, invalidArgument, permissionDenied, failedPrecondition, internalError
+	- wrapError - Adds context to an error and keeps its code
+	- asContractError (helper) - Any error (typed or not) as a ContractError, used by Guard
+	- ParseTransactionError - The envelope of a failed transaction, for off-chain clients
+
+Codes (clients branch on these, never on the message text):
+	NOT_FOUND            - The audit entry, user, session, ... does not exist        -> HTTP 404
+	ALREADY_EXISTS       - The ID (or unique email / username) is taken               -> HTTP 409
+	INVALID_ARGUMENT     - An argument is missing or malformed, details name the field -> HTTP 400
+	PERMISSION_DENIED    - The caller may not do this (not an active ADMIN, ...)     -> HTTP 403
+	FAILED_PRECONDITION  - Valid request, wrong state: closed session, suspended user,
+	                       last active admin (reason LAST_ACTIVE_ADMIN), ...          -> HTTP 409
+	INTERNAL             - Ledger, encoding or unexpected failure, safe to retry       -> HTTP 500
+
+contractapi only passes err.Error() to the peer, so ContractError.Error() is the JSON payload itself.
+Guard (middleware.go) merges it into the envelope of the failed transaction:
+	{"code":"INVALID_ARGUMENT","message":"id is required","details":[{"field":"id","description":"id is required"}],
+	 "function":"AuditContract:LogAudit","txId":"3f1c..."}
+Errors that are not ContractErrors (contractapi routing and argument conversion, plain errors)
+are classified by Guard, anything else is INTERNAL.
+*/
+
+// ErrorCode classifies a ContractError
+type ErrorCode string
+
+// Error codes, see MODULE NOTES
+const (
+	CodeNotFound           ErrorCode = "NOT_FOUND"
+	CodeAlreadyExists      ErrorCode = "ALREADY_EXISTS"
+	CodeInvalidArgument    ErrorCode = "INVALID_ARGUMENT"
+	CodePermissionDenied   ErrorCode = "PERMISSION_DENIED"
+	CodeFailedPrecondition ErrorCode = "FAILED_PRECONDITION"
+	CodeInternal           ErrorCode = "INTERNAL"
+)
+
+// ContractError is the error every contract function returns
+type ContractError struct {
+	Code    ErrorCode        `json:"code"`              // NOT_FOUND, ALREADY_EXISTS, ...
+	Message string           `json:"message"`           // Human readable, not stable
+	Reason  string           `json:"reason,omitempty"`  // Finer cause within the code, e.g. LAST_ACTIVE_ADMIN
+	Details []FieldViolation `json:"details,omitempty"` // Offending arguments (INVALID_ARGUMENT)
+
+	logMessage string // Message with its pii(...) arguments redacted, "" if it was not built here
+}
+
+// FieldViolation names one argument that failed validation
+type FieldViolation struct {
+	Field       string `json:"field"`       // Parameter name as in the function signature, newValue#/status inside JSON payloads
+	Description string `json:"description"` // What is wrong with it
+}
+
+// Error returns the JSON payload, which is what reaches the peer and the client
+func (e *ContractError) Error() string {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return string(e.Code) + ": " + e.Message
+	}
+	return string(payload)
+}
+
+// LogValue logs the code and message as fields instead of the JSON payload, the message redacted (logging.go)
+func (e *ContractError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("code", string(e.Code)),
+		slog.Any("message", logText{clear: e.Message, redacted: e.redactedMessage()}))
+}
+
+// redactedMessage is the message as logged, personal data masked
+func (e *ContractError) redactedMessage() string {
+	if e.logMessage != "" {
+		return e.logMessage
+	}
+	return redactText(e.Message)
+}
+
+func newContractError(code ErrorCode, format string, args ...interface{}) *ContractError {
+	return &ContractError{
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+		logMessage: fmt.Sprintf(format, redactArgs(args)...),
+	}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return newContractError(CodeNotFound, format, args...)
+}
+
+func alreadyExists(format string, args ...interface{}) error {
+	return newContractError(CodeAlreadyExists, format, args...)
+}
+
+// invalidArgument reports a bad argument, the message doubles as the field's description
+func invalidArgument(field string, format string, args ...interface{}) error {
+	e := newContractError(CodeInvalidArgument, format, args...)
+	e.Details = []FieldViolation{{Field: field, Description: e.Message}}
+	return e
+}
+
+func permissionDenied(format string, args ...interface{}) error {
+	return newContractError(CodePermissionDenied, format, args...)
+}
+
+func failedPrecondition(format string, args ...interface{}) error {
+	return newContractError(CodeFailedPrecondition, format, args...)
+}
+
+func internalError(format string, args ...interface{}) error {
+	return newContractError(CodeInternal, format, args...)
+}
+
+// wrapError prefixes err's message with context, keeping its code, reason and details (INTERNAL if untyped)
+func wrapError(err error, format string, args ...interface{}) error {
+	inner := asContractError(err)
+	wrapped := *inner
+	wrapped.Message = fmt.Sprintf(format, args...) + ": " + inner.Message
+	wrapped.logMessage = fmt.Sprintf(format, redactArgs(args)...) + ": " + inner.redactedMessage()
+	return &wrapped
+}
+
+// errorCode returns the code of err, INTERNAL for untyped errors
+func errorCode(err error) ErrorCode {
+	return asContractError(err).Code
+}
+
+/*
+--- asContractError (helper) ---
+Finds the ContractError in err's chain, or decodes one from its text (errors that crossed
+contractapi), or classifies an untyped error
+*/
+func asContractError(err error) *ContractError {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		return contractErr
+	}
+	var invErr *InvariantError
+	if errors.As(err, &invErr) {
+		return invErr.contractError()
+	}
+	return classifyMessage(err.Error())
+}
+
+// classifyMessage turns an error message into a ContractError (see asContractError)
+func classifyMessage(message string) *ContractError {
+	var decoded ContractError
+	if strings.HasPrefix(message, `{"code":`) && json.Unmarshal([]byte(message), &decoded) == nil && decoded.Code != "" {
+		return &decoded
+	}
+
+	switch {
+	// contractapi routing, see ContractChaincode.Invoke and unknownTransaction
+	case strings.HasPrefix(message, "Contract not found with name "),
+		strings.HasPrefix(message, "Function ") && strings.Contains(message, " not found in contract "):
+		return &ContractError{Code: CodeNotFound, Message: message}
+	case message == "Blank function name passed":
+		return &ContractError{Code: CodeInvalidArgument, Message: message}
+	// contractapi argument count and conversion
+	case strings.HasPrefix(message, "incorrect number of params"),
+		strings.HasPrefix(message, "error managing parameter"):
+		return &ContractError{Code: CodeInvalidArgument, Message: message}
+	}
+	return &ContractError{Code: CodeInternal, Message: message}
+}
+
+/*
+--- ParseTransactionError ---
+Finds the TransactionError envelope in the error an off-chain client got for a failed transaction.
+The gateway wraps the chaincode message in its own text
+
+	evaluate CheckpointContract:GetLatestCheckpoint: rpc error: code = Unknown desc = ...
+	chaincode response 500, {"code":"NOT_FOUND","message":"no checkpoints have been created",...}
+
+so the envelope is decoded from its first {"code": on. ok is false for errors without one
+(connection, endorsement or commit failures).
+*/
+func ParseTransactionError(err error) (envelope *TransactionError, ok bool) {
+	if err == nil {
+		return nil, false
+	}
+	message := err.Error()
+	start := strings.Index(message, `{"code":`)
+	if start < 0 {
+		return nil, false
+	}
+	envelope = &TransactionError{}
+	if json.NewDecoder(strings.NewReader(message[start:])).Decode(envelope) != nil || envelope.Code == "" {
+		return nil, false
+	}
+	return envelope, true
+}
+
+// HasErrorCode reports whether err is a failed transaction with the given code
+func HasErrorCode(err error, code ErrorCode) bool {
+	envelope, ok := ParseTransactionError(err)
+	return ok && envelope.Code == code
+}
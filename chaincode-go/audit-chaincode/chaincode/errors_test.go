@@ -0,0 +1,111 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestContractErrorPayload(t *testing.T) {
+	err := invalidArgument("email", "invalid email: %s", "not-an-email")
+
+	var payload map[string]interface{}
+	if json.Unmarshal([]byte(err.Error()), &payload) != nil {
+		t.Fatalf("Error() is not JSON: %s", err.Error())
+	}
+	want := `{"code":"INVALID_ARGUMENT","message":"invalid email: not-an-email","details":[{"field":"email","description":"invalid email: not-an-email"}]}`
+	if err.Error() != want {
+		t.Errorf("payload = %s, want %s", err.Error(), want)
+	}
+	if errorCode(err) != CodeInvalidArgument {
+		t.Errorf("code = %s", errorCode(err))
+	}
+}
+
+func TestWrapErrorKeepsCode(t *testing.T) {
+	err := wrapError(notFound("User  ID=%s does not exist in ledger", "u1"), "failed to get session user %s", "u1")
+	contractErr := asContractError(err)
+	if contractErr.Code != CodeNotFound ||
+		contractErr.Message != "failed to get session user u1: User  ID=u1 does not exist in ledger" {
+		t.Errorf("wrapped = %+v", contractErr)
+	}
+
+	// The original is not modified
+	inner := invalidArgument("id", "id is required")
+	wrapError(inner, "outer")
+	if asContractError(inner).Message != "id is required" {
+		t.Errorf("wrapError changed its argument: %s", inner)
+	}
+
+	if code := errorCode(wrapError(errors.New("connection reset"), "failed to read")); code != CodeInternal {
+		t.Errorf("untyped wrapped code = %s, want INTERNAL", code)
+	}
+}
+
+func TestInvariantErrorCode(t *testing.T) {
+	err := fmt.Errorf("demote: %w", &InvariantError{Invariant: InvariantLastActiveAdmin, UserID: "u1", Message: "last admin"})
+
+	contractErr := asContractError(err)
+	if contractErr.Code != CodeFailedPrecondition || contractErr.Reason != InvariantLastActiveAdmin {
+		t.Errorf("invariant = %+v", contractErr)
+	}
+
+	// Crossing contractapi keeps only the text, which decodes to the same error
+	decoded := classifyMessage((&InvariantError{Invariant: InvariantSelfModification, UserID: "u1", Message: "own role"}).Error())
+	if decoded.Code != CodeFailedPrecondition || decoded.Reason != InvariantSelfModification {
+		t.Errorf("decoded invariant = %+v", decoded)
+	}
+}
+
+func TestClassifyMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		code    ErrorCode
+	}{
+		{"Contract not found with name Nope", CodeNotFound},
+		{"Function Nope not found in contract AuditContract", CodeNotFound},
+		{"Blank function name passed", CodeInvalidArgument},
+		{"incorrect number of params. Expected 1, received 0", CodeInvalidArgument},
+		{"error managing parameter param0. conversion error. cannot convert passed value x to int64", CodeInvalidArgument},
+		{"GET_STATE failed: transaction ID: tx1: connection lost", CodeInternal},
+		{`{"code":"ALREADY_EXISTS","message":"user u1 already exists"}`, CodeAlreadyExists},
+		{`{"code":`, CodeInternal},
+	}
+	for _, test := range tests {
+		if got := classifyMessage(test.message).Code; got != test.code {
+			t.Errorf("classifyMessage(%q) = %s, want %s", test.message, got, test.code)
+		}
+	}
+}
+
+func TestSchemaViolationDetails(t *testing.T) {
+	schema := `{"type":"object","required":["status"],"properties":{"status":{"enum":["ACTIVE","REVOKED"]},"count":{"type":"integer"}}}`
+	err := validateAgainstSchema("newValue", `{"status":"LOST","count":"x"}`, schema)
+
+	contractErr := asContractError(err)
+	if contractErr.Code != CodeInvalidArgument || len(contractErr.Details) != 2 {
+		t.Fatalf("schema error = %+v", contractErr)
+	}
+	if contractErr.Details[0].Field != "newValue#/count" || contractErr.Details[1].Field != "newValue#/status" {
+		t.Errorf("details = %+v", contractErr.Details)
+	}
+}
+
+func TestParseTransactionError(t *testing.T) {
+	gatewayErr := errors.New(`evaluate CheckpointContract:GetLatestCheckpoint: rpc error: code = Unknown desc = chaincode response 500, ` +
+		`{"code":"NOT_FOUND","message":"no checkpoints have been created","function":"CheckpointContract:GetLatestCheckpoint","txId":"tx1"}`)
+	envelope, ok := ParseTransactionError(gatewayErr)
+	if !ok || envelope.Code != CodeNotFound || envelope.TxID != "tx1" {
+		t.Fatalf("envelope = %+v, %v", envelope, ok)
+	}
+	if !HasErrorCode(gatewayErr, CodeNotFound) || HasErrorCode(gatewayErr, CodeAlreadyExists) {
+		t.Error("HasErrorCode does not match the envelope code")
+	}
+
+	for _, err := range []error{nil, errors.New("connection refused"), errors.New(`desc = {"code":`), errors.New(`{"code":""}`)} {
+		if _, ok := ParseTransactionError(err); ok {
+			t.Errorf("ParseTransactionError(%v) found an envelope", err)
+		}
+	}
+}
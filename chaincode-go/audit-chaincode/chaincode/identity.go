@@ -0,0 +1,118 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ identity.go resolves WHO submitted a transaction, shared by AuditContract and UserContract
+
+Every transaction is signed by a client certificate, ctx.GetClientIdentity() exposes it:
+	- GetMSPID()                 - Org of the caller, e.g. Org1MSP
+	- GetX509Certificate()       - The signing certificate (CN = enrollment ID)
+	- GetAttributeValue("userId") - Custom attribute added at enrollment by Fabric CA
+
+Caller ID resolution order:
+	1. "userId" certificate attribute  -> "user-alice" (matches User.ID on the ledger)
+	2. MSP ID + certificate CN         -> "Org1MSP:Admin@org1.example.com"
+
+	Enroll app users with the attribute so their caller ID matches their User record:
+		fabric-ca-client register --id.name alice --id.attrs 'userId=user-alice:ecert'
+	Without the attribute the caller cannot be the target of its own role or status change,
+	so the SELF_MODIFICATION guard does not apply to it (user_guards.go).
+
+requireAdmin gates admin-only transactions (registry changes, ...):
+	- caller certificate carries the "admin" OU (org admins, e.g. Admin@org1.example.com), or
+	- caller ID resolves to an ACTIVE user with role ADMIN
+*/
+
+// callerUserIDAttribute is the certificate attribute that links a client certificate to a User.ID
+const callerUserIDAttribute = "userId"
+
+// getCallerID returns a stable identifier for whoever submitted the transaction
+func getCallerID(ctx contractapi.TransactionContextInterface) (string, error) {
+	// The before hook (middleware.go) resolved the caller once for the whole transaction
+	if txCtx, ok := ctx.(*TransactionContext); ok && txCtx.callerResolved {
+		return txCtx.callerID, txCtx.callerErr
+	}
+
+	clientIdentity := ctx.GetClientIdentity()
+
+	// contractapi stores a nil *cid.ClientID when the creator could not be parsed
+	if clientID, ok := clientIdentity.(*cid.ClientID); clientIdentity == nil || (ok && clientID == nil) {
+		return "", permissionDenied("failed to resolve caller: no client identity on transaction")
+	}
+
+	// Prefer the userId attribute, it maps straight onto a User record
+	userID, found, err := clientIdentity.GetAttributeValue(callerUserIDAttribute)
+	if err != nil {
+		return "", internalError("failed to read caller attribute %s: %v", callerUserIDAttribute, err)
+	}
+	if found && userID != "" {
+		return userID, nil
+	}
+
+	// Fall back to MSP ID + certificate common name
+	mspID, err := clientIdentity.GetMSPID()
+	if err != nil {
+		return "", internalError("failed to read caller MSP ID: %v", err)
+	}
+	cert, err := clientIdentity.GetX509Certificate()
+	if err != nil {
+		return "", internalError("failed to read caller certificate: %v", err)
+	}
+	if cert == nil {
+		return mspID, nil
+	}
+
+	return fmt.Sprintf("%s:%s", mspID, cert.Subject.CommonName), nil
+}
+
+// requireAdmin allows the call only for an ACTIVE ADMIN user, or an org admin certificate (Fabric NodeOU "admin")
+func requireAdmin(ctx contractapi.TransactionContextInterface) error {
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Org admin certificates (e.g. Admin@org1.example.com) are always allowed, they bootstrap the system
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err == nil && cert != nil {
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == "admin" {
+				return nil
+			}
+		}
+	}
+
+	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{callerID})
+	if err != nil {
+		return internalError("failed to create composite key for caller %s: %v", pii(callerID), err)
+	}
+	userJSON, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return internalError("failed to read caller %s: %v", pii(callerID), err)
+	}
+	if userJSON != nil {
+		var user User
+		err = json.Unmarshal(userJSON, &user)
+		if err != nil {
+			return internalError("failed to unmarshal caller %s: %v", pii(callerID), err)
+		}
+		nowMillis, err := txTimeMillis(ctx)
+		if err != nil {
+			return err
+		}
+		normalizeUserStatus(&user, nowMillis)
+		if user.Role == "ADMIN" && user.Status == UserStatusActive {
+			return nil
+		}
+	}
+
+	return permissionDenied("caller %s is not an active ADMIN", pii(callerID))
+}
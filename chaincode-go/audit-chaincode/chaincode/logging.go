@@ -0,0 +1,280 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ logging.go is the structured (log/slog) logging shared by every contract
+
+Configuration (ConfigureLogging, called from main.go):
+	CHAINCODE_LOG_LEVEL   debug | info (default) | warn | error
+	CHAINCODE_LOG_FORMAT  json (default) | text
+
+Every contract function logs through txLog(ctx, "Function"), or ctxLog(ctx) in shared helpers,
+which return the logger the before hook started for the transaction (middleware.go). It carries
+the same fields on every line: function, txId, channel, mspId. Levels:
+	- Enter   DEBUG  "ENTER" with the inputs
+	- Done    INFO   "SUCCESS" with the duration
+	- Reject  WARN   INVALID / NOT_FOUND / DENIED, the caller's fault, with the duration
+	- Fail    ERROR  ledger or encoding failures, with the duration
+
+Redaction (every level except debug): values logged under these keys never reach the log in clear
+	- userId, callerId, createdBy, updatedBy, resourceId, sessionId, username, query, filter, bookmark
+	  -> "h:" + first 12 hex chars of SHA-256, so the same ID still correlates across lines
+	- email      -> first character + domain, a***@example.com
+	- ipAddress  -> network only, 10.1.0.0/16 (IPv4) or /48 (IPv6)
+Use these keys for any new field that identifies a person. Setting CHAINCODE_LOG_LEVEL=debug
+explicitly turns redaction off, for troubleshooting on a dev network only.
+
+Errors are logged redacted too: the client gets the message in clear, the log line gets
+	- ContractError messages with every argument marked pii(...) / piiEmail(...) redacted as above:
+		notFound("user %s does not exist", pii(id))
+	- anything else logged under a key (plain errors, decoded messages) with emails and IPv4
+	  addresses in the text masked
+Mark every user, caller or session ID, username and email that goes into an error message.
+*/
+
+// Logging environment variables
+const (
+	envLogLevel  = "CHAINCODE_LOG_LEVEL"
+	envLogFormat = "CHAINCODE_LOG_FORMAT"
+)
+
+// hashedLogKeys are hashed, emailLogKeys and ipLogKeys masked, unless debug logging is on
+var (
+	hashedLogKeys = map[string]bool{
+		"userId": true, "callerId": true, "createdBy": true, "updatedBy": true,
+		"resourceId": true, "sessionId": true, "username": true, "query": true,
+		"filter": true, "bookmark": true,
+	}
+	emailLogKeys = map[string]bool{"email": true}
+	ipLogKeys    = map[string]bool{"ipAddress": true}
+)
+
+// logger is the chaincode's logger, JSON at INFO with redaction until ConfigureLogging runs
+var logger = newLogger(os.Stderr, slog.LevelInfo, "json")
+
+// ConfigureLogging sets the level and format from the environment and makes the chaincode
+// logger the slog / log default, so every line in the process has the same shape
+func ConfigureLogging(getenv func(string) string) error {
+	level := slog.LevelInfo
+	levelName := strings.TrimSpace(getenv(envLogLevel))
+	if levelName != "" {
+		err := level.UnmarshalText([]byte(levelName))
+		if err != nil {
+			return fmt.Errorf("invalid %s %q, expected debug, info, warn or error", envLogLevel, levelName)
+		}
+	}
+
+	format := strings.ToLower(strings.TrimSpace(getenv(envLogFormat)))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "text" {
+		return fmt.Errorf("invalid %s %q, expected json or text", envLogFormat, format)
+	}
+
+	logger = newLogger(os.Stderr, level, format)
+	slog.SetDefault(logger)
+	return nil
+}
+
+// newLogger builds a handler for level and format, redacting unless level is debug
+func newLogger(w io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if level > slog.LevelDebug {
+		options.ReplaceAttr = redactAttr
+	}
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(w, options))
+	}
+	return slog.New(slog.NewJSONHandler(w, options))
+}
+
+// redactAttr hashes or masks personal data (see MODULE NOTES)
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	switch value := attr.Value.Any().(type) {
+	case logText:
+		return slog.String(attr.Key, value.redacted)
+	case error:
+		return slog.String(attr.Key, redactText(value.Error()))
+	}
+	switch {
+	case hashedLogKeys[attr.Key]:
+		return slog.String(attr.Key, redactHash(attr.Value.String()))
+	case emailLogKeys[attr.Key]:
+		return slog.String(attr.Key, redactEmail(attr.Value.String()))
+	case ipLogKeys[attr.Key]:
+		return slog.String(attr.Key, redactIP(attr.Value.String()))
+	}
+	return attr
+}
+
+func redactHash(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return "h:" + hex.EncodeToString(sum[:])[:12]
+}
+
+func redactEmail(value string) string {
+	local, domain, found := strings.Cut(value, "@")
+	if !found || local == "" {
+		return redactHash(value)
+	}
+	return local[:1] + "***@" + domain
+}
+
+func redactIP(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return redactHash(value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(16, 32)), Mask: net.CIDRMask(16, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// emailPattern and ipv4Pattern find personal data in free text (redactText)
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	ipv4Pattern  = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
+)
+
+// redactText masks the emails and IPv4 addresses in text nobody marked up
+func redactText(text string) string {
+	text = emailPattern.ReplaceAllStringFunc(text, redactEmail)
+	return ipv4Pattern.ReplaceAllStringFunc(text, redactIP)
+}
+
+// personalArg is an error message argument that identifies a person, see pii
+type personalArg struct {
+	value  string
+	redact func(string) string
+}
+
+// String is the clear value, what the client receives
+func (p personalArg) String() string {
+	return p.value
+}
+
+// pii marks a user, caller or session ID or a username in an error message, hashed in the log
+func pii(value string) personalArg {
+	return personalArg{value: value, redact: redactHash}
+}
+
+// piiEmail marks an email in an error message, masked in the log
+func piiEmail(value string) personalArg {
+	return personalArg{value: value, redact: redactEmail}
+}
+
+// redactArgs replaces the personal arguments of an error message with their redacted form
+func redactArgs(args []interface{}) []interface{} {
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if p, ok := arg.(personalArg); ok {
+			arg = p.redact(p.value)
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+// logText is a message with a clear and a redacted form, redactAttr logs the redacted one
+type logText struct {
+	clear    string
+	redacted string
+}
+
+// MarshalText is the clear form, used when debug logging turned redaction off
+func (t logText) MarshalText() ([]byte, error) {
+	return []byte(t.clear), nil
+}
+
+// txLogger logs for one contract function call
+type txLogger struct {
+	*slog.Logger
+	start time.Time
+}
+
+// txLog returns the transaction's logger, the one the before hook set up (middleware.go)
+// or, outside the middleware, a new one for function
+func txLog(ctx contractapi.TransactionContextInterface, function string) *txLogger {
+	if tc, ok := ctx.(*TransactionContext); ok && tc.log != nil {
+		return tc.log
+	}
+	return newTxLog(ctx, function)
+}
+
+// ctxLog is txLog for helpers that do not know the function name, it falls back to the called one
+func ctxLog(ctx contractapi.TransactionContextInterface) *txLogger {
+	function := ""
+	if stub := ctx.GetStub(); stub != nil {
+		function, _ = stub.GetFunctionAndParameters()
+	}
+	return txLog(ctx, function)
+}
+
+// newTxLog returns a logger carrying function, txId, channel and mspId
+func newTxLog(ctx contractapi.TransactionContextInterface, function string) *txLogger {
+	args := []any{"function", function}
+	if stub := ctx.GetStub(); stub != nil {
+		args = append(args, "txId", stub.GetTxID(), "channel", stub.GetChannelID())
+	}
+	identity := ctx.GetClientIdentity()
+	// contractapi stores a nil *cid.ClientID when the creator could not be parsed (see getCallerID)
+	if clientID, ok := identity.(*cid.ClientID); identity != nil && !(ok && clientID == nil) {
+		mspID, err := identity.GetMSPID()
+		if err == nil {
+			args = append(args, "mspId", mspID)
+		}
+	}
+	return &txLogger{Logger: logger.With(args...), start: time.Now()}
+}
+
+// Enter logs the call and its inputs at DEBUG
+func (l *txLogger) Enter(args ...any) {
+	l.Debug("ENTER", args...)
+}
+
+// Done logs a successful call at INFO with its duration
+func (l *txLogger) Done(args ...any) {
+	l.Info("SUCCESS", append(args, l.duration())...)
+}
+
+// Reject logs a call refused because of its input or the caller (INVALID, NOT_FOUND, ...) at WARN
+func (l *txLogger) Reject(msg string, err error, args ...any) {
+	l.Warn(msg, l.withError(args, err)...)
+}
+
+// Fail logs a call that failed on the ledger or while encoding at ERROR
+func (l *txLogger) Fail(msg string, err error, args ...any) {
+	l.Error(msg, l.withError(args, err)...)
+}
+
+func (l *txLogger) withError(args []any, err error) []any {
+	if err != nil {
+		args = append(args, "err", err)
+	}
+	return append(args, l.duration())
+}
+
+func (l *txLogger) duration() slog.Attr {
+	return slog.Duration("duration", time.Since(l.start))
+}
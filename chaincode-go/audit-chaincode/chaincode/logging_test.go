@@ -0,0 +1,160 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	var out bytes.Buffer
+	lg := &txLogger{Logger: newLogger(&out, slog.LevelInfo, "json")}
+	lg.Reject("INVALID_SESSION", errors.New("closed"),
+		"auditId", "audit-1", "userId", "user-alice", "email", "alice@example.com",
+		"ipAddress", "10.1.2.3", "resourceId", "cred-42")
+
+	var line map[string]interface{}
+	err := json.Unmarshal(out.Bytes(), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"level":      "WARN",
+		"msg":        "INVALID_SESSION",
+		"auditId":    "audit-1",
+		"userId":     redactHash("user-alice"),
+		"email":      "a***@example.com",
+		"ipAddress":  "10.1.0.0/16",
+		"resourceId": redactHash("cred-42"),
+		"err":        "closed",
+	}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("%s = %v, want %v", key, line[key], value)
+		}
+	}
+	if _, ok := line["duration"]; !ok {
+		t.Errorf("duration missing: %s", out.String())
+	}
+	if strings.Contains(out.String(), "user-alice") || strings.Contains(out.String(), "alice@") {
+		t.Errorf("personal data leaked: %s", out.String())
+	}
+}
+
+func TestDebugDisablesRedaction(t *testing.T) {
+	var out bytes.Buffer
+	lg := &txLogger{Logger: newLogger(&out, slog.LevelDebug, "text")}
+	lg.Enter("userId", "user-alice", "ipAddress", "2001:db8::1")
+
+	if !strings.Contains(out.String(), "level=DEBUG") || !strings.Contains(out.String(), "userId=user-alice") ||
+		!strings.Contains(out.String(), "ipAddress=2001:db8::1") {
+		t.Errorf("debug line = %s", out.String())
+	}
+}
+
+func TestRedactHelpers(t *testing.T) {
+	if got := redactIP("2001:db8:1:2::1"); got != "2001:db8:1::/48" {
+		t.Errorf("IPv6 = %s", got)
+	}
+	if got := redactIP("not-an-ip"); !strings.HasPrefix(got, "h:") {
+		t.Errorf("invalid IP = %s", got)
+	}
+	if got := redactEmail("no-at-sign"); !strings.HasPrefix(got, "h:") {
+		t.Errorf("invalid email = %s", got)
+	}
+	if redactHash("user-alice") != redactHash("user-alice") || len(redactHash("user-alice")) != 14 {
+		t.Errorf("hash not stable: %s", redactHash("user-alice"))
+	}
+	if redactHash("") != "" {
+		t.Errorf("empty value hashed")
+	}
+}
+
+func TestConfigureLogging(t *testing.T) {
+	saved := logger
+	defer func() {
+		logger = saved
+		slog.SetDefault(saved)
+	}()
+
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+	if err := ConfigureLogging(env(nil)); err != nil {
+		t.Errorf("defaults: %v", err)
+	}
+	if err := ConfigureLogging(env(map[string]string{envLogLevel: "WARN", envLogFormat: "text"})); err != nil {
+		t.Errorf("warn/text: %v", err)
+	}
+	if err := ConfigureLogging(env(map[string]string{envLogLevel: "verbose"})); err == nil || !strings.Contains(err.Error(), envLogLevel) {
+		t.Errorf("bad level: %v", err)
+	}
+	if err := ConfigureLogging(env(map[string]string{envLogFormat: "xml"})); err == nil || !strings.Contains(err.Error(), envLogFormat) {
+		t.Errorf("bad format: %v", err)
+	}
+}
+
+func TestRejectedCallsRedactErrors(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("user-alice", "ADMIN")
+	n.registerUser("user-bob", "USER")
+	out := n.captureLog()
+
+	// The rejections name the users in the error the client gets back ...
+	alice := n.identity("Org1MSP", "alice", "client", "user-alice")
+	envelope := n.reject(CodeFailedPrecondition, alice, "UserContract:SuspendUser", "user-alice", "0")
+	if !strings.Contains(envelope.Message, "user-alice") {
+		t.Errorf("self suspend = %+v", envelope)
+	}
+	bob := n.identity("Org1MSP", "bob", "client", "user-bob")
+	envelope = n.reject(CodePermissionDenied, bob, "RegistryContract:RegisterActionType", "APPROVE", "approve", "false")
+	if !strings.Contains(envelope.Message, "user-bob") {
+		t.Errorf("register as USER = %+v", envelope)
+	}
+
+	// ... but the log only carries their hashes
+	for _, clear := range []string{"user-alice", "user-bob", "alice@", "bob@"} {
+		if strings.Contains(out.String(), clear) {
+			t.Errorf("%s leaked: %s", clear, out.String())
+		}
+	}
+	for msg, hash := range map[string]string{"BLOCKED": redactHash("user-alice"), "DENIED": redactHash("user-bob")} {
+		lines := logLines(t, out, msg)
+		if len(lines) != 1 || !strings.Contains(fmt.Sprint(lines[0]["err"]), hash) {
+			t.Errorf("%s lines = %v, want err naming %s", msg, lines, hash)
+		}
+	}
+}
+
+func TestContractErrorLogValue(t *testing.T) {
+	var out bytes.Buffer
+	lg := &txLogger{Logger: newLogger(&out, slog.LevelInfo, "json")}
+	inner := notFound("user id=%s not found", pii("user-alice"))
+	lg.Fail("ERROR", wrapError(inner, "failed to read %s", piiEmail("alice@example.com")))
+	lg.Fail("ERROR", errors.New("no user found for email carol@example.com from 10.1.2.3"))
+
+	if strings.Contains(out.String(), "user-alice") || strings.Contains(out.String(), "alice@") ||
+		strings.Contains(out.String(), "carol@") || strings.Contains(out.String(), "10.1.2.3") {
+		t.Errorf("personal data leaked: %s", out.String())
+	}
+	for _, want := range []string{redactHash("user-alice"), "a***@example.com", "c***@example.com", "10.1.0.0/16"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("%s missing: %s", want, out.String())
+		}
+	}
+	if wrapped := wrapError(inner, "failed to read %s", piiEmail("alice@example.com")); !strings.Contains(wrapped.Error(), "alice@example.com") {
+		t.Errorf("client message redacted: %v", wrapped)
+	}
+
+	// At DEBUG the full message is logged
+	out.Reset()
+	lg = &txLogger{Logger: newLogger(&out, slog.LevelDebug, "json")}
+	lg.Fail("ERROR", inner)
+	if !strings.Contains(out.String(), "user-alice") {
+		t.Errorf("debug line = %s", out.String())
+	}
+}
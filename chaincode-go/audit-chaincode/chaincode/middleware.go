@@ -0,0 +1,261 @@
+package chaincode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ middleware.go is the layer every transaction passes through, around the contract functions:
+	- Contracts - Every contract of the chaincode with the hooks below installed (main.go)
+	- Guard - Wraps the chaincode: panics become errors, every error leaves as a TransactionError
+
+contractapi hooks (installed on each contract by Contracts):
+	- before  - Starts the transaction's logger and clock (txLog returns it to the functions),
+	            resolves the caller once (getCallerID reuses it),
+	            rejects arguments that are not valid UTF-8, logs TX_BEGIN (DEBUG)
+	- after   - Logs TX_END (INFO) with the caller, the result count of list results
+	            and the duration, only called on success
+	- unknown - "Function X not found in contract Y, available functions: A, B, ..."
+
+Guard (around ContractChaincode.Invoke, the hooks cannot see errors or panics):
+	- A panic is logged with its stack (PANIC, ERROR) and answered with a generic INTERNAL error,
+	  the world state writes of the transaction are discarded with the failed response
+	- Every error is logged as TX_END (WARN, ERROR for INTERNAL) with its code and reason only,
+	  the message can name users and only arrives here as text. It is returned as the JSON envelope
+		{"code":"NOT_FOUND","message":"audit entry audit-9 does not exist in ledger",
+		 "function":"AuditContract:GetAudit","txId":"3f1c..."}
+	  code, reason and details come from the function's ContractError (errors.go)
+
+The TX_BEGIN / TX_END lines are the only entry, success and rejection logging of audit.go and
+users.go; their functions log nothing but the cause of INTERNAL failures (Fail), which the
+returned error keeps out of the TX_END line. The other contracts still add their own named
+lines through txLog (logging.go), on the same logger and clock.
+*/
+
+// TransactionContext is the transaction context of every contract, it carries what the before hook resolved
+type TransactionContext struct {
+	contractapi.TransactionContext
+	function       string    // Contract:Function as called
+	log            *txLogger // started by the before hook, txLog returns it to the contract functions
+	callerResolved bool
+	callerID       string
+	callerErr      error
+}
+
+// Contracts returns every contract of the chaincode with the middleware installed,
+// the first one is the default contract (called without a "Contract:" prefix)
+func Contracts() []contractapi.ContractInterface {
+	audit := &AuditContract{}
+	users := &UserContract{}
+	registry := &RegistryContract{}
+	checkpoints := &CheckpointContract{}
+
+	useMiddleware(&audit.Contract, audit)
+	useMiddleware(&users.Contract, users)
+	useMiddleware(&registry.Contract, registry)
+	useMiddleware(&checkpoints.Contract, checkpoints)
+	return []contractapi.ContractInterface{audit, users, registry, checkpoints}
+}
+
+// useMiddleware sets the hooks and transaction context on the contractapi.Contract embedded in contract
+func useMiddleware(base *contractapi.Contract, contract contractapi.ContractInterface) {
+	name := contractName(contract)
+	base.TransactionContextHandler = new(TransactionContext)
+	base.BeforeTransaction = beforeTransaction(name)
+	base.AfterTransaction = afterTransaction
+	base.UnknownTransaction = unknownTransaction(name, contractFunctions(contract))
+}
+
+// contractName is the name contractapi registers the contract under
+func contractName(contract contractapi.ContractInterface) string {
+	if name := contract.GetName(); name != "" {
+		return name
+	}
+	return reflect.TypeOf(contract).Elem().Name()
+}
+
+// contractFunctions lists the transaction functions contractapi exposes for contract, sorted
+func contractFunctions(contract contractapi.ContractInterface) []string {
+	excluded := map[string]bool{}
+	for _, iface := range []reflect.Type{
+		reflect.TypeOf((*contractapi.ContractInterface)(nil)).Elem(),
+		reflect.TypeOf((*contractapi.IgnoreContractInterface)(nil)).Elem(),
+		reflect.TypeOf((*contractapi.EvaluationContractInterface)(nil)).Elem(),
+	} {
+		for i := 0; i < iface.NumMethod(); i++ {
+			excluded[iface.Method(i).Name] = true
+		}
+	}
+	if ignorer, ok := contract.(contractapi.IgnoreContractInterface); ok {
+		for _, name := range ignorer.GetIgnoredFunctions() {
+			excluded[name] = true
+		}
+	}
+
+	// reflect lists exported methods only, in name order
+	functions := []string{}
+	contractType := reflect.TypeOf(contract)
+	for i := 0; i < contractType.NumMethod(); i++ {
+		if name := contractType.Method(i).Name; !excluded[name] {
+			functions = append(functions, name)
+		}
+	}
+	return functions
+}
+
+/*
+--- BEFORE TRANSACTION ---
+Runs ahead of every function of the contract, an error here stops the transaction
+*/
+func beforeTransaction(contract string) func(*TransactionContext) error {
+	return func(ctx *TransactionContext) error {
+		stub := ctx.GetStub()
+		function, params := stub.GetFunctionAndParameters()
+		if i := strings.LastIndex(function, ":"); i >= 0 {
+			function = function[i+1:]
+		}
+
+		ctx.function = contract + ":" + function
+		ctx.log = newTxLog(ctx, ctx.function)
+
+		// Resolve the caller once, getCallerID returns the cached result for the rest of the transaction
+		ctx.callerID, ctx.callerErr = getCallerID(ctx)
+		ctx.callerResolved = true
+		ctx.log.Debug("TX_BEGIN", "callerId", ctx.callerID, "args", len(params))
+
+		// Arguments end up in JSON on the ledger, invalid UTF-8 would be silently replaced
+		for i, param := range params {
+			if !utf8.ValidString(param) {
+				ctx.log.Reject("INVALID_ARGUMENT", nil, "arg", i)
+				return invalidArgument(fmt.Sprintf("args[%d]", i), "argument %d of %s is not valid UTF-8", i, ctx.function)
+			}
+		}
+		return nil
+	}
+}
+
+/*
+--- AFTER TRANSACTION ---
+Runs after a function returned without error, with its result
+*/
+func afterTransaction(ctx *TransactionContext, result interface{}) error {
+	args := []any{"status", "SUCCESS", "callerId", ctx.callerID}
+	if value := reflect.ValueOf(result); value.Kind() == reflect.Slice {
+		args = append(args, "count", value.Len())
+	}
+	ctx.log.Info("TX_END", append(args, ctx.log.duration())...)
+	return nil
+}
+
+/*
+--- UNKNOWN TRANSACTION ---
+Called for a function name the contract does not have
+*/
+func unknownTransaction(contract string, functions []string) func(*TransactionContext) error {
+	available := strings.Join(functions, ", ")
+	return func(ctx *TransactionContext) error {
+		function, _ := ctx.GetStub().GetFunctionAndParameters()
+		if i := strings.LastIndex(function, ":"); i >= 0 {
+			function = function[i+1:]
+		}
+		ctx.log.Reject("UNKNOWN_FUNCTION", nil, "requested", function)
+		return notFound("Function %s not found in contract %s, available functions: %s", function, contract, available)
+	}
+}
+
+/* --- GUARD --- */
+
+// guarded is a shim.Chaincode that recovers panics and wraps errors of the one it wraps
+type guarded struct {
+	cc              shim.Chaincode
+	defaultContract string
+}
+
+// Guard wraps cc so a panic becomes an INTERNAL error and every error is a TransactionError.
+// defaultContract names the contract used when the function has no "Contract:" prefix.
+func Guard(cc shim.Chaincode, defaultContract string) shim.Chaincode {
+	return &guarded{cc: cc, defaultContract: defaultContract}
+}
+
+// Init guards instantiation calls the same way as Invoke
+func (g *guarded) Init(stub shim.ChaincodeStubInterface) *peer.Response {
+	return g.call(stub, g.cc.Init)
+}
+
+// Invoke guards one transaction
+func (g *guarded) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	return g.call(stub, g.cc.Invoke)
+}
+
+func (g *guarded) call(stub shim.ChaincodeStubInterface, call func(shim.ChaincodeStubInterface) *peer.Response) (response *peer.Response) {
+	start := time.Now()
+	function, _ := stub.GetFunctionAndParameters()
+	if function != "" && !strings.Contains(function, ":") {
+		function = g.defaultContract + ":" + function
+	}
+	fields := []any{"function", function, "txId", stub.GetTxID(), "channel", stub.GetChannelID()}
+	// Same fields as newTxLog, so every TX_END line names the caller's MSP
+	if mspID, err := cid.GetMSPID(stub); err == nil {
+		fields = append(fields, "mspId", mspID)
+	}
+	lg := logger.With(fields...)
+
+	defer func() {
+		if r := recover(); r != nil {
+			lg.Error("PANIC", "panic", fmt.Sprint(r), "stack", string(debug.Stack()), "duration", time.Since(start))
+			response = shim.Error(transactionError(stub, function, &ContractError{
+				Code:    CodeInternal,
+				Message: fmt.Sprintf("internal error in %s, see the chaincode log for transaction %s", function, stub.GetTxID()),
+			}))
+		}
+	}()
+
+	response = call(stub)
+	if response == nil {
+		response = shim.Error("no response")
+	}
+	if response.Status >= shim.ERRORTHRESHOLD {
+		contractErr := classifyMessage(response.Message)
+		level := slog.LevelWarn
+		if contractErr.Code == CodeInternal {
+			level = slog.LevelError
+		}
+		args := []any{"status", "ERROR", "code", contractErr.Code}
+		if contractErr.Reason != "" {
+			args = append(args, "reason", contractErr.Reason)
+		}
+		lg.Log(context.Background(), level, "TX_END", append(args, "duration", time.Since(start))...)
+		response = shim.Error(transactionError(stub, function, contractErr))
+	}
+	return response
+}
+
+// transactionError encodes the envelope a failed transaction returns as its message
+func transactionError(stub shim.ChaincodeStubInterface, function string, contractErr *ContractError) string {
+	envelope, err := json.Marshal(TransactionError{
+		Code:     contractErr.Code,
+		Message:  contractErr.Message,
+		Reason:   contractErr.Reason,
+		Details:  contractErr.Details,
+		Function: function,
+		TxID:     stub.GetTxID(),
+	})
+	if err != nil {
+		return contractErr.Error()
+	}
+	return string(envelope)
+}
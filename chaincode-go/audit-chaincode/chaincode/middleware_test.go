@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// middlewareStub implements just what contractapi and the called functions use; the embedded nil interface panics on anything else
+type middlewareStub struct {
+	shim.ChaincodeStubInterface
+	args  []string
+	state map[string][]byte
+}
+
+func (s *middlewareStub) GetArgs() [][]byte {
+	args := [][]byte{}
+	for _, arg := range s.args {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
+func (s *middlewareStub) GetStringArgs() []string { return s.args }
+func (s *middlewareStub) GetFunctionAndParameters() (string, []string) {
+	return s.args[0], s.args[1:]
+}
+func (s *middlewareStub) GetTxID() string                     { return "tx1" }
+func (s *middlewareStub) GetChannelID() string                { return "mychannel" }
+func (s *middlewareStub) GetCreator() ([]byte, error)         { return nil, errors.New("no creator") }
+func (s *middlewareStub) GetState(key string) ([]byte, error) { return s.state[key], nil }
+
+func invoke(t *testing.T, cc shim.Chaincode, args ...string) *peer.Response {
+	t.Helper()
+	return cc.Invoke(&middlewareStub{args: args, state: map[string][]byte{"audit-1": []byte(`{"id":"audit-1"}`)}})
+}
+
+func newGuardedChaincode(t *testing.T) shim.Chaincode {
+	t.Helper()
+	cc, err := contractapi.NewChaincode(Contracts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Guard(cc, cc.DefaultContract)
+}
+
+func decodeTransactionError(t *testing.T, response *peer.Response) TransactionError {
+	t.Helper()
+	if response.Status < shim.ERRORTHRESHOLD {
+		t.Fatalf("status = %d, want an error", response.Status)
+	}
+	var envelope TransactionError
+	err := json.Unmarshal([]byte(response.Message), &envelope)
+	if err != nil {
+		t.Fatalf("message is not an envelope: %s", response.Message)
+	}
+	return envelope
+}
+
+func TestMiddlewareSuccess(t *testing.T) {
+	cc := newGuardedChaincode(t)
+
+	response := invoke(t, cc, "AuditExists", "audit-1")
+	if response.Status != shim.OK || string(response.Payload) != "true" {
+		t.Errorf("AuditExists = %d %s %s", response.Status, response.Payload, response.Message)
+	}
+}
+
+func TestMiddlewareErrorEnvelope(t *testing.T) {
+	cc := newGuardedChaincode(t)
+
+	envelope := decodeTransactionError(t, invoke(t, cc, "GetAudit", "audit-9"))
+	if envelope.Code != CodeNotFound || envelope.Function != "AuditContract:GetAudit" || envelope.TxID != "tx1" ||
+		envelope.Message != "audit entry audit-9 does not exist in ledger" {
+		t.Errorf("envelope = %+v", envelope)
+	}
+
+	envelope = decodeTransactionError(t, invoke(t, cc, "NoSuchContract:GetAudit", "audit-1"))
+	if envelope.Code != CodeNotFound || envelope.Message != "Contract not found with name NoSuchContract" {
+		t.Errorf("unknown contract = %+v", envelope)
+	}
+
+	envelope = decodeTransactionError(t, invoke(t, cc, "AuditExists", "audit-\xff"))
+	if envelope.Code != CodeInvalidArgument || len(envelope.Details) != 1 || envelope.Details[0].Field != "args[0]" {
+		t.Errorf("invalid UTF-8 = %+v", envelope)
+	}
+
+	envelope = decodeTransactionError(t, invoke(t, cc, "GetAudit", ""))
+	if envelope.Code != CodeInvalidArgument || len(envelope.Details) != 1 || envelope.Details[0].Field != "id" {
+		t.Errorf("missing id = %+v", envelope)
+	}
+
+	// contractapi argument checks are classified too
+	envelope = decodeTransactionError(t, invoke(t, cc, "GetAudit"))
+	if envelope.Code != CodeInvalidArgument || !strings.HasPrefix(envelope.Message, "incorrect number of params") {
+		t.Errorf("missing argument = %+v", envelope)
+	}
+}
+
+func TestMiddlewareUnknownFunction(t *testing.T) {
+	cc := newGuardedChaincode(t)
+
+	envelope := decodeTransactionError(t, invoke(t, cc, "UserContract:GetUsr", "user-1"))
+	if envelope.Code != CodeNotFound || !strings.HasPrefix(envelope.Message, "Function GetUsr not found in contract UserContract, available functions: ") {
+		t.Fatalf("unknown function = %s", envelope.Message)
+	}
+	available := strings.TrimPrefix(envelope.Message, "Function GetUsr not found in contract UserContract, available functions: ")
+	functions := strings.Split(available, ", ")
+	found := false
+	for _, function := range functions {
+		if function == "GetUser" {
+			found = true
+		}
+		if function == "GetBeforeTransaction" || function == "GetName" {
+			t.Errorf("contractapi method listed: %s", function)
+		}
+	}
+	if !found {
+		t.Errorf("GetUser missing from %s", available)
+	}
+}
+
+// panicking is a chaincode whose Invoke panics, like a nil dereference in a contract function
+type panicking struct{}
+
+func (panicking) Init(stub shim.ChaincodeStubInterface) *peer.Response { return shim.Success(nil) }
+func (panicking) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	var entry *AuditEntry
+	return shim.Success([]byte(entry.ID))
+}
+
+func TestGuardRecoversPanic(t *testing.T) {
+	response := invoke(t, Guard(panicking{}, "AuditContract"), "GetAudit", "audit-1")
+
+	envelope := decodeTransactionError(t, response)
+	if envelope.Code != CodeInternal || envelope.Function != "AuditContract:GetAudit" ||
+		envelope.Message != "internal error in AuditContract:GetAudit, see the chaincode log for transaction tx1" {
+		t.Errorf("panic envelope = %+v", envelope)
+	}
+}
+
+func TestGuardLogsCodeOnly(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("user-alice", "USER")
+	out := n.captureLog()
+
+	// The message names the user, TX_END only carries what clients branch on
+	n.reject(CodeFailedPrecondition, n.admin, "UserContract:UpdateUserRole", "user-alice", "USER")
+	lines := logLines(t, out, "TX_END")
+	if len(lines) != 1 || lines[0]["code"] != string(CodeFailedPrecondition) || lines[0]["level"] != "WARN" {
+		t.Fatalf("TX_END = %v", lines)
+	}
+	if lines[0]["mspId"] != "Org1MSP" || lines[0]["txId"] == nil || lines[0]["channel"] == nil {
+		t.Errorf("TX_END lacks the transaction fields: %v", lines[0])
+	}
+	if _, ok := lines[0]["err"]; ok {
+		t.Errorf("TX_END logs the message: %v", lines[0])
+	}
+}
+
+func TestHooksLogTransactions(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("user-alice", "USER")
+	n.logAudit(auditArgs("audit-1", "user-alice", "CREATE", "CREDENTIAL", "cred-1", "", `{"status":"ACTIVE"}`))
+	out := n.captureLog()
+
+	// The audit and user functions leave their logging to the hooks: one TX_END per call
+	n.evaluate(n.client, nil, "UserContract:GetUser", "user-alice")
+	n.evaluate(n.client, nil, "QueryAuditsByUser", "user-alice")
+	n.reject(CodeNotFound, n.client, "GetAudit", "audit-9")
+	lines := logLines(t, out, "TX_END")
+	if total := len(strings.Split(strings.TrimSpace(out.String()), "\n")); total != len(lines) || len(lines) != 3 {
+		t.Fatalf("%d TX_END lines of %d: %s", len(lines), total, out.String())
+	}
+	want := []struct {
+		function string
+		status   string
+	}{
+		{"UserContract:GetUser", "SUCCESS"},
+		{"AuditContract:QueryAuditsByUser", "SUCCESS"},
+		{"AuditContract:GetAudit", "ERROR"},
+	}
+	for i, line := range lines {
+		if line["function"] != want[i].function || line["status"] != want[i].status || line["duration"] == nil {
+			t.Errorf("TX_END %d = %v", i, line)
+		}
+	}
+	if lines[1]["count"] != float64(1) {
+		t.Errorf("QueryAuditsByUser count = %v", lines[1]["count"])
+	}
+}
@@ -19,21 +19,229 @@ type AuditEntry struct {
 	Metadata      string    `json:"metadata"`      // Additional context (JSON)
 	ComplianceTag string    `json:"complianceTag"` // HIPAA, GDPR, SOC2, etc.
 	TxID          string    `json:"txId"`          // Fabric transaction ID
+	Changes       []FieldChange `json:"changes,omitempty" metadata:",optional"` // OldValue -> NewValue patch, set by LogAudit (see diff.go)
+	CorrelationID string    `json:"correlationId,omitempty" metadata:",optional"` // Shared by every entry of one user request (see trace.go)
+	ParentID      string    `json:"parentId,omitempty" metadata:",optional"`      // Entry this one was fanned out from
+	CausedBy      string    `json:"causedBy,omitempty" metadata:",optional"`      // Entry that triggered this one (e.g. the QUERY behind a REVOKE)
+}
 
+// FieldChange object: one RFC 6902 style operation turning OldValue into NewValue
+type FieldChange struct {
+	Op       string `json:"op"`                 // add, remove, replace
+	Path     string `json:"path"`               // JSON Pointer (RFC 6901), e.g. /status
+	Value    string `json:"value,omitempty" metadata:",optional"`    // New value as compact JSON (add, replace)
+	OldValue string `json:"oldValue,omitempty" metadata:",optional"` // Previous value as compact JSON (remove, replace)
 }
 
 // User Object: a system user with permissions
 type User struct {
-	ID           string   `json:"id"`           // UUID
-	Username     string   `json:"username"`     // Username for login
-	Email        string   `json:"email"`        // Email address
-	Role         string   `json:"role"`         // ADMIN, AUDITOR, USER
-	Organization string   `json:"organization"` // Which org they belong to
-	Permissions  []string `json:"permissions"`  // Granular permissions
-	Active       bool     `json:"active"`       // Account status
-	CreatedAt    int64    `json:"createdAt"`    // Creation timestamp
-	UpdatedAt    int64    `json:"updatedAt"`    // Last update timestamp
-	CreatedBy    string   `json:"createdBy"`    // Who created this user
+	ID             string   `json:"id"`             // UUID
+	Username       string   `json:"username"`       // Username for login
+	Email          string   `json:"email"`          // Email address
+	Role           string   `json:"role"`           // ADMIN, AUDITOR, USER
+	Organization   string   `json:"organization"`   // Which org they belong to
+	Permissions    []string `json:"permissions"`    // Granular permissions
+	Active         bool     `json:"active"`         // True only while Status is ACTIVE (kept for older clients)
+	Status         string   `json:"status"`         // PENDING_ACTIVATION, ACTIVE, SUSPENDED, DEACTIVATED, ERASED
+	StatusReason   string   `json:"statusReason"`   // Why the user was last moved to its current status
+	SuspendedUntil int64    `json:"suspendedUntil"` // SUSPENDED only: lapses back to ACTIVE at this time (0 = indefinite)
+	CreatedAt      int64    `json:"createdAt"`      // Creation timestamp
+	UpdatedAt      int64    `json:"updatedAt"`      // Last update timestamp
+	CreatedBy      string   `json:"createdBy"`      // Who created this user
+	UpdatedBy      string   `json:"updatedBy"`      // Who last changed this user
+}
+
+// UserFilter object: optional filters for ListUsers, empty fields match every user
+type UserFilter struct {
+	Role         string `json:"role,omitempty"`         // ADMIN, AUDITOR, USER
+	Organization string `json:"organization,omitempty"` // Exact organization match
+	Status       string `json:"status,omitempty"`       // Exact lifecycle status match
+	Active       *bool  `json:"active,omitempty"`       // nil matches active and inactive users
+	CreatedBy    string `json:"createdBy,omitempty"`    // Who created the user
+}
+
+// UserQueryResult object: one page of users returned by ListUsers
+type UserQueryResult struct {
+	Records             []*User `json:"records"`             // Users matching the filter
+	FetchedRecordsCount int32   `json:"fetchedRecordsCount"` // Number of records in this page
+	Bookmark            string  `json:"bookmark"`            // Pass to the next call, empty when there are no more pages
+}
+
+// ActionType object: an action that may be recorded in LogAudit
+type ActionType struct {
+	Name        string `json:"name"`        // CREATE, ISSUE, LOGIN, ...
+	Description string `json:"description"` // Human readable meaning
+	Mutating    bool   `json:"mutating"`    // True if the action changes the resource (OldValue/NewValue meaningful)
+	BuiltIn     bool   `json:"builtIn"`     // Compiled-in default, not stored on the ledger
+	CreatedBy   string `json:"createdBy"`   // Admin who registered it
+	CreatedAt   int64  `json:"createdAt"`   // Registration timestamp
+}
+
+// ResourceType object: a kind of resource audit entries refer to, and the actions legal on it
+type ResourceType struct {
+	Name           string   `json:"name"`           // CREDENTIAL, USER, ...
+	Description    string   `json:"description"`    // Human readable meaning
+	AllowedActions []string `json:"allowedActions"` // Action types legal for this resource type
+	ValueSchema    string   `json:"valueSchema"`    // JSON Schema for OldValue/NewValue ("" = well-formed JSON only)
+	MetadataSchema string   `json:"metadataSchema"` // JSON Schema for Metadata ("" = well-formed JSON only)
+	CreatedBy      string   `json:"createdBy"`      // Admin who registered it
+	CreatedAt      int64    `json:"createdAt"`      // Registration timestamp
+	UpdatedAt      int64    `json:"updatedAt"`      // Last change of AllowedActions or schemas
+}
+
+// Session object: one authenticated session audit entries can reference via sessionId
+type Session struct {
+	DocType    string `json:"docType"`    // Always "session", keeps sessions out of audit rich queries
+	ID         string `json:"id"`         // Session identifier
+	UserID     string `json:"userId"`     // User who opened the session
+	IPAddress  string `json:"ipAddress"`  // Client IP address at login
+	AuthMethod string `json:"authMethod"` // PASSWORD, SSO, MFA, API_KEY, CERTIFICATE
+	Status     string `json:"status"`     // OPEN, CLOSED
+	StartedAt  int64  `json:"startedAt"`  // Start timestamp (milliseconds)
+	EndedAt    int64  `json:"endedAt"`    // End timestamp (0 while OPEN)
+	EndReason  string `json:"endReason"`  // LOGOUT, TIMEOUT, ... (free text)
+	StartTxID  string `json:"startTxId"`  // Transaction that opened the session
+	EndTxID    string `json:"endTxId"`    // Transaction that closed the session
+}
+
+// SessionTimeline object: a session and its audit entries, oldest first
+type SessionTimeline struct {
+	Session *Session      `json:"session"`
+	Entries []*AuditEntry `json:"entries"`
+}
+
+// ResourceState object: a resource as rebuilt from its audit entries by ReconstructResourceAt
+type ResourceState struct {
+	ResourceType   string `json:"resourceType"`   // Type of resource
+	ResourceID     string `json:"resourceId"`     // Specific resource identifier
+	At             int64  `json:"at"`             // Requested moment (milliseconds)
+	Exists         bool   `json:"exists"`         // False if never created or DELETEd by then
+	State          string `json:"state"`          // NewValue snapshot in effect (JSON string, "" if none)
+	AsOfAuditID    string `json:"asOfAuditId"`    // Entry that produced State
+	AsOfTimestamp  int64  `json:"asOfTimestamp"`  // When that entry was logged
+	AsOfTxID       string `json:"asOfTxId"`       // Transaction of that entry
+	AppliedEntries int    `json:"appliedEntries"` // Number of entries that changed the state
+}
+
+// ContinuityBreak object: one place where a resource's history does not connect
+type ContinuityBreak struct {
+	Kind            string `json:"kind"`            // OLD_VALUE_MISMATCH, MISSING_PREDECESSOR, UNEXPLAINED_JUMP
+	AuditID         string `json:"auditId"`         // Entry that breaks the chain
+	PreviousAuditID string `json:"previousAuditId"` // Entry whose NewValue was expected ("" if none)
+	Timestamp       int64  `json:"timestamp"`       // Timestamp of the breaking entry
+	Expected        string `json:"expected"`        // Previous entry's NewValue
+	Actual          string `json:"actual"`          // Breaking entry's OldValue
+	Message         string `json:"message"`         // Human readable explanation
+}
+
+// ContinuityReport object: result of checking one resource's history
+type ContinuityReport struct {
+	ResourceType   string            `json:"resourceType"`   // Type of resource
+	ResourceID     string            `json:"resourceId"`     // Specific resource identifier
+	EntriesChecked int               `json:"entriesChecked"` // Mutating SUCCESS entries walked
+	Continuous     bool              `json:"continuous"`     // True if no breaks were found
+	Breaks         []ContinuityBreak `json:"breaks"`         // Every break, oldest first
+}
+
+// ContinuitySweep object: result of CheckContinuityByDateRange
+type ContinuitySweep struct {
+	StartDate           int64               `json:"startDate"`           // Range start (milliseconds)
+	EndDate             int64               `json:"endDate"`             // Range end (milliseconds)
+	ResourcesChecked    int                 `json:"resourcesChecked"`    // Resources changed in the range
+	ResourcesWithBreaks int                 `json:"resourcesWithBreaks"` // Resources with at least one break
+	Reports             []*ContinuityReport `json:"reports"`             // Reports of broken resources only
+}
+
+// TraceNode object: one entry of a trace, listed depth first
+type TraceNode struct {
+	Entry    *AuditEntry `json:"entry"`    // The audit entry
+	Depth    int         `json:"depth"`    // 0 for roots
+	Link     string      `json:"link"`     // How it hangs off its tree parent: PARENT, CAUSED_BY ("" for roots)
+	Children []string    `json:"children"` // IDs of direct children, oldest first
+}
+
+// Trace object: the causal tree of one correlation ID, returned by GetTrace
+type Trace struct {
+	CorrelationID string      `json:"correlationId"` // Correlation ID queried
+	Roots         []string    `json:"roots"`         // Entries with no tree parent inside the trace
+	Nodes         []TraceNode `json:"nodes"`         // Every entry, depth first from each root
+}
+
+// Checkpoint object: a Merkle root sealing every audit entry in a time window
+type Checkpoint struct {
+	DocType    string                `json:"docType"`    // Always "checkpoint", keeps checkpoints out of audit rich queries
+	ID         string                `json:"id"`         // ckpt-00000001, ckpt-00000002, ...
+	Sequence   int                   `json:"sequence"`   // Position in the checkpoint chain, starts at 1
+	StartTs    int64                 `json:"startTs"`    // Window start (milliseconds, inclusive)
+	EndTs      int64                 `json:"endTs"`      // Window end (milliseconds, inclusive)
+	TreeSize   int                   `json:"treeSize"`   // Number of leaves
+	Root       string                `json:"root"`       // Merkle root (hex)
+	PrevID     string                `json:"prevId"`     // Previous checkpoint ("" for the first)
+	PrevHash   string                `json:"prevHash"`   // SHA-256 of the previous checkpoint's statement (hex)
+	Statement  string                `json:"statement"`  // Text orgs countersign (see checkpoint.go)
+	Leaves     []CheckpointLeaf      `json:"leaves"`     // Leaves in tree order
+	Signatures []CheckpointSignature `json:"signatures"` // Org countersignatures
+	CreatedBy  string                `json:"createdBy"`  // Admin who created it
+	CreatedAt  int64                 `json:"createdAt"`  // Creation timestamp
+	TxID       string                `json:"txId"`       // Transaction that created it
+	AnchoredAt int64                 `json:"anchoredAt"` // TSA genTime of its anchor (0 = not anchored)
+}
+
+// CheckpointLeaf object: one audit entry sealed by a checkpoint
+type CheckpointLeaf struct {
+	AuditID string `json:"auditId"` // Audit entry ID
+	Hash    string `json:"hash"`    // merkle.LeafHash(CanonicalAuditJSON(entry)) (hex)
+}
+
+// CheckpointSignature object: one org's countersignature over a checkpoint statement
+type CheckpointSignature struct {
+	MSPID       string `json:"mspId"`       // Signing org
+	SignerID    string `json:"signerId"`    // Caller ID of the submitter
+	Certificate string `json:"certificate"` // PEM certificate whose key made the signature
+	Signature   string `json:"signature"`   // Base64 ASN.1 ECDSA signature over SHA-256(statement)
+	SignedAt    int64  `json:"signedAt"`    // Countersign timestamp
+}
+
+// InclusionProof object: sibling path from one entry to its checkpoint's root
+type InclusionProof struct {
+	AuditID      string   `json:"auditId"`      // Audit entry ID
+	CheckpointID string   `json:"checkpointId"` // Checkpoint holding the entry
+	LeafIndex    int      `json:"leafIndex"`    // Position of the entry in the tree
+	TreeSize     int      `json:"treeSize"`     // Number of leaves in the tree
+	LeafHash     string   `json:"leafHash"`     // Leaf hash (hex)
+	Path         []string `json:"path"`         // Sibling hashes bottom up (hex)
+	Root         string   `json:"root"`         // Checkpoint root (hex)
+}
+
+// TrustedTSA object: a Time Stamping Authority root trusted for anchors
+type TrustedTSA struct {
+	DocType     string `json:"docType"`     // Always "trustedTsa"
+	Name        string `json:"name"`        // Short name, e.g. freetsa
+	Certificate string `json:"certificate"` // Root certificate (PEM)
+	Subject     string `json:"subject"`     // Root certificate subject
+	NotAfter    int64  `json:"notAfter"`    // Root certificate expiry
+	AddedBy     string `json:"addedBy"`     // Admin who added it
+	AddedAt     int64  `json:"addedAt"`     // When it was added
+}
+
+// Anchor object: an RFC 3161 trusted timestamp over a checkpoint or an audit entry
+type Anchor struct {
+	DocType       string `json:"docType"`               // Always "anchor"
+	TargetType    string `json:"targetType"`            // CHECKPOINT, AUDIT
+	TargetID      string `json:"targetId"`              // Checkpoint or audit entry ID
+	Digest        string `json:"digest"`                // Timestamped digest (hex)
+	HashAlgorithm string `json:"hashAlgorithm"`         // Always SHA-256
+	Token         string `json:"token"`                 // RFC 3161 TimeStampToken (base64 DER)
+	TSASubject    string `json:"tsaSubject"`            // TSA signing certificate subject
+	SerialNumber  string `json:"serialNumber"`          // TSA serial number of the token
+	GenTime       int64  `json:"genTime"`               // Time the TSA vouches for (milliseconds)
+	ClaimedAt     int64  `json:"claimedAt"`             // Target's own timestamp
+	DriftMs       int64  `json:"driftMs"`               // genTime - claimedAt
+	AnchoredBy    string `json:"anchoredBy"`            // Who stored the anchor
+	AnchoredAt    int64  `json:"anchoredAt"`            // When the anchor was stored
+	TxID          string `json:"txId"`                  // Transaction that stored it
+	Verified      bool   `json:"verified"`              // Re-verification result (GetAnchor)
+	VerifyError   string `json:"verifyError,omitempty" metadata:",optional"` // Why re-verification failed
 }
 
 // ComplianceReport object:  a compliance audit report details 
@@ -66,4 +274,20 @@ type AuditStats struct {
 type QueryResult struct {
 	Key    string `json:"key"`
 	Record *AuditEntry
-}
\ No newline at end of file
+}
+// AuditQueryResult object: one page of audit entries returned by QueryAuditsPage
+type AuditQueryResult struct {
+	Records             []*AuditEntry `json:"records"`             // Entries, timestamp ascending then ID
+	FetchedRecordsCount int32         `json:"fetchedRecordsCount"` // Number of records in this page
+	Bookmark            string        `json:"bookmark"`            // Pass to the next call
+}
+
+// TransactionError object: the message of every failed transaction (middleware.go)
+type TransactionError struct {
+	Code     ErrorCode        `json:"code"`              // NOT_FOUND, ALREADY_EXISTS, ... (errors.go)
+	Message  string           `json:"message"`           // Error returned by the function
+	Reason   string           `json:"reason,omitempty"`  // Finer cause within the code, e.g. LAST_ACTIVE_ADMIN
+	Details  []FieldViolation `json:"details,omitempty"` // Offending arguments (INVALID_ARGUMENT)
+	Function string           `json:"function"`          // Contract:Function as called
+	TxID     string           `json:"txId"`              // Transaction ID, matches the chaincode log lines
+}
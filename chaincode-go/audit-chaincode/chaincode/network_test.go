@@ -0,0 +1,160 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/ledgersim"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// The contract tests run the chaincode as deployed (contractapi + Guard) on a ledgersim ledger
+// with the CouchDB indexes of META-INF, every submit is one committed transaction.
+
+// network is one channel with the chaincode installed and the usual clients
+type network struct {
+	t      *testing.T
+	ledger *ledgersim.Ledger
+	cc     shim.Chaincode
+	admin  *ledgersim.Identity // Org1 admin certificate (OU admin), passes requireAdmin
+	client *ledgersim.Identity // Org1 client without userId attribute
+}
+
+// networkStart is the ledger clock of a new network, one second passes per transaction
+var networkStart = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newNetwork(t *testing.T) *network {
+	t.Helper()
+
+	saved := logger
+	logger = newLogger(io.Discard, slog.LevelInfo, "json")
+	t.Cleanup(func() { logger = saved })
+
+	cc, err := contractapi.NewChaincode(Contracts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := ledgersim.New()
+	ledger.SetClock(networkStart, time.Second)
+	err = ledger.LoadIndexes("../META-INF/statedb/couchdb/indexes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &network{t: t, ledger: ledger, cc: Guard(cc, cc.DefaultContract)}
+	n.admin = n.identity("Org1MSP", "Admin@org1.example.com", "admin", "")
+	n.client = n.identity("Org1MSP", "client1", "client", "")
+	return n
+}
+
+// identity enrolls a client, userID sets the "userId" certificate attribute
+func (n *network) identity(mspID string, commonName string, ou string, userID string) *ledgersim.Identity {
+	n.t.Helper()
+	var attrs map[string]string
+	if userID != "" {
+		attrs = map[string]string{callerUserIDAttribute: userID}
+	}
+	id, err := ledgersim.NewIdentity(mspID, commonName, []string{ou}, attrs)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	return id
+}
+
+// millis is the transaction time of the next transaction in Unix milliseconds
+func (n *network) millis() int64 {
+	return n.ledger.Now().UnixMilli()
+}
+
+// captureLog sends the chaincode log of the rest of the test to the returned buffer, JSON at INFO
+func (n *network) captureLog() *bytes.Buffer {
+	out := &bytes.Buffer{}
+	logger = newLogger(out, slog.LevelInfo, "json")
+	return out
+}
+
+// logLines decodes every JSON line of a captured log with the given msg
+func logLines(t *testing.T, out *bytes.Buffer, msg string) []map[string]interface{} {
+	t.Helper()
+	lines := []map[string]interface{}{}
+	for _, text := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			t.Fatalf("log line %q: %v", text, err)
+		}
+		if line["msg"] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// wait moves the ledger clock forward as if nothing was submitted for d
+func (n *network) wait(d time.Duration) {
+	n.ledger.SetClock(n.ledger.Now().Add(d), time.Second)
+}
+
+// submit commits a transaction that must succeed, out (optional) receives the decoded result
+func (n *network) submit(id *ledgersim.Identity, out interface{}, args ...string) {
+	n.t.Helper()
+	n.decode(n.ledger.Submit(n.cc, ledgersim.Proposal{Identity: id, Args: args}), out, args)
+}
+
+// evaluate runs a query transaction that must succeed
+func (n *network) evaluate(id *ledgersim.Identity, out interface{}, args ...string) {
+	n.t.Helper()
+	n.decode(n.ledger.Evaluate(n.cc, ledgersim.Proposal{Identity: id, Args: args}), out, args)
+}
+
+func (n *network) decode(response *peer.Response, out interface{}, args []string) {
+	n.t.Helper()
+	if response.Status >= shim.ERRORTHRESHOLD {
+		n.t.Fatalf("%s failed: %s", args[0], response.Message)
+	}
+	// contractapi returns nothing for a nil slice or pointer, out keeps its zero value
+	if out == nil || len(response.Payload) == 0 {
+		return
+	}
+	err := json.Unmarshal(response.Payload, out)
+	if err != nil {
+		n.t.Fatalf("%s returned %s: %v", args[0], response.Payload, err)
+	}
+}
+
+// reject submits a transaction that must fail with code, the envelope is returned for further checks
+func (n *network) reject(code ErrorCode, id *ledgersim.Identity, args ...string) TransactionError {
+	n.t.Helper()
+	response := n.ledger.Submit(n.cc, ledgersim.Proposal{Identity: id, Args: args})
+	envelope := decodeTransactionError(n.t, response)
+	if envelope.Code != code {
+		n.t.Errorf("%s: code = %s, want %s (%s)", args[0], envelope.Code, code, envelope.Message)
+	}
+	return envelope
+}
+
+/* --- Fixtures --- */
+
+// auditArgs are the LogAudit arguments of a SUCCESS entry, sessionId and metadata empty
+func auditArgs(id string, userID string, action string, resourceType string, resourceID string,
+	oldValue string, newValue string) []string {
+	return []string{"LogAudit", id, userID, "USER", action, resourceType, resourceID, oldValue, newValue,
+		"SUCCESS", "10.0.0.1", "", "", "SOC2"}
+}
+
+func (n *network) logAudit(args []string) {
+	n.t.Helper()
+	n.submit(n.client, nil, args...)
+}
+
+// registerUser registers an ACTIVE user of Org1
+func (n *network) registerUser(id string, role string) {
+	n.t.Helper()
+	n.submit(n.admin, nil, "UserContract:RegisterUser", id, id, id+"@example.com", role, "Org1", "test")
+}
@@ -0,0 +1,106 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ pagination.go is the bulk read path for exports (export package, cmd/audit-export):
+	- QueryAuditsPage - One page of entries, optionally filtered by date, user and complianceTag
+
+GetAllAudits returns everything in one JSON array, QueryAuditsPage pages through the same
+entries with CouchDB bookmarks instead. Pages are sorted by timestamp then ID (index
+indexTimestampId), the same order checkpoints use for their leaves, so an export can be
+handed straight to cmd/audit-verify.
+
+Paginated rich queries only run in evaluate (query) transactions, Fabric rejects them in
+transactions that get submitted for ordering.
+*/
+
+// maxAuditPageSize bounds one QueryAuditsPage call, larger than maxPageSize (users.go) for exports
+const maxAuditPageSize int32 = 1000
+
+/*
+--- QUERY AUDITS PAGE ---
+Returns one page of audit entries, oldest first
+Params:
+  - startDate, endDate: Unix milliseconds, endDate 0 = no upper bound
+  - userId, complianceTag: optional filters, "" = any
+  - pageSize: 1..1000
+  - bookmark: "" for the first page, then the bookmark of the previous page
+*/
+func (c *AuditContract) QueryAuditsPage(ctx contractapi.TransactionContextInterface,
+	startDate int64, endDate int64, userId string, complianceTag string, pageSize int32, bookmark string) (*AuditQueryResult, error) {
+
+	lg := txLog(ctx, "QueryAuditsPage")
+	lg.Enter("startDate", startDate, "endDate", endDate, "pageSize", pageSize)
+
+	// Input validation
+	if startDate < 0 || endDate < 0 {
+		return nil, invalidArgument("startDate", "startDate and endDate must be positive timestamps")
+	}
+	if endDate != 0 && startDate > endDate {
+		return nil, invalidArgument("startDate", "startDate must be before endDate")
+	}
+	if pageSize < 1 || pageSize > maxAuditPageSize {
+		return nil, invalidArgument("pageSize", "pageSize must be between 1 and %d", maxAuditPageSize)
+	}
+
+	timestamp := map[string]interface{}{"$gte": startDate}
+	if endDate != 0 {
+		timestamp["$lte"] = endDate
+	}
+	selector := map[string]interface{}{
+		"timestamp": timestamp,
+		"docType":   map[string]interface{}{"$exists": false},
+	}
+	if userId != "" {
+		selector["userId"] = userId
+	}
+	if complianceTag != "" {
+		selector["complianceTag"] = complianceTag
+	}
+
+	// Build CouchDB query with json.Marshal, values are user supplied
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector":  selector,
+		"sort":      []map[string]string{{"timestamp": "asc"}, {"id": "asc"}},
+		"use_index": []string{"_design/indexTimestampId", "indexTimestampId"},
+	})
+	if err != nil {
+		return nil, internalError("failed to build query: %v", err)
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJSON), pageSize, bookmark)
+	if err != nil {
+		lg.Fail("ERROR", err)
+		return nil, internalError("failed to execute query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	page := &AuditQueryResult{Records: []*AuditEntry{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError("failed to iterate query results: %v", err)
+		}
+
+		var audit AuditEntry
+		err = json.Unmarshal(queryResponse.Value, &audit)
+		if err != nil {
+			lg.Fail("ERROR unmarshaling", err, "key", queryResponse.Key)
+			return nil, internalError("failed to unmarshal audit entry: %v", err)
+		}
+		page.Records = append(page.Records, &audit)
+	}
+	page.FetchedRecordsCount = int32(len(page.Records))
+	if metadata != nil {
+		page.Bookmark = metadata.GetBookmark()
+	}
+
+	lg.Done("count", page.FetchedRecordsCount)
+	return page, nil
+}
@@ -0,0 +1,530 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ registry.go holds the on-ledger vocabulary used to validate audit entries:
+	- RegistryContract struct (third contract next to AuditContract + UserContract)
+	- Action types: RegisterActionType, GetActionType, ListActionTypes
+	- Resource types: RegisterResourceType, SetResourceTypeActions, SetResourceTypeSchema, GetResourceType, ListResourceTypes
+	- validateAuditAction (helper) - used by LogAudit / QueryAuditsByAction
+
+Each registered resource type declares which actions are legal for it, e.g.
+	RegisterResourceType("CREDENTIAL", "...", `["ISSUE","VERIFY","REVOKE","QUERY"]`)
+	RegisterResourceType("USER", "...", `["CREATE","UPDATE","DELETE","QUERY"]`)
+Resource types nobody registered yet stay open: any registered action is accepted, as before
+the registry existed, so existing producers keep working until an admin locks a type down.
+
+Built-in defaults vs ledger:
+	The default action types below are compiled in so a fresh channel works without setup.
+	Records written by admins are stored under composite keys and win over the defaults:
+		"ACTION_TYPE~LOGIN~"      -> ActionType
+		"RESOURCE_TYPE~SESSION~"  -> ResourceType
+	So adding LOGIN/EXPORT or restricting the actions on CREDENTIAL needs no chaincode upgrade.
+
+Writes are admin-only (requireAdmin, identity.go)
+*/
+
+// RegistryContract provides functions for managing action and resource types
+type RegistryContract struct {
+	contractapi.Contract
+}
+
+const (
+	actionTypeKeyPrefix   = "ACTION_TYPE"
+	resourceTypeKeyPrefix = "RESOURCE_TYPE"
+)
+
+// Type names are upper snake case: CREATE, CREDENTIAL, ACCESS_TOKEN
+var typeNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,31}$`)
+
+// defaultActionTypes are available on every channel unless overridden on the ledger
+var defaultActionTypes = []ActionType{
+	{Name: "CREATE", Description: "Resource created", Mutating: true},
+	{Name: "UPDATE", Description: "Resource changed", Mutating: true},
+	{Name: "DELETE", Description: "Resource removed", Mutating: true},
+	{Name: "QUERY", Description: "Resource read", Mutating: false},
+	{Name: "VERIFY", Description: "Resource checked for validity", Mutating: false},
+	{Name: "REVOKE", Description: "Resource revoked", Mutating: true},
+	{Name: "ISSUE", Description: "Resource issued", Mutating: true},
+}
+
+/*
+--- REGISTER ACTION TYPE ---
+Adds a new action to the vocabulary (admin only)
+- mutating: true if the action changes the resource (OldValue/NewValue are meaningful)
+*/
+func (c *RegistryContract) RegisterActionType(ctx contractapi.TransactionContextInterface,
+	name string, description string, mutating bool) error {
+
+	lg := txLog(ctx, "RegisterActionType")
+	lg.Enter("name", name, "mutating", mutating)
+
+	// Input validation
+	if !typeNamePattern.MatchString(name) {
+		return invalidArgument("name", "invalid action name %q: must match %s", name, typeNamePattern)
+	}
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		lg.Reject("DENIED", err, "name", name)
+		return err
+	}
+
+	existing, err := getActionType(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return alreadyExists("action type %s already exists", name)
+	}
+
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+
+	actionType := ActionType{
+		Name:        name,
+		Description: description,
+		Mutating:    mutating,
+		CreatedBy:   callerID,
+		CreatedAt:   nowMillis,
+	}
+	err = putRegistryRecord(ctx, actionTypeKeyPrefix, name, actionType)
+	if err != nil {
+		lg.Fail("ERROR", err, "name", name)
+		return err
+	}
+
+	lg.Done("name", name)
+	return nil
+}
+
+/*
+--- REGISTER RESOURCE TYPE ---
+Adds a new resource type and the actions allowed on it (admin only)
+- allowedActionsJSON: ["CREATE","UPDATE","LOGIN"], every action must already be registered
+*/
+func (c *RegistryContract) RegisterResourceType(ctx contractapi.TransactionContextInterface,
+	name string, description string, allowedActionsJSON string) error {
+
+	lg := txLog(ctx, "RegisterResourceType")
+	lg.Enter("name", name)
+
+	// Input validation
+	if !typeNamePattern.MatchString(name) {
+		return invalidArgument("name", "invalid resource type name %q: must match %s", name, typeNamePattern)
+	}
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		lg.Reject("DENIED", err, "name", name)
+		return err
+	}
+
+	existing, err := getResourceType(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return alreadyExists("resource type %s already exists, use SetResourceTypeActions to change it", name)
+	}
+
+	allowedActions, err := parseAllowedActions(ctx, allowedActionsJSON)
+	if err != nil {
+		return err
+	}
+
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+
+	resourceType := ResourceType{
+		Name:           name,
+		Description:    description,
+		AllowedActions: allowedActions,
+		CreatedBy:      callerID,
+		CreatedAt:      nowMillis,
+		UpdatedAt:      nowMillis,
+	}
+	err = putRegistryRecord(ctx, resourceTypeKeyPrefix, name, resourceType)
+	if err != nil {
+		lg.Fail("ERROR", err, "name", name)
+		return err
+	}
+
+	lg.Done("name", name, "actions", allowedActions)
+	return nil
+}
+
+/*
+--- SET RESOURCE TYPE ACTIONS ---
+Replaces the allowed actions of a registered resource type (admin only)
+*/
+func (c *RegistryContract) SetResourceTypeActions(ctx contractapi.TransactionContextInterface,
+	name string, allowedActionsJSON string) error {
+
+	lg := txLog(ctx, "SetResourceTypeActions")
+	lg.Enter("name", name)
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		lg.Reject("DENIED", err, "name", name)
+		return err
+	}
+
+	resourceType, err := getResourceType(ctx, name)
+	if err != nil {
+		return err
+	}
+	if resourceType == nil {
+		return notFound("resource type %s does not exist", name)
+	}
+
+	allowedActions, err := parseAllowedActions(ctx, allowedActionsJSON)
+	if err != nil {
+		return err
+	}
+
+	resourceType.AllowedActions = allowedActions
+	err = putResourceTypeUpdate(ctx, resourceType)
+	if err != nil {
+		lg.Fail("ERROR", err, "name", name)
+		return err
+	}
+
+	lg.Done("name", name, "actions", allowedActions)
+	return nil
+}
+
+/*
+--- SET RESOURCE TYPE SCHEMA ---
+Stores the JSON Schema LogAudit enforces for a resource type (admin only)
+- field: "value" (OldValue + NewValue) or "metadata" (Metadata)
+- schemaJSON: a self-contained JSON Schema, "" removes the schema
+*/
+func (c *RegistryContract) SetResourceTypeSchema(ctx contractapi.TransactionContextInterface,
+	name string, field string, schemaJSON string) error {
+
+	lg := txLog(ctx, "SetResourceTypeSchema")
+	lg.Enter("name", name, "field", field)
+
+	// Input validation
+	if field != schemaFieldValue && field != schemaFieldMetadata {
+		return invalidArgument("field", "invalid field %q: must be %q or %q", field, schemaFieldValue, schemaFieldMetadata)
+	}
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		lg.Reject("DENIED", err, "name", name)
+		return err
+	}
+
+	resourceType, err := getResourceType(ctx, name)
+	if err != nil {
+		return err
+	}
+	if resourceType == nil {
+		return notFound("resource type %s does not exist", name)
+	}
+
+	compiled := ""
+	if schemaJSON != "" {
+		compiled, err = compileSchema(schemaJSON)
+		if err != nil {
+			lg.Reject("INVALID", err, "name", name)
+			return err
+		}
+	}
+
+	if field == schemaFieldValue {
+		resourceType.ValueSchema = compiled
+	} else {
+		resourceType.MetadataSchema = compiled
+	}
+
+	err = putResourceTypeUpdate(ctx, resourceType)
+	if err != nil {
+		lg.Fail("ERROR", err, "name", name)
+		return err
+	}
+
+	lg.Done("name", name, "field", field, "cleared", compiled == "")
+	return nil
+}
+
+// GetActionType returns a registered action type (ledger first, then built-in defaults)
+func (c *RegistryContract) GetActionType(ctx contractapi.TransactionContextInterface, name string) (*ActionType, error) {
+	lg := txLog(ctx, "GetActionType")
+	lg.Enter("name", name)
+
+	actionType, err := getActionType(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if actionType == nil {
+		return nil, notFound("action type %s does not exist", name)
+	}
+	return actionType, nil
+}
+
+// GetResourceType returns a registered resource type
+func (c *RegistryContract) GetResourceType(ctx contractapi.TransactionContextInterface, name string) (*ResourceType, error) {
+	lg := txLog(ctx, "GetResourceType")
+	lg.Enter("name", name)
+
+	resourceType, err := getResourceType(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if resourceType == nil {
+		return nil, notFound("resource type %s does not exist", name)
+	}
+	return resourceType, nil
+}
+
+// ListActionTypes returns built-in and ledger action types, sorted by name
+func (c *RegistryContract) ListActionTypes(ctx contractapi.TransactionContextInterface) ([]*ActionType, error) {
+	lg := txLog(ctx, "ListActionTypes")
+	lg.Enter()
+
+	byName := map[string]*ActionType{}
+	for i := range defaultActionTypes {
+		actionType := defaultActionTypes[i]
+		actionType.BuiltIn = true
+		byName[actionType.Name] = &actionType
+	}
+
+	err := scanRegistry(ctx, actionTypeKeyPrefix, func(value []byte) error {
+		var actionType ActionType
+		if err := json.Unmarshal(value, &actionType); err != nil {
+			return internalError("failed to unmarshal action type: %v", err)
+		}
+		byName[actionType.Name] = &actionType
+		return nil
+	})
+	if err != nil {
+		lg.Fail("ERROR", err)
+		return nil, err
+	}
+
+	actionTypes := make([]*ActionType, 0, len(byName))
+	for _, actionType := range byName {
+		actionTypes = append(actionTypes, actionType)
+	}
+	sort.Slice(actionTypes, func(i, j int) bool { return actionTypes[i].Name < actionTypes[j].Name })
+
+	lg.Done("count", len(actionTypes))
+	return actionTypes, nil
+}
+
+// ListResourceTypes returns the registered resource types, sorted by name
+func (c *RegistryContract) ListResourceTypes(ctx contractapi.TransactionContextInterface) ([]*ResourceType, error) {
+	lg := txLog(ctx, "ListResourceTypes")
+	lg.Enter()
+
+	byName := map[string]*ResourceType{}
+	err := scanRegistry(ctx, resourceTypeKeyPrefix, func(value []byte) error {
+		var resourceType ResourceType
+		if err := json.Unmarshal(value, &resourceType); err != nil {
+			return internalError("failed to unmarshal resource type: %v", err)
+		}
+		byName[resourceType.Name] = &resourceType
+		return nil
+	})
+	if err != nil {
+		lg.Fail("ERROR", err)
+		return nil, err
+	}
+
+	resourceTypes := make([]*ResourceType, 0, len(byName))
+	for _, resourceType := range byName {
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	sort.Slice(resourceTypes, func(i, j int) bool { return resourceTypes[i].Name < resourceTypes[j].Name })
+
+	lg.Done("count", len(resourceTypes))
+	return resourceTypes, nil
+}
+
+/*
+--- validateAuditAction (helper) ---
+Checks an audit entry's action and resource type against the registry:
+ 1. action must be a registered action type
+ 2. a registered resource type must allow the action
+ 3. an unregistered resource type (or none) accepts any action, without schemas
+*/
+func validateAuditAction(ctx contractapi.TransactionContextInterface, resourceType string, action string) (*ActionType, *ResourceType, error) {
+	actionType, err := getActionType(ctx, action)
+	if err != nil {
+		return nil, nil, err
+	}
+	if actionType == nil {
+		return nil, nil, invalidArgument("action", "invalid action: %s is not a registered action type", action)
+	}
+
+	resType, err := getResourceType(ctx, resourceType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resType == nil {
+		return actionType, &ResourceType{Name: resourceType}, nil
+	}
+
+	for _, allowed := range resType.AllowedActions {
+		if allowed == action {
+			return actionType, resType, nil
+		}
+	}
+	return nil, nil, invalidArgument("action", "action %s is not allowed on resource type %s (allowed: %v)", action, resourceType, resType.AllowedActions)
+}
+
+// getActionType looks up an action type, nil if it is not registered anywhere
+func getActionType(ctx contractapi.TransactionContextInterface, name string) (*ActionType, error) {
+	var actionType ActionType
+	found, err := getRegistryRecord(ctx, actionTypeKeyPrefix, name, &actionType)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return &actionType, nil
+	}
+
+	for _, builtIn := range defaultActionTypes {
+		if builtIn.Name == name {
+			builtIn.BuiltIn = true
+			return &builtIn, nil
+		}
+	}
+	return nil, nil
+}
+
+// getResourceType looks up a resource type, nil if it is not registered
+func getResourceType(ctx contractapi.TransactionContextInterface, name string) (*ResourceType, error) {
+	var resourceType ResourceType
+	found, err := getRegistryRecord(ctx, resourceTypeKeyPrefix, name, &resourceType)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return &resourceType, nil
+	}
+
+	return nil, nil
+}
+
+// putResourceTypeUpdate writes a changed resource type
+func putResourceTypeUpdate(ctx contractapi.TransactionContextInterface, resourceType *ResourceType) error {
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+	resourceType.UpdatedAt = nowMillis
+
+	return putRegistryRecord(ctx, resourceTypeKeyPrefix, resourceType.Name, resourceType)
+}
+
+// parseAllowedActions decodes a JSON array of action names and checks each one is registered
+func parseAllowedActions(ctx contractapi.TransactionContextInterface, allowedActionsJSON string) ([]string, error) {
+	var allowedActions []string
+	err := json.Unmarshal([]byte(allowedActionsJSON), &allowedActions)
+	if err != nil {
+		return nil, invalidArgument("allowedActionsJSON", "allowedActionsJSON must be a JSON array of action names: %v", err)
+	}
+	if len(allowedActions) == 0 {
+		return nil, invalidArgument("allowedActionsJSON", "allowedActionsJSON must list at least one action")
+	}
+
+	seen := map[string]bool{}
+	for _, action := range allowedActions {
+		if seen[action] {
+			return nil, invalidArgument("allowedActionsJSON", "action %s listed twice", action)
+		}
+		seen[action] = true
+
+		actionType, err := getActionType(ctx, action)
+		if err != nil {
+			return nil, err
+		}
+		if actionType == nil {
+			return nil, invalidArgument("allowedActionsJSON", "action %s is not a registered action type", action)
+		}
+	}
+	sort.Strings(allowedActions)
+	return allowedActions, nil
+}
+
+// getRegistryRecord reads a registry record into out, found=false if there is no ledger record
+func getRegistryRecord(ctx contractapi.TransactionContextInterface, prefix string, name string, out interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(prefix, []string{name})
+	if err != nil {
+		return false, internalError("failed to create %s key for %s: %v", prefix, name, err)
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, internalError("failed to read %s %s: %v", prefix, name, err)
+	}
+	if value == nil {
+		return false, nil
+	}
+	err = json.Unmarshal(value, out)
+	if err != nil {
+		return false, internalError("failed to unmarshal %s %s: %v", prefix, name, err)
+	}
+	return true, nil
+}
+
+// putRegistryRecord writes a registry record under "<prefix>~<name>~"
+func putRegistryRecord(ctx contractapi.TransactionContextInterface, prefix string, name string, record interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(prefix, []string{name})
+	if err != nil {
+		return internalError("failed to create %s key for %s: %v", prefix, name, err)
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return internalError("failed to marshal %s %s: %v", prefix, name, err)
+	}
+	err = ctx.GetStub().PutState(key, value)
+	if err != nil {
+		return internalError("failed to write %s %s to ledger: %v", prefix, name, err)
+	}
+	return nil
+}
+
+// scanRegistry calls fn with every ledger record stored under prefix
+func scanRegistry(ctx contractapi.TransactionContextInterface, prefix string, fn func(value []byte) error) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(prefix, []string{})
+	if err != nil {
+		return internalError("failed to read %s records: %v", prefix, err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return internalError("failed to iterate %s records: %v", prefix, err)
+		}
+		err = fn(queryResponse.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	n := newNetwork(t)
+
+	// Writes are admin only, reads are open to every client
+	n.reject(CodePermissionDenied, n.client, "RegistryContract:RegisterActionType", "LOGIN", "User logged in", "false")
+	n.reject(CodeInvalidArgument, n.admin, "RegistryContract:RegisterActionType", "login", "User logged in", "false")
+	n.submit(n.admin, nil, "RegistryContract:RegisterActionType", "LOGIN", "User logged in", "false")
+	n.reject(CodeAlreadyExists, n.admin, "RegistryContract:RegisterActionType", "LOGIN", "User logged in", "false")
+	n.reject(CodeAlreadyExists, n.admin, "RegistryContract:RegisterActionType", "CREATE", "Resource created", "true")
+
+	var actionType ActionType
+	n.evaluate(n.client, &actionType, "RegistryContract:GetActionType", "LOGIN")
+	if actionType.BuiltIn || actionType.Mutating || actionType.CreatedBy == "" {
+		t.Errorf("LOGIN = %+v", actionType)
+	}
+	var actionTypes []*ActionType
+	n.evaluate(n.client, &actionTypes, "RegistryContract:ListActionTypes")
+	names := []string{}
+	for _, actionType := range actionTypes {
+		names = append(names, actionType.Name)
+	}
+	if got := strings.Join(names, ","); got != "CREATE,DELETE,ISSUE,LOGIN,QUERY,REVOKE,UPDATE,VERIFY" {
+		t.Errorf("ListActionTypes = %s", got)
+	}
+
+	n.reject(CodePermissionDenied, n.client, "RegistryContract:RegisterResourceType", "SESSION", "Login session", `["LOGIN"]`)
+	n.reject(CodeInvalidArgument, n.admin, "RegistryContract:RegisterResourceType", "SESSION", "Login session", `["LOGOUT"]`)
+	n.submit(n.admin, nil, "RegistryContract:RegisterResourceType", "SESSION", "Login session", `["LOGIN","QUERY"]`)
+
+	// LogAudit follows the registry without a chaincode upgrade
+	n.logAudit(auditArgs("audit-1", "user-alice", "LOGIN", "SESSION", "sess-1", "", ""))
+	n.reject(CodeInvalidArgument, n.client, auditArgs("audit-2", "user-alice", "VERIFY", "SESSION", "sess-1", "", "")...)
+
+	// Unregistered resource types accept every registered action until an admin registers them
+	n.logAudit(auditArgs("audit-2", "user-alice", "LOGIN", "CREDENTIAL", "cred-1", "", ""))
+	n.reject(CodeNotFound, n.admin, "RegistryContract:SetResourceTypeActions", "CREDENTIAL", `["ISSUE"]`)
+	n.submit(n.admin, nil, "RegistryContract:RegisterResourceType", "CREDENTIAL", "Issued credential", `["ISSUE","VERIFY","REVOKE","QUERY"]`)
+	n.reject(CodeInvalidArgument, n.client, auditArgs("audit-3", "user-alice", "LOGIN", "CREDENTIAL", "cred-1", "", "")...)
+	n.submit(n.admin, nil, "RegistryContract:SetResourceTypeActions", "CREDENTIAL", `["ISSUE","VERIFY","REVOKE","QUERY","LOGIN"]`)
+	n.logAudit(auditArgs("audit-3", "user-alice", "LOGIN", "CREDENTIAL", "cred-1", "", ""))
+	var resourceType ResourceType
+	n.evaluate(n.client, &resourceType, "RegistryContract:GetResourceType", "CREDENTIAL")
+	if len(resourceType.AllowedActions) != 5 || resourceType.CreatedBy == "" {
+		t.Errorf("CREDENTIAL = %+v", resourceType)
+	}
+	var resourceTypes []*ResourceType
+	n.evaluate(n.client, &resourceTypes, "RegistryContract:ListResourceTypes")
+	if len(resourceTypes) != 2 {
+		t.Errorf("ListResourceTypes = %d types", len(resourceTypes))
+	}
+	n.reject(CodeNotFound, n.admin, "RegistryContract:SetResourceTypeActions", "DEVICE", `["QUERY"]`)
+}
+
+func TestResourceTypeSchema(t *testing.T) {
+	n := newNetwork(t)
+
+	schema := `{"type":"object","required":["status"],"properties":{"status":{"enum":["ACTIVE","REVOKED"]}}}`
+	n.reject(CodeNotFound, n.admin, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "value", schema)
+	n.submit(n.admin, nil, "RegistryContract:RegisterResourceType", "CREDENTIAL", "Issued credential", `["ISSUE","VERIFY","REVOKE","QUERY"]`)
+	n.reject(CodePermissionDenied, n.client, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "value", schema)
+	n.reject(CodeInvalidArgument, n.admin, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "payload", schema)
+	n.reject(CodeInvalidArgument, n.admin, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "value", `{"$ref":"https://example.com/schema.json"}`)
+	// Several bad refs: the first in key order is reported, whatever the map order
+	remote := `{"properties":{"b":{"$ref":"https://example.com/b.json"},"a":{"$ref":"https://example.com/a.json"}}}`
+	for i := 0; i < 10; i++ {
+		envelope := n.reject(CodeInvalidArgument, n.admin, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "value", remote)
+		if !strings.Contains(envelope.Message, "/properties/a/$ref") {
+			t.Fatalf("remote refs = %+v", envelope)
+		}
+	}
+	n.submit(n.admin, nil, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "value", schema)
+
+	n.logAudit(auditArgs("audit-1", "user-alice", "ISSUE", "CREDENTIAL", "cred-1", "", `{"status":"ACTIVE"}`))
+	envelope := n.reject(CodeInvalidArgument, n.client, auditArgs("audit-2", "user-alice", "REVOKE", "CREDENTIAL", "cred-1",
+		`{"status":"ACTIVE"}`, `{"status":"EXPIRED"}`)...)
+	if !strings.Contains(envelope.Message, "newValue#/status") {
+		t.Errorf("schema violation = %+v", envelope)
+	}
+	// Other resource types are not affected
+	n.logAudit(auditArgs("audit-2", "user-alice", "UPDATE", "USER", "user-1", "", `{"status":"EXPIRED"}`))
+
+	// An empty schema removes it again
+	n.submit(n.admin, nil, "RegistryContract:SetResourceTypeSchema", "CREDENTIAL", "value", "")
+	n.logAudit(auditArgs("audit-3", "user-alice", "REVOKE", "CREDENTIAL", "cred-1", `{"status":"ACTIVE"}`, `{"status":"EXPIRED"}`))
+}
@@ -0,0 +1,172 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ resources.go reads the audit entries of one resource as its change history:
+	- GetResourceTimeline - Every entry for resourceType + resourceId, oldest first, ties by audit ID
+	- ReconstructResourceAt - The resource's state at a moment, rebuilt from NewValue snapshots
+
+Replay rules (ReconstructResourceAt):
+	- Entries are applied oldest first, up to and including the requested timestamp
+	- Entries with the same timestamp are applied in audit ID order, so every peer replays them alike
+	- Only SUCCESS entries of mutating action types (registry.go) change the state
+	- A non-empty NewValue replaces the state (NewValue is a full snapshot, not a patch)
+	- DELETE ends the resource's existence until a later entry brings it back
+	- QUERY / VERIFY entries are skipped, so a dispute can ask for the state as of a VERIFY:
+		ReconstructResourceAt("CREDENTIAL", "cred-001", <timestamp of the VERIFY entry>)
+
+Both queries are backed by META-INF/statedb/couchdb/indexes/indexResource.json
+*/
+
+/*
+--- GET RESOURCE TIMELINE ---
+Returns every audit entry for one resource, oldest first
+*/
+func (c *AuditContract) GetResourceTimeline(ctx contractapi.TransactionContextInterface,
+	resourceType string, resourceId string) ([]*AuditEntry, error) {
+
+	lg := txLog(ctx, "GetResourceTimeline")
+	lg.Enter("resourceType", resourceType, "resourceId", resourceId)
+
+	// Input validation
+	if resourceType == "" {
+		return nil, invalidArgument("resourceType", "resourceType is required")
+	}
+	if resourceId == "" {
+		return nil, invalidArgument("resourceId", "resourceId is required")
+	}
+
+	entries, err := c.queryResourceEntries(ctx, lg, resourceType, resourceId, -1)
+	if err != nil {
+		return nil, err
+	}
+	lg.Done("resourceId", resourceId, "entries", len(entries))
+	return entries, nil
+}
+
+/*
+--- RECONSTRUCT RESOURCE AT ---
+Replays a resource's audit entries up to ts (Unix milliseconds, inclusive)
+and returns the state it had at that moment
+*/
+func (c *AuditContract) ReconstructResourceAt(ctx contractapi.TransactionContextInterface,
+	resourceType string, resourceId string, ts int64) (*ResourceState, error) {
+
+	lg := txLog(ctx, "ReconstructResourceAt")
+	lg.Enter("resourceType", resourceType, "resourceId", resourceId, "ts", ts)
+
+	// Input validation
+	if resourceType == "" {
+		return nil, invalidArgument("resourceType", "resourceType is required")
+	}
+	if resourceId == "" {
+		return nil, invalidArgument("resourceId", "resourceId is required")
+	}
+	if ts < 0 {
+		return nil, invalidArgument("ts", "ts must be a positive timestamp")
+	}
+
+	entries, err := c.queryResourceEntries(ctx, lg, resourceType, resourceId, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := replayResourceEntries(ctx, entries)
+	if err != nil {
+		lg.Fail("ERROR", err, "resourceId", resourceId)
+		return nil, err
+	}
+	state.ResourceType = resourceType
+	state.ResourceID = resourceId
+	state.At = ts
+
+	lg.Done("resourceId", resourceId, "exists", state.Exists, "asOf", state.AsOfAuditID, "applied", state.AppliedEntries)
+	return state, nil
+}
+
+// replayResourceEntries folds a resource's entries (oldest first) into its final state
+func replayResourceEntries(ctx contractapi.TransactionContextInterface, entries []*AuditEntry) (*ResourceState, error) {
+	state := &ResourceState{}
+	mutating := mutatingActions{}
+
+	for _, entry := range entries {
+		changesState, err := mutating.changesState(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !changesState {
+			continue
+		}
+
+		switch {
+		case entry.Action == "DELETE":
+			state.Exists = false
+			state.State = ""
+		case entry.NewValue != "":
+			state.Exists = true
+			state.State = entry.NewValue
+		default:
+			// Mutating entry without a snapshot, the state is unchanged
+			continue
+		}
+		state.AsOfAuditID = entry.ID
+		state.AsOfTimestamp = entry.TimeStamp
+		state.AsOfTxID = entry.TxID
+		state.AppliedEntries++
+	}
+	return state, nil
+}
+
+// mutatingActions caches registry lookups of ActionType.Mutating during a replay
+type mutatingActions map[string]bool
+
+// changesState reports whether an entry can change its resource: SUCCESS and a mutating action
+func (m mutatingActions) changesState(ctx contractapi.TransactionContextInterface, entry *AuditEntry) (bool, error) {
+	if entry.Status != "SUCCESS" {
+		return false, nil
+	}
+
+	isMutating, seen := m[entry.Action]
+	if !seen {
+		actionType, err := getActionType(ctx, entry.Action)
+		if err != nil {
+			return false, err
+		}
+		isMutating = actionType != nil && actionType.Mutating
+		m[entry.Action] = isMutating
+	}
+	return isMutating, nil
+}
+
+// queryResourceEntries runs the resource query, until < 0 means no upper time bound
+func (c *AuditContract) queryResourceEntries(ctx contractapi.TransactionContextInterface, lg *txLogger,
+	resourceType string, resourceId string, until int64) ([]*AuditEntry, error) {
+
+	selector := map[string]interface{}{
+		"resourceType": resourceType,
+		"resourceId":   resourceId,
+	}
+	if until >= 0 {
+		selector["timestamp"] = map[string]interface{}{"$lte": until}
+	} else {
+		selector["timestamp"] = map[string]interface{}{"$gte": 0}
+	}
+
+	// Sort on every index field so CouchDB picks indexResourceTimestamp, id breaks timestamp ties
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector":  selector,
+		"sort":      []map[string]string{{"resourceType": "asc"}, {"resourceId": "asc"}, {"timestamp": "asc"}, {"id": "asc"}},
+		"use_index": []string{"_design/indexResourceTimestamp", "indexResourceTimestamp"},
+	})
+	if err != nil {
+		return nil, internalError("failed to build query: %v", err)
+	}
+
+	return c.queryAudits(ctx, string(queryJSON))
+}
@@ -0,0 +1,192 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ schema.go validates the JSON payload fields of an AuditEntry (OldValue, NewValue, Metadata)
+
+Always enforced by LogAudit:
+	- Field is empty, or well-formed JSON
+	- Field is not double-encoded: "\"{\\\"status\\\":\\\"ACTIVE\\\"}\"" is a JSON string
+	  holding JSON, producers must send {"status":"ACTIVE"} instead
+
+Per resource type (optional, stored on the ledger with the ResourceType, see registry.go):
+	- valueSchema    - JSON Schema applied to OldValue and NewValue
+	- metadataSchema - JSON Schema applied to Metadata
+	Set with RegistryContract:SetResourceTypeSchema(resourceType, "value" | "metadata", schemaJSON)
+
+Errors carry the JSON Pointer of the offending value:
+	newValue#/status: status must be one of the following: "ACTIVE", "REVOKED"
+
+Schemas must be self-contained: "$ref" may only point inside the schema ("#/definitions/..."),
+remote refs would make peers fetch over the network and break determinism.
+*/
+
+// Schema slots a resource type can carry
+const (
+	schemaFieldValue    = "value"
+	schemaFieldMetadata = "metadata"
+)
+
+// validateJSONPayload checks one payload field is empty or well-formed, single-encoded JSON
+func validateJSONPayload(field string, value string) error {
+	if value == "" {
+		return nil
+	}
+	if !json.Valid([]byte(value)) {
+		return invalidArgument(field, "%s is not well-formed JSON", field)
+	}
+
+	// A top level JSON string that itself parses as an object/array was encoded twice
+	var inner string
+	if json.Unmarshal([]byte(value), &inner) == nil {
+		trimmed := strings.TrimSpace(inner)
+		if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+			return invalidArgument(field, "%s is double-encoded JSON (a JSON string containing JSON), send the object itself", field)
+		}
+	}
+	return nil
+}
+
+// validateAgainstSchema validates a non-empty payload field against a stored JSON Schema
+func validateAgainstSchema(field string, value string, schemaJSON string) error {
+	if value == "" || schemaJSON == "" {
+		return nil
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schemaJSON))
+	if err != nil {
+		return internalError("stored schema for %s is invalid: %v", field, err)
+	}
+
+	result, err := schema.Validate(gojsonschema.NewStringLoader(value))
+	if err != nil {
+		return internalError("failed to validate %s: %v", field, err)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	// Sort so every peer returns the exact same error text, one detail per offending location
+	var problems []string
+	var details []FieldViolation
+	for _, resultErr := range result.Errors() {
+		problems = append(problems, fmt.Sprintf("%s#%s: %s", field, jsonPointer(resultErr.Context()), resultErr.Description()))
+	}
+	sort.Strings(problems)
+	for _, problem := range problems {
+		location, description, _ := strings.Cut(problem, ": ")
+		details = append(details, FieldViolation{Field: location, Description: description})
+	}
+	return &ContractError{
+		Code:    CodeInvalidArgument,
+		Message: fmt.Sprintf("%s does not match the %s schema: %s", field, field, strings.Join(problems, "; ")),
+		Details: details,
+	}
+}
+
+// jsonPointer turns a gojsonschema context ("(root).items.0.status") into "/items/0/status"
+func jsonPointer(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return ""
+	}
+	return strings.TrimPrefix(context.String("/"), "(root)")
+}
+
+// compileSchema checks a schema compiles and is self-contained, returns it compacted for storage
+func compileSchema(schemaJSON string) (string, error) {
+	var document interface{}
+	err := json.Unmarshal([]byte(schemaJSON), &document)
+	if err != nil {
+		return "", invalidArgument("schemaJSON", "schema is not well-formed JSON: %v", err)
+	}
+	if _, ok := document.(map[string]interface{}); !ok {
+		return "", invalidArgument("schemaJSON", "schema must be a JSON object")
+	}
+
+	err = checkLocalRefs(document, "")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = gojsonschema.NewSchema(gojsonschema.NewStringLoader(schemaJSON))
+	if err != nil {
+		return "", invalidArgument("schemaJSON", "invalid JSON Schema: %v", err)
+	}
+
+	var compact bytes.Buffer
+	err = json.Compact(&compact, []byte(schemaJSON))
+	if err != nil {
+		return "", internalError("failed to compact schema: %v", err)
+	}
+	return compact.String(), nil
+}
+
+// checkLocalRefs rejects any "$ref" that does not point inside the schema
+func checkLocalRefs(node interface{}, path string) error {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		// Walk keys in sorted order so a schema with several bad refs gets the same error on every peer
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := typed[key]
+			if key == "$ref" {
+				ref, _ := child.(string)
+				if !strings.HasPrefix(ref, "#") {
+					return invalidArgument("schemaJSON", "schema %s/$ref %q must be a local reference (start with #)", path, ref)
+				}
+				continue
+			}
+			err := checkLocalRefs(child, path+"/"+key)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range typed {
+			err := checkLocalRefs(child, fmt.Sprintf("%s/%d", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateAuditPayloads runs the JSON checks LogAudit applies to OldValue, NewValue and Metadata
+func validateAuditPayloads(resourceType *ResourceType, oldValue string, newValue string, metadata string) error {
+	payloads := []struct {
+		field  string
+		value  string
+		schema string
+	}{
+		{"oldValue", oldValue, resourceType.ValueSchema},
+		{"newValue", newValue, resourceType.ValueSchema},
+		{"metadata", metadata, resourceType.MetadataSchema},
+	}
+
+	for _, payload := range payloads {
+		err := validateJSONPayload(payload.field, payload.value)
+		if err != nil {
+			return err
+		}
+		err = validateAgainstSchema(payload.field, payload.value, payload.schema)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ sessions.go holds the session records AuditEntry.SessionID points at:
+	- StartSession - Open a session for an ACTIVE user (IP, auth method, start time)
+	- EndSession - Close it (end time + reason)
+	- GetSession - Read one session
+	- GetSessionTimeline - The session plus every audit entry logged in it, oldest first
+	- validateAuditSession (helper) - LogAudit only accepts OPEN sessions that belong to the entry's user
+
+Storage:
+	"SESSION~sess-001~" -> Session
+	Sessions carry docType "session": audit rich queries (queryAudits) skip every document
+	with a docType, so a session's userId never shows up in QueryAuditsByUser.
+
+GetSessionTimeline is backed by META-INF/statedb/couchdb/indexes/indexSessionId.json
+*/
+
+// Session statuses
+const (
+	SessionStatusOpen   = "OPEN"
+	SessionStatusClosed = "CLOSED"
+)
+
+// docTypeSession marks session documents in CouchDB
+const docTypeSession = "session"
+
+// validAuthMethods lists how a session may have been authenticated
+var validAuthMethods = map[string]bool{
+	"PASSWORD": true, "SSO": true, "MFA": true, "API_KEY": true, "CERTIFICATE": true,
+}
+
+/*
+--- START SESSION ---
+Opens a session for a registered, ACTIVE user
+- authMethod: PASSWORD, SSO, MFA, API_KEY or CERTIFICATE
+*/
+func (c *AuditContract) StartSession(ctx contractapi.TransactionContextInterface,
+	id string, userId string, ipAddress string, authMethod string) error {
+
+	lg := txLog(ctx, "StartSession")
+	lg.Enter("sessionId", id, "userId", userId, "authMethod", authMethod)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+	if len(id) > 64 {
+		return invalidArgument("id", "id exceeds maximum length of 64 characters")
+	}
+	if userId == "" {
+		return invalidArgument("userId", "userId is required")
+	}
+	if !validAuthMethods[authMethod] {
+		return invalidArgument("authMethod", "invalid authMethod: %s. Valid methods: PASSWORD, SSO, MFA, API_KEY, CERTIFICATE", authMethod)
+	}
+
+	existing, err := getSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return alreadyExists("session %s already exists", pii(id))
+	}
+
+	// Only active users can open sessions
+	user, err := (&UserContract{}).GetUser(ctx, userId)
+	if err != nil {
+		lg.Fail("ERROR", err, "sessionId", id)
+		return wrapError(err, "failed to get session user %s", pii(userId))
+	}
+	if user.Status != UserStatusActive {
+		return failedPrecondition("cannot start session for user %s with status %s", pii(userId), user.Status)
+	}
+
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+
+	session := Session{
+		DocType:    docTypeSession,
+		ID:         id,
+		UserID:     userId,
+		IPAddress:  ipAddress,
+		AuthMethod: authMethod,
+		Status:     SessionStatusOpen,
+		StartedAt:  nowMillis,
+		StartTxID:  ctx.GetStub().GetTxID(),
+	}
+	err = putSession(ctx, &session)
+	if err != nil {
+		lg.Fail("ERROR", err, "sessionId", id)
+		return err
+	}
+
+	lg.Done("sessionId", id, "userId", userId)
+	return nil
+}
+
+/*
+--- END SESSION ---
+Closes an OPEN session, later LogAudit calls referencing it are rejected
+*/
+func (c *AuditContract) EndSession(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	lg := txLog(ctx, "EndSession")
+	lg.Enter("sessionId", id)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+
+	session, err := getSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return notFound("session %s does not exist", pii(id))
+	}
+	if session.Status != SessionStatusOpen {
+		return failedPrecondition("session %s is already %s", pii(id), session.Status)
+	}
+
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+
+	session.Status = SessionStatusClosed
+	session.EndedAt = nowMillis
+	session.EndReason = reason
+	session.EndTxID = ctx.GetStub().GetTxID()
+
+	err = putSession(ctx, session)
+	if err != nil {
+		lg.Fail("ERROR", err, "sessionId", id)
+		return err
+	}
+
+	lg.Done("sessionId", id, "durationMs", session.EndedAt-session.StartedAt)
+	return nil
+}
+
+// GetSession returns a session by ID
+func (c *AuditContract) GetSession(ctx contractapi.TransactionContextInterface, id string) (*Session, error) {
+	lg := txLog(ctx, "GetSession")
+	lg.Enter("sessionId", id)
+
+	// Input validation
+	if id == "" {
+		return nil, invalidArgument("id", "id is required")
+	}
+
+	session, err := getSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		lg.Reject("NOT_FOUND", nil, "sessionId", id)
+		return nil, notFound("session %s does not exist", pii(id))
+	}
+	return session, nil
+}
+
+/*
+--- GET SESSION TIMELINE ---
+Returns the session and every audit entry logged under it, oldest first
+*/
+func (c *AuditContract) GetSessionTimeline(ctx contractapi.TransactionContextInterface, sessionId string) (*SessionTimeline, error) {
+	lg := txLog(ctx, "GetSessionTimeline")
+	lg.Enter("sessionId", sessionId)
+
+	session, err := c.GetSession(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort on every index field so CouchDB picks indexSessionIdTimestamp, id breaks timestamp ties
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector":  map[string]interface{}{"sessionId": sessionId},
+		"sort":      []map[string]string{{"sessionId": "asc"}, {"timestamp": "asc"}, {"id": "asc"}},
+		"use_index": []string{"_design/indexSessionIdTimestamp", "indexSessionIdTimestamp"},
+	})
+	if err != nil {
+		return nil, internalError("failed to build query: %v", err)
+	}
+
+	entries, err := c.queryAudits(ctx, string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	lg.Done("sessionId", sessionId, "entries", len(entries))
+	return &SessionTimeline{Session: session, Entries: entries}, nil
+}
+
+/*
+--- validateAuditSession (helper) ---
+LogAudit check: a referenced session must exist, be OPEN and belong to the entry's user
+- pending: sessions written earlier in the same transaction, GetState does not see them yet
+*/
+func validateAuditSession(ctx contractapi.TransactionContextInterface, sessionId string, userId string, pending map[string]*Session) error {
+	if sessionId == "" {
+		return nil
+	}
+
+	session, err := getSession(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	if pendingSession, ok := pending[sessionId]; ok {
+		session = pendingSession
+	}
+	if session == nil {
+		return notFound("session %s does not exist", pii(sessionId))
+	}
+	if session.Status != SessionStatusOpen {
+		return failedPrecondition("session %s is %s", pii(sessionId), session.Status)
+	}
+	if session.UserID != userId {
+		return invalidArgument("sessionId", "session %s does not belong to user %s", pii(sessionId), pii(userId))
+	}
+	return nil
+}
+
+// getSession reads a session, nil if it does not exist
+func getSession(ctx contractapi.TransactionContextInterface, id string) (*Session, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("SESSION", []string{id})
+	if err != nil {
+		return nil, internalError("failed to create composite key for session ID=%s: %v", pii(id), err)
+	}
+	sessionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, internalError("failed to read session ID=%s from ledger: %v", pii(id), err)
+	}
+	if sessionJSON == nil {
+		return nil, nil
+	}
+
+	var session Session
+	err = json.Unmarshal(sessionJSON, &session)
+	if err != nil {
+		return nil, internalError("failed to unmarshal session ID=%s: %v", pii(id), err)
+	}
+	return &session, nil
+}
+
+// putSession writes a session under its "SESSION" composite key
+func putSession(ctx contractapi.TransactionContextInterface, session *Session) error {
+	key, err := ctx.GetStub().CreateCompositeKey("SESSION", []string{session.ID})
+	if err != nil {
+		return internalError("failed to create composite key for session ID=%s: %v", pii(session.ID), err)
+	}
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return internalError("failed to marshal session ID=%s: %v", pii(session.ID), err)
+	}
+	err = ctx.GetStub().PutState(key, sessionJSON)
+	if err != nil {
+		return internalError("failed to write session ID=%s to ledger: %v", pii(session.ID), err)
+	}
+	return nil
+}
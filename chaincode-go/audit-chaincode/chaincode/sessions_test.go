@@ -0,0 +1,207 @@
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// withSession sets the sessionId argument of LogAudit args
+func withSession(args []string, sessionID string) []string {
+	args[11] = sessionID
+	return args
+}
+
+// traceArgs are the LogAuditWithTrace arguments of a QUERY entry on cred-1
+func traceArgs(id string, correlationID string, parentID string, causedBy string) []string {
+	args := auditArgs(id, "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", "")
+	args[0] = "LogAuditWithTrace"
+	return append(args, correlationID, parentID, causedBy)
+}
+
+func TestSessions(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("user-alice", "USER")
+	n.registerUser("user-bob", "USER")
+
+	openedAt := n.millis()
+	n.submit(n.client, nil, "StartSession", "sess-1", "user-alice", "10.0.0.1", "MFA")
+	n.reject(CodeAlreadyExists, n.client, "StartSession", "sess-1", "user-alice", "10.0.0.1", "MFA")
+	n.reject(CodeInvalidArgument, n.client, "StartSession", "sess-2", "user-alice", "10.0.0.1", "SMS")
+	n.reject(CodeNotFound, n.client, "StartSession", "sess-2", "user-nobody", "10.0.0.1", "MFA")
+
+	// Only the session's own user can log under it
+	n.logAudit(withSession(auditArgs("audit-1", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""), "sess-1"))
+	n.logAudit(withSession(auditArgs("audit-2", "user-alice", "VERIFY", "CREDENTIAL", "cred-1", "", ""), "sess-1"))
+	n.reject(CodeInvalidArgument, n.client, withSession(auditArgs("audit-3", "user-bob", "QUERY", "CREDENTIAL", "cred-1", "", ""), "sess-1")...)
+	n.reject(CodeNotFound, n.client, withSession(auditArgs("audit-3", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""), "sess-9")...)
+
+	var timeline SessionTimeline
+	n.evaluate(n.client, &timeline, "GetSessionTimeline", "sess-1")
+	if timeline.Session.StartedAt != openedAt || timeline.Session.Status != SessionStatusOpen || auditIDs(timeline.Entries) != "audit-1,audit-2" {
+		t.Errorf("timeline = %+v %s", timeline.Session, auditIDs(timeline.Entries))
+	}
+
+	closedAt := n.millis()
+	n.submit(n.client, nil, "EndSession", "sess-1", "LOGOUT")
+	n.reject(CodeFailedPrecondition, n.client, "EndSession", "sess-1", "LOGOUT")
+	n.reject(CodeFailedPrecondition, n.client, withSession(auditArgs("audit-3", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""), "sess-1")...)
+
+	var session Session
+	n.evaluate(n.client, &session, "GetSession", "sess-1")
+	if session.Status != SessionStatusClosed || session.EndedAt != closedAt || session.EndReason != "LOGOUT" || session.EndTxID == session.StartTxID {
+		t.Errorf("session = %+v", session)
+	}
+
+	// Sessions are stored next to the entries but never returned as entries
+	var entries []*AuditEntry
+	n.evaluate(n.client, &entries, "QueryAuditsByUser", "user-alice")
+	if got := auditIDs(entries); got != "audit-2,audit-1" {
+		t.Errorf("QueryAuditsByUser = %s", got)
+	}
+
+	// Entries sharing a timestamp come back in audit ID order
+	n.submit(n.client, nil, "StartSession", "sess-3", "user-alice", "10.0.0.1", "MFA")
+	n.ledger.SetClock(n.ledger.Now(), 0)
+	n.logAudit(withSession(auditArgs("audit-5", "user-alice", "QUERY", "CREDENTIAL", "cred-1", "", ""), "sess-3"))
+	n.logAudit(withSession(auditArgs("audit-4", "user-alice", "VERIFY", "CREDENTIAL", "cred-1", "", ""), "sess-3"))
+	n.ledger.SetClock(n.ledger.Now(), time.Second)
+	n.evaluate(n.client, &timeline, "GetSessionTimeline", "sess-3")
+	if got := auditIDs(timeline.Entries); got != "audit-4,audit-5" {
+		t.Errorf("tied timeline = %s", got)
+	}
+
+	// A suspended user cannot open a session
+	n.submit(n.admin, nil, "UserContract:SuspendUser", "user-bob", "0")
+	n.reject(CodeFailedPrecondition, n.client, "StartSession", "sess-2", "user-bob", "10.0.0.2", "SSO")
+}
+
+func TestTrace(t *testing.T) {
+	n := newNetwork(t)
+
+	n.logAudit(traceArgs("audit-1", "corr-1", "", ""))
+	// The child inherits the parent's correlation ID
+	n.logAudit(traceArgs("audit-2", "", "audit-1", ""))
+	n.logAudit(traceArgs("audit-3", "corr-1", "", "audit-2"))
+	n.logAudit(traceArgs("audit-4", "corr-1", "", ""))
+	n.logAudit(traceArgs("audit-5", "corr-2", "", "audit-1"))
+
+	n.reject(CodeInvalidArgument, n.client, traceArgs("audit-6", "corr-2", "audit-1", "")...)
+	n.reject(CodeInvalidArgument, n.client, traceArgs("audit-6", "corr-1", "audit-6", "")...)
+	n.reject(CodeNotFound, n.client, traceArgs("audit-6", "corr-1", "", "audit-9")...)
+
+	var entry AuditEntry
+	n.evaluate(n.client, &entry, "GetAudit", "audit-2")
+	if entry.CorrelationID != "corr-1" || entry.ParentID != "audit-1" {
+		t.Errorf("audit-2 = %+v", entry)
+	}
+
+	var trace Trace
+	n.evaluate(n.client, &trace, "GetTrace", "corr-1")
+	got := ""
+	for _, node := range trace.Nodes {
+		got += fmt.Sprintf("%s/%d/%s/%v ", node.Entry.ID, node.Depth, node.Link, node.Children)
+	}
+	if want := "audit-1/0//[audit-2] audit-2/1/PARENT/[audit-3] audit-3/2/CAUSED_BY/[] audit-4/0//[] "; got != want {
+		t.Errorf("nodes = %s, want %s", got, want)
+	}
+	if fmt.Sprint(trace.Roots) != "[audit-1 audit-4]" {
+		t.Errorf("roots = %v", trace.Roots)
+	}
+
+	// causedBy may point outside the trace, the entry is then a root
+	n.evaluate(n.client, &trace, "GetTrace", "corr-2")
+	if len(trace.Nodes) != 1 || fmt.Sprint(trace.Roots) != "[audit-5]" {
+		t.Errorf("corr-2 = %+v", trace)
+	}
+	n.reject(CodeNotFound, n.client, "GetTrace", "corr-3")
+}
+
+func TestResourceHistory(t *testing.T) {
+	n := newNetwork(t)
+
+	created := n.millis()
+	n.logAudit(auditArgs("audit-1", "user-alice", "CREATE", "USER", "user-9", "", `{"role":"USER"}`))
+	n.logAudit(auditArgs("audit-2", "user-alice", "QUERY", "USER", "user-9", "", ""))
+	updated := n.millis()
+	n.logAudit(auditArgs("audit-3", "user-alice", "UPDATE", "USER", "user-9", `{"role": "USER"}`, `{"role":"ADMIN"}`))
+	n.logAudit(auditArgs("audit-4", "user-alice", "UPDATE", "USER", "user-9", `{"role":"AUDITOR"}`, `{"role":"USER"}`))
+	deleted := n.millis()
+	n.logAudit(auditArgs("audit-5", "user-alice", "DELETE", "USER", "user-9", `{"role":"USER"}`, ""))
+
+	var entries []*AuditEntry
+	n.evaluate(n.client, &entries, "GetResourceTimeline", "USER", "user-9")
+	if got := auditIDs(entries); got != "audit-1,audit-2,audit-3,audit-4,audit-5" {
+		t.Errorf("timeline = %s", got)
+	}
+
+	tests := []struct {
+		at     int64
+		exists bool
+		state  string
+		asOf   string
+	}{
+		{created - 1, false, "", ""},
+		{created, true, `{"role":"USER"}`, "audit-1"},
+		{updated - 1, true, `{"role":"USER"}`, "audit-1"},
+		{updated, true, `{"role":"ADMIN"}`, "audit-3"},
+		{deleted, false, "", "audit-5"},
+	}
+	for _, tt := range tests {
+		var state ResourceState
+		n.evaluate(n.client, &state, "ReconstructResourceAt", "USER", "user-9", fmt.Sprint(tt.at))
+		if state.Exists != tt.exists || state.State != tt.state || state.AsOfAuditID != tt.asOf {
+			t.Errorf("at %d = %+v", tt.at, state)
+		}
+	}
+
+	// audit-3 only differs in whitespace, audit-4 claims a state nobody recorded
+	var report ContinuityReport
+	n.evaluate(n.client, &report, "CheckResourceContinuity", "USER", "user-9")
+	if report.Continuous || report.EntriesChecked != 4 || len(report.Breaks) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if b := report.Breaks[0]; b.Kind != ContinuityOldValueMismatch || b.AuditID != "audit-4" || b.PreviousAuditID != "audit-3" {
+		t.Errorf("break = %+v", b)
+	}
+
+	var sweep ContinuitySweep
+	n.evaluate(n.client, &sweep, "CheckContinuityByDateRange", fmt.Sprint(updated), fmt.Sprint(deleted))
+	if sweep.ResourcesChecked != 1 || sweep.ResourcesWithBreaks != 1 || len(sweep.Reports) != 1 {
+		t.Errorf("sweep = %+v", sweep)
+	}
+	n.reject(CodeInvalidArgument, n.client, "ReconstructResourceAt", "USER", "", "0")
+
+	// Entries sharing a timestamp come back in audit ID order, whatever order they were written in
+	n.ledger.SetClock(n.ledger.Now(), 0)
+	tied := n.millis()
+	n.logAudit(auditArgs("audit-7", "user-alice", "CREATE", "USER", "user-8", "", `{"role":"ADMIN"}`))
+	n.logAudit(auditArgs("audit-6", "user-alice", "CREATE", "USER", "user-8", "", `{"role":"USER"}`))
+	n.evaluate(n.client, &entries, "GetResourceTimeline", "USER", "user-8")
+	if got := auditIDs(entries); got != "audit-6,audit-7" {
+		t.Errorf("tied timeline = %s", got)
+	}
+	var state ResourceState
+	n.evaluate(n.client, &state, "ReconstructResourceAt", "USER", "user-8", fmt.Sprint(tied))
+	if state.AsOfAuditID != "audit-7" {
+		t.Errorf("tied state = %+v", state)
+	}
+	n.evaluate(n.client, &report, "CheckResourceContinuity", "USER", "user-8")
+	if len(report.Breaks) != 1 || report.Breaks[0].AuditID != "audit-7" || report.Breaks[0].PreviousAuditID != "audit-6" {
+		t.Errorf("tied report = %+v", report)
+	}
+
+	// A mutating entry with neither value leaves the state alone, for replay and continuity alike
+	n.ledger.SetClock(n.ledger.Now(), time.Second)
+	n.logAudit(auditArgs("audit-10", "user-alice", "CREATE", "USER", "user-10", "", `{"role":"USER"}`))
+	n.logAudit(auditArgs("audit-11", "user-alice", "UPDATE", "USER", "user-10", "", ""))
+	n.logAudit(auditArgs("audit-12", "user-alice", "UPDATE", "USER", "user-10", `{"role":"USER"}`, `{"role":"ADMIN"}`))
+	n.evaluate(n.client, &report, "CheckResourceContinuity", "USER", "user-10")
+	if !report.Continuous || report.EntriesChecked != 3 {
+		t.Errorf("empty update report = %+v", report)
+	}
+	n.evaluate(n.client, &state, "ReconstructResourceAt", "USER", "user-10", fmt.Sprint(n.millis()))
+	if state.State != `{"role":"ADMIN"}` || state.AppliedEntries != 2 {
+		t.Errorf("empty update state = %+v", state)
+	}
+}
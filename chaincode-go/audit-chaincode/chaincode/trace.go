@@ -0,0 +1,212 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ trace.go links the audit entries one user request fans out into:
+	- LogAuditWithTrace - LogAudit plus correlationId, parentId and causedBy
+	- GetTrace - Every entry of a correlation ID as a causal tree
+	- validateTraceLinks (helper) - Run by appendAudit for every new entry
+
+Links (all optional):
+	correlationId - Shared by every entry of one user request
+	parentId      - Entry this one was fanned out from (structural parent)
+	causedBy      - Entry that triggered this one, e.g. the QUERY that led to a REVOKE
+
+Rules:
+	- parentId / causedBy must name existing entries, so the links can never form a cycle
+	- An entry with a parent inherits the parent's correlationId, a different one is rejected
+	- Fabric reads do not see writes of the same transaction: log the parent first,
+	  children in later transactions
+
+Tree built by GetTrace: an entry hangs off its parentId, or its causedBy when it has no
+parent inside the trace. Entries whose links point outside the trace become roots.
+
+GetTrace is backed by META-INF/statedb/couchdb/indexes/indexCorrelationId.json
+*/
+
+// How a TraceNode hangs off its tree parent
+const (
+	traceLinkParent   = "PARENT"
+	traceLinkCausedBy = "CAUSED_BY"
+)
+
+/*
+--- CREATE AUDIT ENTRY WITH TRACE ---
+LogAudit with correlation and causality links, see LogAudit (audit.go) for the other args
+*/
+func (c *AuditContract) LogAuditWithTrace(ctx contractapi.TransactionContextInterface,
+	id string, userId string, userRole string, action string,
+	resourceType string, resourceId string, oldValue string, newValue string,
+	status string, ipAddress string, sessionId string,
+	metadata string, complianceTag string,
+	correlationId string, parentId string, causedBy string) error {
+
+	lg := txLog(ctx, "LogAuditWithTrace")
+	lg.Enter("auditId", id, "userId", userId, "action", action, "correlationId", correlationId, "parentId", parentId, "causedBy", causedBy)
+
+	entry := AuditEntry{
+		ID:            id,
+		UserID:        userId,
+		UserRole:      userRole,
+		Action:        action,
+		ResourceType:  resourceType,
+		ResourceID:    resourceId,
+		OldValue:      oldValue,
+		NewValue:      newValue,
+		Status:        status,
+		IPAddress:     ipAddress,
+		SessionID:     sessionId,
+		Metadata:      metadata,
+		ComplianceTag: complianceTag,
+		CorrelationID: correlationId,
+		ParentID:      parentId,
+		CausedBy:      causedBy,
+	}
+	return c.appendAudit(ctx, &entry, nil)
+}
+
+/*
+--- GET TRACE ---
+Returns every entry sharing a correlation ID, as a causal tree flattened depth first
+*/
+func (c *AuditContract) GetTrace(ctx contractapi.TransactionContextInterface, correlationId string) (*Trace, error) {
+	lg := txLog(ctx, "GetTrace")
+	lg.Enter("correlationId", correlationId)
+
+	// Input validation
+	if correlationId == "" {
+		return nil, invalidArgument("correlationId", "correlationId is required")
+	}
+
+	// Sort on both index fields so CouchDB picks indexCorrelationIdTimestamp
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector":  map[string]interface{}{"correlationId": correlationId},
+		"sort":      []map[string]string{{"correlationId": "asc"}, {"timestamp": "asc"}},
+		"use_index": []string{"_design/indexCorrelationIdTimestamp", "indexCorrelationIdTimestamp"},
+	})
+	if err != nil {
+		return nil, internalError("failed to build query: %v", err)
+	}
+
+	entries, err := c.queryAudits(ctx, string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		lg.Reject("NOT_FOUND", nil, "correlationId", correlationId)
+		return nil, notFound("no audit entries with correlationId %s", correlationId)
+	}
+
+	trace := buildTrace(correlationId, entries)
+	lg.Done("correlationId", correlationId, "entries", len(trace.Nodes), "roots", len(trace.Roots))
+	return trace, nil
+}
+
+// buildTrace arranges a trace's entries into a depth first list of tree nodes
+func buildTrace(correlationId string, entries []*AuditEntry) *Trace {
+	// Timestamp ties are broken by ID so every peer returns the same order
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].TimeStamp != entries[j].TimeStamp {
+			return entries[i].TimeStamp < entries[j].TimeStamp
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	byID := map[string]*AuditEntry{}
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+
+	trace := &Trace{CorrelationID: correlationId, Roots: []string{}, Nodes: []TraceNode{}}
+	children := map[string][]string{}
+	links := map[string]string{}
+	for _, entry := range entries {
+		switch {
+		case entry.ParentID != "" && byID[entry.ParentID] != nil:
+			children[entry.ParentID] = append(children[entry.ParentID], entry.ID)
+			links[entry.ID] = traceLinkParent
+		case entry.CausedBy != "" && byID[entry.CausedBy] != nil:
+			children[entry.CausedBy] = append(children[entry.CausedBy], entry.ID)
+			links[entry.ID] = traceLinkCausedBy
+		default:
+			trace.Roots = append(trace.Roots, entry.ID)
+		}
+	}
+
+	var visit func(id string, depth int)
+	visit = func(id string, depth int) {
+		kids := children[id]
+		if kids == nil {
+			kids = []string{}
+		}
+		trace.Nodes = append(trace.Nodes, TraceNode{Entry: byID[id], Depth: depth, Link: links[id], Children: kids})
+		for _, child := range kids {
+			visit(child, depth+1)
+		}
+	}
+	for _, root := range trace.Roots {
+		visit(root, 0)
+	}
+	return trace
+}
+
+/*
+--- validateTraceLinks (helper) ---
+Checks parentId / causedBy point at existing entries and fills in an inherited correlationId
+*/
+func (c *AuditContract) validateTraceLinks(ctx contractapi.TransactionContextInterface, entry *AuditEntry) error {
+	if len(entry.CorrelationID) > 64 {
+		return invalidArgument("correlationId", "correlationId exceeds maximum length of 64 characters")
+	}
+
+	if entry.ParentID != "" {
+		parent, err := getLinkedAudit(ctx, entry, "parentId", entry.ParentID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.CorrelationID == "":
+			entry.CorrelationID = parent.CorrelationID
+		case parent.CorrelationID != "" && parent.CorrelationID != entry.CorrelationID:
+			return invalidArgument("correlationId", "correlationId %s does not match correlationId %s of parent %s",
+				entry.CorrelationID, parent.CorrelationID, parent.ID)
+		}
+	}
+
+	if entry.CausedBy != "" {
+		_, err := getLinkedAudit(ctx, entry, "causedBy", entry.CausedBy)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getLinkedAudit reads the entry a link field points at, which must exist and not be the entry itself
+func getLinkedAudit(ctx contractapi.TransactionContextInterface, entry *AuditEntry, field string, linkedID string) (*AuditEntry, error) {
+	if linkedID == entry.ID {
+		return nil, invalidArgument(field, "%s cannot reference the entry itself", field)
+	}
+
+	linkedJSON, err := ctx.GetStub().GetState(linkedID)
+	if err != nil {
+		return nil, internalError("failed to read %s %s from ledger: %v", field, linkedID, err)
+	}
+	if linkedJSON == nil {
+		return nil, notFound("%s %s does not exist in ledger", field, linkedID)
+	}
+
+	var linked AuditEntry
+	err = json.Unmarshal(linkedJSON, &linked)
+	if err != nil {
+		return nil, internalError("failed to unmarshal %s %s: %v", field, linkedID, err)
+	}
+	return &linked, nil
+}
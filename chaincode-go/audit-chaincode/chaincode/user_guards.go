@@ -0,0 +1,129 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ user_guards.go holds the lockout protections enforced by UserContract:
+	- LAST_ACTIVE_ADMIN: every organization keeps at least one ACTIVE ADMIN
+		checked when an active admin is demoted, suspended, deactivated or moved to another org
+	- SELF_MODIFICATION: callers cannot change their own role or status
+		(caller = "userId" certificate attribute, see identity.go). Callers without the
+		attribute (org admin certificates) are never a user, so only this check is skipped.
+
+Violations return *InvariantError so clients can tell them apart from validation errors
+and never retry them blindly. On the wire they are FAILED_PRECONDITION with the invariant
+as reason (errors.go); inside the chaincode:
+	var invErr *InvariantError
+	if errors.As(err, &invErr) && invErr.Invariant == InvariantLastActiveAdmin { ... }
+*/
+
+// Invariants guarded by UserContract
+const (
+	InvariantLastActiveAdmin  = "LAST_ACTIVE_ADMIN"
+	InvariantSelfModification = "SELF_MODIFICATION"
+)
+
+// InvariantError is returned when a change would break a UserContract safety invariant
+type InvariantError struct {
+	Invariant string // LAST_ACTIVE_ADMIN, SELF_MODIFICATION
+	UserID    string // User the rejected change targeted
+	Message   string
+}
+
+// Error returns the FAILED_PRECONDITION payload, reason is the invariant (errors.go)
+func (e *InvariantError) Error() string {
+	return e.contractError().Error()
+}
+
+// LogValue logs it like the ContractError it becomes, the user ID redacted
+func (e *InvariantError) LogValue() slog.Value {
+	return e.contractError().LogValue()
+}
+
+func (e *InvariantError) contractError() *ContractError {
+	contractErr := newContractError(CodeFailedPrecondition, "invariant %s violated for user %s: %s", e.Invariant, pii(e.UserID), e.Message)
+	contractErr.Reason = e.Invariant
+	return contractErr
+}
+
+// guardSelfModification stops callers from changing their own role or status.
+// Without the userId attribute the caller is MSP:CN (identity.go), which never equals a User.ID,
+// so org admin certificates pass and can always change anyone else.
+func guardSelfModification(ctx contractapi.TransactionContextInterface, targetID string, change string) error {
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerID == targetID {
+		return &InvariantError{
+			Invariant: InvariantSelfModification,
+			UserID:    targetID,
+			Message:   fmt.Sprintf("callers cannot change their own %s", change),
+		}
+	}
+	return nil
+}
+
+// guardLastActiveAdmin fails if user is the only ACTIVE ADMIN left in their organization.
+// Call it only for changes that take the user out of that set (demote, suspend, deactivate, move org).
+func guardLastActiveAdmin(ctx contractapi.TransactionContextInterface, user *User) error {
+	if user.Role != "ADMIN" || user.Status != UserStatusActive {
+		return nil
+	}
+
+	others, err := countOtherActiveAdmins(ctx, user.Organization, user.ID)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		txLog(ctx, "guardLastActiveAdmin").Reject("BLOCKED", nil, "userId", user.ID, "org", user.Organization)
+		return &InvariantError{
+			Invariant: InvariantLastActiveAdmin,
+			UserID:    user.ID,
+			Message:   fmt.Sprintf("user is the last active ADMIN of organization %q", user.Organization),
+		}
+	}
+	return nil
+}
+
+// countOtherActiveAdmins counts ACTIVE ADMINs in an organization, excluding excludeID
+func countOtherActiveAdmins(ctx contractapi.TransactionContextInterface, organization string, excludeID string) (int, error) {
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("USER", []string{})
+	if err != nil {
+		return 0, internalError("failed to get users: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	count := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, internalError("failed to iterate users: %v", err)
+		}
+
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			return 0, internalError("failed to unmarshal user: %v", err)
+		}
+		normalizeUserStatus(&user, nowMillis)
+
+		if user.ID != excludeID && user.Organization == organization &&
+			user.Role == "ADMIN" && user.Status == UserStatusActive {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,309 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ user_lifecycle.go holds the UserContract status state machine:
+	- ActivateUser - PENDING_ACTIVATION/DEACTIVATED -> ACTIVE
+	- SuspendUser - ACTIVE -> SUSPENDED (optionally until a deadline)
+	- ReinstateUser - SUSPENDED -> ACTIVE
+	- DeactivateUser (users.go) - PENDING_ACTIVATION/ACTIVE/SUSPENDED -> DEACTIVATED
+	- EraseUser - DEACTIVATED -> ERASED (scrubs profile data, terminal)
+
+Transition table:
+
+	PENDING_ACTIVATION --Activate--> ACTIVE --Suspend--> SUSPENDED
+	        |                         ^  |                  |  |
+	        |                         |  +--Reinstate-------+  |
+	        |                         |  (or deadline lapses)  |
+	        +-------Deactivate----> DEACTIVATED <--Deactivate--+
+	                                  |   ^ Activate back to ACTIVE
+	                                  +--Erase--> ERASED (terminal)
+
+ERASED is terminal for every change, not just status: UpdateUserProfile and UpdateUserRole
+reject erased users with FAILED_PRECONDITION so scrubbed profile data cannot come back.
+
+Legacy records (before status existed) only carry "active":
+	active=true  -> ACTIVE
+	active=false -> DEACTIVATED
+
+Suspensions with a deadline lapse on their own: once the transaction time passes
+suspendedUntil the user reads back as ACTIVE, the record is rewritten on its next change.
+
+Every user write also sets a UserChanged event carrying the stored record (setUserEvent).
+*/
+
+// User lifecycle statuses
+const (
+	UserStatusPendingActivation = "PENDING_ACTIVATION"
+	UserStatusActive            = "ACTIVE"
+	UserStatusSuspended         = "SUSPENDED"
+	UserStatusDeactivated       = "DEACTIVATED"
+	UserStatusErased            = "ERASED"
+)
+
+// userStatusTransitions lists the statuses each status may move to
+var userStatusTransitions = map[string][]string{
+	UserStatusPendingActivation: {UserStatusActive, UserStatusDeactivated},
+	UserStatusActive:            {UserStatusSuspended, UserStatusDeactivated},
+	UserStatusSuspended:         {UserStatusActive, UserStatusDeactivated},
+	UserStatusDeactivated:       {UserStatusActive, UserStatusErased},
+	UserStatusErased:            {},
+}
+
+/*
+--- ACTIVATE USER ---
+Activates an invited user (PENDING_ACTIVATION) or brings back a DEACTIVATED one
+*/
+func (c *UserContract) ActivateUser(ctx contractapi.TransactionContextInterface, id string) error {
+	lg := txLog(ctx, "ActivateUser")
+	lg.Enter("userId", id)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+
+	return c.transitionUser(ctx, id, UserStatusActive, "", func(user *User) error {
+		// Reinstating a suspension has its own transaction so it shows up as such in the history
+		if user.Status == UserStatusSuspended {
+			return failedPrecondition("user id=%s is SUSPENDED, use ReinstateUser", pii(id))
+		}
+		return nil
+	})
+}
+
+/*
+--- SUSPEND USER ---
+Temporarily blocks an ACTIVE user
+- untilTs: Unix milliseconds when the suspension lapses, 0 = until ReinstateUser is called
+*/
+func (c *UserContract) SuspendUser(ctx contractapi.TransactionContextInterface, id string, untilTs int64) error {
+	lg := txLog(ctx, "SuspendUser")
+	lg.Enter("userId", id, "untilTs", untilTs)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+	if untilTs < 0 {
+		return invalidArgument("untilTs", "untilTs must be 0 (indefinite) or a positive timestamp")
+	}
+
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return err
+	}
+	if untilTs != 0 && untilTs <= nowMillis {
+		return invalidArgument("untilTs", "untilTs %d must be in the future (transaction time %d)", untilTs, nowMillis)
+	}
+
+	return c.transitionUser(ctx, id, UserStatusSuspended, "", func(user *User) error {
+		user.SuspendedUntil = untilTs
+		return nil
+	})
+}
+
+/*
+--- REINSTATE USER ---
+Ends a suspension early, SUSPENDED -> ACTIVE
+*/
+func (c *UserContract) ReinstateUser(ctx contractapi.TransactionContextInterface, id string) error {
+	lg := txLog(ctx, "ReinstateUser")
+	lg.Enter("userId", id)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+
+	return c.transitionUser(ctx, id, UserStatusActive, "", func(user *User) error {
+		if user.Status != UserStatusSuspended {
+			return failedPrecondition("user id=%s is not suspended (status %s)", pii(id), user.Status)
+		}
+		return nil
+	})
+}
+
+/*
+--- ERASE USER ---
+Right-to-erasure for a DEACTIVATED user
+- Clears username, email and permissions and releases their index keys
+- Terminal: an ERASED user can never be reactivated
+- Earlier versions stay in the key history, the ledger is append-only
+*/
+func (c *UserContract) EraseUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	lg := txLog(ctx, "EraseUser")
+	lg.Enter("userId", id)
+
+	// Input validation
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+	if reason == "" {
+		return invalidArgument("reason", "reason is required")
+	}
+
+	return c.transitionUser(ctx, id, UserStatusErased, reason, func(user *User) error {
+		err := deleteUserIndexes(ctx, user)
+		if err != nil {
+			return err
+		}
+		user.Username = ""
+		user.Email = ""
+		user.Permissions = []string{}
+		return nil
+	})
+}
+
+/*
+--- transitionUser (helper) ---
+Shared body of every lifecycle transaction:
+ 1. load the user (legacy / lapsed statuses normalized)
+ 2. check the transition table and the lockout guards
+ 3. run the transaction specific checks/changes (mutate, optional)
+ 4. set status, reason, UpdatedAt/UpdatedBy and write back
+*/
+func (c *UserContract) transitionUser(ctx contractapi.TransactionContextInterface, id string,
+	to string, reason string, mutate func(user *User) error) error {
+	lg := ctxLog(ctx)
+
+	user, err := c.GetUser(ctx, id)
+	if err != nil {
+		lg.Fail("ERROR", err, "userId", id)
+		return wrapError(err, "failed to get user from ledger, user ID=%s", pii(id))
+	}
+
+	from := user.Status
+	if !canTransitionUser(from, to) {
+		lg.Reject("INVALID_TRANSITION", nil, "userId", id, "from", from, "to", to)
+		return failedPrecondition("cannot move user id=%s from %s to %s", pii(id), from, to)
+	}
+
+	// Lockout protection (user_guards.go): no self status changes,
+	// and the last active admin of an org cannot leave ACTIVE
+	err = guardSelfModification(ctx, id, "status")
+	if err != nil {
+		lg.Reject("BLOCKED", err, "userId", id)
+		return err
+	}
+	if to != UserStatusActive {
+		err = guardLastActiveAdmin(ctx, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	if mutate != nil {
+		err = mutate(user)
+		if err != nil {
+			lg.Fail("ERROR", err, "userId", id)
+			return err
+		}
+	}
+
+	user.Status = to
+	user.Active = to == UserStatusActive
+	user.StatusReason = reason
+	if to != UserStatusSuspended {
+		user.SuspendedUntil = 0
+	}
+
+	// Record when and by whom the user was changed
+	err = stampUserUpdate(ctx, user)
+	if err != nil {
+		lg.Fail("ERROR", err, "userId", id)
+		return err
+	}
+
+	err = putUser(ctx, user)
+	if err != nil {
+		lg.Fail("ERROR", err, "userId", id)
+		return err
+	}
+
+	lg.Done("userId", id, "from", from, "to", to)
+	return nil
+}
+
+// canTransitionUser checks the transition table
+func canTransitionUser(from string, to string) bool {
+	for _, allowed := range userStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeUserStatus maps legacy records and lapsed suspensions onto the current status model
+func normalizeUserStatus(user *User, nowMillis int64) {
+	// Records written before the lifecycle existed only have the active flag
+	if user.Status == "" {
+		if user.Active {
+			user.Status = UserStatusActive
+		} else {
+			user.Status = UserStatusDeactivated
+		}
+	}
+
+	// A suspension with a deadline ends by itself once the deadline has passed
+	if user.Status == UserStatusSuspended && user.SuspendedUntil > 0 && nowMillis >= user.SuspendedUntil {
+		user.Status = UserStatusActive
+		user.StatusReason = "suspension lapsed"
+		user.SuspendedUntil = 0
+	}
+
+	user.Active = user.Status == UserStatusActive
+}
+
+// putUser writes a user under its "USER" composite key
+func putUser(ctx contractapi.TransactionContextInterface, user *User) error {
+	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{user.ID})
+	if err != nil {
+		return internalError("failed to create composite key for user ID=%s: %v", pii(user.ID), err)
+	}
+
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return internalError("failed to marshal user ID=%s: %v", pii(user.ID), err)
+	}
+
+	err = ctx.GetStub().PutState(compositeKey, userJSON)
+	if err != nil {
+		return internalError("failed to write user ID=%s to ledger: %v", pii(user.ID), err)
+	}
+	return setUserEvent(ctx, user.ID, userJSON)
+}
+
+// UserEventName is the chaincode event set by every transaction that writes a user, payload = the stored User JSON
+const UserEventName = "UserChanged"
+
+// setUserEvent emits the written user for off-chain mirrors (mirror package), one user write per transaction
+func setUserEvent(ctx contractapi.TransactionContextInterface, id string, userJSON []byte) error {
+	err := ctx.GetStub().SetEvent(UserEventName, userJSON)
+	if err != nil {
+		return internalError("failed to set %s event for user ID=%s: %v", UserEventName, pii(id), err)
+	}
+	return nil
+}
+
+// NormalizeUserStatus applies the read-time status rules of GetUser / ListUsers (legacy records,
+// lapsed suspensions) to a user as stored, for off-chain copies that must match what the chaincode returns
+func NormalizeUserStatus(user *User, nowMillis int64) {
+	normalizeUserStatus(user, nowMillis)
+}
+
+// txTimeMillis returns the transaction timestamp in Unix milliseconds (same on every peer)
+func txTimeMillis(ctx contractapi.TransactionContextInterface) (int64, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, internalError("failed to get transaction timestamp: %v", err)
+	}
+	return txTimestamp.AsTime().UnixMilli(), nil
+}
@@ -5,8 +5,9 @@ import (
 	//  - contractapi.Contract : base struct for contracts
 	//	- contractapi.TransactionContextInterface : alows you to read/write ledger state
 	"encoding/json"
-	"fmt"
-	"log"
+	"net/mail"
+	"sort"
+	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
@@ -17,8 +18,13 @@ import (
  	- RegisterUser - Create new user with role-based permissions
 	- GetUser - Retrieve user by ID
 	- UpdateUserRole - Change role and update permissions
-	- DeactivateUser - Set active=false (soft delete)
+	- UpdateUserProfile - Change username, email or organization (field-level validation)
+	- DeactivateUser - Move user to DEACTIVATED (soft delete), see user_lifecycle.go for the other states
+	- InviteUser - Like RegisterUser, but the user starts in PENDING_ACTIVATION
 	- UserExists - Check user existence
+	- ListUsers - Page through users, filtered by role, organization, active flag or createdBy
+	- GetUserByEmail / GetUserByUsername - Resolve a user through the USER_EMAIL / USER_NAME index keys
+	- ReindexUsers - Backfill index keys for users registered before the indexes existed
 	- getDefaultPermissions (helper) - Map role to permission array
 
 Roles:
@@ -67,6 +73,9 @@ Composite key:
 		// Returns all keys starting with "USER~"
 */
 
+// maxPageSize caps how many records a single paginated query may return
+const maxPageSize int32 = 100
+
 // AuditContract provides functions for managing audit entries
 type UserContract struct {
 	contractapi.Contract
@@ -79,22 +88,36 @@ Add new users to ledger
 - create new user object
 - build compositekey
 - add user to ledger 
+- add USER_EMAIL / USER_NAME index keys (email + username must be unique)
+- user starts ACTIVE (use InviteUser for PENDING_ACTIVATION)
 */
-func (c *UserContract) RegisterUser(ctx contractapi.TransactionContext, id string, name string, email string, role string, organization string, createdBy string ) error {
-	log.Printf("[RegisterUser] ENTER id=%s role=%s org=%s", id, role, organization)
+func (c *UserContract) RegisterUser(ctx contractapi.TransactionContextInterface, id string, name string, email string, role string, organization string, createdBy string ) error {
+	return c.registerUser(ctx, id, name, email, role, organization, createdBy, UserStatusActive)
+}
 
+/*
+--- INVITE USER ---
+Same as RegisterUser but the user starts in PENDING_ACTIVATION and
+cannot act until ActivateUser is called
+*/
+func (c *UserContract) InviteUser(ctx contractapi.TransactionContextInterface, id string, name string, email string, role string, organization string, createdBy string) error {
+	return c.registerUser(ctx, id, name, email, role, organization, createdBy, UserStatusPendingActivation)
+}
+
+// registerUser validates and writes a new user in the given initial status
+func (c *UserContract) registerUser(ctx contractapi.TransactionContextInterface, id string, name string, email string, role string, organization string, createdBy string, status string) error {
 	//Input validation (id, name, email, role required)
 	if id == "" {
-		return fmt.Errorf("id is required")
+		return invalidArgument("id", "id is required")
 	}
 	if name == "" {
-		return fmt.Errorf("name is required")
+		return invalidArgument("name", "name is required")
 	}
 	if email == ""{
-		return fmt.Errorf("email is required")
+		return invalidArgument("email", "email is required")
 	}
 	if role == ""{
-		return fmt.Errorf("role is required")
+		return invalidArgument("role", "role is required")
 	}
 
 	// Validate role is ADMIN, AUDITOR, or USER
@@ -102,21 +125,33 @@ func (c *UserContract) RegisterUser(ctx contractapi.TransactionContext, id strin
 		"ADMIN": true, "AUDITOR": true, "USER": true,
 	}
 	if !validRoles[role] {
-		return fmt.Errorf("invalid role: %s. Valid Roles: ADMIN, AUDITOR, or USER", role)
+		return invalidArgument("role", "invalid role: %s. Valid Roles: ADMIN, AUDITOR, or USER", role)
 	}
 
 	// Check ID length limits
 	if len(id) > 64 {
-		return fmt.Errorf("id exceeds maximum length of 64 characters")
+		return invalidArgument("id", "id exceeds maximum length of 64 characters")
+	}
+
+	// Case-fold email so Alice@Example.com and alice@example.com are the same identity
+	email = normalizeEmail(email)
+	if err := validateEmail(email); err != nil {
+		return err
 	}
 	
 	// Check if user exists 
 	exists, err := c.UserExists(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to check if user exists: %v", err)
+		return wrapError(err, "failed to check if user exists")
 	}
 	if exists {
-		return fmt.Errorf("user %s already exists", id)
+		return alreadyExists("user %s already exists", pii(id))
+	}
+
+	// Check username and email are not taken by another user
+	err = checkUserIdentityAvailable(ctx, id, name, email)
+	if err != nil {
+		return err
 	}
 
 	//Get default permissions for role
@@ -125,7 +160,7 @@ func (c *UserContract) RegisterUser(ctx contractapi.TransactionContext, id strin
 	// Get deterministic timestamp from transaction (same across all peers)
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+		return internalError("failed to get transaction timestamp: %v", err)
 	}
 
 	// Create User struct with all fields
@@ -136,7 +171,8 @@ func (c *UserContract) RegisterUser(ctx contractapi.TransactionContext, id strin
 		Role:         role,
 		Permissions:  permissions,
 		Organization: organization,
-		Active:       true,
+		Status:       status,
+		Active:       status == UserStatusActive,
 		CreatedBy:    createdBy,
 		CreatedAt:   txTimestamp.AsTime().UnixMilli(),
 	}
@@ -144,27 +180,38 @@ func (c *UserContract) RegisterUser(ctx contractapi.TransactionContext, id strin
 	// Create composite key: namespaces users separately from audits 
 	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{id})
 	if err != nil{
-		log.Printf("[RegisterUser] ERROR creating composite key id=%s err=%v", id, err)
-		return fmt.Errorf("failed to create composite key for user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR creating composite key", err, "userId", id)
+		return internalError("failed to create composite key for user ID=%s: %v", pii(id), err)
 	}
 	// Marshal to JSON
 	userJSON, err := json.Marshal(user)
 	if err != nil {
-		log.Printf("[RegisterUser] ERROR marshaling user id=%s err=%v", id, err)
-		return fmt.Errorf("failed to marshal user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR marshaling user", err, "userId", id)
+		return internalError("failed to marshal user ID=%s: %v", pii(id), err)
 	}
 
 	// Write user to  ledger
 	err = ctx.GetStub().PutState(compositeKey, userJSON)
 	if err != nil {
-		log.Printf("[RegisterUser] ERROR writing user id=%s err=%v", id, err)
-		return fmt.Errorf("failed to write user ID=%s to ledger: %v", id, err)
+		ctxLog(ctx).Fail("ERROR writing user", err, "userId", id)
+		return internalError("failed to write user ID=%s to ledger: %v", pii(id), err)
 	}
 
+	// Emit the stored user for off-chain mirrors
+	err = setUserEvent(ctx, id, userJSON)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return err
+	}
 
+	// Write secondary index keys in the same transaction as the user
+	err = putUserIndexes(ctx, &user)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR writing index keys", err, "userId", id)
+		return err
+	}
 
 	// Log success
-	log.Printf("[RegisterUser] SUCCESS id=%s role=%s org=%s", id, role, organization)
 	return nil
 
 
@@ -182,31 +229,29 @@ Return: full User object with all fields
 
 func (c *UserContract) GetUser(ctx contractapi.TransactionContextInterface, id string) (*User, error) {
 	
-	log.Printf("[GetUser] ENTER id=%s", id)
 	
 	//Input validation for ID
 	if id == "" {
-		return nil, fmt.Errorf("id is required")
+		return nil, invalidArgument("id", "id is required")
 	}
 
 	//Create composite key to match how user was stored
 	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{id})
 	if err != nil{
-		log.Printf("[GetUser] ERROR creating composite key id=%s err=%v", id, err)
-		return nil, fmt.Errorf("failed to create composite key for user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR creating composite key", err, "userId", id)
+		return nil, internalError("failed to create composite key for user ID=%s: %v", pii(id), err)
 	}
 	
 	//Get state from ledger using composite key
 	userJson, err := ctx.GetStub().GetState(compositeKey)
 	if err != nil{
-		log.Printf("[GetUser] ERRORfailed to read user from ledger id=%s err=%v", id, err)
-		return nil, fmt.Errorf("failed to read user from ledger, user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR reading user from ledger", err, "userId", id)
+		return nil, internalError("failed to read user from ledger, user ID=%s: %v", pii(id), err)
 	}
 	
 	//Check if user exists
 	if userJson == nil {
-		log.Printf("[GetUser] NOT_FOUND id=%s", id)
-		return nil, fmt.Errorf("User  ID=%s does not exist in ledger", id)
+		return nil, notFound("User  ID=%s does not exist in ledger", pii(id))
 	}
 
 	
@@ -214,13 +259,19 @@ func (c *UserContract) GetUser(ctx contractapi.TransactionContextInterface, id s
 	var user User
 	err = json.Unmarshal(userJson, &user)
 	if err !=nil {
-		log.Printf("[GetUser] ERROR id=%s err=%v", id, err)
-		return nil, fmt.Errorf("failed to unmarshal user from ledger ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return nil, internalError("failed to unmarshal user from ledger ID=%s: %v", pii(id), err)
 	
 	}
 
+	// Map legacy active flag / lapsed suspensions onto the current status
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	normalizeUserStatus(&user, nowMillis)
+
 	//  Log success with key user info
-	log.Printf("[GetUser] SUCCESS id=%s role=%s active=%v", id, user.Role, user.Active)
 	
 	//Return pointer to user and nil error
 	 return &user, nil
@@ -231,18 +282,18 @@ func (c *UserContract) GetUser(ctx contractapi.TransactionContextInterface, id s
 --- UPDATE USER ROLE ---
 Changes a user's role and updates their permissions based on new role
 - Only for active users
+- Callers cannot change their own role, an org's last active ADMIN cannot be demoted (user_guards.go)
 */
 func (c *UserContract) UpdateUserRole(ctx contractapi.TransactionContextInterface, 
 	id string, newRole string) error {
 	
-	log.Printf("[UpdateUserRole] ENTER id=%s newRole=%s", id, newRole)
 	
 	//Input validation 
 	if id == "" {
-		return fmt.Errorf("id is required")
+		return invalidArgument("id", "id is required")
 	}
 	if newRole == "" {
-		return fmt.Errorf("newRole is required")
+		return invalidArgument("newRole", "newRole is required")
 	}
 
 	
@@ -251,63 +302,83 @@ func (c *UserContract) UpdateUserRole(ctx contractapi.TransactionContextInterfac
 		"ADMIN": true, "AUDITOR": true, "USER": true,
 	}
 	if !validRoles[newRole] {
-		log.Printf("[UpdateUserRole] ERROR, invalid role=%s", newRole)
-		return fmt.Errorf("invalid new role: %s. Valid Roles: ADMIN, AUDITOR, or USER", newRole)
+		return invalidArgument("newRole", "invalid new role: %s. Valid Roles: ADMIN, AUDITOR, or USER", newRole)
 	}
 	
 	//Get current user from ledger
 	user, err := c.GetUser(ctx, id)
 	if err != nil{
-		log.Printf("[UpdateUserRole] ERROR id=%s err=%v", id, err)
-		return fmt.Errorf("failed to get user from ledger, user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return wrapError(err, "failed to get user from ledger, user ID=%s", pii(id))
 
 	}
 
 	//Validate user is active (business rule)
-	//   - If !user.Active → error "cannot update role for inactive user {id}"
-	if !user.Active {
-		log.Printf("[UpdateUserRole] USER_NOT_ACTIVE id=%s ", id)
-		return fmt.Errorf("cannot update role for inactive user id:%s", id)
+	//   - If status != ACTIVE → error "cannot update role for inactive user {id}"
+	if user.Status != UserStatusActive {
+		return failedPrecondition("cannot update role for inactive user id:%s (status %s)", pii(id), user.Status)
 	}
 
 	
 	//Check if role actually changed (optional optimization)
 	if user.Role == newRole{
 		// user already has role {newRole}
-		log.Printf("[UpdateUserRole] ROLE_EXISTS id=%v, currentRole=%v, newRole=%v", id, user.Role, newRole)
-		return fmt.Errorf("user id=%s already has role:%s", id, newRole)
+		return failedPrecondition("user id=%s already has role:%s", pii(id), newRole)
 	}
 	
-	// Update to NEW role and permissions, save old role for logs later 
-	var oldRole = user.Role
+	// Lockout protection: no self-promotion/demotion, and never demote an org's last active admin
+	err = guardSelfModification(ctx, id, "role")
+	if err != nil {
+		return err
+	}
+	if newRole != "ADMIN" {
+		err = guardLastActiveAdmin(ctx, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Update to NEW role and permissions
 	user.Role = newRole
 	user.Permissions = getDefaultPermissions(newRole)
+
+	// Record when and by whom the user was changed
+	err = stampUserUpdate(ctx, user)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return err
+	}
 	
 	//Create composite key (same as RegisterUser/GetUser)
 	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{id})
 	if err != nil{
-		log.Printf("[UpdateUserRole] ERROR creating composite key id=%s err=%v", id, err)
-		return fmt.Errorf("failed to create composite key for user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR creating composite key", err, "userId", id)
+		return internalError("failed to create composite key for user ID=%s: %v", pii(id), err)
 	}
 
 	// Marshal to JSON
 	userJSON, err := json.Marshal(user)
 	if err != nil {
-		log.Printf("[UpdateUserRole] ERROR marshaling user id=%s err=%v", id, err)
-		return fmt.Errorf("failed to marshal user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR marshaling user", err, "userId", id)
+		return internalError("failed to marshal user ID=%s: %v", pii(id), err)
 	}
 	
 	// Write updated user back to ledger
 	err = ctx.GetStub().PutState(compositeKey, userJSON)
 	if err != nil {
-		log.Printf("[UpdateUserRole] ERROR writing user to ledger id=%s err=%v", id, err)
-		return fmt.Errorf("failed to write user to ledger, ID=%s to ledger: %v", id, err)
+		ctxLog(ctx).Fail("ERROR writing user to ledger", err, "userId", id)
+		return internalError("failed to write user to ledger, ID=%s to ledger: %v", pii(id), err)
+	}
+
+	// Emit the stored user for off-chain mirrors
+	err = setUserEvent(ctx, id, userJSON)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return err
 	}
 
 	
 	//Log success with old and new role
-	log.Printf("[UpdateUserRole] SUCCESS id=%s oldRole=%s newRole=%s", 
-	             id, oldRole, newRole)
 
 
 	//Return nil err for success
@@ -315,99 +386,643 @@ func (c *UserContract) UpdateUserRole(ctx contractapi.TransactionContextInterfac
 }
 
 /*
---- DEACTIVATE USER ROLE ---
-Do a soft delete by setting user.Active = false 
-- Preserves data just marks it as inactive rather than deleting the whole thing
+--- UPDATE USER PROFILE ---
+Apply a partial update to a user's profile
+- patchJSON: {"username":"alice2","email":"alice@new.org","organization":"Org2MSP"}
+- Only username, email and organization can be patched
+- id, createdAt and createdBy are immutable, role/active have their own transactions
+- Email and username changes move the USER_EMAIL / USER_NAME index keys in the same transaction
+- ERASED users cannot be changed, erasure must not be undone by patching the profile back
 */
-
-func (c *UserContract) DeactivateUser( ctx contractapi.TransactionContextInterface, id string) error {
-	log.Printf("[DeactivateUser] ENTER id=%s ", id)
-
-	//Input validation for ID
+func (c *UserContract) UpdateUserProfile(ctx contractapi.TransactionContextInterface, id string, patchJSON string) error {
+	// Input validation
 	if id == "" {
-		return  fmt.Errorf("id is required")
+		return invalidArgument("id", "id is required")
+	}
+	if patchJSON == "" {
+		return invalidArgument("patchJSON", "patchJSON is required")
+	}
+
+	patch, err := parseUserProfilePatch(patchJSON)
+	if err != nil {
+		return err
 	}
 
-	// Get user 
+	// Get current user from ledger
 	user, err := c.GetUser(ctx, id)
-	if err != nil{
-		log.Printf("[DeactivateUser] ERROR id=%s err=%v", id, err)
-		return fmt.Errorf("failed to get user from ledger, user ID=%s: %v", id, err)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return wrapError(err, "failed to get user from ledger, user ID=%s", pii(id))
+	}
+	if user.Status == UserStatusErased {
+		return failedPrecondition("cannot update profile of erased user id:%s", pii(id))
+	}
 
+	// Apply the patch on a copy so the old index values are still known
+	updated := *user
+	if patch.Username != nil {
+		updated.Username = *patch.Username
+	}
+	if patch.Email != nil {
+		updated.Email = *patch.Email
+	}
+	if patch.Organization != nil {
+		updated.Organization = *patch.Organization
 	}
 
-	// If user is not active return error 
-	if !user.Active{
-		log.Printf("[DeactivateUser] ERROR user already Deactivate id=%s", id)
-		return fmt.Errorf("user already Deactivate, user ID=%s, Active status=%s", id, user.Active)
+	var changed []string
+	if updated.Username != user.Username {
+		changed = append(changed, "username")
+	}
+	if updated.Email != user.Email {
+		changed = append(changed, "email")
+	}
+	if updated.Organization != user.Organization {
+		changed = append(changed, "organization")
+	}
+	if len(changed) == 0 {
+		return invalidArgument("patchJSON", "patch does not change user id=%s", pii(id))
 	}
 
-	// deactivate user, user.Active = false  
-	user.Active = false  
+	// Moving an org's last active admin elsewhere would leave that org without one
+	if updated.Organization != user.Organization {
+		err = guardLastActiveAdmin(ctx, user)
+		if err != nil {
+			return err
+		}
+	}
 
-	//Create composite key (same as RegisterUser/GetUser)
+	// Move index keys if a unique field changed
+	if updated.Username != user.Username || updated.Email != user.Email {
+		err = checkUserIdentityAvailable(ctx, id, updated.Username, updated.Email)
+		if err != nil {
+			return err
+		}
+		err = deleteUserIndexes(ctx, user)
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR", err, "userId", id)
+			return err
+		}
+		err = putUserIndexes(ctx, &updated)
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR", err, "userId", id)
+			return err
+		}
+	}
+
+	// Record when and by whom the user was changed
+	err = stampUserUpdate(ctx, &updated)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return err
+	}
+
+	// Create composite key (same as RegisterUser/GetUser)
 	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{id})
-	if err != nil{
-		log.Printf("[DeactivateUser] ERROR creating composite key id=%s err=%v", id, err)
-		return fmt.Errorf("failed to create composite key for user ID=%s: %v", id, err)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR creating composite key", err, "userId", id)
+		return internalError("failed to create composite key for user ID=%s: %v", pii(id), err)
 	}
 
 	// Marshal to JSON
-	userJSON, err := json.Marshal(user)
+	userJSON, err := json.Marshal(updated)
 	if err != nil {
-		log.Printf("[DeactivateUser] ERROR marshaling user id=%s err=%v", id, err)
-		return fmt.Errorf("failed to marshal user ID=%s: %v", id, err)
+		ctxLog(ctx).Fail("ERROR marshaling user", err, "userId", id)
+		return internalError("failed to marshal user ID=%s: %v", pii(id), err)
 	}
-	
+
 	// Write updated user back to ledger
 	err = ctx.GetStub().PutState(compositeKey, userJSON)
 	if err != nil {
-		log.Printf("[DeactivateUser] ERROR writing user to ledger id=%s err=%v", id, err)
-		return fmt.Errorf("failed to write user to ledger, ID=%s to ledger: %v", id, err)
+		ctxLog(ctx).Fail("ERROR writing user to ledger", err, "userId", id)
+		return internalError("failed to write user ID=%s to ledger: %v", pii(id), err)
 	}
 
+	// Emit the stored user for off-chain mirrors
+	err = setUserEvent(ctx, id, userJSON)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return err
+	}
 
-	//Log success with old and new role
-	log.Printf("[DeactivateUser] SUCCESS id=%s Active status=%s", id, user.Active)
-
-	//Return nill for error
 	return nil
+}
+
+/*
+--- DEACTIVATE USER ---
+Do a soft delete by moving the user to DEACTIVATED
+- Preserves data just marks it as inactive rather than deleting the whole thing
+- Allowed from PENDING_ACTIVATION, ACTIVE and SUSPENDED, see user_lifecycle.go
+- reason is stored on the user as statusReason
+*/
+func (c *UserContract) DeactivateUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	//Input validation for ID
+	if id == "" {
+		return invalidArgument("id", "id is required")
+	}
+	if reason == "" {
+		return invalidArgument("reason", "reason is required")
+	}
 
+	return c.transitionUser(ctx, id, UserStatusDeactivated, reason, nil)
 }
- 
 
 /*
 --- CHECK  USER EXISTS ---
 Check if the user is in the ledger 
 */
-func (c *UserContract) UserExists(ctx contractapi.TransactionContext, id string) (bool, error){
-	log.Printf("[UserExists] ENTER id=%s ", id)
-
+func (c *UserContract) UserExists(ctx contractapi.TransactionContextInterface, id string) (bool, error){
 	// Input validation
 	if id == "" {
-		return false, fmt.Errorf("id is required")
+		return false, invalidArgument("id", "id is required")
 	}
 
 	// Create composite key (same pattern as RegisterUser)
 	compositeKey, err := ctx.GetStub().CreateCompositeKey("USER", []string{id})
 	if err != nil {
-		log.Printf("[UserExists] ERROR creating composite key id=%s err=%v", id, err)
-		return false, fmt.Errorf("failed to create composite key: %v", err)
+		ctxLog(ctx).Fail("ERROR creating composite key", err, "userId", id)
+		return false, internalError("failed to create composite key: %v", err)
 	}
 
 	// Get state from ledger
 	userJSON, err := ctx.GetStub().GetState(compositeKey)
 	if err != nil {
-		log.Printf("[UserExists] ERROR id=%s err=%v", id, err)
-		return false, fmt.Errorf("failed to check if user exists: %v", err)
+		ctxLog(ctx).Fail("ERROR", err, "userId", id)
+		return false, internalError("failed to check if user exists: %v", err)
 	}
 
 	exists := userJSON != nil
-	log.Printf("[UserExists] id=%s exists=%v", id, exists)
+	ctxLog(ctx).Debug("EXISTS", "userId", id, "exists", exists)
 	return exists, nil
 
 }
 
+/*
+--- GET USER by EMAIL ---
+Resolve a user through the USER_EMAIL index (email is case-folded before lookup)
+*/
+func (c *UserContract) GetUserByEmail(ctx contractapi.TransactionContextInterface, email string) (*User, error) {
+	// Input validation
+	if email == "" {
+		return nil, invalidArgument("email", "email is required")
+	}
+
+	return c.getUserByIndex(ctx, userEmailIndex, normalizeEmail(email))
+}
+
+/*
+--- GET USER by USERNAME ---
+Resolve a user through the USER_NAME index
+*/
+func (c *UserContract) GetUserByUsername(ctx contractapi.TransactionContextInterface, username string) (*User, error) {
+	// Input validation
+	if username == "" {
+		return nil, invalidArgument("username", "username is required")
+	}
+
+	return c.getUserByIndex(ctx, userNameIndex, username)
+}
+
+/*
+--- REINDEX USERS ---
+Backfill USER_EMAIL / USER_NAME keys for users registered before the indexes existed
+- Admin only
+- Fails on the first email/username that is claimed by two users, those need fixing by hand
+Returns the number of users that were (re)indexed
+*/
+func (c *UserContract) ReindexUsers(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("USER", []string{})
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err)
+		return 0, internalError("failed to get users: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// Keys claimed by this transaction: Fabric reads never see the transaction's own writes,
+	// so a second user with the same email would pass checkUserIdentityAvailable
+	claimed := map[[2]string]string{}
+
+	reindexed := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR iterating", err)
+			return 0, internalError("failed to iterate users: %v", err)
+		}
+
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR unmarshaling", err, "key", queryResponse.Key)
+			return 0, internalError("failed to unmarshal user: %v", err)
+		}
+
+		// Erased users released their keys for good
+		if user.Status == UserStatusErased {
+			continue
+		}
+
+		// Older records may hold a mixed-case email, the index always uses the folded form
+		user.Email = normalizeEmail(user.Email)
+
+		err = checkUserIdentityAvailable(ctx, user.ID, user.Username, user.Email)
+		if err != nil {
+			return 0, err
+		}
+		for _, pair := range userIndexValues(&user) {
+			if ownerID, ok := claimed[pair]; ok && ownerID != user.ID {
+				return 0, alreadyExists("%s of user %s is also held by user %s", pair[0], pii(user.ID), pii(ownerID))
+			}
+			claimed[pair] = user.ID
+		}
+
+		err = putUserIndexes(ctx, &user)
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR", err, "userId", user.ID)
+			return 0, err
+		}
+		reindexed++
+	}
+
+	return reindexed, nil
+}
+
+/*
+--- LIST USERS ---
+Page through all users stored under the "USER" composite key namespace
+- filterJSON (optional): {"role":"ADMIN","organization":"Org1MSP","status":"ACTIVE","active":true,"createdBy":"user-alice"}
+- pageSize: max users returned per page (1-100)
+- bookmark: "" for the first page, then the bookmark returned by the previous page
+Keeps reading ledger pages until pageSize matching users are found or the namespace is exhausted,
+so the returned bookmark always points at the first user that has not been looked at yet
+*/
+func (c *UserContract) ListUsers(ctx contractapi.TransactionContextInterface,
+	filterJSON string, pageSize int32, bookmark string) (*UserQueryResult, error) {
+
+
+	// Input validation
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return nil, invalidArgument("pageSize", "pageSize must be between 1 and %d", maxPageSize)
+	}
+
+	filter, err := parseUserFilter(filterJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Needed to report lapsed suspensions as ACTIVE
+	nowMillis, err := txTimeMillis(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []*User{}
+	nextBookmark := bookmark
+
+	// Each pass reads one ledger page, stop once we have a full page of matches
+	for {
+		resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("USER", []string{}, pageSize, nextBookmark)
+		if err != nil {
+			ctxLog(ctx).Fail("ERROR", err)
+			return nil, internalError("failed to get users: %v", err)
+		}
+
+		pageFull := false
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				ctxLog(ctx).Fail("ERROR iterating", err)
+				return nil, internalError("failed to iterate users: %v", err)
+			}
+
+			// Page is full, the next page starts at this record
+			if int32(len(users)) == pageSize {
+				nextBookmark = queryResponse.Key
+				pageFull = true
+				break
+			}
+
+			var user User
+			err = json.Unmarshal(queryResponse.Value, &user)
+			if err != nil {
+				resultsIterator.Close()
+				ctxLog(ctx).Fail("ERROR unmarshaling", err, "key", queryResponse.Key)
+				return nil, internalError("failed to unmarshal user: %v", err)
+			}
+			normalizeUserStatus(&user, nowMillis)
+
+			if filter.matches(&user) {
+				users = append(users, &user)
+			}
+		}
+		resultsIterator.Close()
+
+		if pageFull {
+			break
+		}
+
+		// Ledger page exhausted, continue from where it left off (empty bookmark = no more users)
+		nextBookmark = metadata.Bookmark
+		if nextBookmark == "" {
+			break
+		}
+	}
+
+	return &UserQueryResult{
+		Records:             users,
+		FetchedRecordsCount: int32(len(users)),
+		Bookmark:            nextBookmark,
+	}, nil
+}
+
+/*
+--- parseUserFilter (helper) ---
+Decodes the ListUsers filter, rejecting unknown fields so typos don't silently match everything
+*/
+func parseUserFilter(filterJSON string) (*UserFilter, error) {
+	filter := &UserFilter{}
+	if filterJSON == "" {
+		return filter, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(filterJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(filter); err != nil {
+		return nil, invalidArgument("filterJSON", "invalid filter JSON: %v", err)
+	}
+
+	validRoles := map[string]bool{
+		"ADMIN": true, "AUDITOR": true, "USER": true,
+	}
+	if filter.Role != "" && !validRoles[filter.Role] {
+		return nil, invalidArgument("filterJSON", "invalid role filter: %s. Valid Roles: ADMIN, AUDITOR, or USER", filter.Role)
+	}
+	if filter.Status != "" && userStatusTransitions[filter.Status] == nil {
+		return nil, invalidArgument("filterJSON", "invalid status filter: %s", filter.Status)
+	}
+
+	return filter, nil
+}
+
+// matches reports whether a user passes every filter that was set
+func (f *UserFilter) matches(user *User) bool {
+	if f.Role != "" && user.Role != f.Role {
+		return false
+	}
+	if f.Organization != "" && user.Organization != f.Organization {
+		return false
+	}
+	if f.Status != "" && user.Status != f.Status {
+		return false
+	}
+	if f.Active != nil && user.Active != *f.Active {
+		return false
+	}
+	if f.CreatedBy != "" && user.CreatedBy != f.CreatedBy {
+		return false
+	}
+	return true
+}
+
+/*
+--- USER INDEX KEYS (helpers) ---
+Secondary composite keys that map a unique attribute back to the user ID:
+	"USER_EMAIL~alice@example.com~" -> "user-alice"
+	"USER_NAME~alice~"              -> "user-alice"
+Written and deleted in the same transaction as the user record, so they can never drift
+*/
+const (
+	userEmailIndex = "USER_EMAIL"
+	userNameIndex  = "USER_NAME"
+)
+
+// normalizeEmail case-folds and trims an email before it is stored or indexed
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validateEmail rejects anything that isn't a bare address like alice@example.com
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return invalidArgument("email", "invalid email: %s", piiEmail(email))
+	}
+	return nil
+}
+
+// lookupUserIndex returns the user ID stored under an index key, "" if nobody holds it
+func lookupUserIndex(ctx contractapi.TransactionContextInterface, index string, value string) (string, error) {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{value})
+	if err != nil {
+		return "", internalError("failed to create %s key: %v", index, err)
+	}
+
+	idBytes, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return "", internalError("failed to read %s key: %v", index, err)
+	}
+	return string(idBytes), nil
+}
+
+// checkUserIdentityAvailable makes sure username and email are free, or already belong to user id
+func checkUserIdentityAvailable(ctx contractapi.TransactionContextInterface, id string, username string, email string) error {
+	ownerID, err := lookupUserIndex(ctx, userEmailIndex, email)
+	if err != nil {
+		return err
+	}
+	if ownerID != "" && ownerID != id {
+		return alreadyExists("email %s is already registered to another user", piiEmail(email))
+	}
+
+	ownerID, err = lookupUserIndex(ctx, userNameIndex, username)
+	if err != nil {
+		return err
+	}
+	if ownerID != "" && ownerID != id {
+		return alreadyExists("username %s is already taken", pii(username))
+	}
+	return nil
+}
+
+// userIndexValues lists the (index, value) pairs a user occupies, in a fixed order
+// (erased users have no username/email and hold no index keys)
+func userIndexValues(user *User) [][2]string {
+	var pairs [][2]string
+	if user.Email != "" {
+		pairs = append(pairs, [2]string{userEmailIndex, user.Email})
+	}
+	if user.Username != "" {
+		pairs = append(pairs, [2]string{userNameIndex, user.Username})
+	}
+	return pairs
+}
+
+// putUserIndexes points the USER_EMAIL and USER_NAME keys at the user
+func putUserIndexes(ctx contractapi.TransactionContextInterface, user *User) error {
+	for _, pair := range userIndexValues(user) {
+		index, value := pair[0], pair[1]
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{value})
+		if err != nil {
+			return internalError("failed to create %s key for user ID=%s: %v", index, pii(user.ID), err)
+		}
+		err = ctx.GetStub().PutState(indexKey, []byte(user.ID))
+		if err != nil {
+			return internalError("failed to write %s key for user ID=%s: %v", index, pii(user.ID), err)
+		}
+	}
+	return nil
+}
+
+// deleteUserIndexes removes the USER_EMAIL and USER_NAME keys a user currently holds
+func deleteUserIndexes(ctx contractapi.TransactionContextInterface, user *User) error {
+	for _, pair := range userIndexValues(user) {
+		index, value := pair[0], pair[1]
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{value})
+		if err != nil {
+			return internalError("failed to create %s key for user ID=%s: %v", index, pii(user.ID), err)
+		}
+		err = ctx.GetStub().DelState(indexKey)
+		if err != nil {
+			return internalError("failed to delete %s key for user ID=%s: %v", index, pii(user.ID), err)
+		}
+	}
+	return nil
+}
+
+// getUserByIndex resolves an index key to the full user record
+func (c *UserContract) getUserByIndex(ctx contractapi.TransactionContextInterface, index string, value string) (*User, error) {
+	id, err := lookupUserIndex(ctx, index, value)
+	if err != nil {
+		ctxLog(ctx).Fail("ERROR", err, "index", index)
+		return nil, err
+	}
+	if id == "" {
+		if index == userEmailIndex {
+			return nil, notFound("no user found for %s %s", index, piiEmail(value))
+		}
+		return nil, notFound("no user found for %s %s", index, pii(value))
+	}
+
+	return c.GetUser(ctx, id)
+}
+
+/*
+--- USER PROFILE PATCH (helpers) ---
+Decodes and validates an UpdateUserProfile patch field by field
+*/
+
+// userProfilePatch holds the patchable fields, nil = not in the patch
+type userProfilePatch struct {
+	Username     *string
+	Email        *string
+	Organization *string
+}
+
+// Fields that can never change after RegisterUser
+var immutableUserFields = map[string]bool{
+	"id": true, "createdAt": true, "createdBy": true,
+}
+
+// Fields with their own transaction (UpdateUserRole, DeactivateUser) or maintained by the contract
+var managedUserFields = map[string]string{
+	"role":           "use UpdateUserRole",
+	"permissions":    "derived from role, use UpdateUserRole",
+	"active":         "use ActivateUser / DeactivateUser",
+	"status":         "use the lifecycle transactions (ActivateUser, SuspendUser, ...)",
+	"statusReason":   "set by the lifecycle transactions",
+	"suspendedUntil": "use SuspendUser",
+	"updatedAt":      "set by the contract",
+	"updatedBy":      "set by the contract",
+}
+
+func parseUserProfilePatch(patchJSON string) (*userProfilePatch, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(patchJSON), &fields)
+	if err != nil {
+		return nil, invalidArgument("patchJSON", "invalid patch JSON: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, invalidArgument("patchJSON", "patch must contain at least one field")
+	}
+
+	// Walk fields in sorted order so the same bad patch always gets the same error on every peer
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	patch := &userProfilePatch{}
+	for _, field := range names {
+		raw := fields[field]
+		if immutableUserFields[field] {
+			return nil, invalidArgument("patchJSON", "field %s is immutable", field)
+		}
+		if reason, ok := managedUserFields[field]; ok {
+			return nil, invalidArgument("patchJSON", "field %s cannot be patched: %s", field, reason)
+		}
+
+		var value string
+		switch field {
+		case "username", "email", "organization":
+			err = json.Unmarshal(raw, &value)
+			if err != nil {
+				return nil, invalidArgument("patchJSON", "field %s must be a string", field)
+			}
+		default:
+			return nil, invalidArgument("patchJSON", "unknown field %s", field)
+		}
+
+		switch field {
+		case "username":
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return nil, invalidArgument("patchJSON", "field username cannot be empty")
+			}
+			if len(value) > 64 {
+				return nil, invalidArgument("patchJSON", "field username exceeds maximum length of 64 characters")
+			}
+			patch.Username = &value
+		case "email":
+			value = normalizeEmail(value)
+			if err := validateEmail(value); err != nil {
+				return nil, invalidArgument("patchJSON", "field email: invalid email: %s", piiEmail(value))
+			}
+			patch.Email = &value
+		case "organization":
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return nil, invalidArgument("patchJSON", "field organization cannot be empty")
+			}
+			patch.Organization = &value
+		}
+	}
+
+	return patch, nil
+}
+
+// stampUserUpdate sets UpdatedAt (tx timestamp) and UpdatedBy (caller) on a user about to be written
+func stampUserUpdate(ctx contractapi.TransactionContextInterface, user *User) error {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return internalError("failed to get transaction timestamp: %v", err)
+	}
+	callerID, err := getCallerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	user.UpdatedAt = txTimestamp.AsTime().UnixMilli()
+	user.UpdatedBy = callerID
+	return nil
+}
+
 /*
 --- getDefaultPermissions  ---
 Gives default permissions accodining to user roles 
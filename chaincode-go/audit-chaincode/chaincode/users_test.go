@@ -0,0 +1,260 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserLifecycle(t *testing.T) {
+	n := newNetwork(t)
+
+	n.submit(n.admin, nil, "UserContract:InviteUser", "user-alice", "alice", "alice@example.com", "USER", "Org1", "admin")
+	var user User
+	n.evaluate(n.client, &user, "UserContract:GetUser", "user-alice")
+	if user.Status != UserStatusPendingActivation || user.Active || strings.Join(user.Permissions, ",") != "audit.read.own" {
+		t.Errorf("invited = %+v", user)
+	}
+	n.reject(CodeFailedPrecondition, n.admin, "UserContract:SuspendUser", "user-alice", "0")
+
+	n.submit(n.admin, nil, "UserContract:ActivateUser", "user-alice")
+
+	// A suspension with a deadline lapses on its own
+	until := n.millis() + 1500
+	n.submit(n.admin, nil, "UserContract:SuspendUser", "user-alice", fmt.Sprint(until))
+	n.evaluate(n.client, &user, "UserContract:GetUser", "user-alice")
+	if user.Status != UserStatusSuspended || user.SuspendedUntil != until {
+		t.Errorf("suspended = %+v", user)
+	}
+	n.wait(2 * time.Second)
+	n.evaluate(n.client, &user, "UserContract:GetUser", "user-alice")
+	if user.Status != UserStatusActive || !user.Active {
+		t.Errorf("after deadline = %+v", user)
+	}
+
+	n.submit(n.admin, nil, "UserContract:SuspendUser", "user-alice", "0")
+	n.submit(n.admin, nil, "UserContract:ReinstateUser", "user-alice")
+	n.reject(CodeFailedPrecondition, n.admin, "UserContract:EraseUser", "user-alice", "GDPR request")
+	n.submit(n.admin, nil, "UserContract:DeactivateUser", "user-alice", "left company")
+
+	// Erasure scrubs the profile and frees the email for a new user
+	n.submit(n.admin, nil, "UserContract:EraseUser", "user-alice", "GDPR request")
+	n.evaluate(n.client, &user, "UserContract:GetUser", "user-alice")
+	if user.Status != UserStatusErased || user.Email != "" || user.Username != "" || len(user.Permissions) != 0 {
+		t.Errorf("erased = %+v", user)
+	}
+	n.reject(CodeFailedPrecondition, n.admin, "UserContract:ActivateUser", "user-alice")
+	n.reject(CodeFailedPrecondition, n.admin, "UserContract:UpdateUserProfile", "user-alice", `{"email":"back@example.com","username":"restored"}`)
+	n.reject(CodeFailedPrecondition, n.admin, "UserContract:UpdateUserRole", "user-alice", "ADMIN")
+	n.submit(n.admin, nil, "UserContract:RegisterUser", "user-alice2", "alice", "alice@example.com", "USER", "Org1", "admin")
+
+	// One UserChanged event per write, carrying the stored record
+	statuses := []string{}
+	for _, event := range n.ledger.Events() {
+		if event.Name != UserEventName {
+			continue
+		}
+		var changed User
+		if err := json.Unmarshal(event.Payload, &changed); err != nil {
+			t.Fatalf("event payload = %s", event.Payload)
+		}
+		if changed.ID == "user-alice" {
+			statuses = append(statuses, changed.Status)
+		}
+	}
+	want := "PENDING_ACTIVATION,ACTIVE,SUSPENDED,SUSPENDED,ACTIVE,DEACTIVATED,ERASED"
+	if got := strings.Join(statuses, ","); got != want {
+		t.Errorf("UserChanged statuses = %s, want %s", got, want)
+	}
+}
+
+func TestUserUniqueness(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("user-alice", "USER")
+
+	n.reject(CodeAlreadyExists, n.admin, "UserContract:RegisterUser", "user-alice", "other", "other@example.com", "USER", "Org1", "admin")
+	n.reject(CodeAlreadyExists, n.admin, "UserContract:RegisterUser", "user-bob", "bob", "USER-ALICE@example.com", "USER", "Org1", "admin")
+	n.reject(CodeAlreadyExists, n.admin, "UserContract:RegisterUser", "user-bob", "user-alice", "bob@example.com", "USER", "Org1", "admin")
+	n.reject(CodeInvalidArgument, n.admin, "UserContract:RegisterUser", "user-bob", "bob", "not-an-email", "USER", "Org1", "admin")
+	n.reject(CodeInvalidArgument, n.admin, "UserContract:RegisterUser", "user-bob", "bob", "bob@example.com", "ROOT", "Org1", "admin")
+
+	var user User
+	n.evaluate(n.client, &user, "UserContract:GetUserByEmail", "User-Alice@Example.com")
+	if user.ID != "user-alice" {
+		t.Errorf("GetUserByEmail = %+v", user)
+	}
+	n.reject(CodeNotFound, n.client, "UserContract:GetUserByEmail", "bob@example.com")
+
+	// A profile change moves the index keys with it
+	n.submit(n.admin, nil, "UserContract:UpdateUserProfile", "user-alice", `{"email":"alice@example.org"}`)
+	n.reject(CodeNotFound, n.client, "UserContract:GetUserByEmail", "user-alice@example.com")
+	n.evaluate(n.client, &user, "UserContract:GetUserByEmail", "alice@example.org")
+	if user.ID != "user-alice" {
+		t.Errorf("GetUserByEmail after update = %+v", user)
+	}
+	n.registerUser("user-bob", "USER")
+	n.reject(CodeAlreadyExists, n.admin, "UserContract:UpdateUserProfile", "user-bob", `{"email":"alice@example.org"}`)
+}
+
+func TestUserUpdateStamps(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("user-carol", "ADMIN")
+	n.registerUser("user-alice", "USER")
+	carol := n.identity("Org1MSP", "carol", "client", "user-carol")
+
+	// Every change records the transaction time and the caller
+	changes := []struct {
+		caller string
+		args   []string
+	}{
+		{"user-carol", []string{"UserContract:UpdateUserProfile", "user-alice", `{"username":"alice2"}`}},
+		{"Org1MSP:Admin@org1.example.com", []string{"UserContract:UpdateUserRole", "user-alice", "AUDITOR"}},
+		{"user-carol", []string{"UserContract:DeactivateUser", "user-alice", "left"}},
+	}
+	for _, change := range changes {
+		id := n.admin
+		if change.caller == "user-carol" {
+			id = carol
+		}
+		at := n.millis()
+		n.submit(id, nil, change.args...)
+		var user User
+		n.evaluate(n.client, &user, "UserContract:GetUser", "user-alice")
+		if user.UpdatedAt != at || user.UpdatedBy != change.caller {
+			t.Errorf("%s: updatedAt = %d, updatedBy = %s, want %d, %s", change.args[0], user.UpdatedAt, user.UpdatedBy, at, change.caller)
+		}
+	}
+
+	// The record's identity and creation stamp cannot be patched
+	for _, patch := range []string{`{"id":"user-mallory"}`, `{"createdAt":1}`, `{"createdBy":"user-mallory"}`} {
+		envelope := n.reject(CodeInvalidArgument, n.admin, "UserContract:UpdateUserProfile", "user-carol", patch)
+		if len(envelope.Details) != 1 || envelope.Details[0].Field != "patchJSON" {
+			t.Errorf("%s: details = %+v", patch, envelope.Details)
+		}
+	}
+}
+
+func TestUserGuards(t *testing.T) {
+	n := newNetwork(t)
+	n.registerUser("admin-1", "ADMIN")
+	n.registerUser("user-alice", "USER")
+
+	var envelope TransactionError
+	guard := func(reason string, args ...string) {
+		t.Helper()
+		envelope = n.reject(CodeFailedPrecondition, n.admin, args...)
+		if envelope.Reason != reason {
+			t.Errorf("%v: reason = %s, want %s", args, envelope.Reason, reason)
+		}
+	}
+
+	// admin-1 is the only ACTIVE ADMIN of Org1
+	guard(InvariantLastActiveAdmin, "UserContract:UpdateUserRole", "admin-1", "AUDITOR")
+	guard(InvariantLastActiveAdmin, "UserContract:SuspendUser", "admin-1", "0")
+	guard(InvariantLastActiveAdmin, "UserContract:DeactivateUser", "admin-1", "left")
+	guard(InvariantLastActiveAdmin, "UserContract:UpdateUserProfile", "admin-1", `{"organization":"Org2"}`)
+
+	n.submit(n.admin, nil, "UserContract:UpdateUserRole", "user-alice", "ADMIN")
+	n.submit(n.admin, nil, "UserContract:UpdateUserRole", "admin-1", "AUDITOR")
+
+	// The caller is the userId certificate attribute, it cannot change its own role or status
+	alice := n.identity("Org1MSP", "alice", "client", "user-alice")
+	envelope = n.reject(CodeFailedPrecondition, alice, "UserContract:SuspendUser", "user-alice", "0")
+	if envelope.Reason != InvariantSelfModification {
+		t.Errorf("self suspend = %+v", envelope)
+	}
+	n.submit(alice, nil, "UserContract:UpdateUserRole", "admin-1", "ADMIN")
+
+	// An org admin certificate without the attribute is never the target, the backend keeps working with it
+	n.submit(n.admin, nil, "UserContract:UpdateUserRole", "user-alice", "AUDITOR")
+	n.submit(n.admin, nil, "UserContract:DeactivateUser", "user-alice", "left")
+	var user User
+	n.evaluate(n.client, &user, "UserContract:GetUser", "user-alice")
+	if user.Role != "AUDITOR" || user.Status != UserStatusDeactivated {
+		t.Errorf("after org admin changes = %+v", user)
+	}
+	n.submit(n.admin, nil, "UserContract:ActivateUser", "user-alice")
+}
+
+func TestListUsers(t *testing.T) {
+	n := newNetwork(t)
+	for i := 1; i <= 5; i++ {
+		role := "USER"
+		if i%2 == 0 {
+			role = "AUDITOR"
+		}
+		n.registerUser(fmt.Sprintf("user-%d", i), role)
+	}
+	n.submit(n.admin, nil, "UserContract:DeactivateUser", "user-3", "left")
+
+	list := func(filter string, pageSize int) string {
+		pages, bookmark := []string{}, ""
+		for {
+			var page UserQueryResult
+			n.evaluate(n.client, &page, "UserContract:ListUsers", filter, fmt.Sprint(pageSize), bookmark)
+			ids := []string{}
+			for _, user := range page.Records {
+				ids = append(ids, user.ID)
+			}
+			pages = append(pages, strings.Join(ids, ","))
+			if page.Bookmark == "" {
+				return strings.Join(pages, " | ")
+			}
+			bookmark = page.Bookmark
+		}
+	}
+
+	tests := []struct {
+		filter   string
+		pageSize int
+		want     string
+	}{
+		{"", 2, "user-1,user-2 | user-3,user-4 | user-5"},
+		{"", 5, "user-1,user-2,user-3,user-4,user-5"},
+		{`{"role":"USER"}`, 2, "user-1,user-3 | user-5"},
+		{`{"role":"USER","active":true}`, 2, "user-1,user-5"},
+		{`{"status":"DEACTIVATED"}`, 1, "user-3 | "},
+		{`{"organization":"Org2"}`, 2, ""},
+	}
+	for _, tt := range tests {
+		if got := list(tt.filter, tt.pageSize); got != tt.want {
+			t.Errorf("ListUsers(%s, %d) = %s, want %s", tt.filter, tt.pageSize, got, tt.want)
+		}
+	}
+
+	n.reject(CodeInvalidArgument, n.client, "UserContract:ListUsers", "", "101", "")
+	n.reject(CodeInvalidArgument, n.client, "UserContract:ListUsers", `{"colour":"blue"}`, "10", "")
+}
+
+func TestReindexUsers(t *testing.T) {
+	n := newNetwork(t)
+
+	// Users written before the index keys existed
+	legacy := func(id string, username string, email string) {
+		t.Helper()
+		key := "\x00USER\x00" + id + "\x00"
+		value := fmt.Sprintf(`{"id":%q,"username":%q,"email":%q,"role":"USER","permissions":["audit.read.own"],"organization":"Org1","active":true}`, id, username, email)
+		if err := n.ledger.PutState(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy("user-a", "a", "Dup@example.com")
+	legacy("user-b", "b", "dup@example.com")
+
+	n.reject(CodePermissionDenied, n.client, "UserContract:ReindexUsers")
+	n.reject(CodeAlreadyExists, n.admin, "UserContract:ReindexUsers")
+	if n.ledger.State("\x00USER_EMAIL\x00dup@example.com\x00") != nil {
+		t.Error("conflicting reindex was committed")
+	}
+
+	legacy("user-b", "b", "b@example.com")
+	var count int
+	n.submit(n.admin, &count, "UserContract:ReindexUsers")
+	var user User
+	n.evaluate(n.client, &user, "UserContract:GetUserByEmail", "dup@example.com")
+	if count != 2 || user.ID != "user-a" {
+		t.Errorf("count = %d, dup@example.com = %s", count, user.ID)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/anchor"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa"
+)
+
+/*
+---- MODULE NOTES ----
+
+audit-anchor timestamps checkpoints (and optionally single audit entries) with an RFC 3161 TSA
+and stores the tokens on the ledger through the Fabric Gateway.
+
+	audit-anchor                          - anchor pending checkpoints every -interval
+	audit-anchor -once                    - anchor pending checkpoints once and exit
+	audit-anchor -audit-ids a-1,a-2 -once - also anchor these audit entries
+
+Connection settings come from the same environment as the REST backend (see gateway/config.go),
+plus TSA_URL. The submitting identity only needs to be a registered user, but the TSA's root
+must first be trusted by an admin: CheckpointContract:SetTrustedTSA.
+*/
+
+func main() {
+	tsaURL := flag.String("tsa-url", envOrDefault("TSA_URL", "https://freetsa.org/tsr"), "RFC 3161 TSA endpoint")
+	interval := flag.Duration("interval", time.Hour, "time between anchoring runs")
+	once := flag.Bool("once", false, "run once and exit")
+	auditIDs := flag.String("audit-ids", "", "comma separated audit entries to anchor individually")
+	flag.Parse()
+
+	client, err := gateway.Connect(gateway.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("[audit-anchor] failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	service := &anchor.Service{
+		Ledger: client,
+		TSA:    &tsa.Client{URL: *tsaURL, HTTPClient: &http.Client{Timeout: 30 * time.Second}},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, auditID := range strings.Split(*auditIDs, ",") {
+		auditID = strings.TrimSpace(auditID)
+		if auditID == "" {
+			continue
+		}
+		_, err := service.AnchorAudit(ctx, auditID)
+		if err != nil {
+			log.Printf("[audit-anchor] ERROR %v", err)
+		}
+	}
+
+	for {
+		anchored, err := service.AnchorCheckpoints(ctx)
+		if err != nil {
+			log.Printf("[audit-anchor] ERROR %v", err)
+		}
+		log.Printf("[audit-anchor] anchored %d checkpoint(s)", len(anchored))
+
+		if *once {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
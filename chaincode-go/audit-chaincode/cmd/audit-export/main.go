@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/export"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+)
+
+/*
+---- MODULE NOTES ----
+
+audit-export pages audit entries off the ledger into a file plus a manifest (export package).
+
+	audit-export -format jsonl -out audits.jsonl
+	audit-export -format csv -out soc2.csv -tag SOC2 -start 2025-01-01T00:00:00Z -end 2025-12-31T23:59:59Z
+	audit-export -format parquet -out alice.parquet -user user-alice
+
+-start / -end take Unix milliseconds or RFC 3339. The manifest (default <out>.manifest.json)
+records counts, time bounds and the SHA-256 of the output.
+
+Connection settings come from the same environment as the REST backend (see gateway/config.go).
+*/
+
+func main() {
+	format := flag.String("format", export.FormatJSONL, "jsonl, csv, parquet, cef, leef or ocsf")
+	out := flag.String("out", "", "output file")
+	manifestPath := flag.String("manifest", "", "manifest file (default <out>.manifest.json)")
+	start := flag.String("start", "", "oldest timestamp, Unix ms or RFC 3339 (default: beginning)")
+	end := flag.String("end", "", "newest timestamp, Unix ms or RFC 3339 (default: no upper bound)")
+	userID := flag.String("user", "", "only this user's entries")
+	tag := flag.String("tag", "", "only entries with this compliance tag")
+	pageSize := flag.Int("page-size", export.DefaultPageSize, "entries per QueryAuditsPage call (max 1000)")
+	flag.Parse()
+
+	if *out == "" {
+		log.Fatalf("[audit-export] -out is required")
+	}
+	if *manifestPath == "" {
+		*manifestPath = *out + ".manifest.json"
+	}
+	filter := export.Filter{UserID: *userID, ComplianceTag: *tag}
+	var err error
+	filter.StartTs, err = parseTime(*start)
+	if err != nil {
+		log.Fatalf("[audit-export] invalid -start: %v", err)
+	}
+	filter.EndTs, err = parseTime(*end)
+	if err != nil {
+		log.Fatalf("[audit-export] invalid -end: %v", err)
+	}
+
+	client, err := gateway.Connect(gateway.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("[audit-export] failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Write to a temp file and rename, a failed export never leaves a partial file behind
+	file, err := os.CreateTemp(filepath.Dir(*out), filepath.Base(*out)+".tmp-*")
+	if err != nil {
+		log.Fatalf("[audit-export] %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	manifest, err := export.Export(context.Background(), client, filter, *format, *pageSize, file)
+	if err == nil {
+		err = file.Close()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), *out)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		log.Fatalf("[audit-export] export failed: %v", err)
+	}
+
+	manifest.File = filepath.Base(*out)
+	err = export.WriteManifest(*manifestPath, manifest)
+	if err != nil {
+		log.Fatalf("[audit-export] failed to write manifest: %v", err)
+	}
+	fmt.Printf("exported %d entries (%d pages) to %s, sha256 %s\n", manifest.Entries, manifest.Pages, *out, manifest.SHA256)
+}
+
+// parseTime accepts Unix milliseconds or RFC 3339, "" = 0
+func parseTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return millis, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither Unix milliseconds nor RFC 3339", value)
+	}
+	return parsed.UnixMilli(), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/forward"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/siem"
+)
+
+/*
+---- MODULE NOTES ----
+
+audit-forward follows the chaincode's AuditLogged events and ships every new entry to a
+syslog collector as an RFC 5424 message (forward package).
+
+	audit-forward -network udp -addr siem:514
+	audit-forward -network tls -addr siem:6514 -tls-ca ca.pem -format leef
+	audit-forward -network tls -addr siem:6514 -tls-ca ca.pem -tls-cert me.pem -tls-key me.key
+
+-state-dir holds the checkpoint (last queued block / TxID) and the disk queue, keep it on a
+persistent volume: on restart forwarding resumes where it stopped, and messages queued while
+the collector was down are sent first. -start-block only applies to the first run.
+
+Connection settings come from the same environment as the REST backend (see gateway/config.go).
+*/
+
+func main() {
+	network := flag.String("network", envOrDefault("SYSLOG_NETWORK", forward.NetworkTCP), "udp, tcp or tls")
+	addr := flag.String("addr", os.Getenv("SYSLOG_ADDR"), "collector host:port")
+	format := flag.String("format", siem.FormatCEF, "message body: cef, leef or ocsf")
+	facility := flag.Int("facility", forward.FacilityLocal0, "syslog facility (0-23)")
+	appName := flag.String("app-name", "audit-trail", "RFC 5424 APP-NAME")
+	stateDir := flag.String("state-dir", envOrDefault("FORWARD_STATE_DIR", "forward-state"), "checkpoint and queue directory")
+	startBlock := flag.Uint64("start-block", 0, "first block to read when there is no checkpoint")
+	maxQueue := flag.Int64("max-queue-bytes", 1<<30, "stop reading events while the queue holds this much (0 = unbounded)")
+	tlsCA := flag.String("tls-ca", "", "PEM CA bundle of the collector (tls, default system roots)")
+	tlsCert := flag.String("tls-cert", "", "PEM client certificate (tls, optional)")
+	tlsKey := flag.String("tls-key", "", "PEM client key (tls, optional)")
+	tlsServerName := flag.String("tls-server-name", "", "TLS server name override")
+	flag.Parse()
+
+	var tlsConfig *tls.Config
+	if *network == forward.NetworkTLS {
+		var err error
+		tlsConfig, err = loadTLSConfig(*tlsCA, *tlsCert, *tlsKey, *tlsServerName)
+		if err != nil {
+			log.Fatalf("[audit-forward] %v", err)
+		}
+	}
+	sender, err := forward.NewSender(*network, *addr, tlsConfig, 10*time.Second)
+	if err != nil {
+		log.Fatalf("[audit-forward] %v", err)
+	}
+
+	queue, err := forward.OpenQueue(filepath.Join(*stateDir, "queue"))
+	if err != nil {
+		log.Fatalf("[audit-forward] %v", err)
+	}
+	defer queue.Close()
+	queue.MaxBytes = *maxQueue
+
+	client, err := gateway.Connect(gateway.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("[audit-forward] failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	hostname, _ := os.Hostname()
+	forwarder := &forward.Forwarder{
+		Source: client,
+		Sender: sender,
+		Queue:  queue,
+		Formatter: &forward.Formatter{
+			Facility: *facility,
+			Hostname: hostname,
+			AppName:  *appName,
+			ProcID:   fmt.Sprint(os.Getpid()),
+			Body:     *format,
+			Product:  siem.DefaultProduct,
+		},
+		CheckpointPath: filepath.Join(*stateDir, "checkpoint.json"),
+		StartBlock:     *startBlock,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[audit-forward] forwarding to %s://%s as %s (%d queued)", *network, *addr, *format, queue.Len())
+	err = forwarder.Run(ctx)
+	if err != nil {
+		log.Fatalf("[audit-forward] %v", err)
+	}
+	log.Printf("[audit-forward] stopped (%d queued)", queue.Len())
+}
+
+// loadTLSConfig builds the client TLS configuration, caPath "" = system roots
+func loadTLSConfig(caPath string, certPath string, keyPath string, serverName string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if caPath != "" {
+		caPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA %s: %v", caPath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("TLS CA %s holds no PEM certificates", caPath)
+		}
+		config.RootCAs = pool
+	}
+	if certPath != "" || keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
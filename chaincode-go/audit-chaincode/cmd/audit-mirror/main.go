@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/mirror"
+)
+
+/*
+---- MODULE NOTES ----
+
+audit-mirror keeps a SQLite copy of audit entries and users (mirror package) for the dashboard
+and SQL analytics, and regularly reconciles it against the ledger.
+
+	audit-mirror                              - follow events, reconcile every -reconcile-interval
+	audit-mirror -reconcile -repair           - one reconciliation (with backfill) and exit
+	audit-mirror -reconcile                   - one reconciliation, JSON report, exit 1 on drift
+
+The first run on an existing network should be "-reconcile -repair": entries logged before the
+chaincode emitted events are backfilled from the ledger. Connection settings come from the same
+environment as the REST backend (see gateway/config.go). Built with cgo (mattn/go-sqlite3).
+*/
+
+func main() {
+	dbPath := flag.String("db", envOrDefault("MIRROR_DB", "audit-mirror.db"), "SQLite database file")
+	startBlock := flag.Uint64("start-block", 0, "first block to read when the mirror is empty")
+	interval := flag.Duration("reconcile-interval", time.Hour, "time between reconciliations while following events (0 = never)")
+	once := flag.Bool("reconcile", false, "reconcile once, print the report and exit")
+	repair := flag.Bool("repair", false, "overwrite drifted rows with the ledger version")
+	flag.Parse()
+
+	store, err := mirror.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("[audit-mirror] %v", err)
+	}
+	defer store.Close()
+
+	client, err := gateway.Connect(gateway.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("[audit-mirror] failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	options := mirror.ReconcileOptions{Repair: *repair}
+	if *once {
+		report, err := store.Reconcile(ctx, client, options)
+		if err != nil {
+			log.Fatalf("[audit-mirror] %v", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		if !report.OK() && !*repair {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *interval > 0 {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(*interval):
+				}
+				report, err := store.Reconcile(ctx, client, options)
+				if err != nil {
+					log.Printf("[audit-mirror] ERROR reconcile: %v", err)
+					continue
+				}
+				for _, finding := range report.Findings {
+					log.Printf("[audit-mirror] DRIFT %s %s %s %s repaired=%v",
+						finding.Table, finding.ID, finding.Kind, finding.Detail, finding.Repaired)
+				}
+			}
+		}()
+	}
+
+	indexer := &mirror.Indexer{Source: client, Store: store, StartBlock: *startBlock}
+	indexer.Run(ctx)
+	log.Printf("[audit-mirror] stopped")
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
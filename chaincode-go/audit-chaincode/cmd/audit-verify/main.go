@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/verify"
+)
+
+/*
+---- MODULE NOTES ----
+
+audit-verify checks an exported audit trail without any Fabric access (verify package).
+
+	audit-verify -entries audits.jsonl -checkpoints checkpoints.json
+	audit-verify -entries audits.json -checkpoints checkpoints.json -anchors anchors.json -tsa-roots tsa.pem
+	audit-verify ... -json                 - machine readable report
+
+Entries: JSON array (GetAllAudits output) or JSON Lines, one AuditEntry per line.
+Checkpoints / anchors: GetCheckpoint / GetAnchor outputs, as an array, JSON Lines or one object.
+
+Exit codes:
+	0 - every check passed (UNSEALED warnings allowed)
+	1 - missing, duplicated, reordered, modified or unexpected entries, or broken checkpoints
+	2 - bad usage or unreadable input
+*/
+
+func main() {
+	entriesPath := flag.String("entries", "", "exported audit entries (JSON array or JSON Lines)")
+	checkpointsPath := flag.String("checkpoints", "", "checkpoints covering the export")
+	anchorsPath := flag.String("anchors", "", "RFC 3161 anchors of the checkpoints (optional)")
+	tsaRootsPath := flag.String("tsa-roots", "", "PEM roots of the trusted TSAs, required with -anchors")
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if *entriesPath == "" || *checkpointsPath == "" {
+		usageError("-entries and -checkpoints are required")
+	}
+	if *anchorsPath != "" && *tsaRootsPath == "" {
+		usageError("-tsa-roots is required with -anchors")
+	}
+
+	file := openInput(*entriesPath)
+	entries, err := verify.ReadEntries(file)
+	file.Close()
+	if err != nil {
+		usageError(fmt.Sprintf("%s: %v", *entriesPath, err))
+	}
+	file = openInput(*checkpointsPath)
+	checkpoints, err := verify.ReadCheckpoints(file)
+	file.Close()
+	if err != nil {
+		usageError(fmt.Sprintf("%s: %v", *checkpointsPath, err))
+	}
+
+	var opts verify.Options
+	if *anchorsPath != "" {
+		file = openInput(*anchorsPath)
+		opts.Anchors, err = verify.ReadAnchors(file)
+		file.Close()
+		if err != nil {
+			usageError(fmt.Sprintf("%s: %v", *anchorsPath, err))
+		}
+		opts.TSARoots, err = os.ReadFile(*tsaRootsPath)
+		if err != nil {
+			usageError(err.Error())
+		}
+	}
+
+	report := verify.Verify(entries, checkpoints, opts)
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		usageError(err.Error())
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func openInput(path string) *os.File {
+	file, err := os.Open(path)
+	if err != nil {
+		usageError(err.Error())
+	}
+	return file
+}
+
+func usageError(message string) {
+	fmt.Fprintf(os.Stderr, "audit-verify: %s\n", message)
+	os.Exit(2)
+}
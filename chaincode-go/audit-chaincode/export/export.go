@@ -0,0 +1,233 @@
+// Package export streams audit entries off the ledger into JSON Lines, CSV or Parquet files,
+// page by page through AuditContract:QueryAuditsPage, and describes each output in a manifest.
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/siem"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Export(ctx, ledger, filter, format, w):
+	1. QueryAuditsPage(filter, pageSize, bookmark) until a page comes back short
+	2. every entry goes straight to the format's RecordWriter, nothing is held in memory
+	   beyond one page, except Parquet which buffers one row group (rowGroupSize rows, parquet.go)
+	3. the Manifest records counts, time bounds and the SHA-256 of the bytes written
+
+Entries come out timestamp ascending then ID, so a JSONL export plus its checkpoints can be
+checked with cmd/audit-verify.
+
+Formats:
+	jsonl   - one AuditEntry JSON object per line, same field names as the chaincode
+	csv     - RFC 4180 (CRLF, quoted when needed), header row = Columns
+	parquet - one flat row per entry, Columns as required UTF8 / INT64 columns (parquet.go)
+	cef, leef, ocsf - one SIEM record per line, same mapping as live forwarding (siem package)
+*/
+
+// Output formats
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatCEF     = siem.FormatCEF
+	FormatLEEF    = siem.FormatLEEF
+	FormatOCSF    = siem.FormatOCSF
+)
+
+// DefaultPageSize is the QueryAuditsPage page size used when none is given
+const DefaultPageSize = 500
+
+// Columns are the flat columns of CSV and Parquet exports, in order
+var Columns = []string{
+	"id", "timestamp", "userId", "userRole", "action", "resourceType", "resourceId",
+	"oldValue", "newValue", "status", "ipAddress", "sessionId", "metadata", "complianceTag",
+	"txId", "correlationId", "parentId", "causedBy", "changes",
+}
+
+// Ledger is the part of gateway.Client the exporter uses
+type Ledger interface {
+	Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error)
+}
+
+// Filter selects the entries to export, zero values match everything
+type Filter struct {
+	StartTs       int64  `json:"startTs"`                 // Unix milliseconds, inclusive
+	EndTs         int64  `json:"endTs"`                   // Unix milliseconds, inclusive, 0 = no upper bound
+	UserID        string `json:"userId,omitempty"`        // Only this user's entries
+	ComplianceTag string `json:"complianceTag,omitempty"` // Only entries with this tag
+}
+
+// Manifest describes one export file
+type Manifest struct {
+	File           string   `json:"file"`           // Output file name
+	Format         string   `json:"format"`         // jsonl, csv, parquet
+	Columns        []string `json:"columns"`        // Flat columns (csv, parquet)
+	Filter         Filter   `json:"filter"`         // What was asked for
+	Entries        int      `json:"entries"`        // Entries written
+	Pages          int      `json:"pages"`          // QueryAuditsPage calls
+	FirstTimestamp int64    `json:"firstTimestamp"` // Oldest entry written (0 if none)
+	LastTimestamp  int64    `json:"lastTimestamp"`  // Newest entry written (0 if none)
+	Bytes          int64    `json:"bytes"`          // Size of the output
+	SHA256         string   `json:"sha256"`         // Digest of the output (hex)
+	ExportedAt     string   `json:"exportedAt"`     // RFC 3339
+}
+
+// RecordWriter writes audit entries in one format
+type RecordWriter interface {
+	Write(entry *chaincode.AuditEntry) error
+	Close() error // Flushes, does not close the underlying writer
+}
+
+// NewRecordWriter returns the RecordWriter of a format
+func NewRecordWriter(format string, w io.Writer) (RecordWriter, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatParquet:
+		return newParquetWriter(w)
+	case FormatCEF, FormatLEEF, FormatOCSF:
+		return newSIEMWriter(format, w), nil
+	}
+	return nil, fmt.Errorf("invalid format: %s. Valid formats: %s, %s, %s, %s, %s, %s",
+		format, FormatJSONL, FormatCSV, FormatParquet, FormatCEF, FormatLEEF, FormatOCSF)
+}
+
+// Each pages through the entries matching filter (timestamp ascending, then ID) and calls fn for
+// each one, stopping at the first error. It returns the number of QueryAuditsPage calls made.
+func Each(ctx context.Context, ledger Ledger, filter Filter, pageSize int, fn func(entry *chaincode.AuditEntry) error) (int, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	pages := 0
+	bookmark := ""
+	for {
+		page, err := fetchPage(ctx, ledger, filter, pageSize, bookmark)
+		if err != nil {
+			return pages, err
+		}
+		pages++
+
+		for _, entry := range page.Records {
+			err = fn(entry)
+			if err != nil {
+				return pages, err
+			}
+		}
+
+		if len(page.Records) < pageSize || page.Bookmark == "" || page.Bookmark == bookmark {
+			return pages, nil
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+// Export pages through the ledger and writes every matching entry to w
+func Export(ctx context.Context, ledger Ledger, filter Filter, format string, pageSize int, w io.Writer) (*Manifest, error) {
+	digest := &digestWriter{w: w, hash: sha256.New()}
+	records, err := NewRecordWriter(format, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Format: format, Filter: filter, ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+	if format == FormatCSV || format == FormatParquet {
+		manifest.Columns = Columns
+	}
+
+	pages, err := Each(ctx, ledger, filter, pageSize, func(entry *chaincode.AuditEntry) error {
+		err := records.Write(entry)
+		if err != nil {
+			return fmt.Errorf("failed to write audit entry %s: %v", entry.ID, err)
+		}
+		if manifest.Entries == 0 {
+			manifest.FirstTimestamp = entry.TimeStamp
+		}
+		manifest.LastTimestamp = entry.TimeStamp
+		manifest.Entries++
+		return nil
+	})
+	manifest.Pages = pages
+	if err != nil {
+		return nil, err
+	}
+
+	err = records.Close()
+	if err != nil {
+		return nil, err
+	}
+	manifest.Bytes = digest.n
+	manifest.SHA256 = hex.EncodeToString(digest.hash.Sum(nil))
+	return manifest, nil
+}
+
+// WriteManifest writes a manifest as indented JSON
+func WriteManifest(path string, manifest *Manifest) error {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	return os.WriteFile(path, append(manifestJSON, '\n'), 0o644)
+}
+
+// fetchPage runs one QueryAuditsPage
+func fetchPage(ctx context.Context, ledger Ledger, filter Filter, pageSize int, bookmark string) (*chaincode.AuditQueryResult, error) {
+	result, err := ledger.Evaluate(ctx, "QueryAuditsPage",
+		strconv.FormatInt(filter.StartTs, 10), strconv.FormatInt(filter.EndTs, 10),
+		filter.UserID, filter.ComplianceTag, strconv.Itoa(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit page: %v", err)
+	}
+	var page chaincode.AuditQueryResult
+	err = json.Unmarshal(result, &page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit page: %v", err)
+	}
+	return &page, nil
+}
+
+// row flattens an entry into Columns order, timestamp stays an int64
+func row(entry *chaincode.AuditEntry) ([]string, error) {
+	changes := ""
+	if len(entry.Changes) > 0 {
+		changesJSON, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal changes of %s: %v", entry.ID, err)
+		}
+		changes = string(changesJSON)
+	}
+	return []string{
+		entry.ID, strconv.FormatInt(entry.TimeStamp, 10), entry.UserID, entry.UserRole, entry.Action,
+		entry.ResourceType, entry.ResourceID, entry.OldValue, entry.NewValue, entry.Status,
+		entry.IPAddress, entry.SessionID, entry.Metadata, entry.ComplianceTag,
+		entry.TxID, entry.CorrelationID, entry.ParentID, entry.CausedBy, changes,
+	}, nil
+}
+
+// digestWriter hashes and counts everything written through it
+type digestWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	n    int64
+}
+
+func (d *digestWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.hash.Write(p[:n])
+	d.n += int64(n)
+	return n, err
+}
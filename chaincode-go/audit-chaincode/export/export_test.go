@@ -0,0 +1,322 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/export"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/verify"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// fakeLedger serves QueryAuditsPage from a slice, bookmark = offset of the next page
+type fakeLedger struct {
+	entries []*chaincode.AuditEntry
+	calls   []string
+}
+
+func (l *fakeLedger) Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	if fn != "QueryAuditsPage" {
+		return nil, fmt.Errorf("unexpected evaluate %s", fn)
+	}
+	l.calls = append(l.calls, strings.Join(args, "|"))
+	startTs, _ := strconv.ParseInt(args[0], 10, 64)
+	endTs, _ := strconv.ParseInt(args[1], 10, 64)
+	pageSize, _ := strconv.Atoi(args[4])
+	offset, _ := strconv.Atoi(args[5])
+
+	var matching []*chaincode.AuditEntry
+	for _, entry := range l.entries {
+		if entry.TimeStamp < startTs || (endTs != 0 && entry.TimeStamp > endTs) {
+			continue
+		}
+		if (args[2] != "" && entry.UserID != args[2]) || (args[3] != "" && entry.ComplianceTag != args[3]) {
+			continue
+		}
+		matching = append(matching, entry)
+	}
+	page := &chaincode.AuditQueryResult{Records: []*chaincode.AuditEntry{}}
+	for i := offset; i < len(matching) && i < offset+pageSize; i++ {
+		page.Records = append(page.Records, matching[i])
+	}
+	page.FetchedRecordsCount = int32(len(page.Records))
+	page.Bookmark = strconv.Itoa(offset + len(page.Records))
+	return json.Marshal(page)
+}
+
+func newLedger(count int) *fakeLedger {
+	ledger := &fakeLedger{}
+	for i := 1; i <= count; i++ {
+		tag := "SOC2"
+		if i%2 == 0 {
+			tag = "HIPAA"
+		}
+		ledger.entries = append(ledger.entries, &chaincode.AuditEntry{
+			ID:            fmt.Sprintf("audit-%03d", i),
+			TimeStamp:     int64(1000 * i),
+			UserID:        "user-alice",
+			Action:        "UPDATE",
+			NewValue:      `{"status":"ACTIVE","note":"line1, \"quoted\""}`,
+			Status:        "SUCCESS",
+			ComplianceTag: tag,
+			TxID:          fmt.Sprintf("tx-%03d", i),
+		})
+	}
+	return ledger
+}
+
+func TestExportJSONLPagesAndManifest(t *testing.T) {
+	ledger := newLedger(7)
+	var out bytes.Buffer
+	manifest, err := export.Export(context.Background(), ledger, export.Filter{}, export.FormatJSONL, 3, &out)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if manifest.Entries != 7 || manifest.Pages != 3 {
+		t.Errorf("manifest entries/pages = %d/%d, want 7/3", manifest.Entries, manifest.Pages)
+	}
+	if manifest.FirstTimestamp != 1000 || manifest.LastTimestamp != 7000 {
+		t.Errorf("manifest bounds = %d..%d, want 1000..7000", manifest.FirstTimestamp, manifest.LastTimestamp)
+	}
+	sum := sha256.Sum256(out.Bytes())
+	if manifest.SHA256 != hex.EncodeToString(sum[:]) || manifest.Bytes != int64(out.Len()) {
+		t.Errorf("manifest digest does not describe the output")
+	}
+
+	entries, err := verify.ReadEntries(&out)
+	if err != nil {
+		t.Fatalf("ReadEntries() error = %v", err)
+	}
+	if len(entries) != 7 || entries[6].NewValue != ledger.entries[6].NewValue {
+		t.Errorf("JSONL round trip lost entries")
+	}
+}
+
+func TestExportFilter(t *testing.T) {
+	ledger := newLedger(10)
+	var out bytes.Buffer
+	filter := export.Filter{StartTs: 2000, EndTs: 8000, ComplianceTag: "HIPAA"}
+	manifest, err := export.Export(context.Background(), ledger, filter, export.FormatJSONL, 100, &out)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if manifest.Entries != 4 {
+		t.Errorf("entries = %d, want 4 (audit-002, 004, 006, 008)", manifest.Entries)
+	}
+	if ledger.calls[0] != "2000|8000||HIPAA|100|" {
+		t.Errorf("QueryAuditsPage args = %q", ledger.calls[0])
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	ledger := newLedger(2)
+	var out bytes.Buffer
+	_, err := export.Export(context.Background(), ledger, export.Filter{}, export.FormatCSV, 10, &out)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "\r\n") {
+		t.Error("CSV records are not CRLF terminated (RFC 4180)")
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("csv.ReadAll() error = %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(export.Columns, ",") {
+		t.Fatalf("CSV = %d records, header %v", len(records), records[0])
+	}
+	if records[2][0] != "audit-002" || records[2][1] != "2000" || records[2][8] != ledger.entries[1].NewValue {
+		t.Errorf("CSV row = %v", records[2])
+	}
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	_, err := export.Export(context.Background(), newLedger(1), export.Filter{}, "xml", 10, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("Export() accepted format xml")
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	ledger := newLedger(5)
+	var out bytes.Buffer
+	_, err := export.Export(context.Background(), ledger, export.Filter{}, export.FormatParquet, 2, &out)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	data := out.Bytes()
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatal("missing PAR1 magic")
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerLen : len(data)-8]}
+	meta := footer.readStruct()
+
+	if meta[3].(int64) != 5 {
+		t.Errorf("num_rows = %v, want 5", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(export.Columns)+1 || schema[1].(map[int16]interface{})[4] != "id" {
+		t.Fatalf("schema = %v", schema)
+	}
+
+	// Read the id and timestamp columns back through the footer offsets
+	rowGroups := meta[4].([]interface{})
+	columns := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	ids := readColumn(t, data, columns[0].(map[int16]interface{}), false)
+	timestamps := readColumn(t, data, columns[1].(map[int16]interface{}), true)
+	want := []string{"audit-001", "audit-002", "audit-003", "audit-004", "audit-005"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("id column = %v, want %v", ids, want)
+	}
+	if timestamps[4] != "5000" {
+		t.Errorf("timestamp column = %v", timestamps)
+	}
+}
+
+func TestExportParquetReadBack(t *testing.T) {
+	// More rows than one row group (rowGroupSize = 10000), read with an independent Parquet reader
+	ledger := newLedger(10005)
+	var parquetOut, csvOut bytes.Buffer
+	if _, err := export.Export(context.Background(), ledger, export.Filter{}, export.FormatParquet, 500, &parquetOut); err != nil {
+		t.Fatalf("Export(parquet) error = %v", err)
+	}
+	if _, err := export.Export(context.Background(), ledger, export.Filter{}, export.FormatCSV, 500, &csvOut); err != nil {
+		t.Fatalf("Export(csv) error = %v", err)
+	}
+	records, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(parquetOut.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parquetReader, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatalf("NewParquetColumnReader() error = %v", err)
+	}
+	rows := parquetReader.GetNumRows()
+	if rows != 10005 || len(parquetReader.Footer.RowGroups) != 2 {
+		t.Fatalf("rows = %d, row groups = %d", rows, len(parquetReader.Footer.RowGroups))
+	}
+
+	// Every column holds the same values as the CSV export
+	for column, name := range export.Columns {
+		values, _, _, err := parquetReader.ReadColumnByIndex(int64(column), rows)
+		if err != nil {
+			t.Fatalf("column %s: %v", name, err)
+		}
+		if len(values) != int(rows) {
+			t.Fatalf("column %s has %d values", name, len(values))
+		}
+		for i, value := range values {
+			if got, want := fmt.Sprint(value), records[i+1][column]; got != want {
+				t.Fatalf("row %d column %s = %q, want %q", i, name, got, want)
+			}
+		}
+	}
+}
+
+// readColumn decodes the PLAIN values of one column chunk
+func readColumn(t *testing.T, data []byte, chunk map[int16]interface{}, int64Values bool) []string {
+	t.Helper()
+	meta := chunk[3].(map[int16]interface{})
+	offset := meta[9].(int64)
+	page := &thriftReader{data: data[offset:]}
+	header := page.readStruct()
+	numValues := header[5].(map[int16]interface{})[1].(int32)
+	values := page.data[page.pos : page.pos+int(header[3].(int32))]
+
+	var decoded []string
+	for i := 0; i < int(numValues); i++ {
+		if int64Values {
+			decoded = append(decoded, strconv.FormatInt(int64(binary.LittleEndian.Uint64(values)), 10))
+			values = values[8:]
+			continue
+		}
+		n := binary.LittleEndian.Uint32(values)
+		decoded = append(decoded, string(values[4:4+n]))
+		values = values[4+n:]
+	}
+	return decoded
+}
+
+// thriftReader decodes Thrift compact protocol into maps (structs) and slices (lists)
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readValue(fieldType byte) interface{} {
+	switch fieldType {
+	case 5:
+		return int32(r.zigzag())
+	case 6:
+		return r.zigzag()
+	case 8:
+		n := int(r.uvarint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case 9:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.readValue(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", fieldType))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var lastID int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		id := lastID + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.readValue(header & 0x0f)
+		lastID = id
+	}
+}
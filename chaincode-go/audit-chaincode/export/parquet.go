@@ -0,0 +1,340 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ A deliberately small Parquet writer: flat schema, REQUIRED columns only, PLAIN encoding,
+ no compression, one data page per column chunk. It covers exactly what audit exports need
+ without pulling Thrift and the compression codecs into the module.
+
+File layout (https://parquet.apache.org/docs/file-format/):
+	"PAR1"
+	row group 1: column chunk per column = PageHeader + PLAIN values
+	...
+	FileMetaData (Thrift compact protocol)
+	len(FileMetaData) as 4 byte little endian
+	"PAR1"
+
+Columns: timestamp is INT64 (TIMESTAMP_MILLIS), every other column BYTE_ARRAY (UTF8).
+Rows are buffered until rowGroupSize, so memory stays bounded by one row group on large exports,
+not by the query page size. The tests read the files back with xitongsys/parquet-go.
+*/
+
+// rowGroupSize is the number of rows buffered per row group
+const rowGroupSize = 10000
+
+// Parquet enum values used by the writer (parquet.thrift)
+const (
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetPageData          = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumnChunk is what the footer needs to know about one written column chunk
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+}
+
+// parquetRowGroup is what the footer needs to know about one written row group
+type parquetRowGroup struct {
+	rows    int64
+	columns []parquetColumnChunk
+}
+
+// parquetWriter writes the flat Columns schema
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	rows      [][]string // Buffered rows of the current row group
+	rowGroups []parquetRowGroup
+	totalRows int64
+}
+
+func newParquetWriter(w io.Writer) (*parquetWriter, error) {
+	p := &parquetWriter{w: w}
+	err := p.write(parquetMagic)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parquetWriter) Write(entry *chaincode.AuditEntry) error {
+	record, err := row(entry)
+	if err != nil {
+		return err
+	}
+	p.rows = append(p.rows, record)
+	if len(p.rows) >= rowGroupSize {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	err := p.flushRowGroup()
+	if err != nil {
+		return err
+	}
+	footer := p.fileMetaData()
+	err = p.write(footer)
+	if err != nil {
+		return err
+	}
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	err = p.write(length)
+	if err != nil {
+		return err
+	}
+	return p.write(parquetMagic)
+}
+
+// flushRowGroup writes the buffered rows as one row group
+func (p *parquetWriter) flushRowGroup() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: int64(len(p.rows))}
+	for column, name := range Columns {
+		var values bytes.Buffer
+		for _, record := range p.rows {
+			if name == "timestamp" {
+				timestamp, err := strconv.ParseInt(record[column], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid timestamp %q: %v", record[column], err)
+				}
+				_ = binary.Write(&values, binary.LittleEndian, timestamp)
+				continue
+			}
+			_ = binary.Write(&values, binary.LittleEndian, uint32(len(record[column])))
+			values.WriteString(record[column])
+		}
+
+		header := pageHeader(len(p.rows), values.Len())
+		chunk := parquetColumnChunk{offset: p.offset, size: int64(len(header) + values.Len())}
+		err := p.write(header)
+		if err != nil {
+			return err
+		}
+		err = p.write(values.Bytes())
+		if err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+	}
+
+	p.rowGroups = append(p.rowGroups, group)
+	p.totalRows += group.rows
+	p.rows = p.rows[:0]
+	return nil
+}
+
+func (p *parquetWriter) write(data []byte) error {
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write parquet output: %v", err)
+	}
+	return nil
+}
+
+// pageHeader encodes a v1 data page header for PLAIN values of a REQUIRED column
+func pageHeader(numValues int, size int) []byte {
+	t := &thriftCompact{}
+	t.i32(1, parquetPageData)
+	t.i32(2, int32(size)) // uncompressed_page_size
+	t.i32(3, int32(size)) // compressed_page_size
+	t.structBegin(5)      // data_page_header
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetEncodingPlain)
+	t.i32(3, parquetEncodingRLE) // definition levels (none, REQUIRED)
+	t.i32(4, parquetEncodingRLE) // repetition levels (none, flat)
+	t.structEnd()
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// fileMetaData encodes the footer
+func (p *parquetWriter) fileMetaData() []byte {
+	t := &thriftCompact{}
+	t.i32(1, 1) // version
+
+	// schema: root group, then one element per column
+	t.listBegin(2, thriftStruct, len(Columns)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(Columns)))
+	t.elemEnd()
+	for _, name := range Columns {
+		physical, converted := parquetColumnType(name)
+		t.elemBegin()
+		t.i32(1, physical)
+		t.i32(3, parquetRequired)
+		t.binary(4, name)
+		t.i32(6, converted)
+		t.elemEnd()
+	}
+
+	t.i64(3, p.totalRows)
+
+	t.listBegin(4, thriftStruct, len(p.rowGroups))
+	for _, group := range p.rowGroups {
+		var groupSize int64
+		t.elemBegin()
+		t.listBegin(1, thriftStruct, len(group.columns))
+		for column, chunk := range group.columns {
+			physical, _ := parquetColumnType(Columns[column])
+			groupSize += chunk.size
+			t.elemBegin()
+			t.i64(2, chunk.offset) // file_offset
+			t.structBegin(3)       // meta_data
+			t.i32(1, physical)
+			t.listBegin(2, thriftI32, 1)
+			t.listI32(parquetEncodingPlain)
+			t.listBegin(3, thriftBinary, 1)
+			t.listBinary(Columns[column])
+			t.i32(4, parquetCodecUncompressed)
+			t.i64(5, group.rows)
+			t.i64(6, chunk.size) // total_uncompressed_size
+			t.i64(7, chunk.size) // total_compressed_size
+			t.i64(9, chunk.offset)
+			t.structEnd()
+			t.elemEnd()
+		}
+		t.i64(2, groupSize)
+		t.i64(3, group.rows)
+		t.elemEnd()
+	}
+
+	t.binary(6, "audit-trail export")
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// parquetColumnType returns the physical and converted type of a column
+func parquetColumnType(name string) (int32, int32) {
+	if name == "timestamp" {
+		return parquetTypeInt64, parquetConvertedTimestampMillis
+	}
+	return parquetTypeByteArray, parquetConvertedUTF8
+}
+
+// Thrift compact protocol type IDs
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact encodes Thrift compact protocol structs, just enough for Parquet metadata
+type thriftCompact struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftCompact) fieldHeader(id int16, fieldType byte) {
+	delta := id - t.lastID
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	t.lastID = id
+}
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(uint64(zigzag(int64(v))))
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(uint64(zigzag(v)))
+}
+
+func (t *thriftCompact) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftCompact) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftCompact) structEnd() {
+	t.elemEnd()
+}
+
+func (t *thriftCompact) listBegin(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.varint(uint64(size))
+}
+
+// elemBegin / elemEnd wrap a struct inside a list (or a nested struct field)
+func (t *thriftCompact) elemBegin() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftCompact) elemEnd() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftCompact) listI32(v int32) {
+	t.varint(uint64(zigzag(int64(v))))
+}
+
+func (t *thriftCompact) listBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftCompact) stop() {
+	t.buf.WriteByte(0)
+}
+
+func (t *thriftCompact) varint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	t.buf.Write(scratch[:n])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
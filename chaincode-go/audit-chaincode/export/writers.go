@@ -0,0 +1,84 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/siem"
+)
+
+// jsonlWriter writes one JSON object per line
+type jsonlWriter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	return &jsonlWriter{buf: buf, encoder: encoder}
+}
+
+func (j *jsonlWriter) Write(entry *chaincode.AuditEntry) error {
+	return j.encoder.Encode(entry)
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.buf.Flush()
+}
+
+// csvWriter writes RFC 4180 CSV with a header row
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+	err := writer.Write(Columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %v", err)
+	}
+	return &csvWriter{writer: writer}, nil
+}
+
+func (c *csvWriter) Write(entry *chaincode.AuditEntry) error {
+	record, err := row(entry)
+	if err != nil {
+		return err
+	}
+	return c.writer.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// siemWriter writes one CEF, LEEF or OCSF record per line (siem package)
+type siemWriter struct {
+	buf    *bufio.Writer
+	format string
+}
+
+func newSIEMWriter(format string, w io.Writer) *siemWriter {
+	return &siemWriter{buf: bufio.NewWriter(w), format: format}
+}
+
+func (s *siemWriter) Write(entry *chaincode.AuditEntry) error {
+	line, err := siem.Format(s.format, entry, siem.DefaultProduct)
+	if err != nil {
+		return err
+	}
+	_, err = s.buf.Write(append(line, '\n'))
+	return err
+}
+
+func (s *siemWriter) Close() error {
+	return s.buf.Flush()
+}
@@ -0,0 +1,39 @@
+package forward
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Checkpoint is the last event handed to the queue, where event streaming resumes after a restart
+type Checkpoint struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxID        string `json:"txId"`
+}
+
+// LoadCheckpoint reads the checkpoint at path, nil when there is none yet
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %v", path, err)
+	}
+	var checkpoint Checkpoint
+	err = json.Unmarshal(data, &checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint replaces the checkpoint at path atomically
+func SaveCheckpoint(path string, checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
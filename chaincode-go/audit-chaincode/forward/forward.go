@@ -0,0 +1,208 @@
+// Package forward ships live audit entries to a syslog collector. It follows the chaincode's
+// AuditLogged events through the Fabric Gateway, renders each entry as an RFC 5424 message
+// (body from the siem package) and sends it over UDP, TCP or TLS.
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Two loops share the disk queue:
+
+	events: ChaincodeEvents(checkpoint) -> Format -> Queue.Push -> SaveCheckpoint
+	send:   Queue.Peek -> Sender.Send -> Queue.Pop
+
+ The checkpoint only moves once a message is safely queued, and a message only leaves the
+ queue once the collector took it, so the forwarder can be killed at any point without losing
+ an entry. Both loops retry with exponential backoff (MinBackoff doubling up to MaxBackoff):
+ the event loop when the peer stream fails or the queue is full, the send loop when the
+ collector is down.
+
+ Events other than AuditLogged, and payloads that are not an AuditEntry, are logged and skipped.
+*/
+
+// Source is the part of gateway.Client the forwarder uses
+type Source interface {
+	ChaincodeEvents(ctx context.Context, startBlock uint64, afterTxID string, handle func(gateway.ChaincodeEvent) error) error
+}
+
+// Forwarder moves audit events from a Source to a syslog Sender through a disk Queue
+type Forwarder struct {
+	Source         Source
+	Sender         Sender
+	Queue          *Queue
+	Formatter      *Formatter
+	CheckpointPath string
+	StartBlock     uint64        // first block to read when there is no checkpoint yet
+	MinBackoff     time.Duration // default 1s
+	MaxBackoff     time.Duration // default 1m
+
+	queued chan struct{}
+}
+
+// Run forwards events until ctx is cancelled, then returns nil once both loops have stopped
+func (f *Forwarder) Run(ctx context.Context) error {
+	if f.Source == nil || f.Sender == nil || f.Queue == nil || f.Formatter == nil || f.CheckpointPath == "" {
+		return fmt.Errorf("forwarder needs a Source, Sender, Queue, Formatter and CheckpointPath")
+	}
+	f.queued = make(chan struct{}, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f.sendLoop(ctx)
+	}()
+	f.eventLoop(ctx)
+	wg.Wait()
+	return nil
+}
+
+// eventLoop (re)opens the event stream from the checkpoint until ctx is cancelled
+func (f *Forwarder) eventLoop(ctx context.Context) {
+	retry := f.newBackoff()
+	for ctx.Err() == nil {
+		startBlock, afterTxID := f.StartBlock, ""
+		checkpoint, err := LoadCheckpoint(f.CheckpointPath)
+		if err != nil {
+			log.Printf("[Forwarder] ERROR %v", err)
+			retry.wait(ctx)
+			continue
+		}
+		if checkpoint != nil {
+			startBlock, afterTxID = checkpoint.BlockNumber, checkpoint.TxID
+		}
+
+		log.Printf("[Forwarder] listening from block=%d after txId=%s", startBlock, afterTxID)
+		err = f.Source.ChaincodeEvents(ctx, startBlock, afterTxID, func(event gateway.ChaincodeEvent) error {
+			err := f.handle(event)
+			if err == nil {
+				retry.reset()
+			}
+			return err
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[Forwarder] ERROR event stream: %v", err)
+		retry.wait(ctx)
+	}
+}
+
+// handle queues one event and moves the checkpoint past it
+func (f *Forwarder) handle(event gateway.ChaincodeEvent) error {
+	if event.EventName != chaincode.AuditEventName {
+		log.Printf("[Forwarder] SKIP event=%s txId=%s", event.EventName, event.TxID)
+		return SaveCheckpoint(f.CheckpointPath, Checkpoint{BlockNumber: event.BlockNumber, TxID: event.TxID})
+	}
+
+	var entry chaincode.AuditEntry
+	err := json.Unmarshal(event.Payload, &entry)
+	if err != nil {
+		log.Printf("[Forwarder] SKIP txId=%s invalid AuditEntry payload: %v", event.TxID, err)
+		return SaveCheckpoint(f.CheckpointPath, Checkpoint{BlockNumber: event.BlockNumber, TxID: event.TxID})
+	}
+	msg, err := f.Formatter.Format(&entry)
+	if err != nil {
+		return fmt.Errorf("failed to format audit entry %s: %v", entry.ID, err)
+	}
+
+	err = f.Queue.Push(msg)
+	if err != nil {
+		return fmt.Errorf("failed to queue audit entry %s: %v", entry.ID, err)
+	}
+	err = SaveCheckpoint(f.CheckpointPath, Checkpoint{BlockNumber: event.BlockNumber, TxID: event.TxID})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case f.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// sendLoop drains the queue into the Sender until ctx is cancelled
+func (f *Forwarder) sendLoop(ctx context.Context) {
+	defer f.Sender.Close()
+
+	retry := f.newBackoff()
+	for ctx.Err() == nil {
+		msg, ok, err := f.Queue.Peek()
+		if err != nil {
+			log.Printf("[Forwarder] ERROR %v", err)
+			retry.wait(ctx)
+			continue
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-f.queued:
+			}
+			continue
+		}
+
+		err = f.Sender.Send(msg)
+		if err != nil {
+			log.Printf("[Forwarder] ERROR %v (%d queued)", err, f.Queue.Len())
+			retry.wait(ctx)
+			continue
+		}
+		err = f.Queue.Pop()
+		if err != nil {
+			log.Printf("[Forwarder] ERROR %v", err)
+			retry.wait(ctx)
+			continue
+		}
+		retry.reset()
+	}
+}
+
+func (f *Forwarder) newBackoff() *backoff {
+	b := &backoff{min: f.MinBackoff, max: f.MaxBackoff}
+	if b.min <= 0 {
+		b.min = time.Second
+	}
+	if b.max <= 0 {
+		b.max = time.Minute
+	}
+	if b.max < b.min {
+		b.max = b.min
+	}
+	return b
+}
+
+// backoff waits min, 2*min, 4*min ... up to max between retries
+type backoff struct {
+	min, max time.Duration
+	next     time.Duration
+}
+
+// wait sleeps for the next delay, returning early when ctx is cancelled
+func (b *backoff) wait(ctx context.Context) {
+	if b.next == 0 {
+		b.next = b.min
+	}
+	timer := time.NewTimer(b.next)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	b.next = min(b.next*2, b.max)
+}
+
+func (b *backoff) reset() {
+	b.next = 0
+}
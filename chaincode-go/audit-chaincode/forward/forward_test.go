@@ -0,0 +1,482 @@
+package forward_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/forward"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/siem"
+)
+
+func testEntry(id string, action string, status string) *chaincode.AuditEntry {
+	return &chaincode.AuditEntry{
+		ID:            id,
+		TimeStamp:     1704103200000,
+		UserID:        "user-admin",
+		UserRole:      "ADMIN",
+		Action:        action,
+		ResourceType:  "USER",
+		ResourceID:    "user-alice",
+		Status:        status,
+		IPAddress:     "10.0.0.5",
+		ComplianceTag: "SOC2",
+		TxID:          "tx-" + id,
+	}
+}
+
+func auditEvent(t *testing.T, block uint64, entry *chaincode.AuditEntry) gateway.ChaincodeEvent {
+	t.Helper()
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("failed to marshal entry: %v", err)
+	}
+	return gateway.ChaincodeEvent{BlockNumber: block, TxID: entry.TxID, EventName: chaincode.AuditEventName, Payload: payload}
+}
+
+func testFormatter() *forward.Formatter {
+	return &forward.Formatter{
+		Facility: forward.FacilityLocal0,
+		Hostname: "peer0",
+		AppName:  "audit-trail",
+		Body:     siem.FormatCEF,
+		Product:  siem.DefaultProduct,
+	}
+}
+
+// fakeSource replays events like the gateway: from startBlock, skipping up to afterTxID, then waits
+type fakeSource struct {
+	events []gateway.ChaincodeEvent
+
+	mu     sync.Mutex
+	starts []string
+}
+
+func (s *fakeSource) ChaincodeEvents(ctx context.Context, startBlock uint64, afterTxID string, handle func(gateway.ChaincodeEvent) error) error {
+	s.mu.Lock()
+	s.starts = append(s.starts, fmt.Sprintf("%d/%s", startBlock, afterTxID))
+	s.mu.Unlock()
+
+	skipping := afterTxID != ""
+	for _, event := range s.events {
+		if event.BlockNumber < startBlock {
+			continue
+		}
+		if skipping {
+			skipping = event.TxID != afterTxID
+			continue
+		}
+		err := handle(event)
+		if err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// tcpCollector is a syslog listener reading octet-counted frames
+type tcpCollector struct {
+	listener net.Listener
+	messages chan string
+}
+
+func newTCPCollector(t *testing.T) *tcpCollector {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	c := &tcpCollector{listener: listener, messages: make(chan string, 100)}
+	t.Cleanup(func() { listener.Close() })
+	go c.serve()
+	return c
+}
+
+func (c *tcpCollector) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				length, err := reader.ReadString(' ')
+				if err != nil {
+					return
+				}
+				size, err := strconv.Atoi(strings.TrimSpace(length))
+				if err != nil {
+					return
+				}
+				msg := make([]byte, size)
+				_, err = io.ReadFull(reader, msg)
+				if err != nil {
+					return
+				}
+				c.messages <- string(msg)
+			}
+		}()
+	}
+}
+
+func receive(t *testing.T, messages <-chan string, count int) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < count {
+		select {
+		case msg := <-messages:
+			got = append(got, msg)
+		case <-timeout:
+			t.Fatalf("received %d of %d messages: %q", len(got), count, got)
+		}
+	}
+	return got
+}
+
+func TestFormatRFC5424(t *testing.T) {
+	entry := testEntry("audit-001", "CREATE", "SUCCESS")
+	msg, err := testFormatter().Format(entry)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	want := `<134>1 2024-01-01T10:00:00.000Z peer0 audit-trail - CREATE [auditTrail@32473 id="audit-001" txId="tx-audit-001" userId="user-admin" action="CREATE" resourceType="USER" resourceId="user-alice" status="SUCCESS" complianceTag="SOC2"] ` +
+		siem.CEF(entry, siem.DefaultProduct)
+	if string(msg) != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", msg, want)
+	}
+}
+
+func TestFormatSeverityAndEscaping(t *testing.T) {
+	entry := testEntry("audit-002", "DELETE", "FAILURE")
+	entry.ResourceID = `a"b]c\d`
+	entry.Action = "DELETE"
+	formatter := testFormatter()
+	formatter.Hostname = ""
+	msg, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	// local0 (16) * 8 + warning (4) for a failure
+	if !strings.HasPrefix(string(msg), "<132>1 2024-01-01T10:00:00.000Z - audit-trail - DELETE [") {
+		t.Errorf("unexpected header: %s", msg)
+	}
+	if !strings.Contains(string(msg), `resourceId="a\"b\]c\\d"`) {
+		t.Errorf("resourceId not escaped: %s", msg)
+	}
+
+	formatter.Facility = 24
+	_, err = formatter.Format(entry)
+	if err == nil {
+		t.Error("Format() accepted facility 24")
+	}
+}
+
+func TestQueueSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := forward.OpenQueue(dir)
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	for _, msg := range []string{"one", "two", "three"} {
+		err = queue.Push([]byte(msg))
+		if err != nil {
+			t.Fatalf("Push(%s) error = %v", msg, err)
+		}
+	}
+	err = queue.Pop()
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	queue.Close()
+
+	// A torn record at the tail, as left by a crash during Push
+	file, err := os.OpenFile(filepath.Join(dir, "queue.log"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open queue.log: %v", err)
+	}
+	file.Write([]byte{0, 0, 0, 9, 'x'})
+	file.Close()
+
+	queue, err = forward.OpenQueue(dir)
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	defer queue.Close()
+	if queue.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", queue.Len())
+	}
+	for _, want := range []string{"two", "three"} {
+		msg, ok, err := queue.Peek()
+		if err != nil || !ok || string(msg) != want {
+			t.Fatalf("Peek() = %q, %v, %v, want %q", msg, ok, err, want)
+		}
+		err = queue.Pop()
+		if err != nil {
+			t.Fatalf("Pop() error = %v", err)
+		}
+	}
+	_, ok, _ := queue.Peek()
+	if ok {
+		t.Error("queue not empty after popping every message")
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	queue, err := forward.OpenQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	defer queue.Close()
+	queue.MaxBytes = 10
+	err = queue.Push([]byte("12345678"))
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	err = queue.Push([]byte("x"))
+	if err != forward.ErrQueueFull {
+		t.Errorf("Push() error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestForwardTCP(t *testing.T) {
+	collector := newTCPCollector(t)
+	dir := t.TempDir()
+
+	source := &fakeSource{events: []gateway.ChaincodeEvent{
+		auditEvent(t, 5, testEntry("audit-001", "CREATE", "SUCCESS")),
+		{BlockNumber: 5, TxID: "tx-other", EventName: "SomethingElse"},
+		auditEvent(t, 6, testEntry("audit-002", "UPDATE", "SUCCESS")),
+	}}
+	queue, err := forward.OpenQueue(dir)
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	defer queue.Close()
+	sender, err := forward.NewSender(forward.NetworkTCP, collector.listener.Addr().String(), nil, time.Second)
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	forwarder := &forward.Forwarder{
+		Source:         source,
+		Sender:         sender,
+		Queue:          queue,
+		Formatter:      testFormatter(),
+		CheckpointPath: checkpointPath,
+		MinBackoff:     10 * time.Millisecond,
+	}
+	go func() { done <- forwarder.Run(ctx) }()
+
+	got := receive(t, collector.messages, 2)
+	cancel()
+	err = <-done
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for i, id := range []string{"audit-001", "audit-002"} {
+		if !strings.Contains(got[i], `[auditTrail@32473 id="`+id+`"`) {
+			t.Errorf("message %d = %s, want entry %s", i, got[i], id)
+		}
+	}
+	checkpoint, err := forward.LoadCheckpoint(checkpointPath)
+	if err != nil || checkpoint == nil {
+		t.Fatalf("LoadCheckpoint() = %v, %v", checkpoint, err)
+	}
+	if checkpoint.BlockNumber != 6 || checkpoint.TxID != "tx-audit-002" {
+		t.Errorf("checkpoint = %+v, want block 6 / tx-audit-002", checkpoint)
+	}
+	if queue.Len() != 0 {
+		t.Errorf("queue holds %d messages after delivery", queue.Len())
+	}
+}
+
+func TestForwardUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+	messages := make(chan string, 10)
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+
+	queue, err := forward.OpenQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	defer queue.Close()
+	sender, err := forward.NewSender(forward.NetworkUDP, conn.LocalAddr().String(), nil, time.Second)
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	forwarder := &forward.Forwarder{
+		Source:         &fakeSource{events: []gateway.ChaincodeEvent{auditEvent(t, 1, testEntry("audit-001", "CREATE", "SUCCESS"))}},
+		Sender:         sender,
+		Queue:          queue,
+		Formatter:      testFormatter(),
+		CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+	}
+	go forwarder.Run(ctx)
+
+	got := receive(t, messages, 1)
+	// No octet-counting prefix on UDP, one message per datagram
+	if !strings.HasPrefix(got[0], "<134>1 ") {
+		t.Errorf("datagram = %s", got[0])
+	}
+}
+
+// flakySender fails until up is set, like a collector that is down
+type flakySender struct {
+	mu   sync.Mutex
+	up   bool
+	sent []string
+}
+
+func (s *flakySender) Send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.up {
+		return fmt.Errorf("connection refused")
+	}
+	s.sent = append(s.sent, string(msg))
+	return nil
+}
+
+func (s *flakySender) Close() error { return nil }
+
+func (s *flakySender) setUp() {
+	s.mu.Lock()
+	s.up = true
+	s.mu.Unlock()
+}
+
+func (s *flakySender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent)
+}
+
+func TestForwardQueuesWhileCollectorDown(t *testing.T) {
+	queue, err := forward.OpenQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	defer queue.Close()
+
+	sender := &flakySender{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	forwarder := &forward.Forwarder{
+		Source: &fakeSource{events: []gateway.ChaincodeEvent{
+			auditEvent(t, 1, testEntry("audit-001", "CREATE", "SUCCESS")),
+			auditEvent(t, 2, testEntry("audit-002", "UPDATE", "SUCCESS")),
+			auditEvent(t, 3, testEntry("audit-003", "DELETE", "SUCCESS")),
+		}},
+		Sender:         sender,
+		Queue:          queue,
+		Formatter:      testFormatter(),
+		CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+		MinBackoff:     5 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	}
+	go func() { done <- forwarder.Run(ctx) }()
+
+	waitFor(t, func() bool { return queue.Len() == 3 })
+	sender.setUp()
+	waitFor(t, func() bool { return sender.count() == 3 })
+	cancel()
+	<-done
+
+	for i, id := range []string{"audit-001", "audit-002", "audit-003"} {
+		if !strings.Contains(sender.sent[i], `id="`+id+`"`) {
+			t.Errorf("message %d = %s, want entry %s (in order)", i, sender.sent[i], id)
+		}
+	}
+	if queue.Len() != 0 {
+		t.Errorf("queue holds %d messages after the collector came back", queue.Len())
+	}
+}
+
+func TestForwardResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	err := forward.SaveCheckpoint(checkpointPath, forward.Checkpoint{BlockNumber: 2, TxID: "tx-audit-002"})
+	if err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+	queue, err := forward.OpenQueue(dir)
+	if err != nil {
+		t.Fatalf("OpenQueue() error = %v", err)
+	}
+	defer queue.Close()
+
+	source := &fakeSource{events: []gateway.ChaincodeEvent{
+		auditEvent(t, 1, testEntry("audit-001", "CREATE", "SUCCESS")),
+		auditEvent(t, 2, testEntry("audit-002", "UPDATE", "SUCCESS")),
+		auditEvent(t, 2, testEntry("audit-003", "UPDATE", "SUCCESS")),
+	}}
+	sender := &flakySender{up: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	forwarder := &forward.Forwarder{
+		Source:         source,
+		Sender:         sender,
+		Queue:          queue,
+		Formatter:      testFormatter(),
+		CheckpointPath: checkpointPath,
+	}
+	go func() { done <- forwarder.Run(ctx) }()
+
+	waitFor(t, func() bool { return sender.count() == 1 })
+	cancel()
+	<-done
+
+	if source.starts[0] != "2/tx-audit-002" {
+		t.Errorf("stream started at %s, want 2/tx-audit-002", source.starts[0])
+	}
+	if !strings.Contains(sender.sent[0], `id="audit-003"`) {
+		t.Errorf("sent %s, want only audit-003", sender.sent[0])
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
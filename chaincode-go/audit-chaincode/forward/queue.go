@@ -0,0 +1,238 @@
+package forward
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Queue is a FIFO of messages on disk, so nothing is lost while the collector is down
+ or the forwarder restarts:
+
+	queue.log   records appended as 4 byte big-endian length + message, fsynced per Push
+	queue.head  offset of the first unsent record, replaced atomically on every Pop
+
+ Once every record is sent both files are reset to 0. A record torn by a crash during Push
+ is cut off on open; its event was not checkpointed yet, so it is received again.
+ Delivery is at-least-once: a crash between Send and Pop sends that message twice.
+*/
+
+// ErrQueueFull is returned by Push when the queue holds MaxBytes or more
+var ErrQueueFull = errors.New("forward queue is full")
+
+// maxRecordSize guards against reading a corrupt length as a huge allocation
+const maxRecordSize = 16 << 20
+
+// Queue is a disk-backed FIFO of syslog messages, safe for one producer and one consumer
+type Queue struct {
+	MaxBytes int64 // 0 = unbounded
+
+	mu       sync.Mutex
+	file     *os.File
+	headPath string
+	head     int64
+	size     int64
+	count    int
+}
+
+// OpenQueue opens (or creates) the queue stored in dir
+func OpenQueue(dir string) (*Queue, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create queue directory %s: %v", dir, err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "queue.log"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue: %v", err)
+	}
+	q := &Queue{file: file, headPath: filepath.Join(dir, "queue.head")}
+
+	data, err := os.ReadFile(q.headPath)
+	if err != nil && !os.IsNotExist(err) {
+		file.Close()
+		return nil, fmt.Errorf("failed to read queue head: %v", err)
+	}
+	if len(data) > 0 {
+		q.head, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid queue head %q: %v", data, err)
+		}
+	}
+
+	err = q.recover()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// recover counts the pending records and cuts off a torn last record
+func (q *Queue) recover() error {
+	info, err := q.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat queue: %v", err)
+	}
+	// Crash between the reset in Pop and the head update: everything was sent
+	if q.head > info.Size() {
+		q.head = info.Size()
+	}
+
+	offset := q.head
+	for {
+		msg, err := q.readAt(offset)
+		if err != nil {
+			break
+		}
+		offset += 4 + int64(len(msg))
+		q.count++
+	}
+	if offset < info.Size() {
+		err = q.file.Truncate(offset)
+		if err != nil {
+			return fmt.Errorf("failed to truncate torn queue record: %v", err)
+		}
+	}
+	q.size = offset
+	return nil
+}
+
+// Push appends a message and syncs it to disk
+func (q *Queue) Push(msg []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.MaxBytes > 0 && q.size-q.head >= q.MaxBytes {
+		return ErrQueueFull
+	}
+	record := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(record, uint32(len(msg)))
+	copy(record[4:], msg)
+
+	_, err := q.file.WriteAt(record, q.size)
+	if err == nil {
+		err = q.file.Sync()
+	}
+	if err != nil {
+		q.file.Truncate(q.size)
+		return fmt.Errorf("failed to write queue record: %v", err)
+	}
+	q.size += int64(len(record))
+	q.count++
+	return nil
+}
+
+// Peek returns the first message without removing it, ok is false when the queue is empty
+func (q *Queue) Peek() (msg []byte, ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.head >= q.size {
+		return nil, false, nil
+	}
+	msg, err = q.readAt(q.head)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read queue record at %d: %v", q.head, err)
+	}
+	return msg, true, nil
+}
+
+// Pop removes the first message
+func (q *Queue) Pop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.head >= q.size {
+		return nil
+	}
+	msg, err := q.readAt(q.head)
+	if err != nil {
+		return fmt.Errorf("failed to read queue record at %d: %v", q.head, err)
+	}
+	head := q.head + 4 + int64(len(msg))
+
+	// Everything sent: start over with an empty file
+	if head == q.size {
+		err = q.file.Truncate(0)
+		if err != nil {
+			return fmt.Errorf("failed to reset queue: %v", err)
+		}
+		q.size = 0
+		head = 0
+	}
+	err = writeFileAtomic(q.headPath, []byte(strconv.FormatInt(head, 10)))
+	if err != nil {
+		return err
+	}
+	q.head = head
+	q.count--
+	return nil
+}
+
+// Len is the number of unsent messages
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// Close closes the queue file
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
+
+func (q *Queue) readAt(offset int64) ([]byte, error) {
+	var length [4]byte
+	_, err := q.file.ReadAt(length[:], offset)
+	if err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds %d", size, maxRecordSize)
+	}
+	msg := make([]byte, size)
+	_, err = q.file.ReadAt(msg, offset+4)
+	if err == io.EOF && size == 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeFileAtomic replaces path with data through a synced temp file and a rename
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %v", path, err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package forward
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Transports:
+	udp - one message per datagram (RFC 5426), messages too large for a datagram fail
+	tcp - octet-counting framing "LEN SP MSG" (RFC 6587 section 3.4.1)
+	tls - same framing over TLS (RFC 5425), default port 6514
+
+ The connection is dialed on the first Send and dropped on any error, so the next Send
+ redials: a collector restart costs one failed Send, which the queue retries.
+*/
+
+// Networks accepted by NewSender
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+)
+
+// Sender delivers one syslog message to the collector
+type Sender interface {
+	Send(msg []byte) error
+	Close() error
+}
+
+// NetSender sends messages to a syslog collector over UDP, TCP or TLS
+type NetSender struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	conn      net.Conn
+}
+
+// NewSender validates the settings, nothing is dialed until the first Send.
+// tlsConfig is only used (and required) for the tls network.
+func NewSender(network string, address string, tlsConfig *tls.Config, timeout time.Duration) (*NetSender, error) {
+	switch network {
+	case NetworkUDP, NetworkTCP:
+	case NetworkTLS:
+		if tlsConfig == nil {
+			return nil, fmt.Errorf("TLS configuration is required for the tls network")
+		}
+	default:
+		return nil, fmt.Errorf("invalid syslog network: %s. Valid networks: %s, %s, %s", network, NetworkUDP, NetworkTCP, NetworkTLS)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("syslog address is required")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &NetSender{network: network, address: address, tlsConfig: tlsConfig, timeout: timeout}, nil
+}
+
+// Send writes one message, framed for the network
+func (s *NetSender) Send(msg []byte) error {
+	if s.conn == nil {
+		err := s.dial()
+		if err != nil {
+			return err
+		}
+	}
+
+	frame := msg
+	if s.network != NetworkUDP {
+		frame = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if err == nil {
+		_, err = s.conn.Write(frame)
+	}
+	if err != nil {
+		s.Close()
+		return fmt.Errorf("failed to send to %s://%s: %v", s.network, s.address, err)
+	}
+	return nil
+}
+
+// Close drops the connection, a later Send redials
+func (s *NetSender) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *NetSender) dial() error {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var conn net.Conn
+	var err error
+	if s.network == NetworkTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(s.network, s.address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s://%s: %v", s.network, s.address, err)
+	}
+	s.conn = conn
+	return nil
+}
@@ -0,0 +1,132 @@
+package forward
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/siem"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ One RFC 5424 message per AuditEntry:
+
+	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [auditTrail@32473 ...] MSG
+
+	PRI        facility * 8 + severity, severity from siem.Classify:
+	           Informational -> 6 (info), Low -> 5 (notice), Medium -> 4 (warning)
+	TIMESTAMP  the entry's timestamp (UTC, ms), not the time it was forwarded
+	MSGID      the audit action, e.g. CREATE
+	SD         id, txId, userId, action, resourceType, resourceId, status, complianceTag
+	MSG        the entry as CEF, LEEF or OCSF (siem package), no BOM
+
+ 32473 is the example enterprise number reserved by RFC 5612, replace SDID if the
+ organisation has its own.
+*/
+
+// Syslog facilities commonly used for application logs
+const (
+	FacilityUser   = 1
+	FacilityLocal0 = 16
+)
+
+// SDID is the structured data ID carrying the audit fields
+const SDID = "auditTrail@32473"
+
+// Formatter renders AuditEntries as RFC 5424 messages
+type Formatter struct {
+	Facility int          // 0-23, FacilityLocal0 unless the collector expects another one
+	Hostname string       // "" = NILVALUE
+	AppName  string       // "" = NILVALUE
+	ProcID   string       // "" = NILVALUE
+	Body     string       // MSG format: siem.FormatCEF, FormatLEEF or FormatOCSF
+	Product  siem.Product // vendor / product in the MSG body
+}
+
+// syslogTimeLayout is an RFC 3339 timestamp with milliseconds (RFC 5424 section 6.2.3)
+const syslogTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// sdValueEscaper escapes PARAM-VALUE characters (RFC 5424 section 6.3.3)
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// Format renders one entry as an RFC 5424 message (no framing, no trailing newline)
+func (f *Formatter) Format(entry *chaincode.AuditEntry) ([]byte, error) {
+	if f.Facility < 0 || f.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d: must be 0-23", f.Facility)
+	}
+	body, err := siem.Format(f.Body, entry, f.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(f.Facility*8 + syslogSeverity(siem.Classify(entry).SeverityID)))
+	b.WriteString(">1 ")
+	b.WriteString(time.UnixMilli(entry.TimeStamp).UTC().Format(syslogTimeLayout))
+	for _, field := range []struct {
+		value  string
+		maxLen int
+	}{
+		{f.Hostname, 255},
+		{f.AppName, 48},
+		{f.ProcID, 128},
+		{entry.Action, 32},
+	} {
+		b.WriteByte(' ')
+		b.WriteString(headerField(field.value, field.maxLen))
+	}
+
+	b.WriteString(" [")
+	b.WriteString(SDID)
+	for _, param := range []struct{ name, value string }{
+		{"id", entry.ID},
+		{"txId", entry.TxID},
+		{"userId", entry.UserID},
+		{"action", entry.Action},
+		{"resourceType", entry.ResourceType},
+		{"resourceId", entry.ResourceID},
+		{"status", entry.Status},
+		{"complianceTag", entry.ComplianceTag},
+	} {
+		if param.value == "" {
+			continue
+		}
+		b.WriteByte(' ')
+		b.WriteString(param.name)
+		b.WriteString(`="`)
+		b.WriteString(sdValueEscaper.Replace(param.value))
+		b.WriteByte('"')
+	}
+	b.WriteString("] ")
+	b.Write(body)
+	return []byte(b.String()), nil
+}
+
+// syslogSeverity maps OCSF severity to syslog severity
+func syslogSeverity(severityID int) int {
+	switch severityID {
+	case siem.SeverityMedium:
+		return 4
+	case siem.SeverityLow:
+		return 5
+	}
+	return 6
+}
+
+// headerField keeps printable US-ASCII only and truncates to maxLen, "" becomes NILVALUE
+func headerField(value string, maxLen int) string {
+	var b strings.Builder
+	for i := 0; i < len(value) && b.Len() < maxLen; i++ {
+		if value[i] >= 33 && value[i] <= 126 {
+			b.WriteByte(value[i])
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
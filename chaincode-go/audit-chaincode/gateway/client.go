@@ -0,0 +1,313 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	gw "github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Client talks to one gateway peer, which endorses, orders and reports commit status for us:
+	Evaluate(fn, args...) - query, runs on one peer, nothing is ordered
+	Submit(fn, args...)   - endorse -> sign envelope -> submit -> wait for commit (VALID or error)
+
+ Functions outside the default contract use the "ContractName:Function" form,
+ e.g. "CheckpointContract:GetLatestCheckpoint".
+
+ TransactionBlock asks the channel's query system chaincode (qscc) which block committed a
+ transaction, so callers can tell how far an event stream has to go before it reaches it.
+*/
+
+// qscc is the peer's query system chaincode
+const qscc = "qscc"
+
+// Client is a connection to a gateway peer bound to one channel and chaincode
+type Client struct {
+	conn      *grpc.ClientConn
+	gateway   gw.GatewayClient
+	identity  *Identity
+	channel   string
+	chaincode string
+}
+
+// Connect dials the peer in cfg and loads the signing identity
+func Connect(cfg Config) (*Client, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	identity, err := LoadIdentity(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := insecure.NewCredentials()
+	if cfg.PeerTLSCertPath != "" {
+		caPEM, err := os.ReadFile(cfg.PeerTLSCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read peer TLS certificate %s: %v", cfg.PeerTLSCertPath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("peer TLS certificate %s holds no PEM certificates", cfg.PeerTLSCertPath)
+		}
+		transport = credentials.NewClientTLSFromCert(pool, cfg.PeerHostAlias)
+	}
+
+	conn, err := grpc.NewClient(cfg.PeerEndpoint, grpc.WithTransportCredentials(transport))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer %s: %v", cfg.PeerEndpoint, err)
+	}
+	return NewClient(conn, identity, cfg.Channel, cfg.Chaincode), nil
+}
+
+// NewClient wraps an existing gRPC connection, the caller keeps ownership of conn
+func NewClient(conn *grpc.ClientConn, identity *Identity, channel string, chaincode string) *Client {
+	return &Client{
+		conn:      conn,
+		gateway:   gw.NewGatewayClient(conn),
+		identity:  identity,
+		channel:   channel,
+		chaincode: chaincode,
+	}
+}
+
+// Close closes the connection to the peer
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Identity returns the signing identity
+func (c *Client) Identity() *Identity {
+	return c.identity
+}
+
+// Evaluate runs a query transaction and returns its result
+func (c *Client) Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	return c.evaluate(ctx, c.chaincode, fn, args)
+}
+
+// TransactionBlock returns the number of the block that committed txID
+func (c *Client) TransactionBlock(ctx context.Context, txID string) (uint64, error) {
+	result, err := c.evaluate(ctx, qscc, "GetBlockByTxID", []string{c.channel, txID})
+	if err != nil {
+		return 0, err
+	}
+	var block common.Block
+	err = proto.Unmarshal(result, &block)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal block of %s: %v", txID, err)
+	}
+	return block.GetHeader().GetNumber(), nil
+}
+
+// evaluate runs fn(args...) on one peer against the named chaincode
+func (c *Client) evaluate(ctx context.Context, chaincode string, fn string, args []string) ([]byte, error) {
+	txID, signed, err := c.newProposal(chaincode, fn, args)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.gateway.Evaluate(ctx, &gw.EvaluateRequest{
+		TransactionId:       txID,
+		ChannelId:           c.channel,
+		ProposedTransaction: signed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("evaluate %s: %v", fn, err)
+	}
+	return response.GetResult().GetPayload(), nil
+}
+
+// Submit endorses, orders and waits for the commit of a transaction, returning its result
+func (c *Client) Submit(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	txID, signed, err := c.newProposal(c.chaincode, fn, args)
+	if err != nil {
+		return nil, err
+	}
+
+	endorsed, err := c.gateway.Endorse(ctx, &gw.EndorseRequest{
+		TransactionId:       txID,
+		ChannelId:           c.channel,
+		ProposedTransaction: signed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("endorse %s: %v", fn, err)
+	}
+
+	envelope := endorsed.GetPreparedTransaction()
+	result, err := transactionResult(envelope)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Signature, err = c.identity.Sign(envelope.GetPayload())
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.gateway.Submit(ctx, &gw.SubmitRequest{
+		TransactionId:       txID,
+		ChannelId:           c.channel,
+		PreparedTransaction: envelope,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("submit %s: %v", fn, err)
+	}
+
+	status, err := c.commitStatus(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if status.GetResult() != peer.TxValidationCode_VALID {
+		return nil, fmt.Errorf("transaction %s (%s) failed to commit: %s", txID, fn, status.GetResult())
+	}
+	return result, nil
+}
+
+// commitStatus blocks until the peer reports the transaction's validation code
+func (c *Client) commitStatus(ctx context.Context, txID string) (*gw.CommitStatusResponse, error) {
+	creator, err := c.identity.Creator()
+	if err != nil {
+		return nil, err
+	}
+	request, err := proto.Marshal(&gw.CommitStatusRequest{
+		TransactionId: txID,
+		ChannelId:     c.channel,
+		Identity:      creator,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal commit status request: %v", err)
+	}
+	signature, err := c.identity.Sign(request)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := c.gateway.CommitStatus(ctx, &gw.SignedCommitStatusRequest{Request: request, Signature: signature})
+	if err != nil {
+		return nil, fmt.Errorf("commit status of %s: %v", txID, err)
+	}
+	return status, nil
+}
+
+// newProposal builds and signs a proposal for fn(args...) on the named chaincode
+func (c *Client) newProposal(chaincode string, fn string, args []string) (string, *peer.SignedProposal, error) {
+	creator, err := c.identity.Creator()
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, 24)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	txHash := sha256.Sum256(append(append([]byte{}, nonce...), creator...))
+	txID := hex.EncodeToString(txHash[:])
+
+	chaincodeID := &peer.ChaincodeID{Name: chaincode}
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{ChaincodeId: chaincodeID})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal header extension: %v", err)
+	}
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		TxId:      txID,
+		Timestamp: timestamppb.Now(),
+		ChannelId: c.channel,
+		Extension: extension,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal channel header: %v", err)
+	}
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: creator, Nonce: nonce})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal signature header: %v", err)
+	}
+	header, err := proto.Marshal(&common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal header: %v", err)
+	}
+
+	input := [][]byte{[]byte(fn)}
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+	invocation, err := proto.Marshal(&peer.ChaincodeInvocationSpec{
+		ChaincodeSpec: &peer.ChaincodeSpec{
+			Type:        peer.ChaincodeSpec_GOLANG,
+			ChaincodeId: chaincodeID,
+			Input:       &peer.ChaincodeInput{Args: input},
+		},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal invocation: %v", err)
+	}
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: invocation})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal proposal payload: %v", err)
+	}
+	proposal, err := proto.Marshal(&peer.Proposal{Header: header, Payload: payload})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal proposal: %v", err)
+	}
+
+	signature, err := c.identity.Sign(proposal)
+	if err != nil {
+		return "", nil, err
+	}
+	return txID, &peer.SignedProposal{ProposalBytes: proposal, Signature: signature}, nil
+}
+
+// transactionResult digs the chaincode response payload out of an endorsed transaction envelope
+func transactionResult(envelope *common.Envelope) ([]byte, error) {
+	if envelope == nil {
+		return nil, fmt.Errorf("gateway returned no prepared transaction")
+	}
+
+	payload := &common.Payload{}
+	err := proto.Unmarshal(envelope.GetPayload(), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction payload: %v", err)
+	}
+	transaction := &peer.Transaction{}
+	err = proto.Unmarshal(payload.GetData(), transaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction: %v", err)
+	}
+	if len(transaction.GetActions()) == 0 {
+		return nil, fmt.Errorf("transaction has no actions")
+	}
+	actionPayload := &peer.ChaincodeActionPayload{}
+	err = proto.Unmarshal(transaction.GetActions()[0].GetPayload(), actionPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode action payload: %v", err)
+	}
+	responsePayload := &peer.ProposalResponsePayload{}
+	err = proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal response payload: %v", err)
+	}
+	action := &peer.ChaincodeAction{}
+	err = proto.Unmarshal(responsePayload.GetExtension(), action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode action: %v", err)
+	}
+	return action.GetResponse().GetPayload(), nil
+}
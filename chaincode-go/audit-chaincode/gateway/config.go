@@ -0,0 +1,99 @@
+// Package gateway is a small client for the Fabric Gateway service (Fabric v2.4+ peers).
+// The off-chain services under cmd/ use it to evaluate and submit audit-trail transactions
+// and to follow chaincode events, signing everything with one X.509 identity.
+package gateway
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Configuration mirrors application/config/fabricConfig.js so the Go services and the REST
+ backend can share one .env:
+
+	CHANNEL_NAME        - channel (default audit-channel)
+	CHAINCODE_NAME      - chaincode (default audit-trail)
+	MSP_ID              - MSP of the signing identity (default Org1MSP)
+	CERT_PATH           - PEM certificate of the signing identity
+	KEY_PATH            - PEM private key, or a keystore directory (first file is used)
+	PEER_ENDPOINT       - Gateway peer host:port (default localhost:7051)
+	PEER_TLS_CERT_PATH  - PEM TLS CA certificate of the peer ("" = plaintext, dev only)
+	PEER_HOST_ALIAS     - TLS server name override (default peer0.org1.example.com)
+*/
+
+// Config holds everything needed to connect and sign
+type Config struct {
+	Channel         string
+	Chaincode       string
+	MSPID           string
+	CertPath        string
+	KeyPath         string
+	PeerEndpoint    string
+	PeerTLSCertPath string
+	PeerHostAlias   string
+}
+
+// ConfigFromEnv reads a Config from the environment, applying the same defaults as the REST backend
+func ConfigFromEnv() Config {
+	return Config{
+		Channel:         envOrDefault("CHANNEL_NAME", "audit-channel"),
+		Chaincode:       envOrDefault("CHAINCODE_NAME", "audit-trail"),
+		MSPID:           envOrDefault("MSP_ID", "Org1MSP"),
+		CertPath:        os.Getenv("CERT_PATH"),
+		KeyPath:         os.Getenv("KEY_PATH"),
+		PeerEndpoint:    envOrDefault("PEER_ENDPOINT", "localhost:7051"),
+		PeerTLSCertPath: os.Getenv("PEER_TLS_CERT_PATH"),
+		PeerHostAlias:   envOrDefault("PEER_HOST_ALIAS", "peer0.org1.example.com"),
+	}
+}
+
+// Validate reports the first missing required setting
+func (c Config) Validate() error {
+	switch {
+	case c.Channel == "":
+		return fmt.Errorf("channel is required (CHANNEL_NAME)")
+	case c.Chaincode == "":
+		return fmt.Errorf("chaincode is required (CHAINCODE_NAME)")
+	case c.MSPID == "":
+		return fmt.Errorf("MSP ID is required (MSP_ID)")
+	case c.CertPath == "":
+		return fmt.Errorf("certificate path is required (CERT_PATH)")
+	case c.KeyPath == "":
+		return fmt.Errorf("key path is required (KEY_PATH)")
+	case c.PeerEndpoint == "":
+		return fmt.Errorf("peer endpoint is required (PEER_ENDPOINT)")
+	}
+	return nil
+}
+
+// keyFile resolves KeyPath, which may be a keystore directory like the REST backend expects
+func (c Config) keyFile() (string, error) {
+	info, err := os.Stat(c.KeyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key path %s: %v", c.KeyPath, err)
+	}
+	if !info.IsDir() {
+		return c.KeyPath, nil
+	}
+
+	entries, err := os.ReadDir(c.KeyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keystore %s: %v", c.KeyPath, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return filepath.Join(c.KeyPath, entry.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("no private key found in keystore directory: %s", c.KeyPath)
+}
+
+func envOrDefault(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
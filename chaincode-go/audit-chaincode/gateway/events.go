@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	gw "github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/orderer"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ ChaincodeEvents follows the chaincode's events from a block onward, in commit order.
+ To resume after a restart, pass the block and TxID of the last event handled:
+ the gateway starts at that block and skips everything up to and including that TxID.
+*/
+
+// ChaincodeEvent is one event emitted by a committed transaction
+type ChaincodeEvent struct {
+	BlockNumber uint64
+	TxID        string
+	EventName   string
+	Payload     []byte
+}
+
+// ChaincodeEvents calls handle for every event from startBlock onward (after afterTxID, if set).
+// It blocks until ctx is cancelled, the stream fails, or handle returns an error.
+func (c *Client) ChaincodeEvents(ctx context.Context, startBlock uint64, afterTxID string, handle func(ChaincodeEvent) error) error {
+	creator, err := c.identity.Creator()
+	if err != nil {
+		return err
+	}
+	request, err := proto.Marshal(&gw.ChaincodeEventsRequest{
+		ChannelId:   c.channel,
+		ChaincodeId: c.chaincode,
+		Identity:    creator,
+		StartPosition: &orderer.SeekPosition{
+			Type: &orderer.SeekPosition_Specified{Specified: &orderer.SeekSpecified{Number: startBlock}},
+		},
+		AfterTransactionId: afterTxID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal chaincode events request: %v", err)
+	}
+	signature, err := c.identity.Sign(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.gateway.ChaincodeEvents(ctx, &gw.SignedChaincodeEventsRequest{Request: request, Signature: signature})
+	if err != nil {
+		return fmt.Errorf("chaincode events: %v", err)
+	}
+
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("chaincode events: %v", err)
+		}
+		for _, event := range response.GetEvents() {
+			err = handle(ChaincodeEvent{
+				BlockNumber: response.GetBlockNumber(),
+				TxID:        event.GetTxId(),
+				EventName:   event.GetEventName(),
+				Payload:     event.GetPayload(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
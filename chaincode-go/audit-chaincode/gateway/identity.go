@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"google.golang.org/protobuf/proto"
+)
+
+// Identity is an X.509 signing identity: the certificate peers see as the creator, and its key
+type Identity struct {
+	MSPID       string
+	Certificate *x509.Certificate
+	certPEM     []byte
+	key         *ecdsa.PrivateKey
+}
+
+// NewIdentity builds an identity from PEM certificate and private key bytes (ECDSA, as issued by Fabric CA)
+func NewIdentity(mspID string, certPEM []byte, keyPEM []byte) (*Identity, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+	var parsed crypto.PrivateKey
+	parsed, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		parsed, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %v", err)
+		}
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key must be ECDSA")
+	}
+
+	return &Identity{MSPID: mspID, Certificate: cert, certPEM: certPEM, key: key}, nil
+}
+
+// LoadIdentity reads the identity named by a Config
+func LoadIdentity(cfg Config) (*Identity, error) {
+	certPEM, err := os.ReadFile(cfg.CertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %v", cfg.CertPath, err)
+	}
+	keyPath, err := cfg.keyFile()
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %v", keyPath, err)
+	}
+	return NewIdentity(cfg.MSPID, certPEM, keyPEM)
+}
+
+// Creator is the serialized identity placed in transaction headers
+func (id *Identity) Creator() ([]byte, error) {
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: id.MSPID, IdBytes: id.certPEM})
+}
+
+// Sign signs SHA-256(message) the way Fabric expects: ASN.1 DER ECDSA with a low S value
+func (id *Identity) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	r, s, err := ecdsa.Sign(rand.Reader, id.key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %v", err)
+	}
+	s = lowS(id.key.Curve, s)
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+// lowS maps s onto the lower half of the curve order, peers reject high S signatures
+func lowS(curve elliptic.Curve, s *big.Int) *big.Int {
+	order := curve.Params().N
+	half := new(big.Int).Rsh(order, 1)
+	if s.Cmp(half) > 0 {
+		return new(big.Int).Sub(order, s)
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package ledgersim
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/attrmgr"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ identity.go makes client identities the way Fabric CA enrolls them, so cid.ClientID
+ (ctx.GetClientIdentity()) reads them exactly as on a peer:
+	- ECDSA P-256 key, self-signed X.509 certificate with the CN and OUs given
+	- Attributes in the Fabric CA extension (OID 1.2.3.4.5.6.7.8.1), e.g. {"userId": "user-alice"}
+	- GetCreator returns the msp.SerializedIdentity (MSP ID + PEM certificate)
+
+	admin, _ := ledgersim.NewIdentity("Org1MSP", "Admin@org1.example.com", []string{"admin"}, nil)
+	alice, _ := ledgersim.NewIdentity("Org1MSP", "alice", []string{"client"}, map[string]string{"userId": "user-alice"})
+*/
+
+// Identity is a client that signs proposals
+type Identity struct {
+	mspID   string
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	creator []byte
+}
+
+// NewIdentity creates a client of mspID with a fresh key and certificate
+func NewIdentity(mspID string, commonName string, ous []string, attrs map[string]string) (*Identity, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, OrganizationalUnit: ous, Organization: []string{mspID}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if len(attrs) > 0 {
+		err = attrmgr.New().AddAttributesToCert(&attrmgr.Attributes{Attrs: attrs}, template)
+		if err != nil {
+			return nil, err
+		}
+		// AddAttributesToCert sets Extensions, only ExtraExtensions end up in a new certificate
+		template.ExtraExtensions, template.Extensions = template.Extensions, nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize identity: %v", err)
+	}
+	return &Identity{mspID: mspID, cert: cert, key: key, creator: creator}, nil
+}
+
+// MSPID returns the MSP the identity belongs to
+func (id *Identity) MSPID() string {
+	return id.mspID
+}
+
+// Certificate returns the identity's certificate
+func (id *Identity) Certificate() *x509.Certificate {
+	return id.cert
+}
+
+// Creator returns the serialized identity a proposal carries
+func (id *Identity) Creator() []byte {
+	return id.creator
+}
+
+// Sign signs SHA-256(message) with the identity's key, ASN.1 encoded
+func (id *Identity) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	return ecdsa.SignASN1(rand.Reader, id.key, digest[:])
+}
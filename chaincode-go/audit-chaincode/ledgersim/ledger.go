@@ -0,0 +1,372 @@
+// Package ledgersim is an in-memory Fabric ledger for testing chaincode without a network.
+//
+// It implements shim.ChaincodeStubInterface over a committed world state with key history,
+// events, client identities and a CouchDB Mango query engine, so contracts can be invoked
+// through contractapi (Ledger.Submit) or called directly (Stub.Context) in plain go tests.
+package ledgersim
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ ledger.go is the simulated channel ledger, one per test:
+	- New            - Empty ledger on channel "mychannel", clock at 2025-01-01T00:00:00Z
+	- Submit         - Runs a transaction through the chaincode, commits it when the response is OK (< 400)
+	- Evaluate       - Runs a query transaction, never commits
+	- NewTransaction - A Stub to call contract functions with directly (Stub.Context), then Stub.Commit
+	- LoadIndexes    - Loads the CouchDB indexes of META-INF, sorted rich queries then need one like on a peer
+
+Transactions behave like on a peer:
+	- Reads see the committed state only, never the transaction's own writes
+	- Writes, deletes and the event (last SetEvent wins) apply together on commit, or not at all
+	- Every commit adds a KeyModification to the history of each written key (GetHistoryForKey, newest first)
+	- TxID = hex(SHA-256(nonce || creator)), nonces are deterministic so runs are repeatable
+	- Each transaction is stamped with the clock, which then advances by one second (SetClock)
+
+	ledger := ledgersim.New()
+	admin, _ := ledgersim.NewIdentity("Org1MSP", "Admin@org1.example.com", []string{"admin"}, nil)
+	response := ledger.Submit(cc, ledgersim.Proposal{Identity: admin, Args: []string{"LogAudit", ...}})
+*/
+
+// DefaultChannel is the channel ID every transaction reports
+const DefaultChannel = "mychannel"
+
+// Ledger is the committed state of one simulated channel, safe for concurrent use
+type Ledger struct {
+	mu       sync.Mutex
+	state    map[string][]byte
+	metadata map[string][]byte // State validation parameters
+	history  map[string][]*queryresult.KeyModification
+	events   []Event
+	indexes  []Index
+	clock    time.Time
+	step     time.Duration
+	txCount  uint64
+}
+
+// Event is a chaincode event of a committed transaction
+type Event struct {
+	TxID    string
+	Name    string
+	Payload []byte
+}
+
+// Proposal is what a client sends: who signs it, the arguments (function first) and transient data
+type Proposal struct {
+	Identity  *Identity
+	Args      []string
+	Transient map[string][]byte
+}
+
+// Index is a CouchDB JSON index as deployed from META-INF/statedb/couchdb/indexes
+type Index struct {
+	Name      string
+	DesignDoc string
+	Fields    []string
+}
+
+// New returns an empty ledger
+func New() *Ledger {
+	return &Ledger{
+		state:    map[string][]byte{},
+		metadata: map[string][]byte{},
+		history:  map[string][]*queryresult.KeyModification{},
+		clock:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		step:     time.Second,
+	}
+}
+
+// SetClock sets the timestamp of the next transaction and how far the clock moves after each one
+func (l *Ledger) SetClock(next time.Time, step time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock, l.step = next, step
+}
+
+// Now returns the timestamp the next transaction gets
+func (l *Ledger) Now() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.clock
+}
+
+/* --- TRANSACTIONS --- */
+
+// NewTransaction starts a transaction for the proposal, the clock advances
+func (l *Ledger) NewTransaction(proposal Proposal) *Stub {
+	l.mu.Lock()
+	l.txCount++
+	count := l.txCount
+	timestamp := l.clock
+	l.clock = l.clock.Add(l.step)
+	l.mu.Unlock()
+
+	var creator []byte
+	if proposal.Identity != nil {
+		creator = proposal.Identity.Creator()
+	}
+
+	// Fabric derives the TxID from the nonce and the creator (protoutil.ComputeTxID)
+	nonce := sha256.Sum256(binary.BigEndian.AppendUint64([]byte("ledgersim nonce"), count))
+	txID := sha256.Sum256(append(nonce[:24:24], creator...))
+
+	args := make([][]byte, len(proposal.Args))
+	for i, arg := range proposal.Args {
+		args[i] = []byte(arg)
+	}
+	transient := map[string][]byte{}
+	for key, value := range proposal.Transient {
+		transient[key] = value
+	}
+
+	return &Stub{
+		ledger:    l,
+		txID:      hex.EncodeToString(txID[:]),
+		nonce:     nonce[:24],
+		args:      args,
+		creator:   creator,
+		transient: transient,
+		timestamp: timestamppb.New(timestamp),
+		writes:    map[string]*write{},
+		metadata:  map[string][]byte{},
+	}
+}
+
+// Submit runs a transaction and commits it when the chaincode returned a status below 400
+func (l *Ledger) Submit(cc shim.Chaincode, proposal Proposal) *peer.Response {
+	stub := l.NewTransaction(proposal)
+	response := cc.Invoke(stub)
+	if response != nil && response.Status < shim.ERRORTHRESHOLD {
+		err := stub.Commit()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	return response
+}
+
+// Evaluate runs a query transaction, its writes are discarded
+func (l *Ledger) Evaluate(cc shim.Chaincode, proposal Proposal) *peer.Response {
+	return cc.Invoke(l.NewTransaction(proposal))
+}
+
+// commit applies the writes of a transaction as one block with one transaction
+func (l *Ledger) commit(s *Stub) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]string, 0, len(s.writes))
+	for key := range s.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		w := s.writes[key]
+		if w.deleted {
+			delete(l.state, key)
+		} else {
+			l.state[key] = w.value
+		}
+		l.history[key] = append(l.history[key], &queryresult.KeyModification{
+			TxId:      s.txID,
+			Value:     w.value,
+			Timestamp: s.timestamp,
+			IsDelete:  w.deleted,
+		})
+	}
+	for key, ep := range s.metadata {
+		l.metadata[key] = ep
+	}
+	if s.event != nil {
+		l.events = append(l.events, *s.event)
+	}
+}
+
+/* --- STATE ACCESS (tests) --- */
+
+// State returns the committed value of key, nil if absent
+func (l *Ledger) State(key string) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state[key]
+}
+
+// PutState writes key directly, as a committed transaction of its own (seeding test data)
+func (l *Ledger) PutState(key string, value []byte) error {
+	stub := l.NewTransaction(Proposal{})
+	err := stub.PutState(key, value)
+	if err != nil {
+		return err
+	}
+	return stub.Commit()
+}
+
+// Keys returns every committed key, sorted
+func (l *Ledger) Keys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := make([]string, 0, len(l.state))
+	for key := range l.state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Events returns the events of the committed transactions, oldest first
+func (l *Ledger) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event{}, l.events...)
+}
+
+/* --- INDEXES --- */
+
+// LoadIndexes loads every *.json CouchDB index definition in dir
+func (l *Ledger) LoadIndexes(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no index definitions in %s", dir)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		index, err := ParseIndex(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		l.AddIndex(index)
+	}
+	return nil
+}
+
+// AddIndex adds one index definition
+func (l *Ledger) AddIndex(index Index) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.indexes = append(l.indexes, index)
+}
+
+// ParseIndex parses a CouchDB index definition, fields as names or {"name": "asc"}
+func ParseIndex(data []byte) (Index, error) {
+	var definition struct {
+		Index struct {
+			Fields []interface{} `json:"fields"`
+		} `json:"index"`
+		DesignDoc string `json:"ddoc"`
+		Name      string `json:"name"`
+		Type      string `json:"type"`
+	}
+	err := json.Unmarshal(data, &definition)
+	if err != nil {
+		return Index{}, fmt.Errorf("invalid index definition: %v", err)
+	}
+	if definition.Type != "" && definition.Type != "json" {
+		return Index{}, fmt.Errorf("unsupported index type %s", definition.Type)
+	}
+
+	index := Index{Name: definition.Name, DesignDoc: definition.DesignDoc}
+	for _, field := range definition.Index.Fields {
+		switch field := field.(type) {
+		case string:
+			index.Fields = append(index.Fields, field)
+		case map[string]interface{}:
+			for name := range field {
+				index.Fields = append(index.Fields, name)
+			}
+		}
+	}
+	if len(index.Fields) == 0 {
+		return Index{}, fmt.Errorf("index %s has no fields", index.Name)
+	}
+	return index, nil
+}
+
+/* --- READS (Stub) --- */
+
+// rangeKeys returns the committed entries with startKey <= key < endKey ("" = no upper bound), in key order
+func (l *Ledger) rangeKeys(startKey, endKey string) []*queryresult.KV {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	results := []*queryresult.KV{}
+	for key, value := range l.state {
+		if key >= startKey && (endKey == "" || key < endKey) {
+			results = append(results, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	return results
+}
+
+// richQuery runs a Mango query over the committed JSON documents
+func (l *Ledger) richQuery(text string, pageSize int, bookmark string) ([]*queryresult.KV, string, error) {
+	q, err := parseQuery(text)
+	if err != nil {
+		return nil, "", err
+	}
+
+	l.mu.Lock()
+	if len(l.indexes) > 0 && !q.sortable(l.indexes) {
+		l.mu.Unlock()
+		return nil, "", fmt.Errorf("error handling CouchDB request. Error:no_usable_index,  Status Code:400,  "+
+			"Reason:No index exists for this sort, try indexing by the sort fields. Sort: %s", strings.Join(q.sort, ", "))
+	}
+	docs := []*document{}
+	for key, value := range l.state {
+		var doc map[string]interface{}
+		if json.Unmarshal(value, &doc) != nil || doc == nil {
+			continue // Only JSON objects are documents CouchDB can query
+		}
+		docs = append(docs, &document{key: key, value: value, doc: doc})
+	}
+	l.mu.Unlock()
+	sort.Slice(docs, func(i, j int) bool { return docs[i].key < docs[j].key })
+
+	matches, next, err := q.run(docs, pageSize, bookmark)
+	if err != nil {
+		return nil, "", err
+	}
+	results := []*queryresult.KV{}
+	for _, d := range matches {
+		value, err := q.project(d)
+		if err != nil {
+			return nil, "", err
+		}
+		results = append(results, &queryresult.KV{Key: d.key, Value: value})
+	}
+	return results, next, nil
+}
+
+// keyHistory returns the committed modifications of key, newest first
+func (l *Ledger) keyHistory(key string) []*queryresult.KeyModification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	modifications := l.history[key]
+	results := make([]*queryresult.KeyModification, 0, len(modifications))
+	for i := len(modifications) - 1; i >= 0; i-- {
+		results = append(results, modifications[i])
+	}
+	return results
+}
@@ -0,0 +1,374 @@
+package ledgersim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// chaincodeFunc is a shim.Chaincode made of one function
+type chaincodeFunc func(stub shim.ChaincodeStubInterface) *peer.Response
+
+func (f chaincodeFunc) Init(stub shim.ChaincodeStubInterface) *peer.Response {
+	return shim.Success(nil)
+}
+func (f chaincodeFunc) Invoke(stub shim.ChaincodeStubInterface) *peer.Response { return f(stub) }
+
+func newIdentity(t *testing.T, ous []string, attrs map[string]string) *Identity {
+	t.Helper()
+	id, err := NewIdentity("Org1MSP", "alice", ous, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func seed(t *testing.T, ledger *Ledger, docs map[string]string) {
+	t.Helper()
+	for key, value := range docs {
+		err := ledger.PutState(key, []byte(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// keys lists the keys of a query result, an error shows up as the only "key"
+func keys(iterator shim.StateQueryIteratorInterface, err error) []string {
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	defer iterator.Close()
+	found := []string{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return append(found, "error: "+err.Error())
+		}
+		found = append(found, kv.Key)
+	}
+	return found
+}
+
+func TestTransactionCommit(t *testing.T) {
+	ledger := New()
+	cc := chaincodeFunc(func(stub shim.ChaincodeStubInterface) *peer.Response {
+		_, params := stub.GetFunctionAndParameters()
+		stub.PutState("k", []byte(params[0]))
+		stub.SetEvent("first", nil)
+		stub.SetEvent("Written", []byte(params[0]))
+
+		// Reads never see the transaction's own writes
+		value, _ := stub.GetState("k")
+		if params[0] == "fail" {
+			return shim.Error("failed")
+		}
+		return shim.Success(value)
+	})
+
+	response := ledger.Submit(cc, Proposal{Args: []string{"Put", "v1"}})
+	if response.Status != shim.OK || response.Payload != nil {
+		t.Fatalf("first put = %d %q", response.Status, response.Payload)
+	}
+	response = ledger.Submit(cc, Proposal{Args: []string{"Put", "fail"}})
+	if response.Status != shim.ERROR || string(ledger.State("k")) != "v1" {
+		t.Errorf("failed transaction committed: %q", ledger.State("k"))
+	}
+	response = ledger.Evaluate(cc, Proposal{Args: []string{"Put", "v2"}})
+	if string(response.Payload) != "v1" || string(ledger.State("k")) != "v1" {
+		t.Errorf("evaluate committed: %q", ledger.State("k"))
+	}
+
+	events := ledger.Events()
+	if len(events) != 1 || events[0].Name != "Written" || string(events[0].Payload) != "v1" {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestHistoryAndClock(t *testing.T) {
+	ledger := New()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ledger.SetClock(start, time.Minute)
+
+	seed(t, ledger, map[string]string{"k": "v1"})
+	seed(t, ledger, map[string]string{"k": "v2"})
+	stub := ledger.NewTransaction(Proposal{})
+	stub.DelState("k")
+	stub.Commit()
+	if err := stub.Commit(); err == nil {
+		t.Error("second commit succeeded")
+	}
+
+	iterator, err := ledger.NewTransaction(Proposal{}).GetHistoryForKey("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modifications := []string{}
+	for iterator.HasNext() {
+		modification, _ := iterator.Next()
+		modifications = append(modifications, fmt.Sprintf("%s@%d:%t",
+			modification.Value, modification.Timestamp.AsTime().Sub(start)/time.Minute, modification.IsDelete))
+	}
+	if got := strings.Join(modifications, " "); got != "@2:true v2@1:false v1@0:false" {
+		t.Errorf("history = %s", got)
+	}
+	if ledger.State("k") != nil {
+		t.Error("deleted key still in state")
+	}
+}
+
+func TestRangeQueries(t *testing.T) {
+	ledger := New()
+	seed(t, ledger, map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"})
+	for _, id := range []string{"u1", "u2", "u3"} {
+		key, _ := shim.CreateCompositeKey("USER", []string{id})
+		seed(t, ledger, map[string]string{key: id})
+	}
+	other, _ := shim.CreateCompositeKey("USERS", []string{"x"})
+	seed(t, ledger, map[string]string{other: "x"})
+	stub := ledger.NewTransaction(Proposal{})
+
+	// Open ranges skip the composite key namespace
+	if got := keys(stub.GetStateByRange("", "")); strings.Join(got, ",") != "a,b,c,d" {
+		t.Errorf("all simple keys = %v", got)
+	}
+	if got := keys(stub.GetStateByRange("b", "d")); strings.Join(got, ",") != "b,c" {
+		t.Errorf("range b..d = %v", got)
+	}
+	if _, err := stub.GetStateByRange("\x00USER", ""); err == nil {
+		t.Error("range over the composite namespace accepted")
+	}
+	if got := keys(stub.GetStateByPartialCompositeKey("USER", nil)); len(got) != 3 {
+		t.Errorf("partial composite key = %q", got)
+	}
+
+	// Pages chain through the bookmark, "" after the last one
+	bookmark, pages := "", 0
+	for {
+		iterator, metadata, err := ledger.NewTransaction(Proposal{}).GetStateByPartialCompositeKeyWithPagination("USER", nil, 2, bookmark)
+		found := keys(iterator, err)
+		pages++
+		if int(metadata.FetchedRecordsCount) != len(found) {
+			t.Errorf("fetched = %d, got %d", metadata.FetchedRecordsCount, len(found))
+		}
+		bookmark = metadata.Bookmark
+		if bookmark == "" {
+			break
+		}
+	}
+	if pages != 2 {
+		t.Errorf("pages = %d", pages)
+	}
+
+	// A paginated query and a write cannot share a transaction
+	stub = ledger.NewTransaction(Proposal{})
+	stub.GetStateByRangeWithPagination("", "", 1, "")
+	if err := stub.PutState("e", []byte("5")); err == nil {
+		t.Error("write after paginated query accepted")
+	}
+}
+
+func TestKeyValidation(t *testing.T) {
+	stub := New().NewTransaction(Proposal{})
+	for key, value := range map[string]string{
+		"":        "1",
+		"_design": "1",
+		"k":       `{"_id":"x"}`,
+	} {
+		if err := stub.PutState(key, []byte(value)); err == nil {
+			t.Errorf("PutState(%q, %s) accepted", key, value)
+		}
+	}
+	if _, err := stub.CreateCompositeKey("USER", []string{"a\x00b"}); err == nil {
+		t.Error("composite attribute with U+0000 accepted")
+	}
+	key, _ := stub.CreateCompositeKey("USER", []string{"a", "b"})
+	objectType, attributes, err := stub.SplitCompositeKey(key)
+	if err != nil || objectType != "USER" || strings.Join(attributes, ",") != "a,b" {
+		t.Errorf("split = %s %v %v", objectType, attributes, err)
+	}
+}
+
+func TestIdentityAndProposal(t *testing.T) {
+	ledger := New()
+	alice := newIdentity(t, []string{"client"}, map[string]string{"userId": "user-alice"})
+	stub := ledger.NewTransaction(Proposal{Identity: alice, Args: []string{"Fn", "x"}, Transient: map[string][]byte{"secret": []byte("s")}})
+
+	ctx := stub.Context()
+	userID, found, err := ctx.GetClientIdentity().GetAttributeValue("userId")
+	if err != nil || !found || userID != "user-alice" {
+		t.Errorf("userId = %q %t %v", userID, found, err)
+	}
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+	cert, _ := ctx.GetClientIdentity().GetX509Certificate()
+	if mspID != "Org1MSP" || cert.Subject.CommonName != "alice" || cert.Subject.OrganizationalUnit[0] != "client" {
+		t.Errorf("identity = %s %v", mspID, cert.Subject)
+	}
+
+	transient, _ := stub.GetTransient()
+	if string(transient["secret"]) != "s" {
+		t.Errorf("transient = %v", transient)
+	}
+	if len(stub.GetTxID()) != 64 || stub.GetTxID() == ledger.NewTransaction(Proposal{Identity: alice}).GetTxID() {
+		t.Errorf("txId = %s", stub.GetTxID())
+	}
+
+	// Without an identity the context holds a nil *cid.ClientID, as contractapi sets it
+	anonymous := ledger.NewTransaction(Proposal{}).Context()
+	if clientID, ok := anonymous.GetClientIdentity().(*cid.ClientID); !ok || clientID != nil {
+		t.Errorf("anonymous identity = %v", anonymous.GetClientIdentity())
+	}
+}
+
+var mangoDocs = map[string]string{
+	"audit-1": `{"id":"audit-1","userId":"alice","action":"CREATE","timestamp":100,"tags":["a","b"],"holder":{"name":"x"}}`,
+	"audit-2": `{"id":"audit-2","userId":"bob","action":"UPDATE","timestamp":200,"tags":["b"]}`,
+	"audit-3": `{"id":"audit-3","userId":"alice","action":"DELETE","timestamp":300,"changes":[{"path":"/status","value":"\"REVOKED\""}]}`,
+	"user-1":  `{"id":"user-1","docType":"user","email":"Alice@example.com"}`,
+	"raw":     `not json`,
+}
+
+func TestMangoSelectors(t *testing.T) {
+	ledger := New()
+	seed(t, ledger, mangoDocs)
+
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{`{"userId": "alice"}`, "audit-1,audit-3"},
+		{`{"timestamp": {"$gte": 150, "$lte": 300}}`, "audit-2,audit-3"},
+		{`{"$or": [{"action": "CREATE"}, {"userId": "bob"}]}`, "audit-1,audit-2"},
+		{`{"$and": [{"userId": "alice"}, {"timestamp": {"$gt": 100}}]}`, "audit-3"},
+		{`{"action": {"$in": ["CREATE", "DELETE"]}}`, "audit-1,audit-3"},
+		{`{"action": {"$nin": ["CREATE", "DELETE"]}}`, "audit-2"},
+		{`{"tags": {"$in": ["a"]}}`, "audit-1"},
+		{`{"tags": {"$all": ["b"]}, "tags.0": {"$exists": false}}`, "audit-1,audit-2"},
+		{`{"docType": {"$exists": false}, "userId": {"$ne": "alice"}}`, "audit-2"},
+		{`{"holder.name": "x"}`, "audit-1"},
+		{`{"holder": {"name": "x"}}`, "audit-1"},
+		{`{"changes": {"$elemMatch": {"path": "/status", "value": "\"REVOKED\""}}}`, "audit-3"},
+		{`{"tags": {"$size": 1}}`, "audit-2"},
+		// $not negates the whole condition, so documents without the field match
+		{`{"timestamp": {"$not": {"$lt": 200}}}`, "audit-2,audit-3,user-1"},
+		{`{"$nor": [{"userId": "alice"}, {"docType": "user"}]}`, "audit-2"},
+		{`{"email": {"$regex": "^Alice@"}}`, "user-1"},
+		{`{"timestamp": {"$type": "number", "$mod": [200, 100]}}`, "audit-1,audit-3"},
+		// Collation across types: every string sorts after every number
+		{`{"userId": {"$gt": 1000}}`, "audit-1,audit-2,audit-3"},
+	}
+	for _, tt := range tests {
+		got := keys(ledger.NewTransaction(Proposal{}).GetQueryResult(`{"selector": ` + tt.selector + `}`))
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s = %v, want %s", tt.selector, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{
+		`{"selector": {"timestamp": {"$between": [1, 2]}}}`,
+		`{"selector": {"action": {"$in": "CREATE"}}}`,
+		`{"selector": {}, "sort": [{"timestamp": "asc"}, {"id": "desc"}]}`,
+		`{"selector": {}, "limits": 2}`,
+		`{"sort": ["id"]}`,
+	} {
+		if _, err := ledger.NewTransaction(Proposal{}).GetQueryResult(query); err == nil {
+			t.Errorf("invalid query accepted: %s", query)
+		}
+	}
+}
+
+func TestMangoSortAndFields(t *testing.T) {
+	ledger := New()
+	seed(t, ledger, mangoDocs)
+	stub := ledger.NewTransaction(Proposal{})
+
+	// Documents without the sort field are left out
+	got := keys(stub.GetQueryResult(`{"selector": {}, "sort": [{"timestamp": "desc"}]}`))
+	if strings.Join(got, ",") != "audit-3,audit-2,audit-1" {
+		t.Errorf("sorted desc = %v", got)
+	}
+	got = keys(stub.GetQueryResult(`{"selector": {}, "sort": ["userId", "timestamp"], "skip": 1, "limit": 1}`))
+	if strings.Join(got, ",") != "audit-3" {
+		t.Errorf("skip/limit = %v", got)
+	}
+
+	iterator, _ := stub.GetQueryResult(`{"selector": {"id": "audit-1"}, "fields": ["id", "holder.name"]}`)
+	kv, _ := iterator.Next()
+	if string(kv.Value) != `{"holder":{"name":"x"},"id":"audit-1"}` {
+		t.Errorf("projection = %s", kv.Value)
+	}
+
+	for _, order := range [][]string{
+		{"1", "a", "A", "aa", "b", "B"},
+		{"audit-001", "audit-002", "audit-010"},
+	} {
+		for i := 1; i < len(order); i++ {
+			if collateStrings(order[i-1], order[i]) >= 0 {
+				t.Errorf("collate %q >= %q", order[i-1], order[i])
+			}
+		}
+	}
+}
+
+func TestMangoPagination(t *testing.T) {
+	ledger := New()
+	for i := 0; i < 7; i++ {
+		doc, _ := json.Marshal(map[string]interface{}{"id": fmt.Sprintf("audit-%d", i), "timestamp": 100 - i})
+		seed(t, ledger, map[string]string{fmt.Sprintf("audit-%d", i): string(doc)})
+	}
+	query := `{"selector": {"timestamp": {"$gte": 0}}, "sort": [{"timestamp": "asc"}], "limit": 1}`
+
+	// Same loop as export and mirror: stop on an empty or repeated bookmark
+	bookmark, all := "", []string{}
+	for {
+		iterator, metadata, err := ledger.NewTransaction(Proposal{}).GetQueryResultWithPagination(query, 3, bookmark)
+		all = append(all, keys(iterator, err)...)
+		if metadata.Bookmark == "" || metadata.Bookmark == bookmark {
+			break
+		}
+		bookmark = metadata.Bookmark
+	}
+	if strings.Join(all, ",") != "audit-6,audit-5,audit-4,audit-3,audit-2,audit-1,audit-0" {
+		t.Errorf("pages = %v", all)
+	}
+
+	if _, _, err := ledger.NewTransaction(Proposal{}).GetQueryResultWithPagination(query, 3, "garbage"); err == nil {
+		t.Error("invalid bookmark accepted")
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	ledger := New()
+	err := ledger.LoadIndexes("../META-INF/statedb/couchdb/indexes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := ledger.NewTransaction(Proposal{})
+
+	for _, query := range []string{
+		`{"selector": {"userId": "alice"}, "sort": [{"timestamp": "desc"}]}`,
+		`{"selector": {"sessionId": "s1"}, "sort": [{"sessionId": "asc"}, {"timestamp": "asc"}, {"id": "asc"}]}`,
+		`{"selector": {"resourceType": "DOC", "resourceId": "1", "timestamp": {"$lte": 5}},
+		  "sort": [{"resourceType": "asc"}, {"resourceId": "asc"}, {"timestamp": "asc"}, {"id": "asc"}]}`,
+		`{"selector": {"userId": "alice"}}`,
+	} {
+		if _, err := stub.GetQueryResult(query); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+	for _, query := range []string{
+		`{"selector": {"userId": "alice"}, "sort": [{"action": "asc"}]}`,
+		`{"selector": {"resourceId": "1"}, "sort": [{"timestamp": "asc"}, {"resourceId": "asc"}]}`,
+	} {
+		_, err := stub.GetQueryResult(query)
+		if err == nil || !strings.Contains(err.Error(), "no_usable_index") {
+			t.Errorf("%s: err = %v", query, err)
+		}
+	}
+}
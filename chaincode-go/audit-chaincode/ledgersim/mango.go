@@ -0,0 +1,836 @@
+package ledgersim
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ mango.go evaluates CouchDB Mango queries (the JSON GetQueryResult takes) against the state:
+	- parseQuery - Parses and validates a query, unknown operators and options are errors like in CouchDB
+	- query.run  - Matching documents in result order, skip / limit / bookmark applied
+
+Selectors:
+	{"userId": "user-1"}                              implicit $eq, several fields = implicit $and
+	{"holder.name": "x"}, {"holder": {"name": "x"}}   nested fields
+	$eq $ne $gt $gte $lt $lte                         compare in CouchDB collation order:
+	                                                  null < false < true < numbers < strings < arrays < objects
+	$exists $type $in $nin $size $all $mod $regex
+	$elemMatch $allMatch                              on array fields
+	$and $or $nor $not                                combine selectors (at the top or on a field)
+Every operator except $exists (and what $not / $nor negate) needs the field to be present, as in CouchDB.
+Strings collate like CouchDB's ICU collation for the common cases: punctuation < digits < letters,
+case is ignored first, then lowercase sorts before uppercase ("a" < "A" < "aa" < "b").
+
+Sort, index and paging rules:
+	- "sort": [{"timestamp": "desc"}] or ["timestamp"], all fields in one direction, ties by key
+	- Documents without every sort field are left out, CouchDB reads them from an index that skips them
+	- With indexes loaded (Ledger.LoadIndexes) a sorted query needs an index CouchDB could sort with,
+	  otherwise it fails with no_usable_index like on a peer
+	- Bookmarks are opaque, the last page returns the bookmark it was given
+*/
+
+// query is one parsed Mango query
+type query struct {
+	selector   map[string]interface{}
+	match      matcher
+	sort       []string // Field names as written
+	sortPaths  [][]string
+	descending bool
+	limit      int // 0 = no limit
+	skip       int
+	fields     [][]string // Projection, nil = whole document
+}
+
+// queryOptions are the keys CouchDB accepts in a _find request
+var queryOptions = map[string]bool{
+	"selector": true, "sort": true, "limit": true, "skip": true, "fields": true, "use_index": true,
+	"bookmark": true, "execution_stats": true, "r": true, "conflicts": true, "update": true,
+	"stable": true, "stale": true,
+}
+
+/*
+--- PARSE QUERY ---
+Parses a query string, the errors read like the ones a peer returns for the same query
+*/
+func parseQuery(text string) (*query, error) {
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	err := decoder.Decode(&raw)
+	if err != nil || raw == nil {
+		return nil, fmt.Errorf("invalid query, not a JSON object: %s", text)
+	}
+	for option := range raw {
+		if !queryOptions[option] {
+			return nil, fmt.Errorf("invalid query, unknown option %q", option)
+		}
+	}
+
+	selector, ok := raw["selector"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid query, selector must be a JSON object")
+	}
+	q := &query{selector: selector}
+	q.match, err = compileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.parseSort(raw["sort"])
+	if err != nil {
+		return nil, err
+	}
+	q.limit, err = nonNegativeInt(raw, "limit")
+	if err != nil {
+		return nil, err
+	}
+	q.skip, err = nonNegativeInt(raw, "skip")
+	if err != nil {
+		return nil, err
+	}
+
+	if fields, ok := raw["fields"]; ok {
+		list, ok := fields.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid query, fields must be an array of field names")
+		}
+		q.fields = [][]string{}
+		for _, field := range list {
+			name, ok := field.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid query, fields must be an array of field names")
+			}
+			q.fields = append(q.fields, splitPath(name))
+		}
+	}
+	return q, nil
+}
+
+func (q *query) parseSort(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid query, sort must be an array")
+	}
+	for i, entry := range list {
+		name, direction := "", "asc"
+		switch entry := entry.(type) {
+		case string:
+			name = entry
+		case map[string]interface{}:
+			if len(entry) != 1 {
+				return fmt.Errorf("invalid query, sort entry %d must name exactly one field", i)
+			}
+			for field, dir := range entry {
+				name = field
+				direction, _ = dir.(string)
+			}
+		default:
+			return fmt.Errorf("invalid query, sort entry %d must be a field name or {field: direction}", i)
+		}
+		if direction != "asc" && direction != "desc" {
+			return fmt.Errorf("invalid query, sort direction of %s must be asc or desc", name)
+		}
+		descending := direction == "desc"
+		if i > 0 && descending != q.descending {
+			return fmt.Errorf("unsupported_mixed_sort: Sorts currently only support a single direction for all fields")
+		}
+		q.descending = descending
+		q.sort = append(q.sort, name)
+		q.sortPaths = append(q.sortPaths, splitPath(name))
+	}
+	return nil
+}
+
+func nonNegativeInt(raw map[string]interface{}, option string) (int, error) {
+	value, ok := raw[option]
+	if !ok {
+		return 0, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number < 0 || number != math.Trunc(number) {
+		return 0, fmt.Errorf("invalid query, %s must be a non-negative integer", option)
+	}
+	return int(number), nil
+}
+
+// splitPath splits a field name on dots, "\." is a literal dot
+func splitPath(name string) []string {
+	path := []string{}
+	var part strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '\\' && i+1 < len(name) && name[i+1] == '.':
+			part.WriteByte('.')
+			i++
+		case name[i] == '.':
+			path = append(path, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(name[i])
+		}
+	}
+	return append(path, part.String())
+}
+
+/* --- SELECTOR --- */
+
+// matcher reports whether a decoded JSON value matches
+type matcher func(doc interface{}) bool
+
+func matchAll(matchers []matcher) matcher {
+	return func(doc interface{}) bool {
+		for _, m := range matchers {
+			if !m(doc) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// compileSelector compiles a selector object, field paths are relative to the value matched
+func compileSelector(selector map[string]interface{}) (matcher, error) {
+	matchers := []matcher{}
+	for _, key := range sortedKeys(selector) {
+		var m matcher
+		var err error
+		if strings.HasPrefix(key, "$") {
+			m, err = compileOperator(nil, key, selector[key])
+		} else {
+			m, err = compileField(splitPath(key), selector[key])
+		}
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchAll(matchers), nil
+}
+
+// compileField compiles the condition on one field: a literal, an operator object or a nested selector
+func compileField(path []string, condition interface{}) (matcher, error) {
+	object, ok := condition.(map[string]interface{})
+	if !ok {
+		return compileOperator(path, "$eq", condition)
+	}
+
+	matchers := []matcher{}
+	for _, key := range sortedKeys(object) {
+		var m matcher
+		var err error
+		if strings.HasPrefix(key, "$") {
+			m, err = compileOperator(path, key, object[key])
+		} else {
+			m, err = compileField(append(append([]string{}, path...), splitPath(key)...), object[key])
+		}
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchAll(matchers), nil
+}
+
+// compileOperator compiles one operator applied to the value at path (nil path = the value itself)
+func compileOperator(path []string, operator string, arg interface{}) (matcher, error) {
+	// Combinations take selectors, or conditions when used on a field
+	compile := func(element interface{}) (matcher, error) {
+		if path != nil {
+			return compileField(path, element)
+		}
+		selector, ok := element.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, %s takes selectors", operator)
+		}
+		return compileSelector(selector)
+	}
+
+	switch operator {
+	case "$and", "$or", "$nor":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, %s takes an array", operator)
+		}
+		matchers := []matcher{}
+		for _, element := range list {
+			m, err := compile(element)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		}
+		switch operator {
+		case "$and":
+			return matchAll(matchers), nil
+		case "$or":
+			return func(doc interface{}) bool {
+				for _, m := range matchers {
+					if m(doc) {
+						return true
+					}
+				}
+				return len(matchers) == 0
+			}, nil
+		default:
+			return func(doc interface{}) bool {
+				for _, m := range matchers {
+					if m(doc) {
+						return false
+					}
+				}
+				return true
+			}, nil
+		}
+
+	case "$not":
+		m, err := compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(doc interface{}) bool { return !m(doc) }, nil
+
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, $exists takes a boolean")
+		}
+		return func(doc interface{}) bool {
+			_, found := lookup(doc, path)
+			return found == want
+		}, nil
+	}
+
+	test, err := compileCondition(operator, arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc interface{}) bool {
+		value, found := lookup(doc, path)
+		return found && test(value)
+	}, nil
+}
+
+// compileCondition compiles the operators that test a present value
+func compileCondition(operator string, arg interface{}) (func(interface{}) bool, error) {
+	switch operator {
+	case "$eq":
+		return func(v interface{}) bool { return collate(v, arg) == 0 }, nil
+	case "$ne":
+		return func(v interface{}) bool { return collate(v, arg) != 0 }, nil
+	case "$gt":
+		return func(v interface{}) bool { return collate(v, arg) > 0 }, nil
+	case "$gte":
+		return func(v interface{}) bool { return collate(v, arg) >= 0 }, nil
+	case "$lt":
+		return func(v interface{}) bool { return collate(v, arg) < 0 }, nil
+	case "$lte":
+		return func(v interface{}) bool { return collate(v, arg) <= 0 }, nil
+
+	case "$in", "$nin":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, %s takes an array", operator)
+		}
+		in := func(v interface{}) bool {
+			// An array field is in the list when one of its elements is
+			candidates := []interface{}{v}
+			if array, ok := v.([]interface{}); ok {
+				candidates = array
+			}
+			for _, candidate := range candidates {
+				for _, element := range list {
+					if collate(candidate, element) == 0 {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		if operator == "$nin" {
+			return func(v interface{}) bool { return !in(v) }, nil
+		}
+		return in, nil
+
+	case "$type":
+		name, ok := arg.(string)
+		if !ok || !map[string]bool{"null": true, "boolean": true, "number": true, "string": true, "array": true, "object": true}[name] {
+			return nil, fmt.Errorf("invalid operator argument, $type takes null, boolean, number, string, array or object")
+		}
+		return func(v interface{}) bool { return typeName(v) == name }, nil
+
+	case "$size":
+		size, ok := arg.(float64)
+		if !ok || size != math.Trunc(size) {
+			return nil, fmt.Errorf("invalid operator argument, $size takes an integer")
+		}
+		return func(v interface{}) bool {
+			array, ok := v.([]interface{})
+			return ok && float64(len(array)) == size
+		}, nil
+
+	case "$all":
+		list, ok := arg.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, $all takes an array")
+		}
+		return func(v interface{}) bool {
+			array, ok := v.([]interface{})
+			if !ok {
+				return false
+			}
+			for _, want := range list {
+				found := false
+				for _, element := range array {
+					if collate(element, want) == 0 {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return false
+				}
+			}
+			return true
+		}, nil
+
+	case "$mod":
+		list, ok := arg.([]interface{})
+		if !ok || len(list) != 2 {
+			return nil, fmt.Errorf("invalid operator argument, $mod takes [divisor, remainder]")
+		}
+		divisor, ok1 := list[0].(float64)
+		remainder, ok2 := list[1].(float64)
+		if !ok1 || !ok2 || divisor == 0 || divisor != math.Trunc(divisor) || remainder != math.Trunc(remainder) {
+			return nil, fmt.Errorf("invalid operator argument, $mod takes a non-zero integer divisor and an integer remainder")
+		}
+		return func(v interface{}) bool {
+			number, ok := v.(float64)
+			return ok && number == math.Trunc(number) && math.Mod(number, divisor) == remainder
+		}, nil
+
+	case "$regex":
+		pattern, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, $regex takes a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid operator argument, $regex: %v", err)
+		}
+		return func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && re.MatchString(s)
+		}, nil
+
+	case "$elemMatch", "$allMatch":
+		selector, ok := arg.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid operator argument, %s takes a selector", operator)
+		}
+		m, err := compileSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		all := operator == "$allMatch"
+		return func(v interface{}) bool {
+			array, ok := v.([]interface{})
+			if !ok || len(array) == 0 {
+				return false
+			}
+			for _, element := range array {
+				if m(element) != all {
+					return !all
+				}
+			}
+			return all
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid operator: %s", operator)
+}
+
+// lookup finds the value at path in doc, descending through objects
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	value := doc
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/* --- COLLATION --- */
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func typeRank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// collate compares two decoded JSON values in CouchDB view collation order
+func collate(a, b interface{}) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return compareInts(ra, rb)
+	}
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return collateStrings(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := collate(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(a), len(b))
+	case map[string]interface{}:
+		b := b.(map[string]interface{})
+		keysA, keysB := sortedKeys(a), sortedKeys(b)
+		for i := 0; i < len(keysA) && i < len(keysB); i++ {
+			if c := collateStrings(keysA[i], keysB[i]); c != 0 {
+				return c
+			}
+			if c := collate(a[keysA[i]], b[keysB[i]]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(keysA), len(keysB))
+	}
+	return 0
+}
+
+// collateStrings orders strings ignoring case first, then lowercase before uppercase
+func collateStrings(a, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+	for i := 0; i < len(runesA) && i < len(runesB); i++ {
+		classA, weightA := primaryWeight(runesA[i])
+		classB, weightB := primaryWeight(runesB[i])
+		if classA != classB {
+			return compareInts(classA, classB)
+		}
+		if weightA != weightB {
+			return compareInts(int(weightA), int(weightB))
+		}
+	}
+	if len(runesA) != len(runesB) {
+		return compareInts(len(runesA), len(runesB))
+	}
+	for i := range runesA {
+		if runesA[i] != runesB[i] {
+			if unicode.IsLower(runesA[i]) {
+				return -1
+			}
+			if unicode.IsLower(runesB[i]) {
+				return 1
+			}
+			return compareInts(int(runesA[i]), int(runesB[i]))
+		}
+	}
+	return 0
+}
+
+func primaryWeight(r rune) (int, rune) {
+	switch {
+	case unicode.IsLetter(r):
+		return 2, unicode.ToLower(r)
+	case unicode.IsDigit(r):
+		return 1, r
+	}
+	return 0, r
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+/* --- INDEXES --- */
+
+// sortable reports whether CouchDB could serve the query's sort from one of the indexes
+func (q *query) sortable(indexes []Index) bool {
+	if len(q.sort) == 0 {
+		return true
+	}
+	required, equal := selectorFields(q.selector)
+	for _, index := range indexes {
+		usable := true
+		for _, column := range index.Fields {
+			if !required[column] && !contains(q.sort, column) {
+				usable = false
+				break
+			}
+		}
+		if usable && canUseSort(index.Fields, q.sort, equal) {
+			return true
+		}
+	}
+	return false
+}
+
+// canUseSort: the sort fields are a prefix of the index columns, once leading columns fixed by $eq are dropped
+func canUseSort(columns []string, sortFields []string, equal map[string]bool) bool {
+	for len(columns) >= len(sortFields) {
+		prefix := true
+		for i, field := range sortFields {
+			if columns[i] != field {
+				prefix = false
+				break
+			}
+		}
+		if prefix {
+			return true
+		}
+		if !equal[columns[0]] {
+			return false
+		}
+		columns = columns[1:]
+	}
+	return false
+}
+
+// selectorFields lists the fields a selector requires to exist, and those it fixes to one value
+func selectorFields(selector map[string]interface{}) (required map[string]bool, equal map[string]bool) {
+	required, equal = map[string]bool{}, map[string]bool{}
+	var walk func(prefix string, selector map[string]interface{})
+	walk = func(prefix string, selector map[string]interface{}) {
+		for key, condition := range selector {
+			if key == "$and" {
+				list, _ := condition.([]interface{})
+				for _, element := range list {
+					if nested, ok := element.(map[string]interface{}); ok {
+						walk(prefix, nested)
+					}
+				}
+				continue
+			}
+			if strings.HasPrefix(key, "$") {
+				continue
+			}
+
+			name := prefix + key
+			object, ok := condition.(map[string]interface{})
+			if !ok {
+				required[name], equal[name] = true, true
+				continue
+			}
+			operators := false
+			for op := range object {
+				operators = operators || strings.HasPrefix(op, "$")
+			}
+			if !operators {
+				walk(name+".", object)
+				continue
+			}
+			if exists, ok := object["$exists"].(bool); ok && !exists {
+				continue
+			}
+			required[name] = true
+			if _, ok := object["$eq"]; ok {
+				equal[name] = true
+			}
+		}
+	}
+	walk("", selector)
+	return required, equal
+}
+
+func contains(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+	return false
+}
+
+/* --- RUN --- */
+
+// document is one state entry a query can see
+type document struct {
+	key   string
+	value []byte
+	doc   map[string]interface{}
+	at    []interface{} // Sort values then key, the position bookmarks encode
+}
+
+/*
+--- RUN QUERY ---
+Matches docs (sorted by key), returns the page in result order and the bookmark after it
+- pageSize 0 = every remaining match (GetQueryResult), the query's limit applies when smaller
+*/
+func (q *query) run(docs []*document, pageSize int, bookmark string) ([]*document, string, error) {
+	var after []interface{}
+	if bookmark != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(bookmark)
+		if err == nil {
+			err = json.Unmarshal(decoded, &after)
+		}
+		if err != nil || len(after) != len(q.sort)+1 {
+			return nil, "", fmt.Errorf("invalid_bookmark: Invalid bookmark value: %s", bookmark)
+		}
+	}
+
+	matches := []*document{}
+	for _, d := range docs {
+		if !q.match(d.doc) {
+			continue
+		}
+		position := []interface{}{}
+		present := true
+		for _, path := range q.sortPaths {
+			value, found := lookup(d.doc, path)
+			present = present && found
+			position = append(position, value)
+		}
+		if !present {
+			continue
+		}
+		d.at = append(position, d.key)
+		if after != nil && q.compare(d.at, after) <= 0 {
+			continue
+		}
+		matches = append(matches, d)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return q.compare(matches[i].at, matches[j].at) < 0 })
+
+	if q.skip >= len(matches) {
+		matches = nil
+	} else {
+		matches = matches[q.skip:]
+	}
+	limit := q.limit
+	if pageSize > 0 {
+		limit = pageSize
+	}
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	if len(matches) == 0 {
+		return matches, bookmark, nil
+	}
+	encoded, err := json.Marshal(matches[len(matches)-1].at)
+	if err != nil {
+		return nil, "", err
+	}
+	return matches, base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// compare orders two result positions: sort values in collation order, then keys
+func (q *query) compare(a, b []interface{}) int {
+	c := 0
+	for i := 0; i < len(a)-1 && c == 0; i++ {
+		c = collate(a[i], b[i])
+	}
+	if c == 0 {
+		keyA, _ := a[len(a)-1].(string)
+		keyB, _ := b[len(b)-1].(string)
+		c = strings.Compare(keyA, keyB)
+	}
+	if q.descending {
+		return -c
+	}
+	return c
+}
+
+// project returns the value of a result, only the selected fields when the query has fields
+func (q *query) project(d *document) ([]byte, error) {
+	if q.fields == nil {
+		return d.value, nil
+	}
+	projected := map[string]interface{}{}
+	for _, path := range q.fields {
+		value, found := lookup(d.doc, path)
+		if !found {
+			continue
+		}
+		target := projected
+		for _, name := range path[:len(path)-1] {
+			next, ok := target[name].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				target[name] = next
+			}
+			target = next
+		}
+		target[path[len(path)-1]] = value
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(projected)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
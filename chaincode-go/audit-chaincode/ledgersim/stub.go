@@ -0,0 +1,519 @@
+package ledgersim
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ stub.go is the shim.ChaincodeStubInterface of one simulated transaction:
+	- State         - GetState, PutState, DelState, state validation parameters
+	- Range queries - GetStateByRange, GetStateByPartialCompositeKey (+ WithPagination)
+	- Rich queries  - GetQueryResult, GetQueryResultWithPagination (mango.go)
+	- History       - GetHistoryForKey, committed modifications newest first
+	- Proposal      - Args, TxID, channel, creator, transient data, timestamp, binding, SetEvent
+	- Context       - A contractapi transaction context over the stub, to call contract functions directly
+
+Checks a peer makes are made here too, so a test fails where the network would:
+	- Simple keys may not start with 0x00 (the composite key namespace), nor "_" (reserved by CouchDB),
+	  and must be valid UTF-8. Top level JSON fields may not start with "_" either
+	- Paginated queries and writes cannot be mixed in one transaction
+	- Composite key attributes may not contain U+0000 or U+10FFFF (shim.CreateCompositeKey)
+
+Not simulated, these return an error: private data collections, InvokeChaincode, GetSignedProposal.
+*/
+
+// Stub is one transaction against a Ledger, it implements shim.ChaincodeStubInterface
+type Stub struct {
+	ledger    *Ledger
+	txID      string
+	nonce     []byte
+	args      [][]byte
+	creator   []byte
+	transient map[string][]byte
+	timestamp *timestamppb.Timestamp
+
+	writes    map[string]*write
+	metadata  map[string][]byte
+	event     *Event
+	paginated bool // A paginated query ran, writes are refused
+	committed bool
+}
+
+// write is one pending state change
+type write struct {
+	value   []byte
+	deleted bool
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
+
+// errNotSimulated is returned by the parts of the stub ledgersim does not simulate
+var errNotSimulated = errors.New("not supported by ledgersim")
+
+/*
+--- CONTEXT ---
+Returns a transaction context over the stub, as contractapi builds it for a contract function
+- the client identity is nil when the proposal had none (contractapi does the same)
+*/
+func (s *Stub) Context() *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(s)
+	clientIdentity, _ := cid.New(s)
+	ctx.SetClientIdentity(clientIdentity)
+	return ctx
+}
+
+// Commit applies the transaction's writes and event to the ledger, once
+func (s *Stub) Commit() error {
+	if s.committed {
+		return fmt.Errorf("transaction %s is already committed", s.txID)
+	}
+	s.committed = true
+	s.ledger.commit(s)
+	return nil
+}
+
+/* --- PROPOSAL --- */
+
+// GetArgs returns the arguments, function name first
+func (s *Stub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the arguments as strings
+func (s *Stub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+// GetFunctionAndParameters splits the arguments into the function name and its parameters
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// GetArgsSlice returns the arguments concatenated
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	slice := []byte{}
+	for _, arg := range s.args {
+		slice = append(slice, arg...)
+	}
+	return slice, nil
+}
+
+// GetTxID returns the transaction ID
+func (s *Stub) GetTxID() string {
+	return s.txID
+}
+
+// GetChannelID returns DefaultChannel
+func (s *Stub) GetChannelID() string {
+	return DefaultChannel
+}
+
+// GetCreator returns the serialized identity of the proposal, an error if it had none
+func (s *Stub) GetCreator() ([]byte, error) {
+	if s.creator == nil {
+		return nil, errors.New("proposal has no creator")
+	}
+	return s.creator, nil
+}
+
+// GetTransient returns the transient data of the proposal
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetBinding returns SHA-256(nonce || creator || epoch), epoch is always 0
+func (s *Stub) GetBinding() ([]byte, error) {
+	h := sha256.New()
+	h.Write(s.nonce)
+	h.Write(s.creator)
+	h.Write(make([]byte, 8))
+	return h.Sum(nil), nil
+}
+
+// GetDecorations returns no decorations, there are no peer handlers
+func (s *Stub) GetDecorations() map[string][]byte {
+	return map[string][]byte{}
+}
+
+// GetSignedProposal is not simulated
+func (s *Stub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return nil, errNotSimulated
+}
+
+// GetTxTimestamp returns the timestamp the ledger clock gave the transaction
+func (s *Stub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return s.timestamp, nil
+}
+
+// SetEvent sets the event of the transaction, replacing an earlier one
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+	s.event = &Event{TxID: s.txID, Name: name, Payload: payload}
+	return nil
+}
+
+// InvokeChaincode is not simulated
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) *peer.Response {
+	return shim.Error(fmt.Sprintf("InvokeChaincode %s: %v", chaincodeName, errNotSimulated))
+}
+
+/* --- STATE --- */
+
+// GetState returns the committed value of key, nil if absent
+func (s *Stub) GetState(key string) ([]byte, error) {
+	return s.ledger.State(key), nil
+}
+
+// PutState writes key when the transaction commits, an empty value deletes it as on a peer
+func (s *Stub) PutState(key string, value []byte) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	err := s.checkWrite(key)
+	if err != nil {
+		return err
+	}
+	err = validateValue(key, value)
+	if err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		s.writes[key] = &write{deleted: true}
+		return nil
+	}
+	s.writes[key] = &write{value: append([]byte{}, value...)}
+	return nil
+}
+
+// DelState deletes key when the transaction commits
+func (s *Stub) DelState(key string) error {
+	err := s.checkWrite(key)
+	if err != nil {
+		return err
+	}
+	s.writes[key] = &write{deleted: true}
+	return nil
+}
+
+// SetStateValidationParameter sets the key level endorsement policy of key when the transaction commits
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	err := s.checkWrite(key)
+	if err != nil {
+		return err
+	}
+	s.metadata[key] = ep
+	return nil
+}
+
+// GetStateValidationParameter returns the committed key level endorsement policy of key
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	s.ledger.mu.Lock()
+	defer s.ledger.mu.Unlock()
+	return s.ledger.metadata[key], nil
+}
+
+// checkWrite applies the peer's key rules (CouchDB state database) and the pagination rule
+func (s *Stub) checkWrite(key string) error {
+	if s.paginated {
+		return fmt.Errorf("txid [%s]: unsupported transaction. Writes are not allowed after a paginated query", s.txID)
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("invalid key [%x], must be a UTF-8 string", key)
+	}
+	if strings.HasPrefix(key, "_") {
+		return fmt.Errorf("invalid key [%s], cannot begin with \"_\"", key)
+	}
+	return nil
+}
+
+// validateValue refuses JSON objects with fields CouchDB reserves
+func validateValue(key string, value []byte) error {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(value, &doc) != nil {
+		return nil // Not a JSON object, stored as an attachment on a peer
+	}
+	for field := range doc {
+		if strings.HasPrefix(field, "_") || field == "~version" {
+			return fmt.Errorf("invalid value for key [%s], field [%s] is not valid for the CouchDB state database", key, field)
+		}
+	}
+	return nil
+}
+
+// checkPaginated marks the transaction as paginated, refused once it wrote
+func (s *Stub) checkPaginated() error {
+	if len(s.writes) > 0 || len(s.metadata) > 0 {
+		return fmt.Errorf("txid [%s]: unsupported transaction. Paginated queries are not allowed after writes", s.txID)
+	}
+	s.paginated = true
+	return nil
+}
+
+/* --- RANGE QUERIES --- */
+
+// GetStateByRange returns the simple keys with startKey <= key < endKey ("" = open ended)
+func (s *Stub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	startKey, err := rangeStart(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{results: s.ledger.rangeKeys(startKey, endKey)}, nil
+}
+
+// GetStateByRangeWithPagination returns one page of GetStateByRange, the bookmark is the next key
+func (s *Stub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+
+	startKey, err := rangeStart(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.rangePage(startKey, endKey, pageSize, bookmark)
+}
+
+// GetStateByPartialCompositeKey returns the composite keys that start with objectType and keys
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, endKey, err := compositeRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{results: s.ledger.rangeKeys(startKey, endKey)}, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination returns one page of GetStateByPartialCompositeKey
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string,
+	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+
+	startKey, endKey, err := compositeRange(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.rangePage(startKey, endKey, pageSize, bookmark)
+}
+
+// rangePage reads pageSize entries from the bookmark (or startKey), the next bookmark is "" at the end
+func (s *Stub) rangePage(startKey, endKey string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("pageSize must be greater than 0, got %d", pageSize)
+	}
+	err := s.checkPaginated()
+	if err != nil {
+		return nil, nil, err
+	}
+	if bookmark != "" {
+		if bookmark < startKey || (endKey != "" && bookmark >= endKey) {
+			return nil, nil, fmt.Errorf("bookmark [%s] is out of the range of the query", bookmark)
+		}
+		startKey = bookmark
+	}
+
+	results := s.ledger.rangeKeys(startKey, endKey)
+	next := ""
+	if len(results) > int(pageSize) {
+		next = results[pageSize].Key
+		results = results[:pageSize]
+	}
+	metadata := &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(results)), Bookmark: next}
+	return &stateIterator{results: results}, metadata, nil
+}
+
+// rangeStart applies the shim's rules to a simple key range
+func rangeStart(startKey, endKey string) (string, error) {
+	if startKey == "" {
+		startKey = "\x01" // Skips the composite key namespace
+	}
+	for _, key := range []string{startKey, endKey} {
+		if len(key) > 0 && key[0] == 0x00 {
+			return "", fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+		}
+	}
+	return startKey, nil
+}
+
+// compositeRange is the key range of a partial composite key, as the shim builds it
+func compositeRange(objectType string, keys []string) (string, string, error) {
+	partialKey, err := shim.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return "", "", err
+	}
+	return partialKey, partialKey + string(utf8.MaxRune), nil
+}
+
+// CreateCompositeKey joins objectType and attributes into a composite key (shim.CreateCompositeKey)
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+// SplitCompositeKey splits a composite key into its object type and attributes
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if len(compositeKey) < 2 || compositeKey[0] != 0x00 || compositeKey[len(compositeKey)-1] != 0x00 {
+		return "", nil, fmt.Errorf("invalid composite key %q", compositeKey)
+	}
+	components := strings.Split(compositeKey[1:len(compositeKey)-1], "\x00")
+	return components[0], components[1:], nil
+}
+
+/* --- RICH QUERIES --- */
+
+// GetQueryResult runs a CouchDB Mango query over the committed state (mango.go)
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	results, _, err := s.ledger.richQuery(query, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{results: results}, nil
+}
+
+// GetQueryResultWithPagination returns one page of a Mango query, pageSize replaces the query's limit
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("pageSize must be greater than 0, got %d", pageSize)
+	}
+	err := s.checkPaginated()
+	if err != nil {
+		return nil, nil, err
+	}
+	results, next, err := s.ledger.richQuery(query, int(pageSize), bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(results)), Bookmark: next}
+	return &stateIterator{results: results}, metadata, nil
+}
+
+/* --- HISTORY --- */
+
+// GetHistoryForKey returns the committed modifications of key, newest first
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{results: s.ledger.keyHistory(key)}, nil
+}
+
+/* --- PRIVATE DATA (not simulated) --- */
+
+// GetPrivateData is not simulated
+func (s *Stub) GetPrivateData(collection, key string) ([]byte, error) {
+	return nil, errNotSimulated
+}
+
+// GetPrivateDataHash is not simulated
+func (s *Stub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	return nil, errNotSimulated
+}
+
+// PutPrivateData is not simulated
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	return errNotSimulated
+}
+
+// DelPrivateData is not simulated
+func (s *Stub) DelPrivateData(collection, key string) error {
+	return errNotSimulated
+}
+
+// PurgePrivateData is not simulated
+func (s *Stub) PurgePrivateData(collection, key string) error {
+	return errNotSimulated
+}
+
+// SetPrivateDataValidationParameter is not simulated
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return errNotSimulated
+}
+
+// GetPrivateDataValidationParameter is not simulated
+func (s *Stub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return nil, errNotSimulated
+}
+
+// GetPrivateDataByRange is not simulated
+func (s *Stub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errNotSimulated
+}
+
+// GetPrivateDataByPartialCompositeKey is not simulated
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errNotSimulated
+}
+
+// GetPrivateDataQueryResult is not simulated
+func (s *Stub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errNotSimulated
+}
+
+/* --- ITERATORS --- */
+
+// stateIterator iterates over a fixed result set
+type stateIterator struct {
+	results []*queryresult.KV
+	next    int
+	closed  bool
+}
+
+func (it *stateIterator) HasNext() bool {
+	return !it.closed && it.next < len(it.results)
+}
+
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errors.New("no more results in the iterator")
+	}
+	it.next++
+	return it.results[it.next-1], nil
+}
+
+func (it *stateIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// historyIterator iterates over the modifications of one key
+type historyIterator struct {
+	results []*queryresult.KeyModification
+	next    int
+	closed  bool
+}
+
+func (it *historyIterator) HasNext() bool {
+	return !it.closed && it.next < len(it.results)
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, errors.New("no more results in the iterator")
+	}
+	it.next++
+	return it.results[it.next-1], nil
+}
+
+func (it *historyIterator) Close() error {
+	it.closed = true
+	return nil
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/operations"
 
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
 
@@ -13,16 +16,26 @@ import (
 
 main.go serves as the main entry point doing the following:
 	1. Creates a new chaincode instance
-	2. Registers your contracts (AuditContract + UserContract)
+	2. Registers your contracts (AuditContract + UserContract + RegistryContract + CheckpointContract),
+	   chaincode.Contracts() installs the transaction middleware on each (chaincode/middleware.go)
 	3. Starts the chaincode server
 	4. Listens for transactions from peers
 
- auditChaincode.Start():
-		1. Opens gRPC server on port 9999
-		2. Waits for peer connections
-		3. Handles transaction requests
-		4. Runs until stopped
-		Flow: Peer -> gRPC call -> main.go (Start) -> Routes to AuditContract/UserContract -> Returns result
+ Chaincode-as-a-Service (CHAINCODE_SERVER_ADDRESS + CHAINCODE_ID set, see server.go):
+		1. Validates the address, package ID and TLS files, exits on any error
+		2. Opens gRPC server on CHAINCODE_SERVER_ADDRESS (port 9999 in deployCCAAS.sh)
+		3. Waits for peer connections (TLS / mutual TLS when configured)
+		4. Handles transaction requests until stopped
+		Flow: Peer -> gRPC call -> main.go (ChaincodeServer) -> Routes to AuditContract/UserContract/RegistryContract/CheckpointContract -> Returns result
+
+ shim.Start() (classic mode, neither variable set):
+		The peer launches the binary and the chaincode dials the peer
+
+ CHAINCODE_LOG_LEVEL / CHAINCODE_LOG_FORMAT:
+		log/slog level and json / text output, personal data redacted unless debug (chaincode/logging.go)
+
+ CHAINCODE_OPERATIONS_ADDRESS (optional, either mode):
+		HTTP /healthz, /readyz and /metrics, every transaction measured (operations package)
 
 
 Go rules fo executable programs:
@@ -32,18 +45,61 @@ Go rules fo executable programs:
 */
 
 func main() {
-	// Create new chaincode with both contracts
-	auditChaincode, err := contractapi.NewChaincode(
-		&chaincode.AuditContract{},
-		&chaincode.UserContract{},
-	)
+	// Structured logging first, so every later line (including errors below) has the same shape
+	if err := chaincode.ConfigureLogging(os.Getenv); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// Create new chaincode with all contracts
+	auditChaincode, err := contractapi.NewChaincode(chaincode.Contracts()...)
 	if err != nil {
 		log.Panicf("Error creating audit trail chaincode: %v", err)
 	}
 
-	// Start chaincode server
-	// This listens for transactions from Fabric peers
-	if err := auditChaincode.Start(); err != nil {
-		log.Panicf("Error starting audit trail chaincode: %v", err)
+	config, err := loadServerConfig(os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid chaincode server configuration: %v", err)
+	}
+	operationsAddress, err := loadOperationsAddress(os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid chaincode server configuration: %v", err)
+	}
+
+	// Panics become errors, every error leaves as the JSON envelope (chaincode/middleware.go)
+	var cc shim.Chaincode = chaincode.Guard(auditChaincode, auditChaincode.DefaultContract)
+
+	// Optional operations listener: every transaction is measured for /metrics
+	if operationsAddress != "" {
+		metrics := operations.NewMetrics()
+		cc = operations.Instrument(cc, auditChaincode.DefaultContract, metrics)
+		ops := operations.NewServer(operationsAddress, metrics)
+		if config != nil {
+			ops.AddReadinessCheck("chaincode-server", operations.TCPCheck(config.Address))
+		}
+		if err := ops.Start(); err != nil {
+			log.Fatalf("Error starting operations server: %v", err)
+		}
+		defer ops.Close()
+	}
+
+	// Classic mode: the peer launched us, dial it
+	if config == nil {
+		log.Printf("Starting audit trail chaincode in classic mode")
+		if err := shim.Start(cc); err != nil {
+			log.Panicf("Error starting audit trail chaincode: %v", err)
+		}
+		return
+	}
+
+	// Chaincode-as-a-Service: listen for transactions from Fabric peers
+	server := &shim.ChaincodeServer{
+		CCID:     config.CCID,
+		Address:  config.Address,
+		CC:       cc,
+		TLSProps: config.TLS,
+	}
+	log.Printf("Starting audit trail chaincode server ccid=%s address=%s (%s)", config.CCID, config.Address, config.mode())
+	if err := server.Start(); err != nil {
+		log.Panicf("Error starting audit trail chaincode server: %v", err)
 	}
 }
\ No newline at end of file
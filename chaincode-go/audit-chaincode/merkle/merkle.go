@@ -0,0 +1,135 @@
+// Package merkle builds RFC 6962 (Certificate Transparency) style Merkle trees over
+// audit entry hashes and verifies inclusion proofs against a published root.
+//
+// It has no Fabric dependencies so external verifiers can use it without ledger access.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Hashing (RFC 6962 section 2.1), domain separated so a leaf can never pass as a node:
+	LeafHash(d)    = SHA-256(0x00 || d)
+	NodeHash(l, r) = SHA-256(0x01 || l || r)
+	Root of 0 leaves = SHA-256("")
+
+Trees are built over leaf HASHES, in the order given:
+	MTH(D[n]) = NodeHash(MTH(D[0:k]), MTH(D[k:n])), k = largest power of two < n
+	No padding or duplicated last leaf, so any tree size has exactly one root.
+
+Proof returns the audit path (siblings, bottom up), VerifyInclusion recomputes the root
+from a leaf hash, its index, the tree size and that path (RFC 9162 section 2.1.3.2).
+*/
+
+// HashSize is the length of every hash in this package
+const HashSize = sha256.Size
+
+// Domain separation prefixes
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// LeafHash hashes the data of one leaf
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// NodeHash hashes two child hashes into their parent
+func NodeHash(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// EmptyRoot is the root of a tree with no leaves
+func EmptyRoot() []byte {
+	sum := sha256.Sum256(nil)
+	return sum[:]
+}
+
+// Root computes the tree root over leaf hashes
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return EmptyRoot()
+	}
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return NodeHash(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// Proof returns the audit path for the leaf at index, siblings ordered bottom up
+func Proof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf index %d out of range for tree size %d", index, len(leaves))
+	}
+	return path(leaves, index), nil
+}
+
+func path(leaves [][]byte, index int) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+	k := splitPoint(len(leaves))
+	if index < k {
+		return append(path(leaves[:k], index), Root(leaves[k:]))
+	}
+	return append(path(leaves[k:], index-k), Root(leaves[:k]))
+}
+
+// VerifyInclusion checks that leafHash sits at index in a tree of treeSize leaves with the given root
+func VerifyInclusion(leafHash []byte, index int64, treeSize int64, proof [][]byte, root []byte) error {
+	if index < 0 || index >= treeSize {
+		return fmt.Errorf("leaf index %d out of range for tree size %d", index, treeSize)
+	}
+
+	fn, sn := index, treeSize-1
+	hash := leafHash
+	for _, sibling := range proof {
+		if len(sibling) != HashSize {
+			return fmt.Errorf("proof hash has length %d, want %d", len(sibling), HashSize)
+		}
+		if sn == 0 {
+			return fmt.Errorf("proof is longer than the tree is deep")
+		}
+		if fn%2 == 1 || fn == sn {
+			hash = NodeHash(sibling, hash)
+			// Skip levels where the node is the right-most child without a sibling
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = NodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return fmt.Errorf("proof is shorter than the tree is deep")
+	}
+	if !bytes.Equal(hash, root) {
+		return fmt.Errorf("computed root does not match the expected root")
+	}
+	return nil
+}
+
+// splitPoint returns the largest power of two smaller than n (n > 1)
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
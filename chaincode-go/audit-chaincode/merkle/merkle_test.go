@@ -0,0 +1,121 @@
+package merkle_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/merkle"
+)
+
+// Leaf inputs and roots of the RFC 6962 reference test vectors (certificate-transparency)
+var (
+	vectorLeaves = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	vectorRoots  = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func vectorHashes(t *testing.T, n int) [][]byte {
+	t.Helper()
+	hashes := [][]byte{}
+	for _, leaf := range vectorLeaves[:n] {
+		data, err := hex.DecodeString(leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, merkle.LeafHash(data))
+	}
+	return hashes
+}
+
+func TestRoot(t *testing.T) {
+	if got := hex.EncodeToString(merkle.Root(nil)); got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("empty root = %s", got)
+	}
+	for size, want := range vectorRoots {
+		if got := hex.EncodeToString(merkle.Root(vectorHashes(t, size+1))); got != want {
+			t.Errorf("root of %d leaves = %s, want %s", size+1, got, want)
+		}
+	}
+}
+
+func TestProof(t *testing.T) {
+	leaves := vectorHashes(t, 8)
+	proof, err := merkle.Proof(leaves, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}
+	if len(proof) != len(want) {
+		t.Fatalf("proof of leaf 0 has %d hashes, want %d", len(proof), len(want))
+	}
+	for i, hash := range proof {
+		if got := hex.EncodeToString(hash); got != want[i] {
+			t.Errorf("proof[%d] = %s, want %s", i, got, want[i])
+		}
+	}
+
+	if _, err := merkle.Proof(leaves, 8); err == nil {
+		t.Error("Proof(8) of 8 leaves succeeded")
+	}
+	if _, err := merkle.Proof(leaves, -1); err == nil {
+		t.Error("Proof(-1) succeeded")
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	// Every leaf of every tree size verifies, including the unbalanced ones
+	for size := 1; size <= len(vectorLeaves); size++ {
+		leaves := vectorHashes(t, size)
+		root := merkle.Root(leaves)
+		for index := range leaves {
+			proof, err := merkle.Proof(leaves, index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := merkle.VerifyInclusion(leaves[index], int64(index), int64(size), proof, root); err != nil {
+				t.Errorf("leaf %d of %d: %v", index, size, err)
+			}
+		}
+	}
+
+	leaves := vectorHashes(t, 7)
+	root := merkle.Root(leaves)
+	proof, _ := merkle.Proof(leaves, 4)
+	tampered := append([]byte{}, leaves[4]...)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name     string
+		leaf     []byte
+		index    int64
+		treeSize int64
+		proof    [][]byte
+	}{
+		{"tampered leaf", tampered, 4, 7, proof},
+		{"wrong index", leaves[4], 5, 7, proof},
+		{"wrong tree size", leaves[4], 4, 6, proof},
+		{"index out of range", leaves[4], 7, 7, proof},
+		{"negative index", leaves[4], -1, 7, proof},
+		{"short proof", leaves[4], 4, 7, proof[:len(proof)-1]},
+		{"long proof", leaves[4], 4, 7, append(append([][]byte{}, proof...), leaves[0])},
+		{"short sibling", leaves[4], 4, 7, [][]byte{proof[0][:16], proof[1], proof[2]}},
+		{"leaf hash as root", leaves[4], 4, 7, [][]byte{}},
+	}
+	for _, tt := range tests {
+		if err := merkle.VerifyInclusion(tt.leaf, tt.index, tt.treeSize, tt.proof, root); err == nil {
+			t.Errorf("%s: verified", tt.name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package mirror
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+)
+
+// Source is the part of gateway.Client the indexer uses
+type Source interface {
+	ChaincodeEvents(ctx context.Context, startBlock uint64, afterTxID string, handle func(gateway.ChaincodeEvent) error) error
+}
+
+// Indexer replays chaincode events into a Store, resuming from the Store's checkpoint
+type Indexer struct {
+	Source     Source
+	Store      *Store
+	StartBlock uint64        // first block to read when the mirror is empty
+	MinBackoff time.Duration // default 1s
+	MaxBackoff time.Duration // default 1m
+}
+
+// Run applies events until ctx is cancelled, reopening the stream with backoff when it fails
+func (ix *Indexer) Run(ctx context.Context) {
+	minBackoff, maxBackoff := ix.MinBackoff, ix.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = time.Second
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = max(time.Minute, minBackoff)
+	}
+
+	delay := minBackoff
+	for ctx.Err() == nil {
+		startBlock, afterTxID := ix.StartBlock, ""
+		block, txID, ok, err := ix.Store.Checkpoint()
+		if err == nil {
+			if ok {
+				startBlock, afterTxID = block, txID
+			}
+			log.Printf("[Indexer] listening from block=%d after txId=%s", startBlock, afterTxID)
+			err = ix.Source.ChaincodeEvents(ctx, startBlock, afterTxID, func(event gateway.ChaincodeEvent) error {
+				err := ix.Store.Apply(event)
+				if err == nil {
+					delay = minBackoff
+				}
+				return err
+			})
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[Indexer] ERROR %v, retrying in %s", err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+		delay = min(delay*2, maxBackoff)
+	}
+}
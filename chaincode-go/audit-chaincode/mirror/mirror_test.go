@@ -0,0 +1,403 @@
+package mirror_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/mirror"
+)
+
+// fakeLedger serves QueryAuditsPage, ListUsers and TransactionBlock from memory, bookmarks are offsets
+type fakeLedger struct {
+	entries []*chaincode.AuditEntry
+	users   []*chaincode.User
+	blocks  map[string]uint64 // TxID -> block
+}
+
+func (l *fakeLedger) TransactionBlock(ctx context.Context, txID string) (uint64, error) {
+	block, ok := l.blocks[txID]
+	if !ok {
+		return 0, fmt.Errorf("transaction %s not found", txID)
+	}
+	return block, nil
+}
+
+func (l *fakeLedger) Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error) {
+	switch fn {
+	case "QueryAuditsPage":
+		pageSize, _ := strconv.Atoi(args[4])
+		offset, _ := strconv.Atoi(args[5])
+		page := &chaincode.AuditQueryResult{Records: []*chaincode.AuditEntry{}}
+		for i := offset; i < len(l.entries) && i < offset+pageSize; i++ {
+			page.Records = append(page.Records, l.entries[i])
+		}
+		page.FetchedRecordsCount = int32(len(page.Records))
+		page.Bookmark = strconv.Itoa(offset + len(page.Records))
+		return json.Marshal(page)
+	case "UserContract:ListUsers":
+		pageSize, _ := strconv.Atoi(args[1])
+		offset, _ := strconv.Atoi(args[2])
+		page := &chaincode.UserQueryResult{Records: []*chaincode.User{}}
+		for i := offset; i < len(l.users) && i < offset+pageSize; i++ {
+			page.Records = append(page.Records, l.users[i])
+		}
+		page.FetchedRecordsCount = int32(len(page.Records))
+		if offset+len(page.Records) < len(l.users) {
+			page.Bookmark = strconv.Itoa(offset + len(page.Records))
+		}
+		return json.Marshal(page)
+	}
+	return nil, fmt.Errorf("unexpected evaluate %s", fn)
+}
+
+func testEntry(id string, userID string, action string, ts int64) *chaincode.AuditEntry {
+	return &chaincode.AuditEntry{
+		ID:            id,
+		TimeStamp:     ts,
+		UserID:        userID,
+		UserRole:      "ADMIN",
+		Action:        action,
+		ResourceType:  "CREDENTIAL",
+		ResourceID:    "cred-" + id,
+		NewValue:      `{"status":"ACTIVE"}`,
+		Status:        "SUCCESS",
+		ComplianceTag: "SOC2",
+		TxID:          "tx-" + id,
+		Changes:       []chaincode.FieldChange{{Op: "add", Path: "/status", Value: `"ACTIVE"`}},
+	}
+}
+
+func testUser(id string, org string, updatedAt int64) *chaincode.User {
+	return &chaincode.User{
+		ID:           id,
+		Username:     id,
+		Email:        id + "@example.com",
+		Role:         "USER",
+		Organization: org,
+		Permissions:  []string{"read"},
+		Active:       true,
+		Status:       chaincode.UserStatusActive,
+		CreatedAt:    updatedAt,
+		UpdatedAt:    updatedAt,
+		CreatedBy:    "user-admin",
+		UpdatedBy:    "user-admin",
+	}
+}
+
+func event(t *testing.T, block uint64, name string, txID string, record any) gateway.ChaincodeEvent {
+	t.Helper()
+	payload, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("failed to marshal event payload: %v", err)
+	}
+	return gateway.ChaincodeEvent{BlockNumber: block, TxID: txID, EventName: name, Payload: payload}
+}
+
+func openStore(t *testing.T) *mirror.Store {
+	t.Helper()
+	store, err := mirror.Open(filepath.Join(t.TempDir(), "mirror.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// seed commits the ledger's users and entries one block each and applies them as events
+func seed(t *testing.T, store *mirror.Store, ledger *fakeLedger) {
+	t.Helper()
+	ledger.blocks = map[string]uint64{}
+	block := uint64(1)
+	for _, user := range ledger.users {
+		err := store.Apply(event(t, block, chaincode.UserEventName, "tx-"+user.ID, user))
+		if err != nil {
+			t.Fatalf("Apply(user %s) error = %v", user.ID, err)
+		}
+		block++
+	}
+	for _, entry := range ledger.entries {
+		ledger.blocks[entry.TxID] = block
+		err := store.Apply(event(t, block, chaincode.AuditEventName, entry.TxID, entry))
+		if err != nil {
+			t.Fatalf("Apply(entry %s) error = %v", entry.ID, err)
+		}
+		block++
+	}
+}
+
+func testLedger() *fakeLedger {
+	return &fakeLedger{
+		entries: []*chaincode.AuditEntry{
+			testEntry("audit-001", "user-alice", "ISSUE", 1000),
+			testEntry("audit-002", "user-alice", "REVOKE", 2000),
+			testEntry("audit-003", "user-bob", "ISSUE", 3000),
+		},
+		users: []*chaincode.User{
+			testUser("user-alice", "Org1MSP", 100),
+			testUser("user-bob", "Org2MSP", 200),
+		},
+	}
+}
+
+func TestApplyAndQuery(t *testing.T) {
+	store := openStore(t)
+	ledger := testLedger()
+	seed(t, store, ledger)
+
+	block, txID, ok, err := store.Checkpoint()
+	if err != nil || !ok || block != 5 || txID != "tx-audit-003" {
+		t.Fatalf("Checkpoint() = %d, %s, %v, %v, want 5 / tx-audit-003", block, txID, ok, err)
+	}
+
+	// Aggregation joined with users, the kind of query CouchDB cannot answer
+	rows, err := store.DB().Query(`SELECT organization, action, COUNT(*) FROM audits_with_users
+		GROUP BY organization, action ORDER BY organization, action`)
+	if err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var org, action string
+		var count int
+		err = rows.Scan(&org, &action, &count)
+		if err != nil {
+			t.Fatalf("scan error = %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %d", org, action, count))
+	}
+	want := []string{"Org1MSP ISSUE 1", "Org1MSP REVOKE 1", "Org2MSP ISSUE 1"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("aggregation = %v, want %v", got, want)
+	}
+
+	// Replaying an event is harmless
+	err = store.Apply(event(t, 5, chaincode.AuditEventName, "tx-audit-003", ledger.entries[2]))
+	if err != nil {
+		t.Fatalf("Apply(replay) error = %v", err)
+	}
+	var count int
+	store.DB().QueryRow(`SELECT COUNT(*) FROM audits`).Scan(&count)
+	if count != 3 {
+		t.Errorf("audits = %d after replay, want 3", count)
+	}
+}
+
+func TestApplySkipsUnknownEvents(t *testing.T) {
+	store := openStore(t)
+	err := store.Apply(gateway.ChaincodeEvent{BlockNumber: 7, TxID: "tx-x", EventName: "Other", Payload: []byte("{")})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	err = store.Apply(gateway.ChaincodeEvent{BlockNumber: 8, TxID: "tx-y", EventName: chaincode.AuditEventName, Payload: []byte("{")})
+	if err != nil {
+		t.Fatalf("Apply(invalid payload) error = %v", err)
+	}
+	block, txID, ok, _ := store.Checkpoint()
+	if !ok || block != 8 || txID != "tx-y" {
+		t.Errorf("Checkpoint() = %d, %s, %v, want 8 / tx-y", block, txID, ok)
+	}
+}
+
+func TestReconcileClean(t *testing.T) {
+	store := openStore(t)
+	ledger := testLedger()
+	seed(t, store, ledger)
+
+	report, err := store.Reconcile(context.Background(), ledger, mirror.ReconcileOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !report.OK() || report.AuditsChecked != 3 || report.UsersChecked != 2 || report.Pending != 0 {
+		t.Errorf("report = %+v, want clean with 3 entries and 2 users", report)
+	}
+}
+
+func TestReconcileDrift(t *testing.T) {
+	store := openStore(t)
+	ledger := testLedger()
+	seed(t, store, ledger)
+
+	// Edited by hand, deleted, invented, and a user changed behind the indexer's back
+	db := store.DB()
+	for _, statement := range []string{
+		`UPDATE audits SET status = 'FAILURE' WHERE id = 'audit-001'`,
+		`DELETE FROM audits WHERE id = 'audit-002'`,
+		`INSERT INTO audits SELECT 'audit-999', timestamp, user_id, user_role, action, resource_type, resource_id,
+			old_value, new_value, status, ip_address, session_id, metadata, compliance_tag, tx_id, correlation_id,
+			parent_id, caused_by, changes, block_number, row_hash FROM audits WHERE id = 'audit-003'`,
+		`UPDATE users SET role = 'ADMIN' WHERE id = 'user-bob'`,
+	} {
+		_, err := db.Exec(statement)
+		if err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	// Committed after the last applied event: pending, not drift. audit-005 carries an older
+	// timestamp than anything mirrored, it is still ahead of the indexer.
+	ledger.entries = append(ledger.entries, testEntry("audit-004", "user-bob", "ISSUE", 4000),
+		testEntry("audit-005", "user-bob", "ISSUE", 500))
+	ledger.blocks["tx-audit-004"] = 6
+	ledger.blocks["tx-audit-005"] = 7
+
+	report, err := store.Reconcile(context.Background(), ledger, mirror.ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	var got []string
+	for _, finding := range report.Findings {
+		got = append(got, finding.Table+" "+finding.ID+" "+finding.Kind)
+	}
+	want := []string{
+		"audits audit-001 MODIFIED",
+		"audits audit-002 MISSING",
+		"audits audit-999 EXTRA",
+		"users user-bob MODIFIED",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("findings = %v, want %v", got, want)
+	}
+	if report.Pending != 2 {
+		t.Errorf("pending = %d, want 2", report.Pending)
+	}
+
+	var drift int
+	db.QueryRow(`SELECT COUNT(*) FROM drift WHERE reconciliation_id = ?`, report.ID).Scan(&drift)
+	if drift != 4 {
+		t.Errorf("drift rows = %d, want 4", drift)
+	}
+
+	// Repair, then a second run is clean apart from the pending entry
+	report, err = store.Reconcile(context.Background(), ledger, mirror.ReconcileOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Reconcile(repair) error = %v", err)
+	}
+	for _, finding := range report.Findings {
+		if !finding.Repaired {
+			t.Errorf("finding %+v not repaired", finding)
+		}
+	}
+	var block uint64
+	db.QueryRow(`SELECT block_number FROM audits WHERE id = 'audit-002'`).Scan(&block)
+	if block != 4 {
+		t.Errorf("repaired audit-002 block_number = %d, want 4", block)
+	}
+	report, err = store.Reconcile(context.Background(), ledger, mirror.ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !report.OK() || report.Pending != 2 {
+		t.Errorf("after repair: %+v, want clean with 2 pending", report)
+	}
+
+	// Once the indexer has passed their blocks, unmirrored entries are drift
+	err = store.Apply(event(t, 8, "Other", "tx-other", map[string]string{}))
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	report, err = store.Reconcile(context.Background(), ledger, mirror.ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	got = nil
+	for _, finding := range report.Findings {
+		got = append(got, finding.Table+" "+finding.ID+" "+finding.Kind)
+	}
+	want = []string{"audits audit-004 MISSING", "audits audit-005 MISSING"}
+	if fmt.Sprint(got) != fmt.Sprint(want) || report.Pending != 0 {
+		t.Errorf("findings = %v, pending = %d, want %v and 0 pending", got, report.Pending, want)
+	}
+}
+
+func TestReconcileLapsedSuspension(t *testing.T) {
+	store := openStore(t)
+	suspended := testUser("user-carol", "Org1MSP", 100)
+	suspended.Status = chaincode.UserStatusSuspended
+	suspended.Active = false
+	suspended.SuspendedUntil = 500
+	err := store.Apply(event(t, 1, chaincode.UserEventName, "tx-1", suspended))
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// ListUsers reports the suspension as lapsed, the mirror still holds the stored record
+	lapsed := *suspended
+	chaincode.NormalizeUserStatus(&lapsed, 1000)
+	ledger := &fakeLedger{users: []*chaincode.User{&lapsed}}
+
+	report, err := store.Reconcile(context.Background(), ledger, mirror.ReconcileOptions{Now: func() time.Time { return time.UnixMilli(1000) }})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("lapsed suspension reported as drift: %+v", report.Findings)
+	}
+}
+
+// fakeSource replays events from startBlock, skipping up to afterTxID, then waits
+type fakeSource struct {
+	events []gateway.ChaincodeEvent
+}
+
+func (s *fakeSource) ChaincodeEvents(ctx context.Context, startBlock uint64, afterTxID string, handle func(gateway.ChaincodeEvent) error) error {
+	skipping := afterTxID != ""
+	for _, event := range s.events {
+		if event.BlockNumber < startBlock {
+			continue
+		}
+		if skipping {
+			skipping = event.TxID != afterTxID
+			continue
+		}
+		err := handle(event)
+		if err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestIndexerRun(t *testing.T) {
+	store := openStore(t)
+	ledger := testLedger()
+	source := &fakeSource{events: []gateway.ChaincodeEvent{
+		event(t, 1, chaincode.UserEventName, "tx-u1", ledger.users[0]),
+		event(t, 2, chaincode.AuditEventName, "tx-audit-001", ledger.entries[0]),
+		event(t, 2, chaincode.AuditEventName, "tx-audit-002", ledger.entries[1]),
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		(&mirror.Indexer{Source: source, Store: store}).Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, txID, _, _ := store.Checkpoint()
+		if txID == "tx-audit-002" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("indexer did not reach tx-audit-002")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	var count int
+	store.DB().QueryRow(`SELECT COUNT(*) FROM audits_with_users WHERE username = 'user-alice'`).Scan(&count)
+	if count != 2 {
+		t.Errorf("joined rows = %d, want 2", count)
+	}
+}
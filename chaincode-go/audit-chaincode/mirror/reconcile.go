@@ -0,0 +1,374 @@
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/export"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Reconcile compares every mirror row with the ledger record, by hash:
+
+	1. snapshot the mirror (records rebuilt from the columns, checkpoint, user high-water mark)
+	2. page through the ledger: QueryAuditsPage, UserContract:ListUsers
+	3. MISSING  - on the ledger, not in the mirror
+	   MODIFIED - both, but the hashes differ
+	   EXTRA    - in the mirror, not on the ledger
+	4. store the run in reconciliations and every finding in drift
+
+ Records the indexer has not reached yet are counted as pending, not drift. Entries commit in
+ block order, not timestamp order, so an unmirrored entry is located by its TxID
+ (Ledger.TransactionBlock): committed at or after the mirror's checkpoint block it is pending,
+ before it the indexer has passed it and it is MISSING. Users carry no TxID, a user changed after
+ the newest user change applied from an event is pending.
+ Because the snapshot is taken before the ledger is read, an EXTRA row is always real drift.
+
+ Users are compared as the chaincode returns them: the mirror copy goes through
+ chaincode.NormalizeUserStatus first, so a lapsed suspension is not reported.
+
+ With Repair set, drifted rows are overwritten with (or, for EXTRA, deleted in favour of) the
+ ledger version. Entries logged before the chaincode emitted events are backfilled this way.
+*/
+
+// Drift kinds
+const (
+	DriftMissing  = "MISSING"
+	DriftModified = "MODIFIED"
+	DriftExtra    = "EXTRA"
+)
+
+// Tables reconciled
+const (
+	TableAudits = "audits"
+	TableUsers  = "users"
+)
+
+// listUsersPageSize is the UserContract:ListUsers maximum
+const listUsersPageSize = 100
+
+// Ledger is the part of gateway.Client reconciliation uses
+type Ledger interface {
+	Evaluate(ctx context.Context, fn string, args ...string) ([]byte, error)
+	TransactionBlock(ctx context.Context, txID string) (uint64, error)
+}
+
+// ReconcileOptions tune a Reconcile run
+type ReconcileOptions struct {
+	Repair   bool             // overwrite drifted rows with the ledger version
+	PageSize int              // QueryAuditsPage page size (default export.DefaultPageSize)
+	Now      func() time.Time // clock for NormalizeUserStatus (default time.Now)
+}
+
+// Finding is one difference between the mirror and the ledger
+type Finding struct {
+	Table    string `json:"table"`    // audits or users
+	ID       string `json:"id"`       // Audit or user ID
+	Kind     string `json:"kind"`     // MISSING, MODIFIED, EXTRA
+	Detail   string `json:"detail"`   // Hashes involved
+	Repaired bool   `json:"repaired"` // Row was fixed from the ledger
+}
+
+// Report is the result of one Reconcile run
+type Report struct {
+	ID            int64     `json:"id"`            // reconciliations row
+	StartedAt     int64     `json:"startedAt"`     // ms since epoch
+	FinishedAt    int64     `json:"finishedAt"`    // ms since epoch
+	AuditsChecked int       `json:"auditsChecked"` // Ledger entries compared
+	UsersChecked  int       `json:"usersChecked"`  // Ledger users compared
+	Pending       int       `json:"pending"`       // Not reached by the indexer yet
+	Findings      []Finding `json:"findings"`      // Drift
+}
+
+// OK reports whether the mirror matched the ledger
+func (r *Report) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// repair is the fix for report.Findings[finding], applied once the ledger has been read
+type repair struct {
+	finding int
+	audit   *chaincode.AuditEntry // ledger version (MISSING, MODIFIED audits)
+	user    *chaincode.User       // ledger version (MISSING, MODIFIED users)
+	block   uint64                // block that committed the audit
+}
+
+// Reconcile compares the mirror with the ledger and records the drift it finds
+func (s *Store) Reconcile(ctx context.Context, ledger Ledger, opts ReconcileOptions) (*Report, error) {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	report := &Report{StartedAt: now().UnixMilli(), Findings: []Finding{}}
+	log.Printf("[Reconcile] ENTER repair=%v", opts.Repair)
+
+	// 1. Mirror snapshot
+	state, err := s.state()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, indexed := state[stateBlock]
+	checkpointBlock, _ := strconv.ParseUint(checkpoint, 10, 64)
+	userHighWater, _ := strconv.ParseInt(state[stateUserHighWater], 10, 64)
+
+	mirrorAudits, err := s.auditHashes()
+	if err != nil {
+		return nil, err
+	}
+	mirrorUsers, err := s.users()
+	if err != nil {
+		return nil, err
+	}
+
+	var repairs []repair
+	drift := func(table string, id string, kind string, detail string) int {
+		report.Findings = append(report.Findings, Finding{Table: table, ID: id, Kind: kind, Detail: detail})
+		return len(report.Findings) - 1
+	}
+
+	// 2-3. Audit entries
+	seen := map[string]bool{}
+	_, err = export.Each(ctx, ledger, export.Filter{}, opts.PageSize, func(entry *chaincode.AuditEntry) error {
+		report.AuditsChecked++
+		seen[entry.ID] = true
+		hash, err := auditHash(entry)
+		if err != nil {
+			return err
+		}
+		mirrorHash, ok := mirrorAudits[entry.ID]
+		if ok && mirrorHash == hash {
+			return nil
+		}
+		if !ok && !indexed {
+			report.Pending++
+			return nil
+		}
+		block, err := ledger.TransactionBlock(ctx, entry.TxID)
+		if err != nil {
+			return fmt.Errorf("failed to locate %s (tx %s): %v", entry.ID, entry.TxID, err)
+		}
+		switch {
+		case !ok && block >= checkpointBlock:
+			// The checkpoint block may still hold events after the checkpoint TxID
+			report.Pending++
+		case !ok:
+			repairs = append(repairs, repair{finding: drift(TableAudits, entry.ID, DriftMissing, "ledger "+hash), audit: entry, block: block})
+		default:
+			detail := fmt.Sprintf("ledger %s, mirror %s", hash, mirrorHash)
+			repairs = append(repairs, repair{finding: drift(TableAudits, entry.ID, DriftModified, detail), audit: entry, block: block})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit entries from the ledger: %v", err)
+	}
+	for _, id := range sortedKeys(mirrorAudits) {
+		if !seen[id] {
+			repairs = append(repairs, repair{finding: drift(TableAudits, id, DriftExtra, "mirror "+mirrorAudits[id])})
+		}
+	}
+
+	// 2-3. Users
+	nowMillis := now().UnixMilli()
+	seen = map[string]bool{}
+	err = eachUser(ctx, ledger, func(user *chaincode.User) error {
+		report.UsersChecked++
+		seen[user.ID] = true
+		hash, err := userHash(user)
+		if err != nil {
+			return err
+		}
+		mirrorHash := ""
+		mirrored, ok := mirrorUsers[user.ID]
+		if ok {
+			chaincode.NormalizeUserStatus(mirrored, nowMillis)
+			mirrorHash, err = userHash(mirrored)
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case ok && mirrorHash == hash:
+		case userChangedAt(user) > userHighWater:
+			report.Pending++
+		case !ok:
+			repairs = append(repairs, repair{finding: drift(TableUsers, user.ID, DriftMissing, "ledger "+hash), user: user})
+		default:
+			detail := fmt.Sprintf("ledger %s, mirror %s", hash, mirrorHash)
+			repairs = append(repairs, repair{finding: drift(TableUsers, user.ID, DriftModified, detail), user: user})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users from the ledger: %v", err)
+	}
+	for _, id := range sortedKeys(mirrorUsers) {
+		if !seen[id] {
+			hash, err := userHash(mirrorUsers[id])
+			if err != nil {
+				return nil, err
+			}
+			repairs = append(repairs, repair{finding: drift(TableUsers, id, DriftExtra, "mirror "+hash)})
+		}
+	}
+
+	// 4. Record the run (and apply repairs) in one transaction
+	err = s.recordReconciliation(report, repairs, opts.Repair, now)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[Reconcile] SUCCESS id=%d audits=%d users=%d drift=%d pending=%d",
+		report.ID, report.AuditsChecked, report.UsersChecked, len(report.Findings), report.Pending)
+	return report, nil
+}
+
+// recordReconciliation writes the run, its findings and (with repair) the ledger versions
+func (s *Store) recordReconciliation(report *Report, repairs []repair, fix bool, now func() time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin mirror transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	repaired := 0
+	if fix {
+		for _, r := range repairs {
+			finding := &report.Findings[r.finding]
+			err = applyRepair(tx, finding, r)
+			if err != nil {
+				return err
+			}
+			finding.Repaired = true
+			repaired++
+		}
+	}
+
+	report.FinishedAt = now().UnixMilli()
+	result, err := tx.Exec(`INSERT INTO reconciliations (started_at, finished_at, audits_checked, users_checked,
+		drift, pending, repaired) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		report.StartedAt, report.FinishedAt, report.AuditsChecked, report.UsersChecked,
+		len(report.Findings), report.Pending, repaired)
+	if err != nil {
+		return fmt.Errorf("failed to record reconciliation: %v", err)
+	}
+	report.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to record reconciliation: %v", err)
+	}
+
+	for _, finding := range report.Findings {
+		_, err = tx.Exec(`INSERT INTO drift (reconciliation_id, table_name, record_id, kind, detail, repaired)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			report.ID, finding.Table, finding.ID, finding.Kind, finding.Detail, finding.Repaired)
+		if err != nil {
+			return fmt.Errorf("failed to record drift for %s %s: %v", finding.Table, finding.ID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit reconciliation: %v", err)
+	}
+	return nil
+}
+
+// applyRepair makes one mirror row match the ledger
+func applyRepair(tx *sql.Tx, finding *Finding, r repair) error {
+	switch {
+	case r.audit != nil:
+		return putAudit(tx, r.audit, r.block)
+	case r.user != nil:
+		return putUser(tx, r.user, 0, "")
+	}
+
+	// EXTRA: the ledger has no such record
+	_, err := tx.Exec(`DELETE FROM `+finding.Table+` WHERE id = ?`, finding.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s from mirror: %v", finding.Table, finding.ID, err)
+	}
+	return nil
+}
+
+// eachUser pages through UserContract:ListUsers
+func eachUser(ctx context.Context, ledger Ledger, fn func(user *chaincode.User) error) error {
+	bookmark := ""
+	for {
+		result, err := ledger.Evaluate(ctx, "UserContract:ListUsers", "", strconv.Itoa(listUsersPageSize), bookmark)
+		if err != nil {
+			return err
+		}
+		var page chaincode.UserQueryResult
+		err = json.Unmarshal(result, &page)
+		if err != nil {
+			return fmt.Errorf("invalid ListUsers response: %v", err)
+		}
+		for _, user := range page.Records {
+			err = fn(user)
+			if err != nil {
+				return err
+			}
+		}
+		if page.Bookmark == "" || page.Bookmark == bookmark {
+			return nil
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+// auditHashes hashes every mirrored entry as rebuilt from its columns
+func (s *Store) auditHashes() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT ` + auditColumns + ` FROM audits`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mirror audits: %v", err)
+	}
+	defer rows.Close()
+
+	hashes := map[string]string{}
+	for rows.Next() {
+		entry, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		hashes[entry.ID], err = auditHash(entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, rows.Err()
+}
+
+// users reads every mirrored user as rebuilt from its columns
+func (s *Store) users() (map[string]*chaincode.User, error) {
+	rows, err := s.db.Query(`SELECT ` + userColumns + ` FROM users`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mirror users: %v", err)
+	}
+	defer rows.Close()
+
+	users := map[string]*chaincode.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users[user.ID] = user
+	}
+	return users, rows.Err()
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,383 @@
+// Package mirror keeps an off-chain SQLite copy of audit entries and users for dashboards and
+// SQL analytics (aggregations, joins with users) that CouchDB rich queries cannot do.
+// The mirror is fed by the chaincode's AuditLogged / UserChanged events and checked against
+// the ledger by Reconcile. It is a read model only: compliance evidence still comes from the
+// chain (checkpoints, inclusion proofs, audit-verify).
+package mirror
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/gateway"
+
+	// registers the "sqlite3" database/sql driver (cgo)
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Tables:
+	audits         one row per AuditEntry, flat columns (changes as JSON) + block_number + row_hash
+	users          one row per User as last written on the ledger (permissions as JSON), same extras
+	mirror_state   block / txId of the last applied event, user high-water mark
+	reconciliations, drift   one row per Reconcile run and per difference it found
+	audits_with_users        view joining every entry with its user (username, org, status)
+
+ row_hash is hex SHA-256 of the record when it was mirrored: AuditEntryDigest (canonical JSON)
+ for audits, the JSON encoding of the User for users. Reconcile does not trust it, it rebuilds
+ each record from the columns, so a column edited by hand shows up as drift.
+ block_number is the block the row came from, 0 for users written by a Reconcile repair.
+
+ Every event is applied in one SQL transaction together with the checkpoint, so a crash never
+ applies an event twice or skips one. Audit rows are upserted too, so replaying is harmless.
+*/
+
+const schema = `
+CREATE TABLE IF NOT EXISTS audits (
+	id             TEXT PRIMARY KEY,
+	timestamp      INTEGER NOT NULL,
+	user_id        TEXT NOT NULL,
+	user_role      TEXT NOT NULL,
+	action         TEXT NOT NULL,
+	resource_type  TEXT NOT NULL,
+	resource_id    TEXT NOT NULL,
+	old_value      TEXT NOT NULL,
+	new_value      TEXT NOT NULL,
+	status         TEXT NOT NULL,
+	ip_address     TEXT NOT NULL,
+	session_id     TEXT NOT NULL,
+	metadata       TEXT NOT NULL,
+	compliance_tag TEXT NOT NULL,
+	tx_id          TEXT NOT NULL,
+	correlation_id TEXT NOT NULL,
+	parent_id      TEXT NOT NULL,
+	caused_by      TEXT NOT NULL,
+	changes        TEXT NOT NULL,
+	block_number   INTEGER NOT NULL,
+	row_hash       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audits_timestamp ON audits (timestamp);
+CREATE INDEX IF NOT EXISTS audits_user ON audits (user_id, timestamp);
+CREATE INDEX IF NOT EXISTS audits_compliance_tag ON audits (compliance_tag, timestamp);
+CREATE INDEX IF NOT EXISTS audits_action ON audits (action, timestamp);
+CREATE INDEX IF NOT EXISTS audits_resource ON audits (resource_type, resource_id, timestamp);
+CREATE INDEX IF NOT EXISTS audits_correlation ON audits (correlation_id);
+
+CREATE TABLE IF NOT EXISTS users (
+	id              TEXT PRIMARY KEY,
+	username        TEXT NOT NULL,
+	email           TEXT NOT NULL,
+	role            TEXT NOT NULL,
+	organization    TEXT NOT NULL,
+	permissions     TEXT NOT NULL,
+	active          INTEGER NOT NULL,
+	status          TEXT NOT NULL,
+	status_reason   TEXT NOT NULL,
+	suspended_until INTEGER NOT NULL,
+	created_at      INTEGER NOT NULL,
+	updated_at      INTEGER NOT NULL,
+	created_by      TEXT NOT NULL,
+	updated_by      TEXT NOT NULL,
+	block_number    INTEGER NOT NULL,
+	tx_id           TEXT NOT NULL,
+	row_hash        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS users_organization ON users (organization, role);
+
+CREATE TABLE IF NOT EXISTS mirror_state (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reconciliations (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at     INTEGER NOT NULL,
+	finished_at    INTEGER NOT NULL,
+	audits_checked INTEGER NOT NULL,
+	users_checked  INTEGER NOT NULL,
+	drift          INTEGER NOT NULL,
+	pending        INTEGER NOT NULL,
+	repaired       INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS drift (
+	reconciliation_id INTEGER NOT NULL REFERENCES reconciliations (id),
+	table_name        TEXT NOT NULL,
+	record_id         TEXT NOT NULL,
+	kind              TEXT NOT NULL,
+	detail            TEXT NOT NULL,
+	repaired          INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS drift_reconciliation ON drift (reconciliation_id);
+
+CREATE VIEW IF NOT EXISTS audits_with_users AS
+	SELECT a.*, u.username, u.email, u.organization, u.status AS user_status
+	FROM audits a LEFT JOIN users u ON u.id = a.user_id;
+`
+
+// mirror_state keys
+const (
+	stateBlock         = "block"
+	stateTxID          = "txId"
+	stateUserHighWater = "userHighWater"
+)
+
+// Store is the SQLite mirror database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (or creates) the mirror at path and brings its schema up to date
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mirror %s: %v", path, err)
+	}
+	// One writer: the indexer and Reconcile repairs never race each other
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create mirror schema in %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// DB exposes the database for read-only SQL (dashboards, ad hoc analytics)
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Checkpoint returns the block and TxID of the last applied event, ok is false before the first one
+func (s *Store) Checkpoint() (block uint64, txID string, ok bool, err error) {
+	state, err := s.state()
+	if err != nil {
+		return 0, "", false, err
+	}
+	blockValue, ok := state[stateBlock]
+	if !ok {
+		return 0, "", false, nil
+	}
+	block, err = strconv.ParseUint(blockValue, 10, 64)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("invalid mirror checkpoint block %q: %v", blockValue, err)
+	}
+	return block, state[stateTxID], true, nil
+}
+
+// Apply writes one chaincode event to the mirror and moves the checkpoint past it.
+// Other events, and payloads that do not decode, only move the checkpoint (Reconcile reports the gap).
+func (s *Store) Apply(event gateway.ChaincodeEvent) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin mirror transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	switch event.EventName {
+	case chaincode.AuditEventName:
+		var entry chaincode.AuditEntry
+		if json.Unmarshal(event.Payload, &entry) != nil {
+			log.Printf("[Mirror] SKIP txId=%s invalid %s payload", event.TxID, event.EventName)
+			break
+		}
+		err = putAudit(tx, &entry, event.BlockNumber)
+	case chaincode.UserEventName:
+		var user chaincode.User
+		if json.Unmarshal(event.Payload, &user) != nil {
+			log.Printf("[Mirror] SKIP txId=%s invalid %s payload", event.TxID, event.EventName)
+			break
+		}
+		err = putUser(tx, &user, event.BlockNumber, event.TxID)
+		if err == nil {
+			err = raiseHighWater(tx, stateUserHighWater, userChangedAt(&user))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	err = setState(tx, stateBlock, strconv.FormatUint(event.BlockNumber, 10))
+	if err == nil {
+		err = setState(tx, stateTxID, event.TxID)
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit mirror transaction: %v", err)
+	}
+	return nil
+}
+
+// putAudit upserts one entry
+func putAudit(tx *sql.Tx, entry *chaincode.AuditEntry, block uint64) error {
+	hash, err := auditHash(entry)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal changes of audit entry %s: %v", entry.ID, err)
+	}
+	if entry.Changes == nil {
+		changes = []byte("[]")
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO audits (id, timestamp, user_id, user_role, action, resource_type,
+		resource_id, old_value, new_value, status, ip_address, session_id, metadata, compliance_tag, tx_id,
+		correlation_id, parent_id, caused_by, changes, block_number, row_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.TimeStamp, entry.UserID, entry.UserRole, entry.Action, entry.ResourceType,
+		entry.ResourceID, entry.OldValue, entry.NewValue, entry.Status, entry.IPAddress, entry.SessionID,
+		entry.Metadata, entry.ComplianceTag, entry.TxID, entry.CorrelationID, entry.ParentID, entry.CausedBy,
+		string(changes), int64(block), hash)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry %s to mirror: %v", entry.ID, err)
+	}
+	return nil
+}
+
+// putUser upserts one user
+func putUser(tx *sql.Tx, user *chaincode.User, block uint64, txID string) error {
+	hash, err := userHash(user)
+	if err != nil {
+		return err
+	}
+	// nil stays null, the row hash covers the difference between null and []
+	permissions, err := json.Marshal(user.Permissions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal permissions of user %s: %v", user.ID, err)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO users (id, username, email, role, organization, permissions, active,
+		status, status_reason, suspended_until, created_at, updated_at, created_by, updated_by, block_number,
+		tx_id, row_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.Email, user.Role, user.Organization, string(permissions), user.Active,
+		user.Status, user.StatusReason, user.SuspendedUntil, user.CreatedAt, user.UpdatedAt, user.CreatedBy,
+		user.UpdatedBy, int64(block), txID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to write user %s to mirror: %v", user.ID, err)
+	}
+	return nil
+}
+
+// auditColumns / userColumns are read back by scanAudit / scanUser
+const (
+	auditColumns = `id, timestamp, user_id, user_role, action, resource_type, resource_id, old_value, new_value,
+		status, ip_address, session_id, metadata, compliance_tag, tx_id, correlation_id, parent_id, caused_by, changes`
+	userColumns = `id, username, email, role, organization, permissions, active, status, status_reason,
+		suspended_until, created_at, updated_at, created_by, updated_by`
+)
+
+// scanAudit rebuilds an AuditEntry from a row of auditColumns
+func scanAudit(rows *sql.Rows) (*chaincode.AuditEntry, error) {
+	var entry chaincode.AuditEntry
+	var changes string
+	err := rows.Scan(&entry.ID, &entry.TimeStamp, &entry.UserID, &entry.UserRole, &entry.Action,
+		&entry.ResourceType, &entry.ResourceID, &entry.OldValue, &entry.NewValue, &entry.Status,
+		&entry.IPAddress, &entry.SessionID, &entry.Metadata, &entry.ComplianceTag, &entry.TxID,
+		&entry.CorrelationID, &entry.ParentID, &entry.CausedBy, &changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit row: %v", err)
+	}
+	err = json.Unmarshal([]byte(changes), &entry.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid changes in audit row %s: %v", entry.ID, err)
+	}
+	return &entry, nil
+}
+
+// scanUser rebuilds a User from a row of userColumns
+func scanUser(rows *sql.Rows) (*chaincode.User, error) {
+	var user chaincode.User
+	var permissions string
+	err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Organization, &permissions,
+		&user.Active, &user.Status, &user.StatusReason, &user.SuspendedUntil, &user.CreatedAt, &user.UpdatedAt,
+		&user.CreatedBy, &user.UpdatedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user row: %v", err)
+	}
+	err = json.Unmarshal([]byte(permissions), &user.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid permissions in user row %s: %v", user.ID, err)
+	}
+	return &user, nil
+}
+
+// auditHash is the row hash of an entry: AuditEntryDigest, hex
+func auditHash(entry *chaincode.AuditEntry) (string, error) {
+	digest, err := chaincode.AuditEntryDigest(entry)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest), nil
+}
+
+// userHash is the row hash of a user: SHA-256 of its JSON encoding, hex
+func userHash(user *chaincode.User) (string, error) {
+	doc, err := json.Marshal(user)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal user %s: %v", user.ID, err)
+	}
+	sum := sha256.Sum256(doc)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// userChangedAt is when a user was last written (UpdatedAt, or CreatedAt for new users)
+func userChangedAt(user *chaincode.User) int64 {
+	return max(user.UpdatedAt, user.CreatedAt)
+}
+
+func raiseHighWater(tx *sql.Tx, key string, value int64) error {
+	_, err := tx.Exec(`INSERT INTO mirror_state (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+		WHERE CAST(excluded.value AS INTEGER) > CAST(mirror_state.value AS INTEGER)`,
+		key, strconv.FormatInt(value, 10))
+	if err != nil {
+		return fmt.Errorf("failed to update mirror state %s: %v", key, err)
+	}
+	return nil
+}
+
+func setState(tx *sql.Tx, key string, value string) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO mirror_state (key, value) VALUES (?, ?)`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to update mirror state %s: %v", key, err)
+	}
+	return nil
+}
+
+func (s *Store) state() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM mirror_state`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mirror state: %v", err)
+	}
+	defer rows.Close()
+
+	state := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		err = rows.Scan(&key, &value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mirror state: %v", err)
+		}
+		state[key] = value
+	}
+	return state, rows.Err()
+}
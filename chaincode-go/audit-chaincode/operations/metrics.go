@@ -0,0 +1,160 @@
+// Package operations is the chaincode's HTTP operations listener: liveness (/healthz),
+// readiness (/readyz) and Prometheus metrics (/metrics) for every transaction the chaincode
+// serves. It runs next to the gRPC chaincode server in the same process.
+package operations
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Instrument wraps the whole chaincode (shim.Chaincode), so every AuditContract, UserContract,
+ RegistryContract and CheckpointContract function is measured the same way without touching
+ the contracts:
+
+	peer -> Invoke -> countingStub -> contractapi routing -> contract function
+	                      |
+	                      +-- counts GetState / iterator records (reads), PutState / DelState (writes)
+
+ The function label is "Contract:Function" with the default contract filled in. Calls to a
+ function or contract that does not exist are labelled "unknown", so a client cannot grow the
+ label set. Only public state is counted, the contracts do not use private data.
+
+	audit_chaincode_transactions_total{function}              counter
+	audit_chaincode_transaction_errors_total{function}        counter (non-200 responses)
+	audit_chaincode_transaction_duration_seconds{function}    histogram
+	audit_chaincode_ledger_reads{function}                    histogram, keys read per invocation
+	audit_chaincode_ledger_writes{function}                   histogram, keys written per invocation
+
+ plus the standard Go runtime and process collectors.
+*/
+
+// UnknownFunction labels calls to functions or contracts the chaincode does not have
+const UnknownFunction = "unknown"
+
+// Metrics holds the chaincode's Prometheus collectors in their own registry
+type Metrics struct {
+	Registry     *prometheus.Registry
+	transactions *prometheus.CounterVec
+	errors       *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	reads        *prometheus.HistogramVec
+	writes       *prometheus.HistogramVec
+}
+
+// NewMetrics registers the transaction, ledger, Go runtime and process collectors
+func NewMetrics() *Metrics {
+	ledgerBuckets := []float64{0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		transactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "audit_chaincode_transactions_total",
+			Help: "Transactions invoked, by contract function.",
+		}, []string{"function"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "audit_chaincode_transaction_errors_total",
+			Help: "Transactions that returned an error, by contract function.",
+		}, []string{"function"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "audit_chaincode_transaction_duration_seconds",
+			Help:    "Time spent in the chaincode per transaction, by contract function.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"function"}),
+		reads: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "audit_chaincode_ledger_reads",
+			Help:    "World state keys read per transaction, by contract function.",
+			Buckets: ledgerBuckets,
+		}, []string{"function"}),
+		writes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "audit_chaincode_ledger_writes",
+			Help:    "World state keys written or deleted per transaction, by contract function.",
+			Buckets: ledgerBuckets,
+		}, []string{"function"}),
+	}
+	m.Registry.MustRegister(
+		m.transactions, m.errors, m.duration, m.reads, m.writes,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observe records one finished transaction
+func (m *Metrics) observe(function string, elapsed time.Duration, reads int, writes int, failed bool) {
+	m.transactions.WithLabelValues(function).Inc()
+	if failed {
+		m.errors.WithLabelValues(function).Inc()
+	}
+	m.duration.WithLabelValues(function).Observe(elapsed.Seconds())
+	m.reads.WithLabelValues(function).Observe(float64(reads))
+	m.writes.WithLabelValues(function).Observe(float64(writes))
+}
+
+// instrumented is a shim.Chaincode that measures every Init and Invoke of the one it wraps
+type instrumented struct {
+	cc              shim.Chaincode
+	defaultContract string
+	metrics         *Metrics
+}
+
+// Instrument wraps cc so every transaction is recorded in m. defaultContract names the
+// contract used when the function has no "Contract:" prefix (ContractChaincode.DefaultContract).
+func Instrument(cc shim.Chaincode, defaultContract string, m *Metrics) shim.Chaincode {
+	return &instrumented{cc: cc, defaultContract: defaultContract, metrics: m}
+}
+
+// Init measures instantiation calls the same way as Invoke
+func (i *instrumented) Init(stub shim.ChaincodeStubInterface) *peer.Response {
+	return i.measure(stub, i.cc.Init)
+}
+
+// Invoke measures one transaction
+func (i *instrumented) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	return i.measure(stub, i.cc.Invoke)
+}
+
+func (i *instrumented) measure(stub shim.ChaincodeStubInterface, call func(shim.ChaincodeStubInterface) *peer.Response) *peer.Response {
+	counting := &countingStub{ChaincodeStubInterface: stub}
+	start := time.Now()
+	response := call(counting)
+	elapsed := time.Now().Sub(start)
+
+	function, _ := stub.GetFunctionAndParameters()
+	failed := response == nil || response.Status >= shim.ERRORTHRESHOLD
+	i.metrics.observe(i.functionLabel(function, response), elapsed, counting.reads, counting.writes, failed)
+	return response
+}
+
+// functionLabel is "Contract:Function", or UnknownFunction when contractapi could not route it
+func (i *instrumented) functionLabel(function string, response *peer.Response) string {
+	if response != nil && response.Status >= shim.ERRORTHRESHOLD {
+		message := response.Message
+		// The chaincode's Guard returns errors as a JSON envelope, the routing error is its message
+		var envelope struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal([]byte(message), &envelope) == nil && envelope.Message != "" {
+			message = envelope.Message
+		}
+		if strings.HasPrefix(message, "Contract not found with name ") ||
+			(strings.HasPrefix(message, "Function ") && strings.Contains(message, " not found in contract ")) ||
+			message == "Blank function name passed" {
+			return UnknownFunction
+		}
+	}
+	if function == "" {
+		return UnknownFunction
+	}
+	if !strings.Contains(function, ":") {
+		function = i.defaultContract + ":" + function
+	}
+	return function
+}
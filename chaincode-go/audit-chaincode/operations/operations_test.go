@@ -0,0 +1,210 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// fakeStub implements just what the tests call; the embedded nil interface panics on anything else
+type fakeStub struct {
+	shim.ChaincodeStubInterface
+	function string
+	state    map[string][]byte
+}
+
+func (s *fakeStub) GetFunctionAndParameters() (string, []string) { return s.function, nil }
+func (s *fakeStub) GetState(key string) ([]byte, error)          { return s.state[key], nil }
+func (s *fakeStub) PutState(key string, value []byte) error {
+	s.state[key] = value
+	return nil
+}
+func (s *fakeStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return &fakeIterator{remaining: 3}, nil
+}
+
+type fakeIterator struct{ remaining int }
+
+func (it *fakeIterator) HasNext() bool { return it.remaining > 0 }
+func (it *fakeIterator) Close() error  { return nil }
+func (it *fakeIterator) Next() (*queryresult.KV, error) {
+	it.remaining--
+	return &queryresult.KV{Key: "k"}, nil
+}
+
+// fakeChaincode routes like contractapi: two reads, a range scan and a write, or an error
+type fakeChaincode struct{}
+
+func (fakeChaincode) Init(stub shim.ChaincodeStubInterface) *peer.Response { return shim.Success(nil) }
+func (fakeChaincode) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	function, _ := stub.GetFunctionAndParameters()
+	switch function {
+	case "LogAudit":
+		stub.GetState("a")
+		stub.GetState("b")
+		iterator, _ := stub.GetStateByRange("", "")
+		for iterator.HasNext() {
+			iterator.Next()
+		}
+		stub.PutState("a", []byte("1"))
+		return shim.Success(nil)
+	case "UserContract:GetUser":
+		return shim.Error("User  ID=u1 does not exist in ledger")
+	case "Wrapped":
+		return shim.Error(`{"function":"AuditContract:Wrapped","txId":"tx1","message":"Function Wrapped not found in contract AuditContract"}`)
+	default:
+		return shim.Error("Function " + function + " not found in contract AuditContract")
+	}
+}
+
+// sample returns the value of a counter, or the count and sum of a histogram, for one function
+func sample(t *testing.T, m *Metrics, name string, function string) (float64, float64) {
+	t.Helper()
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if metric.GetLabel()[0].GetValue() != function {
+				continue
+			}
+			if metric.Counter != nil {
+				return metric.Counter.GetValue(), 0
+			}
+			return float64(metric.Histogram.GetSampleCount()), metric.Histogram.GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+func TestInstrument(t *testing.T) {
+	m := NewMetrics()
+	cc := Instrument(fakeChaincode{}, "AuditContract", m)
+
+	for _, function := range []string{"LogAudit", "LogAudit", "UserContract:GetUser", "NoSuchThing", "Wrapped"} {
+		cc.Invoke(&fakeStub{function: function, state: map[string][]byte{}})
+	}
+
+	if count, _ := sample(t, m, "audit_chaincode_transactions_total", "AuditContract:LogAudit"); count != 2 {
+		t.Errorf("LogAudit transactions = %v, want 2", count)
+	}
+	if count, _ := sample(t, m, "audit_chaincode_transaction_errors_total", "AuditContract:LogAudit"); count != 0 {
+		t.Errorf("LogAudit errors = %v, want 0", count)
+	}
+	if count, _ := sample(t, m, "audit_chaincode_transaction_errors_total", "UserContract:GetUser"); count != 1 {
+		t.Errorf("GetUser errors = %v, want 1", count)
+	}
+	if count, _ := sample(t, m, "audit_chaincode_transactions_total", UnknownFunction); count != 2 {
+		t.Errorf("unknown transactions = %v, want 2 (plain and enveloped)", count)
+	}
+	if count, _ := sample(t, m, "audit_chaincode_transactions_total", "AuditContract:NoSuchThing"); count != 0 {
+		t.Errorf("unknown function got its own label")
+	}
+
+	// 2 GetState + 3 range records per call, 1 PutState
+	if count, sum := sample(t, m, "audit_chaincode_ledger_reads", "AuditContract:LogAudit"); count != 2 || sum != 10 {
+		t.Errorf("reads count=%v sum=%v, want 2 and 10", count, sum)
+	}
+	if count, sum := sample(t, m, "audit_chaincode_ledger_writes", "AuditContract:LogAudit"); count != 2 || sum != 2 {
+		t.Errorf("writes count=%v sum=%v, want 2 and 2", count, sum)
+	}
+	if count, _ := sample(t, m, "audit_chaincode_transaction_duration_seconds", "AuditContract:LogAudit"); count != 2 {
+		t.Errorf("duration observations = %v, want 2", count)
+	}
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	body, _ := io.ReadAll(recorder.Body)
+	return recorder.Code, string(body)
+}
+
+func TestEndpoints(t *testing.T) {
+	m := NewMetrics()
+	Instrument(fakeChaincode{}, "AuditContract", m).Invoke(&fakeStub{function: "LogAudit", state: map[string][]byte{}})
+	s := NewServer("127.0.0.1:0", m)
+	handler := s.Handler()
+
+	code, body := get(t, handler, "/healthz")
+	if code != http.StatusOK || !strings.Contains(body, `"status":"OK"`) {
+		t.Errorf("healthz = %d %s", code, body)
+	}
+
+	s.AddReadinessCheck("ok", func(ctx context.Context) error { return nil })
+	code, _ = get(t, handler, "/readyz")
+	if code != http.StatusOK {
+		t.Errorf("readyz with passing checks = %d", code)
+	}
+
+	s.AddReadinessCheck("chaincode-server", func(ctx context.Context) error { return errors.New("down") })
+	code, body = get(t, handler, "/readyz")
+	var status Status
+	err := json.Unmarshal([]byte(body), &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusServiceUnavailable || len(status.FailedChecks) != 1 ||
+		status.FailedChecks[0].Component != "chaincode-server" || status.FailedChecks[0].Reason != "down" {
+		t.Errorf("readyz with failing check = %d %s", code, body)
+	}
+
+	code, body = get(t, handler, "/metrics")
+	if code != http.StatusOK || !strings.Contains(body, `audit_chaincode_transactions_total{function="AuditContract:LogAudit"} 1`) ||
+		!strings.Contains(body, "go_goroutines") {
+		t.Errorf("metrics = %d\n%s", code, body)
+	}
+}
+
+func TestStartAndTCPCheck(t *testing.T) {
+	s := NewServer("127.0.0.1:0", NewMetrics())
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	response, err := http.Get("http://" + s.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("healthz over HTTP = %d", response.StatusCode)
+	}
+
+	// The operations listener itself stands in for the gRPC port; wildcard hosts dial loopback
+	_, port, _ := net.SplitHostPort(s.Addr().String())
+	if err := TCPCheck("0.0.0.0:" + port)(context.Background()); err != nil {
+		t.Errorf("TCPCheck on a listening port: %v", err)
+	}
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := closed.Addr().String()
+	closed.Close()
+	if err := TCPCheck(address)(context.Background()); err == nil {
+		t.Errorf("TCPCheck on a closed port passed")
+	}
+
+	if err := NewServer("127.0.0.1:bad", NewMetrics()).Start(); err == nil {
+		t.Errorf("Start on a bad address passed")
+	}
+}
@@ -0,0 +1,179 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Endpoints (same JSON shape as the Fabric peer's operations service):
+
+	GET /healthz  200 {"status":"OK","time":...} while the process is up (liveness)
+	GET /readyz   200 when every readiness check passes, otherwise 503 with
+	              {"status":"Service Unavailable","failed_checks":[{"component","reason"}]}
+	GET /metrics  Prometheus text format (Metrics.Registry)
+
+ Readiness checks run on every /readyz request with a short timeout, so a probe never hangs.
+ In Chaincode-as-a-Service mode main.go adds TCPCheck on the gRPC listen address.
+*/
+
+// checkTimeout bounds each readiness check
+const checkTimeout = 2 * time.Second
+
+// Check reports why a component is not ready, nil when it is
+type Check func(ctx context.Context) error
+
+// Status is the /healthz and /readyz response body
+type Status struct {
+	Status       string        `json:"status"`                  // OK or Service Unavailable
+	Time         time.Time     `json:"time"`                    // Server time
+	FailedChecks []FailedCheck `json:"failed_checks,omitempty"` // Readiness checks that failed
+}
+
+// FailedCheck is one failed readiness check
+type FailedCheck struct {
+	Component string `json:"component"` // Check name
+	Reason    string `json:"reason"`    // Check error
+}
+
+// Server is the operations HTTP listener
+type Server struct {
+	Address string
+	Metrics *Metrics
+
+	mu       sync.Mutex
+	names    []string
+	checks   map[string]Check
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer creates an operations server for address; nothing listens until Start
+func NewServer(address string, metrics *Metrics) *Server {
+	return &Server{Address: address, Metrics: metrics, checks: map[string]Check{}}
+}
+
+// AddReadinessCheck registers a check /readyz runs; a second check with the same name replaces the first
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.checks[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.checks[name] = check
+}
+
+// Handler serves /healthz, /readyz and /metrics
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, Status{Status: "OK", Time: time.Now()})
+	})
+	mux.HandleFunc("GET /readyz", s.readyz)
+	mux.Handle("GET /metrics", promhttp.HandlerFor(s.Metrics.Registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// Start binds the listener (so a bad address fails now) and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", s.Address, err)
+	}
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	s.mu.Lock()
+	s.listener, s.server = listener, server
+	s.mu.Unlock()
+
+	log.Printf("[Operations] listening on %s", listener.Addr())
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("[Operations] ERROR %v", err)
+		}
+	}()
+	return nil
+}
+
+// Addr is the bound address once started (useful with port 0)
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops the listener
+func (s *Server) Close() error {
+	s.mu.Lock()
+	server := s.server
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Close()
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	names := append([]string(nil), s.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = s.checks[name]
+	}
+	s.mu.Unlock()
+
+	status := Status{Status: "OK", Time: time.Now()}
+	for i, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			status.FailedChecks = append(status.FailedChecks, FailedCheck{Component: names[i], Reason: err.Error()})
+		}
+	}
+	if len(status.FailedChecks) > 0 {
+		status.Status = "Service Unavailable"
+		writeStatus(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	writeStatus(w, http.StatusOK, status)
+}
+
+func writeStatus(w http.ResponseWriter, code int, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// TCPCheck is ready once something accepts connections on address. A wildcard listen host
+// (0.0.0.0, ::, empty) is dialled on loopback.
+func TCPCheck(address string) Check {
+	host, port, err := net.SplitHostPort(address)
+	if err == nil {
+		ip := net.ParseIP(host)
+		if host == "" || (ip != nil && ip.IsUnspecified()) {
+			address = net.JoinHostPort("localhost", port)
+		}
+	}
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return fmt.Errorf("not accepting connections on %s: %v", address, err)
+		}
+		return conn.Close()
+	}
+}
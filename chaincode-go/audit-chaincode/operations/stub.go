@@ -0,0 +1,108 @@
+package operations
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// countingStub counts the world state keys one transaction reads and writes.
+// A stub serves a single transaction, so plain counters are enough.
+type countingStub struct {
+	shim.ChaincodeStubInterface
+	reads  int
+	writes int
+}
+
+func (s *countingStub) GetState(key string) ([]byte, error) {
+	s.reads++
+	return s.ChaincodeStubInterface.GetState(key)
+}
+
+func (s *countingStub) PutState(key string, value []byte) error {
+	s.writes++
+	return s.ChaincodeStubInterface.PutState(key, value)
+}
+
+func (s *countingStub) DelState(key string) error {
+	s.writes++
+	return s.ChaincodeStubInterface.DelState(key)
+}
+
+func (s *countingStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return s.states(s.ChaincodeStubInterface.GetStateByRange(startKey, endKey))
+}
+
+func (s *countingStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.statesPage(s.ChaincodeStubInterface.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark))
+}
+
+func (s *countingStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	return s.states(s.ChaincodeStubInterface.GetStateByPartialCompositeKey(objectType, keys))
+}
+
+func (s *countingStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string,
+	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.statesPage(s.ChaincodeStubInterface.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookmark))
+}
+
+func (s *countingStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return s.states(s.ChaincodeStubInterface.GetQueryResult(query))
+}
+
+func (s *countingStub) GetQueryResultWithPagination(query string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.statesPage(s.ChaincodeStubInterface.GetQueryResultWithPagination(query, pageSize, bookmark))
+}
+
+func (s *countingStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	iterator, err := s.ChaincodeStubInterface.GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &countingHistoryIterator{HistoryQueryIteratorInterface: iterator, reads: &s.reads}, nil
+}
+
+func (s *countingStub) states(iterator shim.StateQueryIteratorInterface, err error) (shim.StateQueryIteratorInterface, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &countingIterator{StateQueryIteratorInterface: iterator, reads: &s.reads}, nil
+}
+
+func (s *countingStub) statesPage(iterator shim.StateQueryIteratorInterface, metadata *peer.QueryResponseMetadata,
+	err error) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	return &countingIterator{StateQueryIteratorInterface: iterator, reads: &s.reads}, metadata, nil
+}
+
+// countingIterator counts every record a range, composite key or rich query returns
+type countingIterator struct {
+	shim.StateQueryIteratorInterface
+	reads *int
+}
+
+func (it *countingIterator) Next() (*queryresult.KV, error) {
+	kv, err := it.StateQueryIteratorInterface.Next()
+	if err == nil {
+		*it.reads++
+	}
+	return kv, err
+}
+
+// countingHistoryIterator counts every key modification GetHistoryForKey returns
+type countingHistoryIterator struct {
+	shim.HistoryQueryIteratorInterface
+	reads *int
+}
+
+func (it *countingHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	modification, err := it.HistoryQueryIteratorInterface.Next()
+	if err == nil {
+		*it.reads++
+	}
+	return modification, err
+}
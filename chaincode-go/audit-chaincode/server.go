@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+)
+
+/*
+---- MODULE NOTES ----
+
+server.go reads the Chaincode-as-a-Service settings from the environment:
+
+	CHAINCODE_SERVER_ADDRESS      listen address, host:port (deployCCAAS.sh uses 0.0.0.0:9999)
+	CHAINCODE_ID                  package ID the peer installed, label:hash
+	CHAINCODE_TLS_KEY             PEM private key file    \ both or neither,
+	CHAINCODE_TLS_CERT            PEM certificate file    / TLS is on when set
+	CHAINCODE_CLIENT_CA_CERT      PEM CA bundle; when set, peers must present a client certificate
+	                              signed by it (mutual TLS, needs the TLS key and cert)
+	CHAINCODE_OPERATIONS_ADDRESS  optional host:port for /healthz, /readyz and /metrics
+	                              (operations package), in either mode
+
+With neither CHAINCODE_SERVER_ADDRESS nor CHAINCODE_ID set, the chaincode runs in classic mode
+(the peer launches it and it dials the peer, shim.Start()).
+contractapi's own Start() only looks at CHAINCODE_SERVER_ADDRESS + CORE_CHAINCODE_ID_NAME and the
+peer's CORE_PEER_TLS_* variables, and panics on a bad file, hence this explicit setup.
+Everything is checked before the server starts, so a bad deployment fails with a clear error
+instead of a peer that cannot connect.
+*/
+
+// CCAAS environment variables
+const (
+	envServerAddress = "CHAINCODE_SERVER_ADDRESS"
+	envChaincodeID   = "CHAINCODE_ID"
+	envTLSKey        = "CHAINCODE_TLS_KEY"
+	envTLSCert       = "CHAINCODE_TLS_CERT"
+	envClientCACert  = "CHAINCODE_CLIENT_CA_CERT"
+	envOperations    = "CHAINCODE_OPERATIONS_ADDRESS"
+)
+
+// serverConfig is a validated CCAAS configuration
+type serverConfig struct {
+	Address string
+	CCID    string
+	TLS     shim.TLSProperties
+}
+
+// loadServerConfig reads the CCAAS settings; nil without error means classic mode
+func loadServerConfig(getenv func(string) string) (*serverConfig, error) {
+	address := strings.TrimSpace(getenv(envServerAddress))
+	ccid := strings.TrimSpace(getenv(envChaincodeID))
+	keyPath := strings.TrimSpace(getenv(envTLSKey))
+	certPath := strings.TrimSpace(getenv(envTLSCert))
+	caPath := strings.TrimSpace(getenv(envClientCACert))
+
+	if address == "" && ccid == "" {
+		if keyPath != "" || certPath != "" || caPath != "" {
+			return nil, fmt.Errorf("TLS settings need %s and %s (Chaincode-as-a-Service mode)", envServerAddress, envChaincodeID)
+		}
+		return nil, nil
+	}
+	if address == "" {
+		return nil, fmt.Errorf("%s is set but %s is not", envChaincodeID, envServerAddress)
+	}
+	if ccid == "" {
+		return nil, fmt.Errorf("%s is set but %s is not", envServerAddress, envChaincodeID)
+	}
+
+	// 1. Listen address
+	err := checkListenAddress(envServerAddress, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Package ID
+	label, hash, found := strings.Cut(ccid, ":")
+	if !found || label == "" || hash == "" {
+		return nil, fmt.Errorf("invalid %s %q, expected the package ID label:hash", envChaincodeID, ccid)
+	}
+
+	config := &serverConfig{Address: address, CCID: ccid, TLS: shim.TLSProperties{Disabled: true}}
+
+	// 3. TLS
+	if keyPath == "" && certPath == "" {
+		if caPath != "" {
+			return nil, fmt.Errorf("%s needs %s and %s", envClientCACert, envTLSKey, envTLSCert)
+		}
+		return config, nil
+	}
+	if keyPath == "" {
+		return nil, fmt.Errorf("%s is set but %s is not", envTLSCert, envTLSKey)
+	}
+	if certPath == "" {
+		return nil, fmt.Errorf("%s is set but %s is not", envTLSKey, envTLSCert)
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", envTLSKey, err)
+	}
+	cert, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", envTLSCert, err)
+	}
+	_, err = tls.X509KeyPair(cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS key pair (%s, %s): %v", envTLSCert, envTLSKey, err)
+	}
+	config.TLS = shim.TLSProperties{Key: key, Cert: cert}
+
+	if caPath != "" {
+		clientCA, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", envClientCACert, err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(clientCA) {
+			return nil, fmt.Errorf("invalid %s: no PEM certificates in %s", envClientCACert, caPath)
+		}
+		config.TLS.ClientCACerts = clientCA
+	}
+	return config, nil
+}
+
+// loadOperationsAddress reads the operations listener address; empty means disabled
+func loadOperationsAddress(getenv func(string) string) (string, error) {
+	address := strings.TrimSpace(getenv(envOperations))
+	if address == "" {
+		return "", nil
+	}
+	err := checkListenAddress(envOperations, address)
+	if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// checkListenAddress validates a host:port listen address taken from variable
+func checkListenAddress(variable string, address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q, expected host:port: %v", variable, address, err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return fmt.Errorf("invalid %s %q: port must be 1-65535", variable, address)
+	}
+	return nil
+}
+
+// mode describes the configuration for the startup log
+func (c *serverConfig) mode() string {
+	switch {
+	case c.TLS.Disabled:
+		return "plaintext"
+	case c.TLS.ClientCACerts != nil:
+		return "mutual TLS"
+	default:
+		return "TLS"
+	}
+}
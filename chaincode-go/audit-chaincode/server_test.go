@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCCID = "audit-trail_1.0:0123456789abcdef"
+
+// writeKeyPair writes a self-signed certificate and its key, returning their paths
+func writeKeyPair(t *testing.T, dir string, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, name+".pem")
+	keyPath := filepath.Join(dir, name+".key")
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeKeyPair(t, dir, "server")
+	caPath, otherKeyPath := writeKeyPair(t, dir, "client-ca")
+	garbage := filepath.Join(dir, "garbage.pem")
+	err := os.WriteFile(garbage, []byte("not a certificate"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ccaas := map[string]string{envServerAddress: "0.0.0.0:9999", envChaincodeID: testCCID}
+	with := func(extra map[string]string) map[string]string {
+		env := map[string]string{}
+		for k, v := range ccaas {
+			env[k] = v
+		}
+		for k, v := range extra {
+			env[k] = v
+		}
+		return env
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		classic bool
+		mode    string
+		wantErr string
+	}{
+		{name: "classic", env: map[string]string{}, classic: true},
+		{name: "plaintext", env: ccaas, mode: "plaintext"},
+		{name: "tls", env: with(map[string]string{envTLSKey: keyPath, envTLSCert: certPath}), mode: "TLS"},
+		{name: "mutual tls", env: with(map[string]string{envTLSKey: keyPath, envTLSCert: certPath, envClientCACert: caPath}), mode: "mutual TLS"},
+
+		{name: "address only", env: map[string]string{envServerAddress: ":9999"}, wantErr: envChaincodeID},
+		{name: "id only", env: map[string]string{envChaincodeID: testCCID}, wantErr: envServerAddress},
+		{name: "tls without ccaas", env: map[string]string{envTLSKey: keyPath, envTLSCert: certPath}, wantErr: "TLS settings need"},
+		{name: "no port", env: with(map[string]string{envServerAddress: "0.0.0.0"}), wantErr: "expected host:port"},
+		{name: "bad port", env: with(map[string]string{envServerAddress: "0.0.0.0:99999"}), wantErr: "port must be"},
+		{name: "bad id", env: with(map[string]string{envChaincodeID: "audit-trail"}), wantErr: "label:hash"},
+		{name: "key only", env: with(map[string]string{envTLSKey: keyPath}), wantErr: envTLSCert},
+		{name: "cert only", env: with(map[string]string{envTLSCert: certPath}), wantErr: envTLSKey},
+		{name: "missing key file", env: with(map[string]string{envTLSKey: filepath.Join(dir, "nope.key"), envTLSCert: certPath}), wantErr: "failed to read " + envTLSKey},
+		{name: "mismatched pair", env: with(map[string]string{envTLSKey: otherKeyPath, envTLSCert: certPath}), wantErr: "invalid TLS key pair"},
+		{name: "ca without tls", env: with(map[string]string{envClientCACert: caPath}), wantErr: "needs"},
+		{name: "bad ca", env: with(map[string]string{envTLSKey: keyPath, envTLSCert: certPath, envClientCACert: garbage}), wantErr: "no PEM certificates"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadServerConfig(func(key string) string { return tt.env[key] })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.classic {
+				if config != nil {
+					t.Fatalf("expected classic mode, got %+v", config)
+				}
+				return
+			}
+			if config.Address != tt.env[envServerAddress] || config.CCID != testCCID {
+				t.Errorf("address/ccid = %s %s", config.Address, config.CCID)
+			}
+			if config.mode() != tt.mode {
+				t.Errorf("mode = %s, want %s", config.mode(), tt.mode)
+			}
+		})
+	}
+}
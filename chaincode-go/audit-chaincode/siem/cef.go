@@ -0,0 +1,98 @@
+package siem
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ CEF (ArcSight Common Event Format, revision 25):
+	CEF:0|Vendor|Product|Version|<resourceType>:<action>|<action resourceType>|<severity>|<extension>
+
+ Extension keys, always in this order (empty values are left out):
+	rt            timestamp (ms since epoch)
+	externalId    audit ID
+	suser / spriv userId / userRole
+	act / outcome action / status
+	src           IPv4 address (IPv6 goes to c6a2, "Source IPv6 Address")
+	cat           OCSF class name
+	cs1..cs6      complianceTag, resourceType, resourceId, sessionId, txId, correlationId
+
+ Escaping: header fields escape \ and |, extension values escape \ and = and encode newlines.
+*/
+
+// cefHeaderEscaper escapes CEF header fields
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// cefValueEscaper escapes CEF extension values
+var cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// CEF formats an entry as one CEF line (no trailing newline)
+func CEF(entry *chaincode.AuditEntry, product Product) string {
+	c := Classify(entry)
+
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, field := range []string{
+		product.Vendor, product.Name, product.Version,
+		entry.ResourceType + ":" + entry.Action, eventName(entry),
+	} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(field))
+	}
+	b.WriteByte('|')
+	b.WriteString(strconv.Itoa(cefSeverity(c.SeverityID)))
+	b.WriteByte('|')
+
+	ext := &cefExtension{b: &b}
+	ext.add("rt", strconv.FormatInt(entry.TimeStamp, 10))
+	ext.add("externalId", entry.ID)
+	ext.add("suser", entry.UserID)
+	ext.add("spriv", entry.UserRole)
+	ext.add("act", entry.Action)
+	ext.add("outcome", entry.Status)
+	if isIPv4(entry.IPAddress) {
+		ext.add("src", entry.IPAddress)
+	} else if isIPv6(entry.IPAddress) {
+		ext.labeled("c6a2", "Source IPv6 Address", entry.IPAddress)
+	}
+	ext.add("cat", c.ClassName)
+	ext.labeled("cs1", "ComplianceTag", entry.ComplianceTag)
+	ext.labeled("cs2", "ResourceType", entry.ResourceType)
+	ext.labeled("cs3", "ResourceId", entry.ResourceID)
+	ext.labeled("cs4", "SessionId", entry.SessionID)
+	ext.labeled("cs5", "FabricTxId", entry.TxID)
+	ext.labeled("cs6", "CorrelationId", entry.CorrelationID)
+	return b.String()
+}
+
+// cefExtension writes space separated key=value pairs
+type cefExtension struct {
+	b     *strings.Builder
+	count int
+}
+
+func (e *cefExtension) add(key string, value string) {
+	if value == "" {
+		return
+	}
+	if e.count > 0 {
+		e.b.WriteByte(' ')
+	}
+	e.b.WriteString(key)
+	e.b.WriteByte('=')
+	e.b.WriteString(cefValueEscaper.Replace(value))
+	e.count++
+}
+
+// labeled writes a custom field and its label, e.g. cs1=SOC2 cs1Label=ComplianceTag
+func (e *cefExtension) labeled(key string, label string, value string) {
+	if value == "" {
+		return
+	}
+	e.add(key, value)
+	e.add(key+"Label", label)
+}
@@ -0,0 +1,88 @@
+package siem
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ LEEF 2.0 (IBM QRadar Log Event Extended Format), tab delimited:
+	LEEF:2.0|Vendor|Product|Version|<resourceType>:<action>|x09|<attributes>
+
+ Attributes, always in this order (empty values are left out):
+	devTime, devTimeFormat  timestamp as yyyy-MM-dd'T'HH:mm:ss.SSSZ (UTC)
+	cat, sev                OCSF class name, 0-10 severity
+	usrName, role           userId, userRole
+	src / srcIPv6           IP address (anything else is left out)
+	action, outcome         action, status
+	resource, resourceType  resourceId, resourceType
+	auditId, txId, sessionId, correlationId, complianceTag
+
+ LEEF has no escape sequences: tabs and line breaks in values become spaces, | in header
+ fields is escaped as \|.
+*/
+
+// leefTimeLayout matches the devTimeFormat sent with every event
+const (
+	leefTimeLayout = "2006-01-02T15:04:05.000-0700"
+	leefTimeFormat = "yyyy-MM-dd'T'HH:mm:ss.SSSZ"
+)
+
+// leefHeaderEscaper escapes LEEF header fields
+var leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\t", " ", "\r", " ", "\n", " ")
+
+// leefValueCleaner keeps values on one line and inside their attribute
+var leefValueCleaner = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// LEEF formats an entry as one LEEF 2.0 line (no trailing newline)
+func LEEF(entry *chaincode.AuditEntry, product Product) string {
+	c := Classify(entry)
+
+	var b strings.Builder
+	b.WriteString("LEEF:2.0")
+	for _, field := range []string{product.Vendor, product.Name, product.Version, entry.ResourceType + ":" + entry.Action} {
+		b.WriteByte('|')
+		b.WriteString(leefHeaderEscaper.Replace(field))
+	}
+	b.WriteString("|x09|")
+
+	count := 0
+	add := func(key string, value string) {
+		if value == "" {
+			return
+		}
+		if count > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(leefValueCleaner.Replace(value))
+		count++
+	}
+
+	add("devTime", time.UnixMilli(entry.TimeStamp).UTC().Format(leefTimeLayout))
+	add("devTimeFormat", leefTimeFormat)
+	add("cat", c.ClassName)
+	add("sev", strconv.Itoa(cefSeverity(c.SeverityID)))
+	add("usrName", entry.UserID)
+	add("role", entry.UserRole)
+	if isIPv4(entry.IPAddress) {
+		add("src", entry.IPAddress)
+	} else if isIPv6(entry.IPAddress) {
+		add("srcIPv6", entry.IPAddress)
+	}
+	add("action", entry.Action)
+	add("outcome", entry.Status)
+	add("resource", entry.ResourceID)
+	add("resourceType", entry.ResourceType)
+	add("auditId", entry.ID)
+	add("txId", entry.TxID)
+	add("sessionId", entry.SessionID)
+	add("correlationId", entry.CorrelationID)
+	add("complianceTag", entry.ComplianceTag)
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package siem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ OCSF 1.1.0 events, class picked by Classify:
+	Account Change (3001) - user is the account that changed (resourceId)
+	API Activity (6003)   - api.operation = action, resources = [{type, uid}]
+
+ Common attributes:
+	time                 timestamp (ms since epoch)
+	actor.user           uid = userId, groups = [userRole]
+	actor.session.uid    sessionId
+	src_endpoint.ip      ipAddress
+	metadata.uid         audit ID
+	metadata.correlation_uid  correlationId
+	metadata.labels      [complianceTag]
+	unmapped             fabric_tx_id, parent_id, caused_by
+*/
+
+// OCSFVersion is the OCSF schema version the events follow
+const OCSFVersion = "1.1.0"
+
+// OCSFEvent is one OCSF event, the fields used by Account Change and API Activity
+type OCSFEvent struct {
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	TypeUID      int    `json:"type_uid"`
+	TypeName     string `json:"type_name"`
+	Time         int64  `json:"time"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity"`
+	StatusID     int    `json:"status_id"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+
+	Metadata    OCSFMetadata      `json:"metadata"`
+	Actor       OCSFActor         `json:"actor"`
+	SrcEndpoint *OCSFEndpoint     `json:"src_endpoint,omitempty"`
+	User        *OCSFUser         `json:"user,omitempty"`      // Account Change: the changed account
+	API         *OCSFAPI          `json:"api,omitempty"`       // API Activity
+	Resources   []OCSFResource    `json:"resources,omitempty"` // API Activity
+	Unmapped    map[string]string `json:"unmapped,omitempty"`
+}
+
+// OCSFMetadata is the OCSF metadata object
+type OCSFMetadata struct {
+	Version        string      `json:"version"`
+	Product        OCSFProduct `json:"product"`
+	UID            string      `json:"uid"`
+	CorrelationUID string      `json:"correlation_uid,omitempty"`
+	Labels         []string    `json:"labels,omitempty"`
+}
+
+// OCSFProduct is the OCSF product object
+type OCSFProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version"`
+}
+
+// OCSFActor is the OCSF actor object
+type OCSFActor struct {
+	User    *OCSFUser    `json:"user,omitempty"`
+	Session *OCSFSession `json:"session,omitempty"`
+}
+
+// OCSFUser is the OCSF user object
+type OCSFUser struct {
+	UID    string      `json:"uid"`
+	Groups []OCSFGroup `json:"groups,omitempty"`
+}
+
+// OCSFGroup is the OCSF group object
+type OCSFGroup struct {
+	Name string `json:"name"`
+}
+
+// OCSFSession is the OCSF session object
+type OCSFSession struct {
+	UID string `json:"uid"`
+}
+
+// OCSFEndpoint is the OCSF network endpoint object
+type OCSFEndpoint struct {
+	IP string `json:"ip"`
+}
+
+// OCSFAPI is the OCSF api object
+type OCSFAPI struct {
+	Operation string `json:"operation"`
+}
+
+// OCSFResource is the OCSF resource details object
+type OCSFResource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// OCSF maps an entry to an OCSF event
+func OCSF(entry *chaincode.AuditEntry, product Product) *OCSFEvent {
+	c := Classify(entry)
+
+	event := &OCSFEvent{
+		ActivityID:   c.ActivityID,
+		ActivityName: c.ActivityName,
+		CategoryUID:  c.CategoryUID,
+		CategoryName: c.CategoryName,
+		ClassUID:     c.ClassUID,
+		ClassName:    c.ClassName,
+		TypeUID:      c.TypeUID(),
+		TypeName:     fmt.Sprintf("%s: %s", c.ClassName, c.ActivityName),
+		Time:         entry.TimeStamp,
+		SeverityID:   c.SeverityID,
+		Severity:     c.Severity,
+		StatusID:     c.StatusID,
+		Status:       c.Status,
+		Message:      fmt.Sprintf("%s %s %s by %s: %s", entry.Action, entry.ResourceType, entry.ResourceID, entry.UserID, entry.Status),
+		Metadata: OCSFMetadata{
+			Version:        OCSFVersion,
+			Product:        OCSFProduct{Name: product.Name, VendorName: product.Vendor, Version: product.Version},
+			UID:            entry.ID,
+			CorrelationUID: entry.CorrelationID,
+		},
+	}
+	if entry.ComplianceTag != "" {
+		event.Metadata.Labels = []string{entry.ComplianceTag}
+	}
+
+	if entry.UserID != "" {
+		event.Actor.User = &OCSFUser{UID: entry.UserID}
+		if entry.UserRole != "" {
+			event.Actor.User.Groups = []OCSFGroup{{Name: entry.UserRole}}
+		}
+	}
+	if entry.SessionID != "" {
+		event.Actor.Session = &OCSFSession{UID: entry.SessionID}
+	}
+	if entry.IPAddress != "" {
+		event.SrcEndpoint = &OCSFEndpoint{IP: entry.IPAddress}
+	}
+
+	if c.ClassUID == ClassAccountChange {
+		event.User = &OCSFUser{UID: entry.ResourceID}
+	} else {
+		event.API = &OCSFAPI{Operation: entry.Action}
+		event.Resources = []OCSFResource{{Type: entry.ResourceType, UID: entry.ResourceID}}
+	}
+
+	unmapped := map[string]string{}
+	for key, value := range map[string]string{
+		"fabric_tx_id": entry.TxID,
+		"parent_id":    entry.ParentID,
+		"caused_by":    entry.CausedBy,
+	} {
+		if value != "" {
+			unmapped[key] = value
+		}
+	}
+	if len(unmapped) > 0 {
+		event.Unmapped = unmapped
+	}
+	return event
+}
+
+// OCSFJSON is OCSF as one compact JSON line (no trailing newline)
+func OCSFJSON(entry *chaincode.AuditEntry, product Product) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(OCSF(entry, product))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OCSF event for %s: %v", entry.ID, err)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
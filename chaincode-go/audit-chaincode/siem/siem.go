@@ -0,0 +1,186 @@
+// Package siem maps AuditEntry records to SIEM formats: ArcSight CEF, IBM QRadar LEEF and
+// OCSF events. One Classify mapping drives all three, and both bulk export (export package)
+// and live forwarding use this package, so a SIEM sees the same event either way.
+package siem
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Classify(entry) -> OCSF class + activity + status + severity:
+
+	resourceType USER, action CREATE        -> Account Change (3001) / Create (1)
+	resourceType USER, action DELETE        -> Account Change (3001) / Delete (6)
+	resourceType USER, action UPDATE        -> Account Change (3001) / Other (99)
+	anything else                            -> API Activity (6003):
+		CREATE, ISSUE -> Create (1)   QUERY, VERIFY -> Read (2)
+		UPDATE, REVOKE -> Update (3)  DELETE -> Delete (4)   other -> Other (99)
+
+	status SUCCESS -> Success (1), FAILURE -> Failure (2), else Unknown (0)
+	severity: Informational (1), Low (2) for successful DELETE / REVOKE, Medium (3) for failures
+
+ Old / new values and metadata are never sent to a SIEM, they may hold personal data.
+ The audit ID, TxID and compliance tag always travel, so an analyst can pull the entry
+ (and its inclusion proof) from the ledger.
+*/
+
+// Product identifies the sender in CEF / LEEF headers and OCSF metadata
+type Product struct {
+	Vendor  string
+	Name    string
+	Version string
+}
+
+// DefaultProduct is used when no Product is configured
+var DefaultProduct = Product{Vendor: "AuditTrail", Name: "audit-trail-chaincode", Version: "1.0"}
+
+// OCSF classes
+const (
+	ClassAccountChange = 3001
+	ClassAPIActivity   = 6003
+)
+
+// OCSF status IDs
+const (
+	StatusUnknown = 0
+	StatusSuccess = 1
+	StatusFailure = 2
+)
+
+// OCSF severity IDs
+const (
+	SeverityInformational = 1
+	SeverityLow           = 2
+	SeverityMedium        = 3
+)
+
+// ActivityOther is the OCSF activity for actions without a specific mapping
+const ActivityOther = 99
+
+// Classification is the OCSF view of one entry, shared by every format
+type Classification struct {
+	CategoryUID  int
+	CategoryName string
+	ClassUID     int
+	ClassName    string
+	ActivityID   int
+	ActivityName string
+	StatusID     int
+	Status       string
+	SeverityID   int
+	Severity     string
+}
+
+// TypeUID is the OCSF type_uid: class_uid * 100 + activity_id
+func (c Classification) TypeUID() int {
+	return c.ClassUID*100 + c.ActivityID
+}
+
+// accountChangeActivities maps USER actions to Account Change activities
+var accountChangeActivities = map[string]int{"CREATE": 1, "DELETE": 6, "UPDATE": ActivityOther}
+
+// accountChangeNames are the OCSF Account Change activity names used above
+var accountChangeNames = map[int]string{1: "Create", 6: "Delete", ActivityOther: "Other"}
+
+// apiActivities maps actions to API Activity activities
+var apiActivities = map[string]int{
+	"CREATE": 1, "ISSUE": 1,
+	"QUERY": 2, "VERIFY": 2,
+	"UPDATE": 3, "REVOKE": 3,
+	"DELETE": 4,
+}
+
+// apiActivityNames are the OCSF API Activity activity names
+var apiActivityNames = map[int]string{1: "Create", 2: "Read", 3: "Update", 4: "Delete", ActivityOther: "Other"}
+
+// Classify maps an entry to its OCSF class, activity, status and severity
+func Classify(entry *chaincode.AuditEntry) Classification {
+	var c Classification
+
+	if activity, ok := accountChangeActivities[entry.Action]; ok && entry.ResourceType == "USER" {
+		c.CategoryUID, c.CategoryName = 3, "Identity & Access Management"
+		c.ClassUID, c.ClassName = ClassAccountChange, "Account Change"
+		c.ActivityID, c.ActivityName = activity, accountChangeNames[activity]
+	} else {
+		activity, ok := apiActivities[entry.Action]
+		if !ok {
+			activity = ActivityOther
+		}
+		c.CategoryUID, c.CategoryName = 6, "Application Activity"
+		c.ClassUID, c.ClassName = ClassAPIActivity, "API Activity"
+		c.ActivityID, c.ActivityName = activity, apiActivityNames[activity]
+	}
+
+	switch entry.Status {
+	case "SUCCESS":
+		c.StatusID, c.Status = StatusSuccess, "Success"
+	case "FAILURE":
+		c.StatusID, c.Status = StatusFailure, "Failure"
+	default:
+		c.StatusID, c.Status = StatusUnknown, "Unknown"
+	}
+
+	switch {
+	case c.StatusID == StatusFailure:
+		c.SeverityID, c.Severity = SeverityMedium, "Medium"
+	case entry.Action == "DELETE" || entry.Action == "REVOKE":
+		c.SeverityID, c.Severity = SeverityLow, "Low"
+	default:
+		c.SeverityID, c.Severity = SeverityInformational, "Informational"
+	}
+	return c
+}
+
+// cefSeverity maps OCSF severity to the 0-10 scale of CEF and LEEF
+func cefSeverity(severityID int) int {
+	switch severityID {
+	case SeverityMedium:
+		return 6
+	case SeverityLow:
+		return 4
+	}
+	return 2
+}
+
+// isIPv4 reports whether an address belongs in an IPv4-only field (CEF src)
+func isIPv4(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() != nil
+}
+
+// isIPv6 reports whether an address is a valid IPv6 (and not IPv4) address
+func isIPv6(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// eventName is the human readable event name, e.g. "REVOKE CREDENTIAL"
+func eventName(entry *chaincode.AuditEntry) string {
+	return strings.TrimSpace(entry.Action + " " + entry.ResourceType)
+}
+
+// Formats handled by Format
+const (
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+	FormatOCSF = "ocsf"
+)
+
+// Format renders an entry as one line of a SIEM format (cef, leef or ocsf)
+func Format(format string, entry *chaincode.AuditEntry, product Product) ([]byte, error) {
+	switch format {
+	case FormatCEF:
+		return []byte(CEF(entry, product)), nil
+	case FormatLEEF:
+		return []byte(LEEF(entry, product)), nil
+	case FormatOCSF:
+		return OCSFJSON(entry, product)
+	}
+	return nil, fmt.Errorf("invalid SIEM format: %s. Valid formats: %s, %s, %s", format, FormatCEF, FormatLEEF, FormatOCSF)
+}
@@ -0,0 +1,92 @@
+package siem_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/siem"
+)
+
+// go test ./siem -update rewrites the golden files after an intended mapping change
+var update = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+func loadEntries(t *testing.T) []*chaincode.AuditEntry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "entries.json"))
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %v", err)
+	}
+	var entries []*chaincode.AuditEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		t.Fatalf("failed to parse fixtures: %v", err)
+	}
+	return entries
+}
+
+func TestGoldenFiles(t *testing.T) {
+	entries := loadEntries(t)
+	for _, format := range []string{siem.FormatCEF, siem.FormatLEEF, siem.FormatOCSF} {
+		t.Run(format, func(t *testing.T) {
+			var got bytes.Buffer
+			for _, entry := range entries {
+				line, err := siem.Format(format, entry, siem.DefaultProduct)
+				if err != nil {
+					t.Fatalf("Format(%s) error = %v", entry.ID, err)
+				}
+				got.Write(line)
+				got.WriteByte('\n')
+			}
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				err := os.WriteFile(golden, got.Bytes(), 0o644)
+				if err != nil {
+					t.Fatalf("failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read %s (run with -update to create it): %v", golden, err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("%s output differs from %s\n got:\n%s\nwant:\n%s", format, golden, got.String(), want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		action, resourceType, status string
+		wantType, wantSeverity       int
+	}{
+		{"CREATE", "USER", "SUCCESS", 300101, siem.SeverityInformational},
+		{"DELETE", "USER", "SUCCESS", 300106, siem.SeverityLow},
+		{"UPDATE", "USER", "SUCCESS", 300199, siem.SeverityInformational},
+		{"QUERY", "USER", "SUCCESS", 600302, siem.SeverityInformational},
+		{"ISSUE", "CREDENTIAL", "SUCCESS", 600301, siem.SeverityInformational},
+		{"VERIFY", "CREDENTIAL", "FAILURE", 600302, siem.SeverityMedium},
+		{"REVOKE", "CREDENTIAL", "SUCCESS", 600303, siem.SeverityLow},
+		{"LOGIN", "SESSION", "SUCCESS", 600399, siem.SeverityInformational},
+	}
+	for _, tt := range tests {
+		c := siem.Classify(&chaincode.AuditEntry{Action: tt.action, ResourceType: tt.resourceType, Status: tt.status})
+		if c.TypeUID() != tt.wantType || c.SeverityID != tt.wantSeverity {
+			t.Errorf("Classify(%s %s %s) = type %d severity %d, want %d / %d",
+				tt.action, tt.resourceType, tt.status, c.TypeUID(), c.SeverityID, tt.wantType, tt.wantSeverity)
+		}
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	_, err := siem.Format("syslog", &chaincode.AuditEntry{}, siem.DefaultProduct)
+	if err == nil {
+		t.Fatal("Format() accepted an unknown format")
+	}
+}
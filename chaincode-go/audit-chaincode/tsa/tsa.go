@@ -0,0 +1,205 @@
+// Package tsa requests and verifies RFC 3161 trusted timestamps.
+//
+// Client is used off-chain by the anchoring service (cmd/audit-anchor). Verify is shared with
+// the chaincode, which checks every token before storing it and again on every read, so it
+// must stay deterministic: certificate chains are checked at the token's own genTime, never
+// at the local clock.
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Flow:
+	digest := sha256(data)                  - e.g. a checkpoint statement (chaincode/anchor.go)
+	token, _ := client.Timestamp(ctx, digest) - DER TimeStampToken (CMS SignedData)
+	verified, _ := Verify(token, digest, roots)
+
+Verify checks, in order:
+	1. token parses and its CMS signature is valid
+	2. the token's message imprint is SHA-256 and equals digest
+	3. the signer certificate chains to roots, was valid at genTime and carries the
+	   id-kp-timeStamping extended key usage
+*/
+
+// Content types of the RFC 3161 HTTP transport (RFC 3161 section 3.4)
+const (
+	contentTypeQuery = "application/timestamp-query"
+	contentTypeReply = "application/timestamp-reply"
+)
+
+// maxResponseSize bounds the TSA reply, real tokens are a few KB
+const maxResponseSize = 1 << 20
+
+// Client requests timestamps from one Time Stamping Authority over HTTP
+type Client struct {
+	URL        string       // TSA endpoint, e.g. https://freetsa.org/tsr
+	HTTPClient *http.Client // nil = http.DefaultClient
+}
+
+// Verified describes a token that passed Verify
+type Verified struct {
+	GenTime      time.Time           // Time the TSA vouches for
+	SerialNumber string              // TSA serial number of the token (decimal)
+	Policy       string              // TSA policy OID
+	Signer       *x509.Certificate   // TSA signing certificate
+	Chain        []*x509.Certificate // Signer up to the trusted root
+}
+
+// Timestamp asks the TSA to timestamp a SHA-256 digest and returns the DER token
+func (c *Client) Timestamp(ctx context.Context, digest []byte) ([]byte, error) {
+	if len(digest) != crypto.SHA256.Size() {
+		return nil, fmt.Errorf("digest must be a SHA-256 hash (%d bytes), got %d bytes", crypto.SHA256.Size(), len(digest))
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	request, err := (&timestamp.Request{
+		HashAlgorithm: crypto.SHA256,
+		HashedMessage: digest,
+		Certificates:  true,
+		Nonce:         nonce,
+	}).Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build timestamp request: %v", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %v", err)
+	}
+	httpRequest.Header.Set("Content-Type", contentTypeQuery)
+	httpRequest.Header.Set("Accept", contentTypeReply)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	httpResponse, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp request to %s failed: %v", c.URL, err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA %s answered HTTP %d", c.URL, httpResponse.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TSA response: %v", err)
+	}
+
+	response, err := timestamp.ParseResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("TSA %s rejected the request: %v", c.URL, err)
+	}
+	if response.Nonce == nil || response.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("TSA response nonce does not match the request")
+	}
+	if !bytes.Equal(response.HashedMessage, digest) {
+		return nil, fmt.Errorf("TSA response timestamps a different digest")
+	}
+	return response.RawToken, nil
+}
+
+// Verify checks a DER token covers digest and was signed by a TSA chaining to roots
+func Verify(token []byte, digest []byte, roots *x509.CertPool) (*Verified, error) {
+	if roots == nil {
+		return nil, fmt.Errorf("no trusted TSA roots")
+	}
+
+	parsed, err := timestamp.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %v", err)
+	}
+	if parsed.HashAlgorithm != crypto.SHA256 {
+		return nil, fmt.Errorf("timestamp token uses %v, want SHA-256", parsed.HashAlgorithm)
+	}
+	if !bytes.Equal(parsed.HashedMessage, digest) {
+		return nil, fmt.Errorf("timestamp token covers a different digest")
+	}
+
+	signed, err := pkcs7.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp token: %v", err)
+	}
+	if len(signed.Certificates) == 0 {
+		return nil, fmt.Errorf("timestamp token carries no TSA certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range signed.Certificates {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   parsed.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	err = signed.VerifyWithOpts(opts)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp token is not signed by a trusted TSA: %v", err)
+	}
+
+	signer := signed.GetOnlySigner()
+	if signer == nil {
+		return nil, fmt.Errorf("timestamp token must have exactly one signer")
+	}
+	chains, err := signer.Verify(opts)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp token is not signed by a trusted TSA: %v", err)
+	}
+
+	verified := &Verified{
+		GenTime: parsed.Time,
+		Policy:  parsed.Policy.String(),
+		Signer:  signer,
+		Chain:   chains[0],
+	}
+	if parsed.SerialNumber != nil {
+		verified.SerialNumber = parsed.SerialNumber.String()
+	}
+	return verified, nil
+}
+
+// ParseCertificates decodes every certificate in a PEM bundle
+func ParseCertificates(pemBundle []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := pemBundle
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return certs, nil
+}
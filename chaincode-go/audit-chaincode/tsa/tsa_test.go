@@ -0,0 +1,113 @@
+package tsa_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa/tsatest"
+)
+
+func newStandIn(t *testing.T) *tsatest.Server {
+	t.Helper()
+	server, err := tsatest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start TSA stand-in: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTimestampAndVerify(t *testing.T) {
+	server := newStandIn(t)
+	genTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	server.SetNow(func() time.Time { return genTime })
+
+	digest := sha256.Sum256([]byte("audit-trail-checkpoint:v1\nckpt-00000001"))
+	client := &tsa.Client{URL: server.URL}
+	token, err := client.Timestamp(context.Background(), digest[:])
+	if err != nil {
+		t.Fatalf("Timestamp() error = %v", err)
+	}
+
+	verified, err := tsa.Verify(token, digest[:], server.Pool())
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !verified.GenTime.Equal(genTime) {
+		t.Errorf("GenTime = %v, want %v", verified.GenTime, genTime)
+	}
+	if verified.Signer.Subject.CommonName != "tsatest TSA" {
+		t.Errorf("Signer = %q, want tsatest TSA", verified.Signer.Subject.CommonName)
+	}
+	if verified.SerialNumber == "" {
+		t.Error("SerialNumber is empty")
+	}
+}
+
+func TestVerifyRejectsOtherDigest(t *testing.T) {
+	server := newStandIn(t)
+	digest := sha256.Sum256([]byte("entry"))
+	token, err := server.Issue(digest[:])
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	other := sha256.Sum256([]byte("tampered entry"))
+	_, err = tsa.Verify(token, other[:], server.Pool())
+	if err == nil || !strings.Contains(err.Error(), "different digest") {
+		t.Fatalf("Verify() error = %v, want different digest", err)
+	}
+}
+
+func TestVerifyRejectsUntrustedTSA(t *testing.T) {
+	server := newStandIn(t)
+	untrusted := newStandIn(t)
+	digest := sha256.Sum256([]byte("entry"))
+	token, err := untrusted.Issue(digest[:])
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	_, err = tsa.Verify(token, digest[:], server.Pool())
+	if err == nil || !strings.Contains(err.Error(), "trusted TSA") {
+		t.Fatalf("Verify() error = %v, want untrusted TSA", err)
+	}
+}
+
+func TestVerifyChecksChainAtGenTime(t *testing.T) {
+	server := newStandIn(t)
+	// Outside the stand-in certificates' validity window
+	server.SetNow(func() time.Time { return time.Now().Add(48 * time.Hour) })
+	digest := sha256.Sum256([]byte("entry"))
+	token, err := server.Issue(digest[:])
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	_, err = tsa.Verify(token, digest[:], server.Pool())
+	if err == nil {
+		t.Fatal("Verify() accepted a token issued after the TSA certificate expired")
+	}
+}
+
+func TestTimestampRejected(t *testing.T) {
+	server := newStandIn(t)
+	server.SetReject(true)
+	digest := sha256.Sum256([]byte("entry"))
+
+	_, err := (&tsa.Client{URL: server.URL}).Timestamp(context.Background(), digest[:])
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("Timestamp() error = %v, want rejected", err)
+	}
+}
+
+func TestTimestampRequiresSHA256Digest(t *testing.T) {
+	_, err := (&tsa.Client{URL: "http://127.0.0.1:0"}).Timestamp(context.Background(), []byte("short"))
+	if err == nil {
+		t.Fatal("Timestamp() accepted a digest that is not SHA-256 sized")
+	}
+}
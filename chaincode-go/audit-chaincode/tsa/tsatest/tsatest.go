@@ -0,0 +1,205 @@
+// Package tsatest runs a local RFC 3161 Time Stamping Authority stand-in for tests.
+//
+// The stand-in has its own throwaway root CA and a TSA certificate with the
+// id-kp-timeStamping extended key usage, so tokens it issues pass tsa.Verify
+// when its root is trusted, and fail against any other root.
+package tsatest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/digitorus/timestamp"
+)
+
+// policyOID is the TSA policy the stand-in stamps into its tokens
+var policyOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+// Server is a running TSA stand-in
+type Server struct {
+	URL     string            // POST timestamp queries here
+	Root    *x509.Certificate // Trust anchor of the stand-in
+	RootPEM []byte            // Root as PEM, e.g. for RegistryContract-style trust stores
+	Signer  *x509.Certificate // TSA signing certificate
+
+	mu       sync.Mutex
+	now      func() time.Time
+	reject   bool
+	requests int
+
+	httpServer *httptest.Server
+	signerKey  crypto.Signer
+}
+
+// NewServer starts a stand-in TSA on a local port, Close it when done
+func NewServer() (*Server, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	notBefore := time.Now().Add(-time.Hour)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tsatest root CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, err
+	}
+
+	signerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signerTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "tsatest TSA"},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	signerDER, err := x509.CreateCertificate(rand.Reader, signerTemplate, root, &signerKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := x509.ParseCertificate(signerDER)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Root:      root,
+		RootPEM:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}),
+		Signer:    signer,
+		now:       time.Now,
+		signerKey: signerKey,
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.httpServer.URL
+	return s, nil
+}
+
+// Close shuts the stand-in down
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// Pool returns a cert pool trusting only this stand-in
+func (s *Server) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Root)
+	return pool
+}
+
+// SetNow fixes the genTime of future tokens
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetReject makes the stand-in answer every request with a rejection status
+func (s *Server) SetReject(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+// Requests returns how many timestamp queries were answered
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Issue signs a token for digest directly, without HTTP
+func (s *Server) Issue(digest []byte) ([]byte, error) {
+	response, err := s.respond(&timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: digest})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := timestamp.ParseResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.RawToken, nil
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a timestamp query", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var response []byte
+	s.mu.Lock()
+	reject := s.reject
+	s.mu.Unlock()
+	if reject {
+		response, err = timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.BadRequest)
+	} else {
+		var request *timestamp.Request
+		request, err = timestamp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response, err = s.respond(request)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	_, _ = w.Write(response)
+}
+
+func (s *Server) respond(request *timestamp.Request) ([]byte, error) {
+	s.mu.Lock()
+	now := s.now()
+	s.requests++
+	s.mu.Unlock()
+
+	stamp := &timestamp.Timestamp{
+		HashAlgorithm:     request.HashAlgorithm,
+		HashedMessage:     request.HashedMessage,
+		Time:              now.UTC().Truncate(time.Second),
+		Nonce:             request.Nonce,
+		Policy:            policyOID,
+		AddTSACertificate: true,
+	}
+	response, err := stamp.CreateResponseWithOpts(s.Signer, s.signerKey, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timestamp response: %v", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,402 @@
+// Package verify checks an exported audit trail offline: no peer, no Fabric identity.
+//
+// Input is the export of AuditEntry records (each carrying its TxID) plus the checkpoints
+// covering them, as returned by CheckpointContract:GetCheckpoint. Every hash, chain link
+// and Merkle root is recomputed from the entries and compared with the stored digests.
+package verify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/merkle"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/tsa"
+)
+
+/*
+ ----- MODULE NOTES: -----
+ Checks, per checkpoint (sorted by sequence):
+	- statement recomputed from the checkpoint fields          -> STATEMENT_MISMATCH
+	- treeSize = number of leaves, root = Merkle root of leaves -> ROOT_MISMATCH
+	- sequence, window and prevId/prevHash link to the previous -> CHAIN_BROKEN
+	- every countersignature verifies against its certificate  -> BAD_SIGNATURE
+	- RFC 3161 anchor (optional, needs TSA roots)               -> BAD_ANCHOR
+
+ Per entry:
+	- each leaf has an exported entry                      -> MISSING
+	- each exported entry hashes to its leaf hash           -> MODIFIED
+	- an entry inside a checkpoint window is one of its leaves -> UNEXPECTED
+	- an audit ID appears once                              -> DUPLICATE
+	- export order is timestamp ascending, then audit ID    -> REORDERED
+	- the TxID is present                                   -> MISSING_TXID
+	- entries outside every checkpoint window can't be proven -> UNSEALED (warning only)
+
+Only checkpoints handed to Verify are checked: export whole checkpoint windows, and include
+the checkpoint before the first one to also check the first prevHash link.
+*/
+
+// Finding kinds
+const (
+	KindMissing           = "MISSING"
+	KindModified          = "MODIFIED"
+	KindUnexpected        = "UNEXPECTED"
+	KindDuplicate         = "DUPLICATE"
+	KindReordered         = "REORDERED"
+	KindMissingTxID       = "MISSING_TXID"
+	KindUnsealed          = "UNSEALED"
+	KindStatementMismatch = "STATEMENT_MISMATCH"
+	KindRootMismatch      = "ROOT_MISMATCH"
+	KindChainBroken       = "CHAIN_BROKEN"
+	KindBadSignature      = "BAD_SIGNATURE"
+	KindBadAnchor         = "BAD_ANCHOR"
+)
+
+// Finding is one problem found in the export
+type Finding struct {
+	Kind         string `json:"kind"`
+	AuditID      string `json:"auditId,omitempty"`
+	CheckpointID string `json:"checkpointId,omitempty"`
+	Detail       string `json:"detail"`
+	Warning      bool   `json:"warning,omitempty"` // Does not fail verification
+}
+
+// Report is the outcome of Verify
+type Report struct {
+	Entries     int       `json:"entries"`     // Exported entries read (duplicates included)
+	Sealed      int       `json:"sealed"`      // Entries proven by a checkpoint
+	Checkpoints int       `json:"checkpoints"` // Checkpoints checked
+	Anchored    int       `json:"anchored"`    // Checkpoints with a valid RFC 3161 anchor
+	Findings    []Finding `json:"findings"`
+}
+
+// Options tune Verify
+type Options struct {
+	Anchors  []*chaincode.Anchor // CHECKPOINT anchors to check (optional)
+	TSARoots []byte              // PEM roots the anchors must chain to
+}
+
+// OK reports whether the export passed, warnings aside
+func (r *Report) OK() bool {
+	for _, finding := range r.Findings {
+		if !finding.Warning {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks entries against checkpoints
+func Verify(entries []*chaincode.AuditEntry, checkpoints []*chaincode.Checkpoint, opts Options) *Report {
+	report := &Report{Entries: len(entries), Checkpoints: len(checkpoints), Findings: []Finding{}}
+
+	checkpoints = append([]*chaincode.Checkpoint{}, checkpoints...)
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Sequence < checkpoints[j].Sequence })
+
+	for i, checkpoint := range checkpoints {
+		var previous *chaincode.Checkpoint
+		if i > 0 {
+			previous = checkpoints[i-1]
+		}
+		report.checkCheckpoint(checkpoint, previous)
+	}
+	report.checkAnchors(checkpoints, opts)
+
+	// Unique entries, in export order
+	byID := map[string]*chaincode.AuditEntry{}
+	for i, entry := range entries {
+		if first, seen := byID[entry.ID]; seen {
+			detail := "exported more than once (identical copy)"
+			if !sameEntry(first, entry) {
+				detail = "exported more than once with different content"
+			}
+			report.add(Finding{Kind: KindDuplicate, AuditID: entry.ID, Detail: detail})
+			continue
+		}
+		byID[entry.ID] = entry
+
+		if entry.TxID == "" {
+			report.add(Finding{Kind: KindMissingTxID, AuditID: entry.ID, Detail: "entry carries no transaction ID"})
+		}
+		if i > 0 && before(entry, entries[i-1]) {
+			report.add(Finding{Kind: KindReordered, AuditID: entry.ID,
+				Detail: fmt.Sprintf("exported after %s but sorts before it (timestamp %d)", entries[i-1].ID, entry.TimeStamp)})
+		}
+	}
+
+	// Leaves against entries
+	sealed := map[string]string{}
+	for _, checkpoint := range checkpoints {
+		for index, leaf := range checkpoint.Leaves {
+			sealed[leaf.AuditID] = checkpoint.ID
+			entry, ok := byID[leaf.AuditID]
+			if !ok {
+				report.add(Finding{Kind: KindMissing, AuditID: leaf.AuditID, CheckpointID: checkpoint.ID,
+					Detail: fmt.Sprintf("leaf %d is not in the export", index)})
+				continue
+			}
+			leafHash, err := chaincode.AuditLeafHash(entry)
+			if err != nil || hex.EncodeToString(leafHash) != leaf.Hash {
+				report.add(Finding{Kind: KindModified, AuditID: leaf.AuditID, CheckpointID: checkpoint.ID,
+					Detail: fmt.Sprintf("entry does not hash to leaf %d", index)})
+				continue
+			}
+			report.Sealed++
+		}
+	}
+
+	// Entries no leaf accounts for
+	for _, entry := range uniqueInOrder(entries) {
+		if _, ok := sealed[entry.ID]; ok {
+			continue
+		}
+		window := windowOf(checkpoints, entry.TimeStamp)
+		if window != nil {
+			report.add(Finding{Kind: KindUnexpected, AuditID: entry.ID, CheckpointID: window.ID,
+				Detail: fmt.Sprintf("timestamp %d is inside the checkpoint window but the entry is not one of its leaves", entry.TimeStamp)})
+			continue
+		}
+		report.add(Finding{Kind: KindUnsealed, AuditID: entry.ID, Warning: true,
+			Detail: fmt.Sprintf("timestamp %d is outside every checkpoint, it cannot be proven", entry.TimeStamp)})
+	}
+
+	return report
+}
+
+// checkCheckpoint recomputes one checkpoint's statement, root and link to previous
+func (r *Report) checkCheckpoint(checkpoint *chaincode.Checkpoint, previous *chaincode.Checkpoint) {
+	if chaincode.CheckpointStatement(checkpoint) != checkpoint.Statement {
+		r.add(Finding{Kind: KindStatementMismatch, CheckpointID: checkpoint.ID,
+			Detail: "stored statement does not match the checkpoint fields"})
+	}
+
+	hashes := make([][]byte, 0, len(checkpoint.Leaves))
+	for index, leaf := range checkpoint.Leaves {
+		leafHash, err := hex.DecodeString(leaf.Hash)
+		if err != nil || len(leafHash) != merkle.HashSize {
+			r.add(Finding{Kind: KindRootMismatch, CheckpointID: checkpoint.ID, AuditID: leaf.AuditID,
+				Detail: fmt.Sprintf("leaf %d hash is not a hex SHA-256", index)})
+			return
+		}
+		hashes = append(hashes, leafHash)
+	}
+	if checkpoint.TreeSize != len(checkpoint.Leaves) {
+		r.add(Finding{Kind: KindRootMismatch, CheckpointID: checkpoint.ID,
+			Detail: fmt.Sprintf("treeSize is %d but the checkpoint lists %d leaves", checkpoint.TreeSize, len(checkpoint.Leaves))})
+	}
+	if root := hex.EncodeToString(merkle.Root(hashes)); root != checkpoint.Root {
+		r.add(Finding{Kind: KindRootMismatch, CheckpointID: checkpoint.ID,
+			Detail: fmt.Sprintf("leaves hash to root %s, checkpoint says %s", root, checkpoint.Root)})
+	}
+
+	if previous != nil {
+		switch {
+		case checkpoint.Sequence != previous.Sequence+1:
+			r.add(Finding{Kind: KindChainBroken, CheckpointID: checkpoint.ID,
+				Detail: fmt.Sprintf("sequence %d follows %d, checkpoints are missing", checkpoint.Sequence, previous.Sequence)})
+		case checkpoint.PrevID != previous.ID:
+			r.add(Finding{Kind: KindChainBroken, CheckpointID: checkpoint.ID,
+				Detail: fmt.Sprintf("prevId is %s, want %s", checkpoint.PrevID, previous.ID)})
+		case checkpoint.PrevHash != statementHash(previous.Statement):
+			r.add(Finding{Kind: KindChainBroken, CheckpointID: checkpoint.ID,
+				Detail: fmt.Sprintf("prevHash does not match the statement of %s", previous.ID)})
+		case checkpoint.StartTs != previous.EndTs+1:
+			r.add(Finding{Kind: KindChainBroken, CheckpointID: checkpoint.ID,
+				Detail: fmt.Sprintf("window starts at %d, %s ends at %d", checkpoint.StartTs, previous.ID, previous.EndTs)})
+		}
+	}
+
+	digest := sha256.Sum256([]byte(checkpoint.Statement))
+	for _, signature := range checkpoint.Signatures {
+		err := verifySignature(signature, digest[:])
+		if err != nil {
+			r.add(Finding{Kind: KindBadSignature, CheckpointID: checkpoint.ID,
+				Detail: fmt.Sprintf("countersignature of %s: %v", signature.MSPID, err)})
+		}
+	}
+}
+
+// checkAnchors verifies the RFC 3161 tokens of checkpoints
+func (r *Report) checkAnchors(checkpoints []*chaincode.Checkpoint, opts Options) {
+	if len(opts.Anchors) == 0 {
+		return
+	}
+	roots, err := tsa.ParseCertificates(opts.TSARoots)
+	if err != nil {
+		r.add(Finding{Kind: KindBadAnchor, Detail: fmt.Sprintf("TSA roots: %v", err)})
+		return
+	}
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		pool.AddCert(root)
+	}
+
+	byID := map[string]*chaincode.Checkpoint{}
+	for _, checkpoint := range checkpoints {
+		byID[checkpoint.ID] = checkpoint
+	}
+	for _, anchor := range opts.Anchors {
+		checkpoint, ok := byID[anchor.TargetID]
+		if anchor.TargetType != chaincode.AnchorTargetCheckpoint || !ok {
+			continue
+		}
+		token, err := base64.StdEncoding.DecodeString(anchor.Token)
+		if err == nil {
+			_, err = tsa.Verify(token, chaincode.CheckpointDigest(checkpoint), pool)
+		}
+		if err != nil {
+			r.add(Finding{Kind: KindBadAnchor, CheckpointID: checkpoint.ID, Detail: err.Error()})
+			continue
+		}
+		r.Anchored++
+	}
+}
+
+// WriteText writes a human readable report
+func (r *Report) WriteText(w io.Writer) error {
+	status := "PASSED"
+	if !r.OK() {
+		status = "FAILED"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "audit-verify: %s\n", status)
+	fmt.Fprintf(&buf, "  entries: %d read, %d sealed by checkpoints\n", r.Entries, r.Sealed)
+	fmt.Fprintf(&buf, "  checkpoints: %d checked, %d anchored\n", r.Checkpoints, r.Anchored)
+	fmt.Fprintf(&buf, "  findings: %d\n", len(r.Findings))
+	for _, finding := range r.Findings {
+		level := "ERROR"
+		if finding.Warning {
+			level = "WARN "
+		}
+		fmt.Fprintf(&buf, "  %s %-18s %-14s %-14s %s\n", level, finding.Kind,
+			orDash(finding.CheckpointID), orDash(finding.AuditID), finding.Detail)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (r *Report) add(finding Finding) {
+	r.Findings = append(r.Findings, finding)
+}
+
+// verifySignature checks one countersignature against the certificate stored with it
+func verifySignature(signature chaincode.CheckpointSignature, digest []byte) error {
+	certs, err := tsa.ParseCertificates([]byte(signature.Certificate))
+	if err != nil {
+		return err
+	}
+	publicKey, ok := certs[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certificate does not hold an ECDSA key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64")
+	}
+	if !ecdsa.VerifyASN1(publicKey, digest, sig) {
+		return fmt.Errorf("signature does not verify")
+	}
+	return nil
+}
+
+// statementHash matches the chaincode's prevHash
+func statementHash(statement string) string {
+	sum := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(sum[:])
+}
+
+// before orders entries like checkpoint leaves: timestamp, then audit ID
+func before(a *chaincode.AuditEntry, b *chaincode.AuditEntry) bool {
+	if a.TimeStamp != b.TimeStamp {
+		return a.TimeStamp < b.TimeStamp
+	}
+	return a.ID < b.ID
+}
+
+func sameEntry(a *chaincode.AuditEntry, b *chaincode.AuditEntry) bool {
+	aJSON, errA := chaincode.CanonicalAuditJSON(a)
+	bJSON, errB := chaincode.CanonicalAuditJSON(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+func uniqueInOrder(entries []*chaincode.AuditEntry) []*chaincode.AuditEntry {
+	seen := map[string]bool{}
+	unique := make([]*chaincode.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !seen[entry.ID] {
+			seen[entry.ID] = true
+			unique = append(unique, entry)
+		}
+	}
+	return unique
+}
+
+func windowOf(checkpoints []*chaincode.Checkpoint, timestamp int64) *chaincode.Checkpoint {
+	for _, checkpoint := range checkpoints {
+		if timestamp >= checkpoint.StartTs && timestamp <= checkpoint.EndTs {
+			return checkpoint
+		}
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// ReadEntries reads audit entries from a JSON array (GetAllAudits) or JSON Lines
+func ReadEntries(r io.Reader) ([]*chaincode.AuditEntry, error) {
+	return readAll[chaincode.AuditEntry](r)
+}
+
+// ReadCheckpoints reads checkpoints from a JSON array, JSON Lines or a single object
+func ReadCheckpoints(r io.Reader) ([]*chaincode.Checkpoint, error) {
+	return readAll[chaincode.Checkpoint](r)
+}
+
+// ReadAnchors reads anchors from a JSON array, JSON Lines or a single object
+func ReadAnchors(r io.Reader) ([]*chaincode.Anchor, error) {
+	return readAll[chaincode.Anchor](r)
+}
+
+// readAll decodes a JSON array, or a stream of JSON objects (JSON Lines)
+func readAll[T any](r io.Reader) ([]*T, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []*T
+		err = json.Unmarshal(trimmed, &items)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %v", err)
+		}
+		return items, nil
+	}
+
+	items := []*T{}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	for record := 1; ; record++ {
+		var item T
+		err = decoder.Decode(&item)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON record %d: %v", record, err)
+		}
+		items = append(items, &item)
+	}
+}
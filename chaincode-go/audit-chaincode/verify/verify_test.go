@@ -0,0 +1,198 @@
+package verify_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/chaincode"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/merkle"
+	"github.com/ck496/audit-trail/chaincode-go/audit-chaincode/verify"
+)
+
+// fixture returns 6 entries sealed by two checkpoints (3 entries each), built like CreateCheckpoint
+func fixture(t *testing.T) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+	t.Helper()
+	var entries []*chaincode.AuditEntry
+	for i := 1; i <= 6; i++ {
+		entries = append(entries, &chaincode.AuditEntry{
+			ID:        fmt.Sprintf("audit-%03d", i),
+			TimeStamp: int64(1000 * i),
+			UserID:    "user-alice",
+			Action:    "UPDATE",
+			Status:    "SUCCESS",
+			TxID:      fmt.Sprintf("tx-%03d", i),
+		})
+	}
+
+	var checkpoints []*chaincode.Checkpoint
+	prevID, prevHash := "", ""
+	for sequence, window := range [][]*chaincode.AuditEntry{entries[:3], entries[3:]} {
+		checkpoint := &chaincode.Checkpoint{
+			ID:       fmt.Sprintf("ckpt-%08d", sequence+1),
+			Sequence: sequence + 1,
+			StartTs:  int64(3000*sequence + 1),
+			EndTs:    int64(3000 * (sequence + 1)),
+			PrevID:   prevID,
+			PrevHash: prevHash,
+		}
+		var hashes [][]byte
+		for _, entry := range window {
+			leafHash, err := chaincode.AuditLeafHash(entry)
+			if err != nil {
+				t.Fatalf("AuditLeafHash() error = %v", err)
+			}
+			hashes = append(hashes, leafHash)
+			checkpoint.Leaves = append(checkpoint.Leaves, chaincode.CheckpointLeaf{AuditID: entry.ID, Hash: hex.EncodeToString(leafHash)})
+		}
+		checkpoint.TreeSize = len(hashes)
+		checkpoint.Root = hex.EncodeToString(merkle.Root(hashes))
+		checkpoint.Statement = chaincode.CheckpointStatement(checkpoint)
+		checkpoints = append(checkpoints, checkpoint)
+
+		sum := sha256.Sum256([]byte(checkpoint.Statement))
+		prevID, prevHash = checkpoint.ID, hex.EncodeToString(sum[:])
+	}
+	return entries, checkpoints
+}
+
+func kinds(report *verify.Report) []string {
+	var found []string
+	for _, finding := range report.Findings {
+		found = append(found, finding.Kind+":"+finding.AuditID+finding.CheckpointID)
+	}
+	return found
+}
+
+func TestVerifyIntactExport(t *testing.T) {
+	entries, checkpoints := fixture(t)
+	report := verify.Verify(entries, checkpoints, verify.Options{})
+	if !report.OK() || len(report.Findings) != 0 {
+		t.Fatalf("findings = %v, want none", kinds(report))
+	}
+	if report.Sealed != 6 {
+		t.Errorf("Sealed = %d, want 6", report.Sealed)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(entries []*chaincode.AuditEntry, checkpoints []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint)
+		want   string
+	}{
+		{
+			name: "missing",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				return append(e[:1:1], e[2:]...), c
+			},
+			want: "MISSING:audit-002ckpt-00000001",
+		},
+		{
+			name: "modified",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				e[4].Status = "FAILURE"
+				return e, c
+			},
+			want: "MODIFIED:audit-005ckpt-00000002",
+		},
+		{
+			name: "duplicated",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				return append(e, e[5]), c
+			},
+			want: "DUPLICATE:audit-006",
+		},
+		{
+			name: "reordered",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				e[1], e[2] = e[2], e[1]
+				return e, c
+			},
+			want: "REORDERED:audit-002",
+		},
+		{
+			name: "inserted",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				extra := &chaincode.AuditEntry{ID: "audit-099", TimeStamp: 6000, TxID: "tx-099"}
+				return append(e, extra), c
+			},
+			want: "UNEXPECTED:audit-099ckpt-00000002",
+		},
+		{
+			name: "root rewritten",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				c[0].Leaves = c[0].Leaves[:2]
+				return e, c
+			},
+			want: "ROOT_MISMATCH:ckpt-00000001",
+		},
+		{
+			name: "chain broken",
+			tamper: func(e []*chaincode.AuditEntry, c []*chaincode.Checkpoint) ([]*chaincode.AuditEntry, []*chaincode.Checkpoint) {
+				c[1].PrevHash = strings.Repeat("0", 64)
+				c[1].Statement = chaincode.CheckpointStatement(c[1])
+				return e, c
+			},
+			want: "CHAIN_BROKEN:ckpt-00000002",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, checkpoints := tt.tamper(fixture(t))
+			report := verify.Verify(entries, checkpoints, verify.Options{})
+			if report.OK() {
+				t.Fatalf("Verify() passed a tampered export")
+			}
+			found := kinds(report)
+			for _, kind := range found {
+				if kind == tt.want {
+					return
+				}
+			}
+			t.Fatalf("findings = %v, want %s", found, tt.want)
+		})
+	}
+}
+
+func TestVerifyUnsealedIsWarning(t *testing.T) {
+	entries, checkpoints := fixture(t)
+	entries = append(entries, &chaincode.AuditEntry{ID: "audit-007", TimeStamp: 9000, TxID: "tx-007"})
+	report := verify.Verify(entries, checkpoints, verify.Options{})
+	if !report.OK() {
+		t.Fatalf("findings = %v, want only warnings", kinds(report))
+	}
+	if got := kinds(report); len(got) != 1 || got[0] != "UNSEALED:audit-007" {
+		t.Errorf("findings = %v, want [UNSEALED:audit-007]", got)
+	}
+}
+
+func TestReadEntriesArrayAndLines(t *testing.T) {
+	array := `[{"id":"audit-001","timestamp":1},{"id":"audit-002","timestamp":2}]`
+	lines := "{\"id\":\"audit-001\",\"timestamp\":1}\n{\"id\":\"audit-002\",\"timestamp\":2}\n"
+	for _, input := range []string{array, lines} {
+		entries, err := verify.ReadEntries(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ReadEntries() error = %v", err)
+		}
+		if len(entries) != 2 || entries[1].ID != "audit-002" {
+			t.Errorf("ReadEntries() = %d entries, want audit-001, audit-002", len(entries))
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	entries, checkpoints := fixture(t)
+	var out bytes.Buffer
+	err := verify.Verify(entries[1:], checkpoints, verify.Options{}).WriteText(&out)
+	if err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "FAILED") || !strings.Contains(out.String(), "audit-001") {
+		t.Errorf("report does not name the missing entry:\n%s", out.String())
+	}
+}
@@ -1,7 +1,10 @@
-import { useState } from 'react'
-import { registerUser } from '../services/api'
+import { useEffect, useState } from 'react'
+import { listUsers, registerUser } from '../services/api'
 import './UserManagement.css'
 
+// Users per directory page (ListUsers allows 1-100)
+const PAGE_SIZE = 10;
+
 const UserManagement = ({ addUser, recentUsers }) => {
     const [formData, setFormData] = useState({
         username: '',
@@ -14,7 +17,36 @@ const UserManagement = ({ addUser, recentUsers }) => {
         [error, setError] = useState(null),
         [success, setSuccess] = useState(null);
 
+    // User directory: one ListUsers page at a time, bookmarks of the pages before it kept for "Previous"
+    const [filters, setFilters] = useState({ role: '', organization: '', active: '' });
+    const [directory, setDirectory] = useState({ users: [], bookmark: '' }),
+        [pageBookmarks, setPageBookmarks] = useState(['']),
+        [directoryLoading, setDirectoryLoading] = useState(false),
+        [directoryError, setDirectoryError] = useState(null);
+
     const handleChange = (e) => { setFormData({ ...formData, [e.target.name]: e.target.value }) }
+    const handleFilterChange = (e) => { setFilters({ ...filters, [e.target.name]: e.target.value }) }
+
+    const loadPage = async (bookmarks) => {
+        setDirectoryLoading(true);
+        setDirectoryError(null);
+        try {
+            const page = await listUsers({ ...filters, pageSize: PAGE_SIZE }, bookmarks[bookmarks.length - 1]);
+            setDirectory(page);
+            setPageBookmarks(bookmarks);
+        } catch (err) { setDirectoryError(err.message) }
+        finally { setDirectoryLoading(false) }
+    };
+
+    // First page on open
+    useEffect(() => { loadPage(['']) }, []); // eslint-disable-line react-hooks/exhaustive-deps
+
+    const handleSearch = (e) => {
+        e.preventDefault();
+        loadPage(['']);
+    };
+    const nextPage = () => loadPage([...pageBookmarks, directory.bookmark]);
+    const previousPage = () => loadPage(pageBookmarks.slice(0, -1));
 
     const handleSubmit = async (e) => {
         e.preventDefault();
@@ -31,6 +63,7 @@ const UserManagement = ({ addUser, recentUsers }) => {
             const result = await registerUser(userData);
             setSuccess(result);
             addUser(result);
+            loadPage(['']);
             // Reset form
             setFormData({
                 username: '',
@@ -93,6 +126,85 @@ const UserManagement = ({ addUser, recentUsers }) => {
                 </div>
             )}
 
+            <div className="recent-credentials">
+                <h3>User Directory</h3>
+                <form onSubmit={handleSearch} className="directory-filters">
+                    <div className="form-group">
+                        <label>Role</label>
+                        <select name="role" value={filters.role} onChange={handleFilterChange}>
+                            <option value="">All</option>
+                            <option value="USER">USER</option>
+                            <option value="AUDITOR">AUDITOR</option>
+                            <option value="ADMIN">ADMIN</option>
+                        </select>
+                    </div>
+                    <div className="form-group">
+                        <label>Organization</label>
+                        <input type="text" name="organization" value={filters.organization} onChange={handleFilterChange} placeholder="Any" />
+                    </div>
+                    <div className="form-group">
+                        <label>Status</label>
+                        <select name="active" value={filters.active} onChange={handleFilterChange}>
+                            <option value="">All</option>
+                            <option value="true">Active</option>
+                            <option value="false">Inactive</option>
+                        </select>
+                    </div>
+                    <div className="form-actions">
+                        <button type="submit" className="btn-primary" disabled={directoryLoading}>
+                            {directoryLoading ? 'Loading...' : 'Search'}
+                        </button>
+                    </div>
+                </form>
+
+                {directoryError && <div className="alert error">{directoryError}</div>}
+
+                {directory.users.length === 0 ? (
+                    <p className="empty-state">No users match these filters.</p>
+                ) : (
+                    <div className="table-responsive">
+                        <table>
+                            <thead>
+                                <tr>
+                                    <th>ID</th>
+                                    <th>Username</th>
+                                    <th>Email</th>
+                                    <th>Role</th>
+                                    <th>Organization</th>
+                                    <th>Status</th>
+                                </tr>
+                            </thead>
+                            <tbody>
+                                {directory.users.map((user) => (
+                                    <tr key={user.id}>
+                                        <td>{user.id}</td>
+                                        <td>{user.username}</td>
+                                        <td>{user.email}</td>
+                                        <td>{user.role}</td>
+                                        <td>{user.organization}</td>
+                                        <td>
+                                            <span className={`badge ${user.active ? 'active' : 'revoked'}`}>
+                                                {user.status || (user.active ? 'Active' : 'Inactive')}
+                                            </span>
+                                        </td>
+                                    </tr>
+                                ))}
+                            </tbody>
+                        </table>
+                    </div>
+                )}
+
+                <div className="pagination">
+                    <button type="button" className="btn-primary" onClick={previousPage} disabled={directoryLoading || pageBookmarks.length === 1}>
+                        Previous
+                    </button>
+                    <span>Page {pageBookmarks.length}</span>
+                    <button type="button" className="btn-primary" onClick={nextPage} disabled={directoryLoading || !directory.bookmark}>
+                        Next
+                    </button>
+                </div>
+            </div>
+
             <div className="recent-credentials">
                 <h3>Recent Users</h3>
                 {recentUsers.length === 0 ? (
@@ -53,6 +53,19 @@ export const updateUserRole = async (userId, data) => {
   return userData.data;
 };
 
+export const listUsers = async (filters = {}, bookmark = "") => {
+  // filters: { role, organization, active, createdBy, pageSize }
+  const params = new URLSearchParams();
+  Object.entries(filters).forEach(([key, value]) => {
+    if (value !== undefined && value !== "") params.append(key, value);
+  });
+  if (bookmark) params.append("bookmark", bookmark);
+
+  const response = await fetch(`${API_BASE_URL}/users?${params.toString()}`);
+  const res = await handle_response(response);
+  return { users: res.data || [], bookmark: res.bookmark }; // Backend returns {success, data, count, bookmark}
+};
+
 export const logAudit = async (data) => {
   // Add ID if not provided
   const payload = {